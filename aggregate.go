@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// aggregateHandler serves the organization-level rollups of
+// aggregateCollector only, with the usage thresholds counted per threshold.
+func aggregateHandler(collector *deepl.DeepLCollector, thresholds []float64, opts promhttp.HandlerOpts) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newAggregateCollector(collector, thresholds))
+	return promhttp.HandlerFor(registry, opts)
+}
+
+// aggregateCollector exports organization-level rollups of the usage of all
+// accounts without any per-account series, for global Prometheus servers
+// that must not ingest the cardinality of every account.
+type aggregateCollector struct {
+	parent     *deepl.DeepLCollector
+	thresholds []float64
+
+	characterCount  *prometheus.Desc
+	characterLimit  *prometheus.Desc
+	maxUsagePercent *prometheus.Desc
+	accounts        *prometheus.Desc
+	failing         *prometheus.Desc
+	aboveThreshold  *prometheus.Desc
+}
+
+func newAggregateCollector(parent *deepl.DeepLCollector, thresholds []float64) *aggregateCollector {
+	return &aggregateCollector{
+		parent:     parent,
+		thresholds: thresholds,
+		characterCount: prometheus.NewDesc(
+			"deepl_org_character_count",
+			"Characters translated in the current billing period by all accounts",
+			nil, nil,
+		),
+		characterLimit: prometheus.NewDesc(
+			"deepl_org_character_limit",
+			"Sum of the character limits of all accounts",
+			nil, nil,
+		),
+		maxUsagePercent: prometheus.NewDesc(
+			"deepl_org_max_usage_percent",
+			"Highest percentage of its character limit used by an account",
+			nil, nil,
+		),
+		accounts: prometheus.NewDesc(
+			"deepl_org_accounts",
+			"Number of monitored accounts",
+			nil, nil,
+		),
+		failing: prometheus.NewDesc(
+			"deepl_org_accounts_failing",
+			"Number of accounts whose usage could not be fetched",
+			nil, nil,
+		),
+		aboveThreshold: prometheus.NewDesc(
+			"deepl_org_accounts_above_threshold",
+			"Number of accounts whose usage percentage reached the threshold",
+			[]string{"threshold"}, nil,
+		),
+	}
+}
+
+func (c *aggregateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.characterCount
+	ch <- c.characterLimit
+	ch <- c.maxUsagePercent
+	ch <- c.accounts
+	ch <- c.failing
+	ch <- c.aboveThreshold
+}
+
+func (c *aggregateCollector) Collect(ch chan<- prometheus.Metric) {
+	var (
+		count, limit   int64
+		maxPercent     float64
+		failing        int
+		aboveThreshold = make([]int, len(c.thresholds))
+	)
+	usages := usageSnapshot(context.Background(), c.parent)
+	for _, usage := range usages {
+		if usage.Error != "" {
+			failing++
+			continue
+		}
+		count += usage.CharacterCount
+		limit += usage.CharacterLimit
+		maxPercent = max(maxPercent, usage.UsagePercent)
+		for i, threshold := range c.thresholds {
+			if usage.UsagePercent >= threshold {
+				aboveThreshold[i]++
+			}
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.characterCount, prometheus.GaugeValue, float64(count))
+	ch <- prometheus.MustNewConstMetric(c.characterLimit, prometheus.GaugeValue, float64(limit))
+	ch <- prometheus.MustNewConstMetric(c.maxUsagePercent, prometheus.GaugeValue, maxPercent)
+	ch <- prometheus.MustNewConstMetric(c.accounts, prometheus.GaugeValue, float64(len(usages)))
+	ch <- prometheus.MustNewConstMetric(c.failing, prometheus.GaugeValue, float64(failing))
+	for i, threshold := range c.thresholds {
+		ch <- prometheus.MustNewConstMetric(
+			c.aboveThreshold,
+			prometheus.GaugeValue,
+			float64(aboveThreshold[i]),
+			strconv.FormatFloat(threshold, 'f', -1, 64),
+		)
+	}
+}
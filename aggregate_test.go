@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAggregateCollector(t *testing.T) {
+	usage := map[string]string{
+		"DeepL-Auth-Key key-a": `{"character_count": 900, "character_limit": 1000}`,
+		"DeepL-Auth-Key key-b": `{"character_count": 100, "character_limit": 1000}`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := usage[r.Header.Get("Authorization")]
+		if !ok {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	var clients []*deepl.Client
+	for _, name := range []string{"a", "b", "c"} {
+		client, err := deepl.NewClient(deepl.ClientConfig{Name: name, APIKey: "key-" + name, ServerURL: ts.URL})
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		clients = append(clients, client)
+	}
+	c, err := deepl.NewDeepLCollector(clients, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newAggregateCollector(c, []float64{80, 95}))
+
+	expected := `
+# HELP deepl_org_accounts Number of monitored accounts
+# TYPE deepl_org_accounts gauge
+deepl_org_accounts 3
+# HELP deepl_org_accounts_above_threshold Number of accounts whose usage percentage reached the threshold
+# TYPE deepl_org_accounts_above_threshold gauge
+deepl_org_accounts_above_threshold{threshold="80"} 1
+deepl_org_accounts_above_threshold{threshold="95"} 0
+# HELP deepl_org_accounts_failing Number of accounts whose usage could not be fetched
+# TYPE deepl_org_accounts_failing gauge
+deepl_org_accounts_failing 1
+# HELP deepl_org_character_count Characters translated in the current billing period by all accounts
+# TYPE deepl_org_character_count gauge
+deepl_org_character_count 1000
+# HELP deepl_org_character_limit Sum of the character limits of all accounts
+# TYPE deepl_org_character_limit gauge
+deepl_org_character_limit 2000
+# HELP deepl_org_max_usage_percent Highest percentage of its character limit used by an account
+# TYPE deepl_org_max_usage_percent gauge
+deepl_org_max_usage_percent 90
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// ruleGroups is a Prometheus rules file.
+type ruleGroups struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string      `yaml:"name"`
+	Rules []alertRule `yaml:"rules"`
+}
+
+type alertRule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// prometheusRule is the PrometheusRule resource of the Prometheus Operator.
+type prometheusRule struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name   string            `yaml:"name"`
+		Labels map[string]string `yaml:"labels,omitempty"`
+	} `yaml:"metadata"`
+	Spec ruleGroups `yaml:"spec"`
+}
+
+// alertOptions control the generated alerts.
+type alertOptions struct {
+	thresholds []float64
+	job        string
+	staleAfter time.Duration
+}
+
+// parseThresholds parses the comma-separated usage percentages to alert at.
+func parseThresholds(list string) ([]float64, error) {
+	var thresholds []float64
+	for _, value := range strings.Split(list, ",") {
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || threshold <= 0 || threshold > 100 {
+			return nil, fmt.Errorf("invalid threshold %q: must be a percentage between 0 and 100", value)
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	sort.Float64s(thresholds)
+	return thresholds, nil
+}
+
+// parseUsageThresholds parses the --collector.usage.thresholds list, which
+// may be empty.
+func parseUsageThresholds(list string) ([]float64, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	return parseThresholds(list)
+}
+
+// newAlertRules returns the alerting rules for quota thresholds, stale data
+// and the exporter being down. The highest threshold is critical, all others
+// are warnings.
+func newAlertRules(opts alertOptions) ruleGroups {
+	var rules []alertRule
+	for i, threshold := range opts.thresholds {
+		severity := "warning"
+		if i == len(opts.thresholds)-1 && len(opts.thresholds) > 1 {
+			severity = "critical"
+		}
+		value := strconv.FormatFloat(threshold, 'f', -1, 64)
+		upper := ""
+		if i < len(opts.thresholds)-1 {
+			// Only the highest threshold reached fires.
+			upper = " < " + strconv.FormatFloat(opts.thresholds[i+1], 'f', -1, 64)
+		}
+		rules = append(rules, alertRule{
+			Alert:  "DeepLUsageAbove" + strings.ReplaceAll(value, ".", "_") + "Percent",
+			Expr:   "deepl_character_usage_percent >= " + value + upper,
+			For:    "5m",
+			Labels: map[string]string{"severity": severity, "service": "deepl"},
+			Annotations: map[string]string{
+				"summary":     "DeepL account {{ $labels.account }} used " + value + "% of its character limit",
+				"description": "DeepL API usage of {{ $labels.account }} is at {{ $value | humanize }}% of the character limit. Consider upgrading the plan or reducing usage.",
+			},
+		})
+	}
+
+	stale := promDuration(opts.staleAfter)
+	rules = append(rules,
+		alertRule{
+			Alert:  "DeepLExporterDown",
+			Expr:   fmt.Sprintf(`up{job=%q} == 0`, opts.job),
+			For:    "5m",
+			Labels: map[string]string{"severity": "critical", "service": "deepl"},
+			Annotations: map[string]string{
+				"summary":     "DeepL exporter {{ $labels.instance }} is down",
+				"description": "Prometheus cannot scrape the DeepL exporter, DeepL usage is not monitored.",
+			},
+		},
+		alertRule{
+			Alert:  "DeepLMetricsStale",
+			Expr:   "max by (account, collector) (deepl_scrape_collector_success) == 0",
+			For:    stale,
+			Labels: map[string]string{"severity": "warning", "service": "deepl"},
+			Annotations: map[string]string{
+				"summary":     "DeepL {{ $labels.collector }} metrics of {{ $labels.account }} are stale",
+				"description": "The exporter failed to fetch {{ $labels.collector }} from DeepL for {{ $labels.account }} for " + stale + ".",
+			},
+		},
+		alertRule{
+			Alert:  "DeepLRefreshStale",
+			Expr:   fmt.Sprintf("time() - deepl_last_refresh_timestamp_seconds > %d", int(opts.staleAfter.Seconds())),
+			Labels: map[string]string{"severity": "warning", "service": "deepl"},
+			Annotations: map[string]string{
+				"summary":     "DeepL metrics of {{ $labels.account }} were not refreshed for " + stale,
+				"description": "The background polling of {{ $labels.account }} has not succeeded for {{ $value | humanizeDuration }}.",
+			},
+		},
+		alertRule{
+			Alert:  "DeepLAPIKeyFailover",
+			Expr:   "deepl_api_key_failover == 1",
+			Labels: map[string]string{"severity": "warning", "service": "deepl"},
+			Annotations: map[string]string{
+				"summary":     "DeepL API key of {{ $labels.account }} was rejected",
+				"description": "The primary DeepL API key was rejected and the backup key is in use. Replace the primary key.",
+			},
+		},
+	)
+
+	return ruleGroups{Groups: []ruleGroup{{Name: "deepl", Rules: rules}}}
+}
+
+// promDuration formats d as a Prometheus duration, e.g. 1h or 90m.
+func promDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+}
+
+// generateAlerts prints alerting rules as a rules file or PrometheusRule.
+func generateAlerts(args []string, out io.Writer) error {
+	fs := newGenerateFlagSet("alerts")
+	fs.SetOutput(out)
+	thresholds := fs.String("threshold", "80,95", "Comma-separated character usage percentages to alert at. The highest one is critical.")
+	format := fs.String("format", "rules", "Output format: rules for a Prometheus rules file or prometheusrule for the Prometheus Operator.")
+	job := fs.String("job", "deepl", "Prometheus job scraping the exporter, used to detect it being down.")
+	staleAfter := fs.Duration("stale-after", time.Hour, "How long metrics may fail to refresh before alerting.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := settings.resolve(fs, os.Getenv); err != nil {
+		return err
+	}
+
+	parsed, err := parseThresholds(*thresholds)
+	if err != nil {
+		return err
+	}
+	if *staleAfter < time.Second {
+		return fmt.Errorf("invalid --stale-after %s: must be at least 1s", *staleAfter)
+	}
+	rules := newAlertRules(alertOptions{thresholds: parsed, job: *job, staleAfter: *staleAfter})
+
+	var doc any
+	switch *format {
+	case "rules":
+		doc = rules
+	case "prometheusrule":
+		resource := prometheusRule{APIVersion: "monitoring.coreos.com/v1", Kind: "PrometheusRule", Spec: rules}
+		resource.Metadata.Name = "deepl-exporter"
+		resource.Metadata.Labels = map[string]string{"app.kubernetes.io/name": "deepl-exporter"}
+		doc = resource
+	default:
+		return fmt.Errorf("invalid --format %q: must be rules or prometheusrule", *format)
+	}
+
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"golang.org/x/sync/errgroup"
+)
+
+// AccountUsage is the character usage of one account as served by the JSON
+// API. Error is set instead of the counts if DeepL could not be queried.
+type AccountUsage struct {
+	Account        string  `json:"account"`
+	CharacterCount int64   `json:"character_count"`
+	CharacterLimit int64   `json:"character_limit"`
+	UsagePercent   float64 `json:"usage_percent"`
+	Error          string  `json:"error,omitempty"`
+}
+
+// UsageResponse is the body of /api/v1/usage.
+type UsageResponse struct {
+	Accounts []AccountUsage `json:"accounts"`
+}
+
+// usageAPIHandler serves the character usage of all accounts, or of those
+// given with ?account=<name>, as JSON for tools that do not speak the
+// Prometheus exposition format. In polling mode, it is served from the
+// cache, so clients of the API do not spend the rate limit of DeepL.
+func usageAPIHandler(collector *deepl.DeepLCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		clients := collector.Clients()
+		if names := r.URL.Query()["account"]; len(names) > 0 {
+			clients = nil
+			for _, name := range names {
+				client := collector.Client(name)
+				if client == nil {
+					http.Error(w, "unknown account "+name, http.StatusBadRequest)
+					return
+				}
+				clients = append(clients, client)
+			}
+		}
+
+		writeJSON(w, http.StatusOK, UsageResponse{Accounts: accountUsages(r.Context(), collector, clients)})
+	})
+}
+
+// accountUsages returns the usage of the given accounts of c: the cached
+// one in polling mode so no extra requests are sent, fetched from DeepL
+// otherwise.
+func accountUsages(ctx context.Context, c *deepl.DeepLCollector, clients []*deepl.Client) []AccountUsage {
+	usages := make([]AccountUsage, len(clients))
+	if c.Polling() {
+		for i, client := range clients {
+			usage, ok := cachedAccountUsage(c, client.Name())
+			if !ok {
+				usage = AccountUsage{Account: client.Name(), Error: "no usage fetched yet"}
+			}
+			usages[i] = usage
+		}
+		return usages
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+	var g errgroup.Group
+	for i, client := range clients {
+		g.Go(func() error {
+			usages[i] = accountUsage(ctx, client)
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return usages
+}
+
+func accountUsage(ctx context.Context, client *deepl.Client) AccountUsage {
+	usage, err := deepl.FetchUsage(ctx, client)
+	if err != nil {
+		return AccountUsage{Account: client.Name(), Error: err.Error()}
+	}
+	return newAccountUsage(client.Name(), *usage)
+}
+
+// cachedAccountUsage returns the usage of the account cached by the last
+// Refresh of c, ok is false if there is none.
+func cachedAccountUsage(c *deepl.DeepLCollector, account string) (AccountUsage, bool) {
+	usage, _, ok := c.CachedUsage(account)
+	if !ok {
+		return AccountUsage{}, false
+	}
+	return newAccountUsage(account, usage), true
+}
+
+func newAccountUsage(account string, usage deepl.DeepLUsage) AccountUsage {
+	result := AccountUsage{
+		Account:        account,
+		CharacterCount: usage.CharacterCount,
+		CharacterLimit: usage.CharacterLimit,
+	}
+	if usage.CharacterLimit > 0 {
+		result.UsagePercent = float64(usage.CharacterCount) / float64(usage.CharacterLimit) * 100
+	}
+	return result
+}
+
+// writeJSON writes v as the JSON body of a response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write JSON response: %v", err)
+	}
+}
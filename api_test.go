@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+func TestUsageAPIHandler(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "DeepL-Auth-Key key-b" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"character_count": 1000, "character_limit": 4000}`)
+	}))
+	defer ts.Close()
+
+	var clients []*deepl.Client
+	for _, name := range []string{"a", "b"} {
+		client, err := deepl.NewClient(deepl.ClientConfig{Name: name, APIKey: "key-" + name, ServerURL: ts.URL})
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		clients = append(clients, client)
+	}
+	c, err := deepl.NewDeepLCollector(clients, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := usageAPIHandler(c)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/usage", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON content type, got %q", ct)
+	}
+
+	var resp UsageResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Accounts) != 2 {
+		t.Fatalf("expected 2 accounts, got %d", len(resp.Accounts))
+	}
+	if got := resp.Accounts[0]; got.Account != "a" || got.CharacterCount != 1000 || got.UsagePercent != 25 || got.Error != "" {
+		t.Errorf("unexpected usage of account a: %+v", got)
+	}
+	if got := resp.Accounts[1]; got.Account != "b" || got.Error == "" {
+		t.Errorf("expected an error for account b, got %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/usage?account=a", nil))
+	resp = UsageResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Accounts) != 1 || resp.Accounts[0].Account != "a" {
+		t.Errorf("expected only account a, got %+v", resp.Accounts)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/usage?account=unknown", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for unknown account, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/usage", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405 for POST, got %d", rec.Code)
+	}
+}
+
+func TestUsageAPIHandlerServesCacheWhenPolling(t *testing.T) {
+	var requests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = fmt.Fprintln(w, `{"character_count": 1000, "character_limit": 4000}`)
+	}))
+	defer ts.Close()
+
+	client, err := deepl.NewClient(deepl.ClientConfig{Name: "a", APIKey: "key-a", ServerURL: ts.URL})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	c, err := deepl.NewDeepLCollector([]*deepl.Client{client}, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.EnablePolling()
+	handler := usageAPIHandler(c)
+
+	var resp UsageResponse
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/usage", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Accounts) != 1 || resp.Accounts[0].Error == "" {
+		t.Errorf("expected an error before the first poll, got %+v", resp.Accounts)
+	}
+
+	c.Refresh(t.Context(), client)
+	for range 3 {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/usage", nil))
+	}
+	resp = UsageResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Accounts) != 1 || resp.Accounts[0].CharacterCount != 1000 {
+		t.Errorf("expected the cached usage, got %+v", resp.Accounts)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("expected only the poll to request DeepL, got %d requests", got)
+	}
+}
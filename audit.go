@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditEntry is one administrative operation in the audit log. Every entry
+// carries the hash of the previous one, so removing, reordering or editing
+// entries breaks the chain.
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	Actor    string    `json:"actor"`
+	SourceIP string    `json:"source_ip"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Status   int       `json:"status,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	// Suppressed is the number of denied requests not recorded since the
+	// previous denied entry, see recordDenied.
+	Suppressed int    `json:"suppressed,omitempty"`
+	PrevHash   string `json:"prev_hash"`
+	Hash       string `json:"hash,omitempty"`
+}
+
+const (
+	// auditDeniedBurst denied requests are recorded per auditDeniedInterval
+	// at most, so anyone who can reach the port cannot grow the audit log
+	// without limit.
+	auditDeniedBurst    = 10
+	auditDeniedInterval = time.Minute
+)
+
+// hash returns the hash of the entry chained to its PrevHash, an HMAC if a
+// key is given so the chain cannot be recomputed without it.
+func (e AuditEntry) hash(key []byte) (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	if len(key) > 0 {
+		return hex.EncodeToString(hmacSHA256(key, string(data))), nil
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// AuditLog appends AuditEntry records as JSON lines.
+type AuditLog struct {
+	key []byte
+	now func() time.Time
+
+	mu       sync.Mutex
+	w        io.Writer
+	lastHash string
+	paused   bool
+
+	// ops is read-locked by the audited operations in flight and locked
+	// while the log is paused, see pause.
+	ops sync.RWMutex
+
+	deniedMu         sync.Mutex
+	deniedWindow     time.Time
+	deniedRecorded   int
+	deniedSuppressed int
+}
+
+// OpenAuditLog appends to the audit log at path, continuing the hash chain
+// of its existing entries, or writes to stdout if path is "-". key is
+// optional.
+func OpenAuditLog(path string, key []byte) (*AuditLog, error) {
+	if path == "-" {
+		return &AuditLog{key: key, now: time.Now, w: os.Stdout}, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if err := repairAuditLog(f); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("audit log %s: %w", path, err)
+	}
+	lastHash, err := verifyAuditLog(f, key)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("audit log %s: %w", path, err)
+	}
+	return &AuditLog{key: key, now: time.Now, w: f, lastHash: lastHash}, nil
+}
+
+// Record chains entry to the previous one and appends it. Failures are
+// logged, as the operation already happened.
+func (a *AuditLog) Record(entry AuditEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.paused {
+		log.Printf("Audit log paused for a restart, dropping %s entry of %s", entry.Action, entry.Actor)
+		return
+	}
+	entry.Time = a.now().UTC()
+	entry.PrevHash = a.lastHash
+	hash, err := entry.hash(a.key)
+	if err != nil {
+		log.Printf("Failed to write audit log entry: %v", err)
+		return
+	}
+	entry.Hash = hash
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Failed to write audit log entry: %v", err)
+		return
+	}
+	if _, err := a.w.Write(append(data, '\n')); err != nil {
+		log.Printf("Failed to write audit log entry: %v", err)
+		return
+	}
+	a.lastHash = hash
+}
+
+// audited wraps h to record every request changing state, i.e. not using
+// GET or HEAD, as action once it was served. It must be wrapped by the
+// Authorizer so the actor is known.
+func (a *AuditLog) audited(action string, h http.Handler) http.Handler {
+	if a == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+		// Operations are rejected rather than left unrecorded while the
+		// log is paused, the client retries them against the new process.
+		if !a.ops.TryRLock() {
+			http.Error(w, "restarting, retry later", http.StatusServiceUnavailable)
+			return
+		}
+		defer a.ops.RUnlock()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		a.Record(AuditEntry{
+			Action:   action,
+			Actor:    requestActor(r),
+			SourceIP: clientIP(r),
+			Method:   r.Method,
+			Path:     r.URL.RequestURI(),
+			Status:   rec.status,
+		})
+	})
+}
+
+// pause waits for the audited operations in flight to be recorded and stops
+// writing until resume is called, so a new process taking over on restart
+// continues the hash chain alone. Later operations are rejected with 503 and
+// other entries are dropped. It does nothing on a nil log.
+func (a *AuditLog) pause() {
+	if a == nil {
+		return
+	}
+	a.ops.Lock()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.paused = true
+}
+
+// resume undoes pause.
+func (a *AuditLog) resume() {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	a.paused = false
+	a.mu.Unlock()
+	a.ops.Unlock()
+}
+
+// recordDenied records a request the Authorizer rejected, so attempts to
+// guess credentials show up in the chain. actor is who the token belongs to
+// if it was valid but lacks the scope. Beyond auditDeniedBurst requests per
+// auditDeniedInterval, requests are only counted and the count is recorded
+// with the next denied entry.
+func (a *AuditLog) recordDenied(r *http.Request, actor string) {
+	if a == nil {
+		return
+	}
+	a.deniedMu.Lock()
+	now := a.now()
+	if now.Sub(a.deniedWindow) >= auditDeniedInterval {
+		a.deniedWindow, a.deniedRecorded = now, 0
+	}
+	if a.deniedRecorded >= auditDeniedBurst {
+		a.deniedSuppressed++
+		a.deniedMu.Unlock()
+		return
+	}
+	suppressed := a.deniedSuppressed
+	a.deniedRecorded++
+	a.deniedSuppressed = 0
+	a.deniedMu.Unlock()
+
+	if actor == "" {
+		actor = "anonymous"
+	}
+	a.Record(AuditEntry{
+		Action:     "denied",
+		Actor:      actor,
+		SourceIP:   clientIP(r),
+		Method:     r.Method,
+		Path:       r.URL.RequestURI(),
+		Status:     http.StatusUnauthorized,
+		Suppressed: suppressed,
+	})
+}
+
+// recordReload records a reload not triggered by a request, e.g. of the TLS
+// certificate on SIGHUP, with the file reloaded as path and the error if it
+// failed.
+func (a *AuditLog) recordReload(action, trigger, path string, err error) {
+	if a == nil {
+		return
+	}
+	entry := AuditEntry{Action: action, Actor: trigger, Path: path}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	a.Record(entry)
+}
+
+// statusRecorder remembers the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// repairAuditLog removes an incomplete last entry from f, e.g. one cut off
+// by a crash while it was written. Complete entries end with a newline.
+func repairAuditLog(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	if size == 0 {
+		return nil
+	}
+	buf := make([]byte, 4096)
+	end := size
+	for end > 0 {
+		start := max(end-int64(len(buf)), 0)
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil {
+			return err
+		}
+		if end == size && chunk[len(chunk)-1] == '\n' {
+			return nil
+		}
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			end = start + int64(i) + 1
+			break
+		}
+		end = start
+	}
+	log.Printf("Removing incomplete last entry of audit log %s (%d bytes)", f.Name(), size-end)
+	return f.Truncate(end)
+}
+
+// verifyAuditLog checks the hash chain of the audit log read from r and
+// returns the hash of its last entry.
+func verifyAuditLog(r io.Reader, key []byte) (string, error) {
+	var lastHash string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return "", fmt.Errorf("line %d: %w", line, err)
+		}
+		if entry.PrevHash != lastHash {
+			return "", fmt.Errorf("line %d: chain broken, an entry was removed or reordered", line)
+		}
+		hash, err := entry.hash(key)
+		if err != nil {
+			return "", fmt.Errorf("line %d: %w", line, err)
+		}
+		if hash != entry.Hash {
+			return "", fmt.Errorf("line %d: hash mismatch, the entry was modified", line)
+		}
+		lastHash = entry.Hash
+	}
+	return lastHash, scanner.Err()
+}
+
+// runVerifyAuditLog implements the verify-audit-log subcommand.
+func runVerifyAuditLog(args []string, out io.Writer) int {
+	// The key file defaults to the one of the environment the exporter was
+	// started with.
+	if err := settings.resolve(flag.CommandLine, os.Getenv); err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL %v\n", err)
+		return 2
+	}
+	fs := flag.NewFlagSet("verify-audit-log", flag.ContinueOnError)
+	fs.SetOutput(out)
+	keyFile := fs.String("key-file", settings.WebAuditLogKeyFile, "File with the HMAC key the log was written with (env: WEB_AUDIT_LOG_KEY_FILE).")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(out, "Usage: deepl-exporter verify-audit-log [--key-file FILE] AUDIT_LOG")
+		_, _ = fmt.Fprintln(out, "Checks that no entry of the audit log was modified, removed or reordered.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	key, err := readAuditLogKey(*keyFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL %v\n", err)
+		return 1
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL %v\n", err)
+		return 1
+	}
+	defer f.Close()
+	if _, err := verifyAuditLog(f, key); err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL %v\n", err)
+		return 1
+	}
+	_, _ = fmt.Fprintln(out, "OK audit log is intact")
+	return 0
+}
+
+// readAuditLogKey returns the HMAC key stored in path, or nil if path is
+// empty.
+func readAuditLogKey(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	key, err := readTokenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("audit log key: %w", err)
+	}
+	return []byte(key), nil
+}
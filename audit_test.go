@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	key := []byte("audit-key")
+	audit, err := OpenAuditLog(path, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	auth, err := NewAuthorizer("", nil, []APITokenConfig{{Name: "ci", Token: "ci-secret", Scopes: []string{scopeReload}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := auth.require(scopeReload, audit.audited("refresh", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})))
+
+	for _, method := range []string{http.MethodPost, http.MethodGet, http.MethodPost} {
+		r := httptest.NewRequest(method, "/-/refresh?account=team-a", nil)
+		r.RemoteAddr = "192.0.2.7:4242"
+		r.Header.Set("Authorization", "Bearer ci-secret")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 entries for the POST requests, got:\n%s", data)
+	}
+	for _, want := range []string{`"action":"refresh"`, `"actor":"api-token:ci"`, `"source_ip":"192.0.2.7"`, `"path":"/-/refresh?account=team-a"`, `"status":200`} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("expected %s in %s", want, lines[0])
+		}
+	}
+
+	// Reopening continues the chain.
+	audit, err = OpenAuditLog(path, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	audit.Record(AuditEntry{Action: "refresh", Actor: "admin-token"})
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifyAuditLog(bytes.NewReader(data), key); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+	for name, tampered := range map[string][]string{
+		"modified":  {lines[0], strings.Replace(lines[1], "api-token:ci", "admin-token", 1), lines[2]},
+		"removed":   {lines[0], lines[2]},
+		"reordered": {lines[1], lines[0], lines[2]},
+	} {
+		if _, err := verifyAuditLog(strings.NewReader(strings.Join(tampered, "\n")), key); err == nil {
+			t.Errorf("%s: expected the tampering to be detected", name)
+		}
+	}
+	if _, err := verifyAuditLog(bytes.NewReader(data), []byte("other-key")); err == nil {
+		t.Error("expected a wrong key to be detected")
+	}
+}
+
+func TestAuditLogDenied(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit, err := OpenAuditLog(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	auth, err := NewAuthorizer("", nil, []APITokenConfig{{Name: "dashboard", Token: "read-secret", Scopes: []string{scopeReadUsage}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	auth.audit = audit
+	handler := auth.require(scopeReload, audit.audited("refresh", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected the request to be rejected")
+	})))
+
+	for _, token := range []string{"guessed", "read-secret"} {
+		r := httptest.NewRequest(http.MethodPost, "/-/refresh", nil)
+		r.RemoteAddr = "192.0.2.7:4242"
+		r.Header.Set("Authorization", "Bearer "+token)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 entries for the rejected requests, got:\n%s", data)
+	}
+	for i, actor := range []string{"anonymous", "api-token:dashboard"} {
+		for _, want := range []string{`"action":"denied"`, `"actor":"` + actor + `"`, `"source_ip":"192.0.2.7"`, `"status":401`} {
+			if !strings.Contains(lines[i], want) {
+				t.Errorf("expected %s in %s", want, lines[i])
+			}
+		}
+	}
+}
+
+func TestAuditLogDeniedRateLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit, err := OpenAuditLog(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	audit.now = func() time.Time { return now }
+
+	r := httptest.NewRequest(http.MethodPost, "/-/refresh", nil)
+	for range auditDeniedBurst + 5 {
+		audit.recordDenied(r, "")
+	}
+	now = now.Add(auditDeniedInterval)
+	audit.recordDenied(r, "")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != auditDeniedBurst+1 {
+		t.Fatalf("expected %d entries, got %d", auditDeniedBurst+1, len(lines))
+	}
+	if !strings.Contains(lines[auditDeniedBurst], `"suppressed":5`) {
+		t.Errorf("expected the suppressed requests to be counted in %s", lines[auditDeniedBurst])
+	}
+	if strings.Contains(lines[0], "suppressed") {
+		t.Errorf("expected no suppressed requests in %s", lines[0])
+	}
+}
+
+func TestOpenAuditLogIncompleteEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit, err := OpenAuditLog(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	audit.Record(AuditEntry{Action: "refresh", Actor: "admin-token"})
+	complete, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A crash cut off the second entry.
+	if err := os.WriteFile(path, append(complete, `{"time":"2026-10-16T09:30:00Z","act`...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	audit, err = OpenAuditLog(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	audit.Record(AuditEntry{Action: "refresh", Actor: "admin-token"})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, complete) || strings.Count(string(data), "\n") != 2 {
+		t.Errorf("expected the incomplete entry to be removed, got:\n%s", data)
+	}
+	if _, err := verifyAuditLog(bytes.NewReader(data), nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestAuditLogPause(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit, err := OpenAuditLog(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	served := 0
+	handler := audit.audited("refresh", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	}))
+	serve := func(method string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, "/-/refresh", nil))
+		return w.Code
+	}
+
+	audit.pause()
+	if code := serve(http.MethodPost); code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 while paused, got %d", code)
+	}
+	if code := serve(http.MethodGet); code != http.StatusOK {
+		t.Errorf("expected reads to be served while paused, got %d", code)
+	}
+	audit.recordReload("reload-tls", "signal:SIGHUP", "tls.crt", nil)
+	audit.resume()
+	if code := serve(http.MethodPost); code != http.StatusOK {
+		t.Errorf("expected 200 after resuming, got %d", code)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if served != 2 || strings.Count(string(data), "\n") != 1 {
+		t.Errorf("expected only the operation after resuming to be served and recorded, served %d, got:\n%s", served, data)
+	}
+}
+
+func TestRunVerifyAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit, err := OpenAuditLog(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	audit.Record(AuditEntry{Action: "refresh", Actor: "admin-token"})
+
+	var out bytes.Buffer
+	if code := runVerifyAuditLog([]string{path}, &out); code != 0 {
+		t.Errorf("expected exit code 0, got %d: %s", code, out.String())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, bytes.ReplaceAll(data, []byte("admin-token"), []byte("intruder")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if code := runVerifyAuditLog([]string{path}, &out); code != 1 || !strings.Contains(out.String(), "modified") {
+		t.Errorf("expected the modification to be reported, got %d: %s", code, out.String())
+	}
+}
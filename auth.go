@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Scopes of the API tokens, each granting access to some endpoints.
+const (
+	scopeReadUsage  = "read-usage"
+	scopeReload     = "reload"
+	scopeManageKeys = "manage-keys"
+)
+
+var apiTokenScopes = []string{scopeReadUsage, scopeReload, scopeManageKeys}
+
+// APITokenConfig is a static bearer token granting least-privilege access
+// to the endpoints of its scopes. Exactly one of Token and TokenFile must be
+// set.
+type APITokenConfig struct {
+	Name      string     `yaml:"name"`
+	Token     string     `yaml:"token"`
+	TokenFile string     `yaml:"token_file"`
+	Scopes    stringList `yaml:"scopes"`
+}
+
+// resolveToken returns the bearer token.
+func (t *APITokenConfig) resolveToken() (string, error) {
+	if t.TokenFile == "" {
+		return t.Token, nil
+	}
+	token, err := readTokenFile(t.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("API token %q: %w", t.Name, err)
+	}
+	return token, nil
+}
+
+// scopedToken is a resolved APITokenConfig.
+type scopedToken struct {
+	name   string
+	token  string
+	scopes []string
+}
+
+// actorKey is the context key of the identity of an authenticated request.
+type actorKey struct{}
+
+// requestActor returns who sent an authenticated request, e.g.
+// "admin-token", "api-token:ci" or "jwt:alice", or "anonymous".
+func requestActor(r *http.Request) string {
+	if actor, ok := r.Context().Value(actorKey{}).(string); ok {
+		return actor
+	}
+	return "anonymous"
+}
+
+// Authorizer checks the bearer token of requests. The admin token grants
+// access to every endpoint, scoped tokens and JWTs accepted by the verifier
+// to the endpoints of their scopes only.
+type Authorizer struct {
+	adminToken string
+	verifier   *JWTVerifier
+	tokens     []scopedToken
+	// audit records the rejected requests, if set.
+	audit *AuditLog
+}
+
+// NewAuthorizer resolves the tokens of the given API token configurations.
+// adminToken and verifier are optional.
+func NewAuthorizer(adminToken string, verifier *JWTVerifier, tokens []APITokenConfig) (*Authorizer, error) {
+	a := &Authorizer{adminToken: adminToken, verifier: verifier}
+	for _, cfg := range tokens {
+		token, err := cfg.resolveToken()
+		if err != nil {
+			return nil, err
+		}
+		a.tokens = append(a.tokens, scopedToken{name: cfg.Name, token: token, scopes: cfg.Scopes})
+	}
+	return a, nil
+}
+
+// enabled reports whether any credential is configured.
+func (a *Authorizer) enabled() bool {
+	return a.adminToken != "" || a.verifier != nil || len(a.tokens) > 0
+}
+
+// require wraps h to only let requests through whose bearer token grants
+// scope, or that carry the admin token. An empty scope restricts h to the
+// admin token and JWTs granting every scope. Browsers may send the tokens
+// with basic auth instead, see authorizeBasic.
+func (a *Authorizer) require(scope string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor, allowed := "", false
+		if given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			actor, allowed = a.authorize(r, given, scope)
+		} else if user, password, ok := r.BasicAuth(); ok {
+			actor, allowed = a.authorizeBasic(user, password, scope)
+		}
+		if allowed {
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), actorKey{}, actor)))
+			return
+		}
+		a.audit.recordDenied(r, actor)
+		// Browsers ignore the Bearer challenge and prompt for basic auth.
+		w.Header().Add("WWW-Authenticate", `Bearer realm="deepl-exporter"`)
+		w.Header().Add("WWW-Authenticate", `Basic realm="deepl-exporter", charset="UTF-8"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// requireForReads wraps h like require with the read-usage scope once any
+// credential is configured, and returns it unchanged otherwise, so the
+// quota data of shared deployments is not world-readable.
+func (a *Authorizer) requireForReads(h http.Handler) http.Handler {
+	if !a.enabled() {
+		return h
+	}
+	return a.require(scopeReadUsage, h)
+}
+
+// authorize returns who the bearer token given belongs to and whether it
+// grants scope.
+func (a *Authorizer) authorize(r *http.Request, given, scope string) (string, bool) {
+	if a.adminToken != "" && subtle.ConstantTimeCompare([]byte(given), []byte(a.adminToken)) == 1 {
+		return "admin-token", true
+	}
+	for _, token := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token.token)) == 1 {
+			return "api-token:" + token.name, scope != "" && slices.Contains(token.scopes, scope)
+		}
+	}
+	if a.verifier != nil {
+		claims, err := a.verifier.Verify(r.Context(), given)
+		if err != nil {
+			return "", false
+		}
+		if scope == "" {
+			return "jwt:" + claims.Subject, !slices.ContainsFunc(apiTokenScopes, func(s string) bool {
+				return !slices.Contains(claims.Scopes, s)
+			})
+		}
+		return "jwt:" + claims.Subject, slices.Contains(claims.Scopes, scope)
+	}
+	return "", false
+}
+
+// authorizeBasic is authorize for basic auth, so people can open the HTML
+// pages in a browser: the user is the name of a scoped token and the password
+// the token, or the user is admin and the password the admin token. JWTs are
+// only accepted as bearer tokens.
+func (a *Authorizer) authorizeBasic(user, password, scope string) (string, bool) {
+	if user == "admin" && a.adminToken != "" && subtle.ConstantTimeCompare([]byte(password), []byte(a.adminToken)) == 1 {
+		return "admin-token", true
+	}
+	for _, token := range a.tokens {
+		if user == token.name && subtle.ConstantTimeCompare([]byte(password), []byte(token.token)) == 1 {
+			return "api-token:" + token.name, scope != "" && slices.Contains(token.scopes, scope)
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+func TestAuthorizer(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{rsaJWK("k", &key.PublicKey)}})
+	}))
+	defer jwks.Close()
+
+	verifier, err := NewJWTVerifier(context.Background(), "https://idp.example.com", "deepl-exporter", jwks.URL, "scope", []string{"RS256"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	jwtWithScopes := func(scopes any) string {
+		claims := map[string]any{"iss": "https://idp.example.com", "aud": "deepl-exporter", "exp": time.Now().Add(time.Hour).Unix()}
+		if scopes != nil {
+			claims["scope"] = scopes
+		}
+		return signJWT(t, "k", key, claims)
+	}
+	jwt := jwtWithScopes("openid read-usage reload manage-keys")
+	readJWT := jwtWithScopes([]string{scopeReadUsage})
+	unscopedJWT := jwtWithScopes(nil)
+
+	tokenFile := filepath.Join(t.TempDir(), "ci-token")
+	if err := os.WriteFile(tokenFile, []byte("ci-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := NewAuthorizer("admin-secret", verifier, []APITokenConfig{
+		{Name: "dashboard", Token: "read-secret", Scopes: []string{scopeReadUsage}},
+		{Name: "ci", TokenFile: tokenFile, Scopes: []string{scopeReadUsage, scopeReload}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for _, tt := range []struct {
+		name  string
+		scope string
+		user  string
+		given string
+		code  int
+	}{
+		{name: "admin token", given: "admin-secret", code: http.StatusOK},
+		{name: "JWT with every scope", given: jwt, code: http.StatusOK},
+		{name: "JWT with scope", scope: scopeReload, given: jwt, code: http.StatusOK},
+		{name: "JWT with scope list", scope: scopeReadUsage, given: readJWT, code: http.StatusOK},
+		{name: "JWT without scope", scope: scopeReload, given: readJWT, code: http.StatusUnauthorized},
+		{name: "JWT on admin endpoint", given: readJWT, code: http.StatusUnauthorized},
+		{name: "JWT without scope claim", scope: scopeReadUsage, given: unscopedJWT, code: http.StatusUnauthorized},
+		{name: "scoped token", scope: scopeReadUsage, given: "read-secret", code: http.StatusOK},
+		{name: "scoped token from file", scope: scopeReload, given: "ci-secret", code: http.StatusOK},
+		{name: "scoped token without scope", scope: scopeReload, given: "read-secret", code: http.StatusUnauthorized},
+		{name: "scoped token on admin endpoint", given: "ci-secret", code: http.StatusUnauthorized},
+		{name: "wrong token", scope: scopeReadUsage, given: "guess", code: http.StatusUnauthorized},
+		{name: "no token", scope: scopeReadUsage, code: http.StatusUnauthorized},
+		{name: "basic auth with admin token", user: "admin", given: "admin-secret", code: http.StatusOK},
+		{name: "basic auth with scoped token", scope: scopeReadUsage, user: "dashboard", given: "read-secret", code: http.StatusOK},
+		{name: "basic auth without scope", scope: scopeReload, user: "dashboard", given: "read-secret", code: http.StatusUnauthorized},
+		{name: "basic auth with another name", scope: scopeReadUsage, user: "ci", given: "read-secret", code: http.StatusUnauthorized},
+		{name: "basic auth with JWT", user: "admin", given: jwt, code: http.StatusUnauthorized},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		switch {
+		case tt.user != "":
+			r.SetBasicAuth(tt.user, tt.given)
+		case tt.given != "":
+			r.Header.Set("Authorization", "Bearer "+tt.given)
+		}
+		rec := httptest.NewRecorder()
+		auth.require(tt.scope, ok).ServeHTTP(rec, r)
+		if rec.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.code, rec.Code)
+		}
+		if tt.code == http.StatusUnauthorized && !slices.Contains(rec.Header().Values("WWW-Authenticate"), `Basic realm="deepl-exporter", charset="UTF-8"`) {
+			t.Errorf("%s: expected a basic auth challenge, got %q", tt.name, rec.Header().Values("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestAuthorizerJWTScopesOnRefresh(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{rsaJWK("k", &key.PublicKey)}})
+	}))
+	defer jwks.Close()
+	deeplServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, deepl.DeepLUsage{CharacterCount: 1000, CharacterLimit: 10000})
+	}))
+	defer deeplServer.Close()
+
+	verifier, err := NewJWTVerifier(context.Background(), "https://idp.example.com", "deepl-exporter", jwks.URL, "scp", []string{"RS256"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	auth, err := NewAuthorizer("", verifier, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	collector, err := deepl.NewDeepLCollector([]*deepl.Client{newTestClient(t, deeplServer.URL)}, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := auth.require(scopeReload, refreshHandler(collector))
+
+	for _, tt := range []struct {
+		name   string
+		claims map[string]any
+		code   int
+	}{
+		{name: "without the claim", code: http.StatusUnauthorized},
+		{name: "with another scope", claims: map[string]any{"scp": []string{scopeReadUsage}}, code: http.StatusUnauthorized},
+		{name: "with the scope in another claim", claims: map[string]any{"scope": scopeReload}, code: http.StatusUnauthorized},
+		{name: "with the scope", claims: map[string]any{"scp": []string{scopeReload}}, code: http.StatusOK},
+	} {
+		claims := map[string]any{"iss": "https://idp.example.com", "aud": "deepl-exporter", "sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+		maps.Copy(claims, tt.claims)
+		r := httptest.NewRequest(http.MethodPost, "/-/refresh", nil)
+		r.Header.Set("Authorization", "Bearer "+signJWT(t, "k", key, claims))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if rec.Code != tt.code {
+			t.Errorf("JWT %s: expected status %d, got %d", tt.name, tt.code, rec.Code)
+		}
+	}
+}
+
+func TestAuthorizerRestrictsReads(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	read := func(auth *Authorizer, token string) int {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/usage", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		auth.requireForReads(ok).ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	// Without credentials, the JSON API stays public.
+	auth, err := NewAuthorizer("", nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if code := read(auth, ""); code != http.StatusOK {
+		t.Errorf("expected the JSON API to be public, got %d", code)
+	}
+
+	// Any credential protects the quota data, even the admin token alone.
+	auth, err = NewAuthorizer("admin-secret", nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if code := read(auth, ""); code != http.StatusUnauthorized {
+		t.Errorf("expected the JSON API to require the admin token, got %d", code)
+	}
+	if code := read(auth, "admin-secret"); code != http.StatusOK {
+		t.Errorf("expected the admin token to read the usage, got %d", code)
+	}
+
+	auth, err = NewAuthorizer("", nil, []APITokenConfig{{Name: "dashboard", Token: "read-secret", Scopes: []string{scopeReadUsage}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if code := read(auth, ""); code != http.StatusUnauthorized {
+		t.Errorf("expected the JSON API to require a token, got %d", code)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// awsCredentials returns the credentials of the default AWS credential
+// chain: the AWS_* environment variables, the shared config and credentials
+// files including SSO profiles, web identity tokens as used by IRSA and the
+// container or instance role. They are loaded once, cached and refreshed
+// before they expire.
+var awsCredentials = sync.OnceValues(func() (aws.CredentialsProvider, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	return cfg.Credentials, nil
+})
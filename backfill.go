@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// runBackfill implements `deepl-exporter backfill`, which sends the samples
+// of the usage history to a Prometheus remote-write endpoint with their
+// original timestamps, e.g. to give a new Prometheus or Mimir tenant the
+// usage from before it existed. It returns the exit code.
+func runBackfill(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	fs.SetOutput(out)
+	from := fs.String("from", "", "Start of the samples to send, RFC 3339 or Unix seconds. All samples if unset.")
+	to := fs.String("to", "", "End of the samples to send, exclusive. Now if unset.")
+	remoteWriteURL := fs.String("remote-write-url", "", "Remote-write endpoint, e.g. http://mimir:8080/api/v1/push.")
+	tenant := fs.String("tenant", "", "Tenant sent as X-Scope-OrgID, e.g. for Mimir or Cortex.")
+	tokenFile := fs.String("bearer-token-file", "", "File with a bearer token sent to the remote-write endpoint.")
+	batchSize := fs.Int("batch-size", 2000, "Maximum number of samples per request.")
+	var labels stringSlice
+	fs.Var(&labels, "label", "Label added to every series as name=value, e.g. job=deepl-exporter to match the scraped series. Repeatable.")
+	timeout := fs.Duration("timeout", defaultTimeout, "Timeout of every request.")
+	// The global flags are accepted too, so the history is read from the
+	// same --history.path or --history.postgres-url as the exporter.
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(out, "Usage: deepl-exporter backfill --remote-write-url URL [--from TIME] [--to TIME] [flags]")
+		_, _ = fmt.Fprintln(out, "Sends the usage history to a remote-write endpoint with the original timestamps.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := settings.resolve(fs, os.Getenv); err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL %v\n", err)
+		return 2
+	}
+
+	if *remoteWriteURL == "" {
+		_, _ = fmt.Fprintln(out, "FAIL --remote-write-url is required")
+		return 2
+	}
+	if *batchSize <= 0 {
+		_, _ = fmt.Fprintf(out, "FAIL --batch-size must be positive, got %d\n", *batchSize)
+		return 2
+	}
+	extraLabels, err := parseBackfillLabels(labels.values)
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL %v\n", err)
+		return 2
+	}
+	start, end := time.Time{}, time.Now()
+	if *from != "" {
+		if start, err = parseTimestamp(*from); err != nil {
+			_, _ = fmt.Fprintf(out, "FAIL --from: %v\n", err)
+			return 2
+		}
+	}
+	if *to != "" {
+		if end, err = parseTimestamp(*to); err != nil {
+			_, _ = fmt.Fprintf(out, "FAIL --to: %v\n", err)
+			return 2
+		}
+	}
+	cfg := backfillConfig{URL: *remoteWriteURL, Tenant: *tenant, Labels: extraLabels, BatchSize: *batchSize, Timeout: *timeout}
+	if *tokenFile != "" {
+		if cfg.BearerToken, err = readTokenFile(*tokenFile); err != nil {
+			_, _ = fmt.Fprintf(out, "FAIL %v\n", err)
+			return 1
+		}
+	}
+
+	ctx := context.Background()
+	history, location, err := openHistory(ctx, settings.HistoryPath, settings.HistoryPostgresURL)
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL %v\n", err)
+		return 1
+	}
+	defer func() {
+		_ = history.Close()
+	}()
+	samples, err := history.Query(ctx, "", start, end)
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL failed to read the history in %s: %v\n", location, err)
+		return 1
+	}
+
+	sent, err := backfill(ctx, http.DefaultClient, cfg, samples)
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL sent %d of %d samples: %v\n", sent, len(samples), err)
+		return 1
+	}
+	_, _ = fmt.Fprintf(out, "OK   sent %d samples from %s to %s\n", sent, location, cfg.URL)
+	return 0
+}
+
+// backfillConfig configures the requests to the remote-write endpoint.
+type backfillConfig struct {
+	URL         string
+	Tenant      string
+	BearerToken string
+	// Labels are added to every series.
+	Labels    []remoteWriteLabel
+	BatchSize int
+	Timeout   time.Duration
+}
+
+type remoteWriteLabel struct {
+	Name, Value string
+}
+
+// remoteWriteSeries is a time series of a remote-write request, its samples
+// in ascending order of their Unix milliseconds.
+type remoteWriteSeries struct {
+	labels     []remoteWriteLabel
+	values     []float64
+	timestamps []int64
+}
+
+// parseBackfillLabels parses name=value pairs into labels. Every name may
+// only be given once, remote-write receivers reject series with duplicate
+// labels.
+func parseBackfillLabels(pairs []string) ([]remoteWriteLabel, error) {
+	labels := make([]remoteWriteLabel, 0, len(pairs))
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" || strings.HasPrefix(name, "__") || name == "account" {
+			return nil, fmt.Errorf("invalid label %q: must be name=value with a name other than account", pair)
+		}
+		if slices.ContainsFunc(labels, func(l remoteWriteLabel) bool { return l.Name == name }) {
+			return nil, fmt.Errorf("duplicate label %q", name)
+		}
+		labels = append(labels, remoteWriteLabel{Name: name, Value: value})
+	}
+	return labels, nil
+}
+
+// backfill sends samples as deepl_character_count and deepl_character_limit
+// in batches of cfg.BatchSize samples, and returns how many were accepted.
+// The samples are expected in ascending order of time.
+func backfill(ctx context.Context, client *http.Client, cfg backfillConfig, samples []HistorySample) (int, error) {
+	sent := 0
+	for len(samples) > 0 {
+		// Every sample of the history becomes two remote-write samples.
+		n := max(min(len(samples), cfg.BatchSize/2), 1)
+		batch := samples[:n]
+		samples = samples[n:]
+
+		if err := sendRemoteWrite(ctx, client, cfg, backfillSeries(batch, cfg.Labels)); err != nil {
+			return sent, err
+		}
+		sent += len(batch)
+	}
+	return sent, nil
+}
+
+// backfillSeries groups samples into the series of every metric and account.
+func backfillSeries(samples []HistorySample, extra []remoteWriteLabel) []remoteWriteSeries {
+	index := make(map[[2]string]int)
+	var series []remoteWriteSeries
+	add := func(metric, account string, value float64, t time.Time) {
+		key := [2]string{metric, account}
+		i, ok := index[key]
+		if !ok {
+			labels := append([]remoteWriteLabel{{"__name__", metric}, {"account", account}}, extra...)
+			sort.Slice(labels, func(a, b int) bool { return labels[a].Name < labels[b].Name })
+			i = len(series)
+			index[key] = i
+			series = append(series, remoteWriteSeries{labels: labels})
+		}
+		series[i].values = append(series[i].values, value)
+		series[i].timestamps = append(series[i].timestamps, t.UnixMilli())
+	}
+	for _, sample := range samples {
+		add("deepl_character_count", sample.Account, float64(sample.CharacterCount), sample.Time)
+		add("deepl_character_limit", sample.Account, float64(sample.CharacterLimit), sample.Time)
+	}
+	return series
+}
+
+// sendRemoteWrite sends series in a snappy-compressed remote-write 1.0
+// request.
+func sendRemoteWrite(ctx context.Context, client *http.Client, cfg backfillConfig, series []remoteWriteSeries) error {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	body := snappy.Encode(nil, encodeWriteRequest(series))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", buildUserAgent(""))
+	if cfg.Tenant != "" {
+		req.Header.Set("X-Scope-OrgID", cfg.Tenant)
+	}
+	if cfg.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.BearerToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned status %d: %s", cfg.URL, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// encodeWriteRequest encodes series as a prometheus.WriteRequest protobuf
+// message:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []remoteWriteSeries) []byte {
+	var request []byte
+	for _, s := range series {
+		var ts []byte
+		for _, label := range s.labels {
+			var l []byte
+			l = protowire.AppendTag(l, 1, protowire.BytesType)
+			l = protowire.AppendString(l, label.Name)
+			l = protowire.AppendTag(l, 2, protowire.BytesType)
+			l = protowire.AppendString(l, label.Value)
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, l)
+		}
+		for i, value := range s.values {
+			var sample []byte
+			sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+			sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+			sample = protowire.AppendVarint(sample, uint64(s.timestamps[i]))
+			ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, sample)
+		}
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, ts)
+	}
+	return request
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeWriteRequest decodes a remote-write request into the samples of
+// every series, keyed by its labels.
+func decodeWriteRequest(t *testing.T, data []byte) map[string][][2]float64 {
+	t.Helper()
+	fields := func(data []byte, fn func(num protowire.Number, value []byte, scalar uint64)) {
+		for len(data) > 0 {
+			num, typ, n := protowire.ConsumeTag(data)
+			data = data[n:]
+			switch typ {
+			case protowire.BytesType:
+				value, n := protowire.ConsumeBytes(data)
+				fn(num, value, 0)
+				data = data[n:]
+			case protowire.Fixed64Type:
+				value, n := protowire.ConsumeFixed64(data)
+				fn(num, nil, value)
+				data = data[n:]
+			case protowire.VarintType:
+				value, n := protowire.ConsumeVarint(data)
+				fn(num, nil, value)
+				data = data[n:]
+			default:
+				t.Fatalf("unexpected wire type %d", typ)
+			}
+		}
+	}
+
+	series := make(map[string][][2]float64)
+	fields(data, func(_ protowire.Number, ts []byte, _ uint64) {
+		var labels []string
+		var samples [][2]float64
+		fields(ts, func(num protowire.Number, value []byte, _ uint64) {
+			if num == 1 {
+				var label [2]string
+				fields(value, func(num protowire.Number, value []byte, _ uint64) { label[num-1] = string(value) })
+				labels = append(labels, label[0]+"="+label[1])
+				return
+			}
+			var sample [2]float64
+			fields(value, func(num protowire.Number, _ []byte, scalar uint64) {
+				if num == 1 {
+					sample[0] = math.Float64frombits(scalar)
+				} else {
+					sample[1] = float64(int64(scalar))
+				}
+			})
+			samples = append(samples, sample)
+		})
+		series[strings.Join(labels, ",")] = samples
+	})
+	return series
+}
+
+func TestBackfill(t *testing.T) {
+	var requests []map[string][][2]float64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("X-Scope-OrgID") != "team-a" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		data, err := snappy.Decode(nil, body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, decodeWriteRequest(t, data))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	start := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	samples := []HistorySample{
+		{Time: start, Account: "a", CharacterCount: 100, CharacterLimit: 1000},
+		{Time: start, Account: "b", CharacterCount: 5, CharacterLimit: 50},
+		{Time: start.Add(time.Minute), Account: "a", CharacterCount: 150, CharacterLimit: 1000},
+	}
+	labels, err := parseBackfillLabels([]string{"job=deepl-exporter"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg := backfillConfig{URL: ts.URL, Tenant: "team-a", Labels: labels, BatchSize: 4, Timeout: time.Second}
+
+	sent, err := backfill(context.Background(), ts.Client(), cfg, samples)
+	if err != nil || sent != 3 {
+		t.Fatalf("backfill() = %d, %v", sent, err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests of at most 4 samples, got %d", len(requests))
+	}
+	millis := float64(start.UnixMilli())
+	got := requests[0][`__name__=deepl_character_count,account=a,job=deepl-exporter`]
+	if len(got) != 1 || got[0] != [2]float64{100, millis} {
+		t.Errorf("unexpected samples of the first request: %v", requests[0])
+	}
+	got = requests[1][`__name__=deepl_character_limit,account=a,job=deepl-exporter`]
+	if len(got) != 1 || got[0] != [2]float64{1000, millis + 60000} {
+		t.Errorf("unexpected samples of the second request: %v", requests[1])
+	}
+}
+
+func TestParseBackfillLabels_Invalid(t *testing.T) {
+	for _, pair := range []string{"job", "=x", "account=a", "__name__=x"} {
+		if _, err := parseBackfillLabels([]string{pair}); err == nil {
+			t.Errorf("expected error for %q", pair)
+		}
+	}
+	if _, err := parseBackfillLabels([]string{"job=a", "env=prod", "job=b"}); err == nil {
+		t.Error("expected error for a duplicate label")
+	}
+}
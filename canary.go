@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+const translatePath = "/v2/translate"
+
+// CanaryConfig configures the canary translations.
+type CanaryConfig struct {
+	Interval   time.Duration
+	Text       string
+	TargetLang string
+	// Budget is the maximum number of characters the canary translates per
+	// account and calendar month in UTC.
+	Budget int64
+}
+
+type translateRequest struct {
+	Text       []string `json:"text"`
+	TargetLang string   `json:"target_lang"`
+}
+
+type translateResponse struct {
+	Translations []translation `json:"translations"`
+}
+
+type translation struct {
+	DetectedSourceLanguage string `json:"detected_source_language"`
+	Text                   string `json:"text"`
+}
+
+// canaryState is the outcome of the last canary translation of an account
+// and its spending of the budget.
+type canaryState struct {
+	ran      bool
+	success  bool
+	duration time.Duration
+	last     time.Time
+	month    time.Time
+	used     int64
+}
+
+// Canary periodically translates a short text with every account to verify
+// that translations actually work, within a hard character budget.
+type Canary struct {
+	cfg     CanaryConfig
+	clients []*deepl.Client
+	now     func() time.Time
+
+	mu     sync.Mutex
+	states map[string]*canaryState
+
+	success   *prometheus.Desc
+	duration  *prometheus.Desc
+	lastRun   *prometheus.Desc
+	remaining *prometheus.Desc
+}
+
+func NewCanary(clients []*deepl.Client, cfg CanaryConfig) (*Canary, error) {
+	if cfg.Interval <= 0 {
+		return nil, fmt.Errorf("invalid canary interval %s: must be positive", cfg.Interval)
+	}
+	if cfg.Text == "" || cfg.TargetLang == "" {
+		return nil, errors.New("the canary text and target language must be set")
+	}
+	if cfg.Budget < int64(utf8.RuneCountInString(cfg.Text)) {
+		return nil, fmt.Errorf("the canary budget of %d characters does not allow a single translation", cfg.Budget)
+	}
+
+	c := &Canary{
+		cfg:     cfg,
+		clients: clients,
+		now:     time.Now,
+		states:  make(map[string]*canaryState, len(clients)),
+		success: prometheus.NewDesc(
+			"deepl_canary_success",
+			"Whether the last canary translation succeeded",
+			[]string{"account"},
+			nil,
+		),
+		duration: prometheus.NewDesc(
+			"deepl_canary_duration_seconds",
+			"End-to-end duration of the last canary translation",
+			[]string{"account"},
+			nil,
+		),
+		lastRun: prometheus.NewDesc(
+			"deepl_canary_last_run_timestamp_seconds",
+			"Unix timestamp of the last canary translation",
+			[]string{"account"},
+			nil,
+		),
+		remaining: prometheus.NewDesc(
+			"deepl_canary_budget_remaining_characters",
+			"Characters the canary may still translate this month",
+			[]string{"account"},
+			nil,
+		),
+	}
+	for _, client := range clients {
+		c.states[client.Name()] = &canaryState{}
+	}
+	return c, nil
+}
+
+// Run translates with every account every interval until ctx is canceled.
+// With leader election only the leader translates, isLeader is nil
+// otherwise.
+func (c *Canary) Run(ctx context.Context, isLeader func() bool) {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if isLeader == nil || isLeader() {
+			c.ProbeAll(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProbeAll translates with every account concurrently.
+func (c *Canary) ProbeAll(ctx context.Context) {
+	var g errgroup.Group
+	for _, client := range c.clients {
+		g.Go(func() error {
+			if err := c.Probe(ctx, client); err != nil {
+				log.Printf("Canary translation for account %s failed: %v", client.Name(), err)
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+}
+
+// Probe translates the canary text with one account, unless the budget of
+// the month is spent. The characters are counted before the request is
+// sent, so the budget holds even if DeepL bills failed requests.
+func (c *Canary) Probe(ctx context.Context, client *deepl.Client) error {
+	characters := int64(utf8.RuneCountInString(c.cfg.Text))
+	now := c.now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	c.mu.Lock()
+	state := c.states[client.Name()]
+	if !state.month.Equal(month) {
+		state.month, state.used = month, 0
+	}
+	if state.used+characters > c.cfg.Budget {
+		c.mu.Unlock()
+		return fmt.Errorf("the budget of %d characters for %s is spent", c.cfg.Budget, month.Format("January 2006"))
+	}
+	state.used += characters
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	begin := time.Now()
+	var resp translateResponse
+	err := client.PostJSON(ctx, translatePath, translateRequest{Text: []string{c.cfg.Text}, TargetLang: c.cfg.TargetLang}, &resp)
+	if err == nil && (len(resp.Translations) == 0 || resp.Translations[0].Text == "") {
+		err = errors.New("empty translation")
+	}
+	duration := time.Since(begin)
+
+	c.mu.Lock()
+	state.ran, state.success, state.duration, state.last = true, err == nil, duration, now
+	c.mu.Unlock()
+	return err
+}
+
+func (c *Canary) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.success
+	ch <- c.duration
+	ch <- c.lastRun
+	ch <- c.remaining
+}
+
+func (c *Canary) Collect(ch chan<- prometheus.Metric) {
+	now := c.now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for account, state := range c.states {
+		remaining := c.cfg.Budget
+		if state.month.Equal(month) {
+			remaining -= state.used
+		}
+		ch <- prometheus.MustNewConstMetric(c.remaining, prometheus.GaugeValue, float64(remaining), account)
+		if !state.ran {
+			continue
+		}
+
+		success := 0.0
+		if state.success {
+			success = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.success, prometheus.GaugeValue, success, account)
+		ch <- prometheus.MustNewConstMetric(c.duration, prometheus.GaugeValue, state.duration.Seconds(), account)
+		ch <- prometheus.MustNewConstMetric(c.lastRun, prometheus.GaugeValue, float64(state.last.UnixNano())/1e9, account)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCanaryProbe(t *testing.T) {
+	var requests int
+	api := fakeAPIHandler(map[string]string{"test-key": "default"}, nil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		api.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL)
+	canary, err := NewCanary([]*deepl.Client{client}, CanaryConfig{Interval: time.Hour, Text: "Hello", TargetLang: "DE", Budget: 12})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	canary.now = func() time.Time { return now }
+
+	for range 2 {
+		if err := canary.Probe(context.Background(), client); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := canary.Probe(context.Background(), client); err == nil || !strings.Contains(err.Error(), "budget") {
+		t.Errorf("expected the budget to be spent, got %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests within the budget, got %d", requests)
+	}
+
+	expected := `
+# HELP deepl_canary_budget_remaining_characters Characters the canary may still translate this month
+# TYPE deepl_canary_budget_remaining_characters gauge
+deepl_canary_budget_remaining_characters{account="default"} 2
+# HELP deepl_canary_success Whether the last canary translation succeeded
+# TYPE deepl_canary_success gauge
+deepl_canary_success{account="default"} 1
+`
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(canary)
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "deepl_canary_budget_remaining_characters", "deepl_canary_success"); err != nil {
+		t.Error(err)
+	}
+
+	// The budget is renewed the next month.
+	now = time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	if err := canary.Probe(context.Background(), client); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCanaryProbeFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, translateResponse{})
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL)
+	canary, err := NewCanary([]*deepl.Client{client}, CanaryConfig{Interval: time.Hour, Text: "Hello", TargetLang: "DE", Budget: 100})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := canary.Probe(context.Background(), client); err == nil {
+		t.Fatal("expected error for an empty translation")
+	}
+	expected := `
+# HELP deepl_canary_success Whether the last canary translation succeeded
+# TYPE deepl_canary_success gauge
+deepl_canary_success{account="default"} 0
+`
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(canary)
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "deepl_canary_success"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewCanaryValidation(t *testing.T) {
+	if _, err := NewCanary(nil, CanaryConfig{Interval: time.Hour, Text: "Hello", TargetLang: "DE", Budget: 4}); err == nil {
+		t.Error("expected error for a budget smaller than the text")
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+// runCheck implements `deepl-exporter check`: it validates the configuration,
+// resolves the API keys of all accounts and sends a test request for each of
+// them. It returns the exit code, non-zero if anything failed.
+func runCheck(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.SetOutput(out)
+	config := fs.String("config", "", "Configuration file to check. DEEPL_API_KEY is checked if unset (env: CONFIG_FILE).")
+	timeout := fs.Duration("timeout", defaultTimeout, "Timeout of the test request of each account.")
+	// The global flags are accepted too, so the check runs with the same
+	// proxy, CA and endpoint settings as the exporter.
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(out, "Usage: deepl-exporter check [--config FILE] [flags]")
+		_, _ = fmt.Fprintln(out, "Validates the configuration and sends a test request for every API key.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := settings.resolve(fs, os.Getenv); err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL %v\n", err)
+		return 2
+	}
+	if *config == "" {
+		*config = settings.ConfigFile
+	}
+
+	cfg, err := checkConfig(*config)
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL %v\n", err)
+		return 1
+	}
+	if *config != "" {
+		if err := settings.applyConfig(fs, cfg.Flags); err != nil {
+			_, _ = fmt.Fprintf(out, "FAIL invalid config file %s: %v\n", *config, err)
+			return 1
+		}
+		_, _ = fmt.Fprintf(out, "OK   configuration file %s is valid (%d accounts)\n", *config, len(cfg.Accounts))
+	}
+
+	defaults, err := flagClientConfig()
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL %v\n", err)
+		return 1
+	}
+
+	failed := false
+	for _, account := range cfg.Accounts {
+		for _, result := range checkAccount(account, defaults, *timeout) {
+			_, _ = fmt.Fprintln(out, result)
+			failed = failed || result.err != nil
+		}
+	}
+
+	if failed {
+		_, _ = fmt.Fprintln(out, "Check failed")
+		return 1
+	}
+	_, _ = fmt.Fprintln(out, "All checks passed")
+	return 0
+}
+
+// checkConfig returns the configuration in path, or one with the account
+// given by DEEPL_API_KEY if path is empty.
+func checkConfig(path string) (*Config, error) {
+	if path == "" {
+		account := AccountConfig{Name: defaultAccountName, APIKeyEnv: "DEEPL_API_KEY"}
+		if os.Getenv("DEEPL_BACKUP_API_KEY") != "" {
+			account.BackupAPIKeyEnv = "DEEPL_BACKUP_API_KEY"
+		}
+		return &Config{Accounts: []AccountConfig{account}}, nil
+	}
+	return LoadConfig(path)
+}
+
+// checkResult is the outcome of checking one API key.
+type checkResult struct {
+	account string
+	key     string
+	detail  string
+	err     error
+}
+
+func (r checkResult) String() string {
+	if r.err != nil {
+		return fmt.Sprintf("FAIL account %s, %s: %v", r.account, r.key, r.err)
+	}
+	return fmt.Sprintf("OK   account %s, %s: %s", r.account, r.key, r.detail)
+}
+
+// checkAccount resolves the keys of account and sends a test request with
+// each of them.
+func checkAccount(account AccountConfig, defaults deepl.ClientConfig, timeout time.Duration) []checkResult {
+	cfg, err := account.clientConfig(defaults)
+	if err != nil {
+		return []checkResult{{account: account.Name, key: "primary key", err: err}}
+	}
+
+	results := []checkResult{checkKey(cfg, "primary key", timeout)}
+	if cfg.BackupAPIKey != "" {
+		cfg.APIKey, cfg.BackupAPIKey = cfg.BackupAPIKey, ""
+		results = append(results, checkKey(cfg, "backup key", timeout))
+	}
+	return results
+}
+
+func checkKey(cfg deepl.ClientConfig, key string, timeout time.Duration) checkResult {
+	result := checkResult{account: cfg.Name, key: key}
+
+	client, err := deepl.NewClient(cfg)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	usage, err := deepl.FetchUsage(ctx, client)
+	if err != nil {
+		result.err = fmt.Errorf("%w%s", err, checkHint(err))
+		return result
+	}
+
+	result.detail = fmt.Sprintf("%d of %d characters used", usage.CharacterCount, usage.CharacterLimit)
+	if client.EndpointMismatch() {
+		baseURL, _ := client.Endpoints()
+		result.detail += fmt.Sprintf(", but only accepted by %s: set api_type to choose it explicitly", baseURL)
+	}
+	return result
+}
+
+// checkHint returns advice on how to fix err.
+func checkHint(err error) string {
+	var apiErr *deepl.APIError
+	switch {
+	case errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden):
+		return " (the key was rejected: check that it is valid and not revoked, and that api_type or server_url match it)"
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests:
+		return " (DeepL is rate limiting the key: retry later)"
+	case errors.As(err, &apiErr):
+		return ""
+	case errors.Is(err, context.DeadlineExceeded):
+		return " (no response in time: check network connectivity, DNS and the proxy settings)"
+	default:
+		return " (check network connectivity, DNS, the proxy settings and the CA file)"
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunCheck(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "DeepL-Auth-Key good-key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"character_count": 10, "character_limit": 100}`)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		config   string
+		code     int
+		contains []string
+	}{
+		{
+			name: "Valid",
+			config: fmt.Sprintf(`
+accounts:
+  - name: a
+    api_key: good-key
+    server_url: %s
+`, ts.URL),
+			code:     0,
+			contains: []string{"configuration file", "OK   account a, primary key: 10 of 100 characters used", "All checks passed"},
+		},
+		{
+			name: "Rejected and unresolvable keys",
+			config: fmt.Sprintf(`
+accounts:
+  - name: a
+    api_key: good-key
+    backup_api_key: bad-key
+    server_url: %[1]s
+  - name: b
+    api_key_env: TEST_CHECK_UNSET_KEY
+    server_url: %[1]s
+`, ts.URL),
+			code: 1,
+			contains: []string{
+				"OK   account a, primary key",
+				"FAIL account a, backup key: API returned status 403",
+				"the key was rejected",
+				"FAIL account b, primary key: account \"b\": environment variable TEST_CHECK_UNSET_KEY is not set",
+				"Check failed",
+			},
+		},
+		{
+			name:     "Invalid configuration",
+			config:   "accounts: []",
+			code:     1,
+			contains: []string{"FAIL invalid config file", "no accounts configured"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			code := runCheck([]string{"--config", writeConfig(t, tt.config)}, &out)
+			if code != tt.code {
+				t.Errorf("expected exit code %d, got %d", tt.code, code)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(out.String(), s) {
+					t.Errorf("expected %q in output:\n%s", s, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRunCheck_InvalidFlag(t *testing.T) {
+	var out bytes.Buffer
+	if code := runCheck([]string{"--unknown"}, &out); code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+}
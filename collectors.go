@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+var collectorState = collectorFlags()
+
+// registerUsageSettings defines the --collector.usage.* flags of s in fs.
+func registerUsageSettings(fs *flag.FlagSet, s *Settings) {
+	fs.DurationVar(
+		&s.UsageAnomalyWindow,
+		"collector.usage.anomaly-window",
+		7*24*time.Hour,
+		"Period of hourly consumption the usage anomaly score is computed against.",
+	)
+	fs.StringVar(
+		&s.UsageThresholds,
+		"collector.usage.thresholds",
+		"80,95",
+		"Comma-separated usage percentages exported as deepl_usage_above_threshold, empty to disable.",
+	)
+	fs.Float64Var(
+		&s.UsagePricePerMillion,
+		"collector.usage.price-per-million-characters",
+		0,
+		"Price of a million characters used to export deepl_characters_cost, 0 to disable.",
+	)
+	fs.Float64Var(
+		&s.UsageAnomalyThreshold,
+		"collector.usage.anomaly-threshold",
+		3,
+		"Anomaly score from which the consumption of the last hour is flagged as a spike.",
+	)
+	fs.StringVar(
+		&s.UsageBillingAnchor,
+		"collector.usage.billing-anchor",
+		"",
+		"Start of the monthly billing cycle, a day of month such as 15 or the subscription date such as 2024-03-15, to export deepl_billing_period_seconds_remaining.",
+	)
+}
+
+// collectorFlags adds a --collector.<name> flag to enable or disable every
+// collector registered with the deepl package.
+func collectorFlags() map[string]*bool {
+	state := make(map[string]*bool)
+	for name, enabledByDefault := range deepl.Collectors() {
+		helpDefault := "disabled"
+		if enabledByDefault {
+			helpDefault = "enabled"
+		}
+		state[name] = flag.Bool(
+			"collector."+name,
+			enabledByDefault,
+			fmt.Sprintf("Enable the %s collector (default: %s).", name, helpDefault),
+		)
+	}
+	return state
+}
+
+// enabledCollectors returns the sorted names of all collectors enabled via
+// their flags.
+func enabledCollectors() []string {
+	names := []string{}
+	for name, state := range collectorState {
+		if *state {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// usageOptions returns the options of the usage collector set by the
+// --collector.usage.* flags, which are validated at startup.
+func usageOptions() deepl.UsageOptions {
+	thresholds, _ := parseUsageThresholds(settings.UsageThresholds)
+	anchor, _ := deepl.ParseBillingAnchor(settings.UsageBillingAnchor)
+	return deepl.UsageOptions{
+		Thresholds:       thresholds,
+		PricePerMillion:  settings.UsagePricePerMillion,
+		AnomalyWindow:    settings.UsageAnomalyWindow,
+		AnomalyThreshold: settings.UsageAnomalyThreshold,
+		BillingAnchor:    anchor,
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	// Enables zstd for the metrics handlers of promhttp.
+	_ "github.com/prometheus/client_golang/prometheus/promhttp/zstd"
+)
+
+// parseCompressions parses the comma-separated list of response encodings
+// offered to clients, in order of preference. "none" disables compression.
+func parseCompressions(list string) ([]promhttp.Compression, error) {
+	var offered []promhttp.Compression
+	for _, name := range strings.Split(list, ",") {
+		switch name = strings.TrimSpace(strings.ToLower(name)); name {
+		case "", "none":
+		case string(promhttp.Gzip), string(promhttp.Zstd):
+			offered = append(offered, promhttp.Compression(name))
+		default:
+			return nil, fmt.Errorf("unsupported compression %q: must be gzip, zstd or none", name)
+		}
+	}
+	return offered, nil
+}
+
+// promhttpOpts returns the options of the metrics handlers offering the
+// given encodings. openMetrics enables the OpenMetrics format, including
+// _created samples and exemplars, for clients asking for it.
+func promhttpOpts(offered []promhttp.Compression, openMetrics bool) promhttp.HandlerOpts {
+	opts := promhttp.HandlerOpts{
+		EnableOpenMetrics:                   openMetrics,
+		EnableOpenMetricsTextCreatedSamples: openMetrics,
+	}
+	if len(offered) == 0 {
+		opts.DisableCompression = true
+	} else {
+		opts.OfferedCompressions = append([]promhttp.Compression{promhttp.Identity}, offered...)
+	}
+	return opts
+}
+
+// negotiateEncoding returns the first of the offered encodings accepted by
+// the Accept-Encoding header, or an empty string if none is.
+func negotiateEncoding(acceptEncoding string, offered []promhttp.Compression) promhttp.Compression {
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	for _, encoding := range offered {
+		q, ok := accepted[string(encoding)]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > 0 {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressHandler compresses the responses of h with the best of the
+// offered encodings the client accepts.
+func compressHandler(offered []promhttp.Compression, h http.Handler) http.Handler {
+	if len(offered) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), offered)
+		var writer io.WriteCloser
+		switch encoding {
+		case promhttp.Gzip:
+			writer = gzip.NewWriter(w)
+		case promhttp.Zstd:
+			encoder, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+			if err != nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+			writer = encoder
+		default:
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Encoding", string(encoding))
+		w.Header().Del("Content-Length")
+		h.ServeHTTP(&compressedResponseWriter{ResponseWriter: w, writer: writer}, r)
+		_ = writer.Close()
+	})
+}
+
+// compressedResponseWriter sends the body written by a handler through a
+// compressing writer.
+type compressedResponseWriter struct {
+	http.ResponseWriter
+	writer io.Writer
+}
+
+func (w *compressedResponseWriter) Write(b []byte) (int, error) {
+	return w.writer.Write(b)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func TestParseCompressions(t *testing.T) {
+	offered, err := parseCompressions("zstd, GZIP")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(offered) != 2 || offered[0] != promhttp.Zstd || offered[1] != promhttp.Gzip {
+		t.Errorf("expected [zstd gzip], got %v", offered)
+	}
+
+	if offered, err := parseCompressions("none"); err != nil || len(offered) != 0 {
+		t.Errorf("expected no compression, got %v, %v", offered, err)
+	}
+	if _, err := parseCompressions("brotli"); err == nil {
+		t.Error("expected error for unsupported compression")
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	offered := []promhttp.Compression{promhttp.Zstd, promhttp.Gzip}
+	tests := []struct {
+		acceptEncoding string
+		expected       promhttp.Compression
+	}{
+		{acceptEncoding: "", expected: ""},
+		{acceptEncoding: "gzip", expected: promhttp.Gzip},
+		{acceptEncoding: "gzip, zstd", expected: promhttp.Zstd},
+		{acceptEncoding: "gzip, zstd;q=0", expected: promhttp.Gzip},
+		{acceptEncoding: "*", expected: promhttp.Zstd},
+		{acceptEncoding: "br", expected: ""},
+	}
+
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.acceptEncoding, offered); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.acceptEncoding, tt.expected, got)
+		}
+	}
+}
+
+func TestCompressHandler(t *testing.T) {
+	const body = `{"accounts": []}`
+	handler := compressHandler([]promhttp.Compression{promhttp.Zstd, promhttp.Gzip}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, body)
+	}))
+
+	tests := []struct {
+		acceptEncoding string
+		decode         func(io.Reader) (io.Reader, error)
+	}{
+		{acceptEncoding: "", decode: func(r io.Reader) (io.Reader, error) { return r, nil }},
+		{acceptEncoding: "gzip", decode: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{acceptEncoding: "zstd", decode: func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.acceptEncoding, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/usage", nil)
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.acceptEncoding {
+				t.Errorf("expected Content-Encoding %q, got %q", tt.acceptEncoding, got)
+			}
+			reader, err := tt.decode(rec.Body)
+			if err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			decoded, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			if string(decoded) != body {
+				t.Errorf("expected %q, got %q", body, decoded)
+			}
+		})
+	}
+}
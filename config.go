@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"go.yaml.in/yaml/v2"
+)
+
+// defaultAccountName labels the metrics of the API key passed via
+// DEEPL_API_KEY when no configuration file is used.
+const defaultAccountName = deepl.DefaultAccountName
+
+// Config is the content of the file passed with --config.file.
+type Config struct {
+	Accounts []AccountConfig `yaml:"accounts"`
+	Metrics  MetricsConfig   `yaml:"metrics"`
+	// Tenants are served their own /metrics/<tenant> endpoint exporting
+	// the metrics of their accounts only.
+	Tenants []TenantConfig `yaml:"tenants"`
+	// APITokens grant access to some of the runtime endpoints only.
+	APITokens []APITokenConfig `yaml:"api_tokens"`
+	// Include lists glob patterns of further config files, relative to
+	// this one, whose accounts are added, e.g. conf.d/*.yaml.
+	Include stringList `yaml:"include"`
+	// Flags sets command line flags by name, e.g. deepl.poll-interval: 5m.
+	// Flags given on the command line or through their environment
+	// variable take precedence.
+	Flags map[string]any `yaml:"flags"`
+}
+
+// MetricsConfig tunes the metrics exported about the DeepL API itself.
+type MetricsConfig struct {
+	// LatencyBuckets are the upper bounds in seconds of the classic buckets
+	// of the request latency histogram. Prometheus defaults if empty.
+	LatencyBuckets []float64 `yaml:"latency_buckets"`
+}
+
+// AccountConfig describes a single DeepL API key to monitor. Exactly one of
+// APIKey, APIKeyFile and APIKeyEnv must be set.
+type AccountConfig struct {
+	Name       string `yaml:"name"`
+	APIKey     string `yaml:"api_key"`
+	APIKeyFile string `yaml:"api_key_file"`
+	APIKeyEnv  string `yaml:"api_key_env"`
+	// BackupAPIKey, BackupAPIKeyFile and BackupAPIKeyEnv optionally
+	// configure a secondary key used once the primary one is rejected. At
+	// most one of them may be set.
+	BackupAPIKey     string `yaml:"backup_api_key"`
+	BackupAPIKeyFile string `yaml:"backup_api_key_file"`
+	BackupAPIKeyEnv  string `yaml:"backup_api_key_env"`
+	// APIType and ServerURL override the global --deepl.api-type and
+	// --deepl.url flags for this account.
+	APIType   string `yaml:"api_type"`
+	ServerURL string `yaml:"server_url"`
+	// AuthHeader and AuthScheme control how the key is sent, e.g. for API
+	// gateways expecting it in a different header. Setting AuthScheme to an
+	// empty string sends the bare key.
+	AuthHeader string            `yaml:"auth_header"`
+	AuthScheme *string           `yaml:"auth_scheme"`
+	Headers    map[string]string `yaml:"headers"`
+	// ProxyURL overrides the global --deepl.proxy-url flag.
+	ProxyURL string `yaml:"proxy_url"`
+	// CAFile overrides the global --deepl.ca-file flag, while
+	// InsecureSkipVerify disables TLS verification for this account only.
+	CAFile             string `yaml:"ca_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	// RateLimit overrides the global --deepl.rate-limit-per-account flag.
+	RateLimit int `yaml:"rate_limit"`
+	// CostCenter and Project label the cost metrics of the account for
+	// chargeback.
+	CostCenter string `yaml:"cost_center"`
+	Project    string `yaml:"project"`
+}
+
+// LoadConfig reads and validates the configuration file at path.
+func LoadConfig(path string) (*Config, error) {
+	cfg, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pattern := range cfg.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include %q in config file %s: %w", pattern, path, err)
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			included, err := readConfigFile(file)
+			if err != nil {
+				return nil, err
+			}
+			if len(included.Include) > 0 || len(included.Flags) > 0 || len(included.Metrics.LatencyBuckets) > 0 || len(included.Tenants) > 0 || len(included.APITokens) > 0 {
+				return nil, fmt.Errorf("included config file %s: only accounts may be defined", file)
+			}
+			cfg.Accounts = append(cfg.Accounts, included.Accounts...)
+		}
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// readConfigFile parses a single config file without following includes or
+// validating it.
+func readConfigFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	data, err = expandEnv(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand config file %s: %w", path, err)
+	}
+
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, explainUnknownFields(err))
+	}
+	return &cfg, nil
+}
+
+// stringList is a YAML value given either as a single string or as a list.
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(unmarshal func(any) error) error {
+	var single string
+	if err := unmarshal(&single); err == nil {
+		*l = stringList{single}
+		return nil
+	}
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// envReference matches ${VAR} and ${VAR:-default} in a config file, or the
+// escaped form $${...}.
+var envReference = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv replaces ${VAR} in data with the value of the environment
+// variable VAR, or the default given as ${VAR:-default} if it is unset or
+// empty. $${VAR} is kept as the literal ${VAR}. Referencing an unset variable
+// without a default is an error, so a missing variable never silently
+// results in an empty setting.
+func expandEnv(data []byte) ([]byte, error) {
+	var missing []string
+	expanded := envReference.ReplaceAllFunc(data, func(ref []byte) []byte {
+		if bytes.HasPrefix(ref, []byte("$$")) {
+			return ref[1:]
+		}
+		m := envReference.FindSubmatch(ref)
+		if value := os.Getenv(string(m[1])); value != "" {
+			return []byte(value)
+		}
+		if m[2] != nil {
+			return m[3]
+		}
+		missing = append(missing, string(m[1]))
+		return ref
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("environment variables not set: %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+var unknownFieldPattern = regexp.MustCompile(`field (\S+) not found in type main\.(\w+)`)
+
+// configTypes are the types of the config file sections, by name.
+var configTypes = map[string]reflect.Type{
+	"Config":         reflect.TypeFor[Config](),
+	"AccountConfig":  reflect.TypeFor[AccountConfig](),
+	"MetricsConfig":  reflect.TypeFor[MetricsConfig](),
+	"TenantConfig":   reflect.TypeFor[TenantConfig](),
+	"APITokenConfig": reflect.TypeFor[APITokenConfig](),
+}
+
+// explainUnknownFields suggests the closest known field for every unknown
+// field reported by the strict YAML parser, e.g. api_key for api_kye.
+func explainUnknownFields(err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+
+	messages := make([]string, len(typeErr.Errors))
+	for i, message := range typeErr.Errors {
+		messages[i] = message
+		m := unknownFieldPattern.FindStringSubmatch(message)
+		if m == nil {
+			continue
+		}
+		fields := yamlFields(configTypes[m[2]])
+		messages[i] = unknownFieldPattern.ReplaceAllString(message, "unknown field $1")
+		if suggestion := closestField(m[1], fields); suggestion != "" {
+			messages[i] += fmt.Sprintf(", did you mean %s?", suggestion)
+		}
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
+
+// yamlFields returns the YAML keys of the fields of struct type t.
+func yamlFields(t reflect.Type) []string {
+	if t == nil {
+		return nil
+	}
+	var fields []string
+	for field := range t.Fields() {
+		if name, _, _ := strings.Cut(field.Tag.Get("yaml"), ","); name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// closestField returns the field most similar to name, or an empty string if
+// none is close enough to be a typo.
+func closestField(name string, fields []string) string {
+	best, bestDistance := "", len(name)/2+1
+	for _, field := range fields {
+		if d := editDistance(name, field); d < bestDistance {
+			best, bestDistance = field, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b, counting a
+// swap of two adjacent characters as one edit.
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func (c *Config) validate() error {
+	if len(c.Accounts) == 0 {
+		return errors.New("no accounts configured")
+	}
+	if err := deepl.ValidateBuckets(c.Metrics.LatencyBuckets); err != nil {
+		return fmt.Errorf("metrics.latency_buckets: %w", err)
+	}
+
+	seen := make(map[string]bool, len(c.Accounts))
+	for i, account := range c.Accounts {
+		if account.Name == "" {
+			return fmt.Errorf("account #%d: name is required", i+1)
+		}
+		if seen[account.Name] {
+			return fmt.Errorf("account %q: duplicate name", account.Name)
+		}
+		seen[account.Name] = true
+
+		if countSet(account.APIKey, account.APIKeyFile, account.APIKeyEnv) != 1 {
+			return fmt.Errorf("account %q: exactly one of api_key, api_key_file and api_key_env is required", account.Name)
+		}
+		if countSet(account.BackupAPIKey, account.BackupAPIKeyFile, account.BackupAPIKeyEnv) > 1 {
+			return fmt.Errorf("account %q: at most one of backup_api_key, backup_api_key_file and backup_api_key_env is allowed", account.Name)
+		}
+
+		for name := range account.Headers {
+			if strings.EqualFold(name, account.authHeader()) {
+				return fmt.Errorf("account %q: header %s conflicts with the auth header", account.Name, name)
+			}
+		}
+	}
+
+	tenants := make(map[string]bool, len(c.Tenants))
+	for i, tenant := range c.Tenants {
+		if tenant.Name == "" || strings.Contains(tenant.Name, "/") {
+			return fmt.Errorf("tenant #%d: name is required and must not contain /", i+1)
+		}
+		if tenant.Name == "aggregate" {
+			// It would be shadowed by /metrics/aggregate.
+			return fmt.Errorf("tenant %q: name is reserved", tenant.Name)
+		}
+		if tenants[tenant.Name] {
+			return fmt.Errorf("tenant %q: duplicate name", tenant.Name)
+		}
+		tenants[tenant.Name] = true
+
+		if countSet(tenant.Token, tenant.TokenFile) != 1 {
+			return fmt.Errorf("tenant %q: exactly one of token and token_file is required", tenant.Name)
+		}
+		if len(tenant.Accounts) == 0 {
+			return fmt.Errorf("tenant %q: no accounts", tenant.Name)
+		}
+		for _, account := range tenant.Accounts {
+			if !seen[account] {
+				return fmt.Errorf("tenant %q: unknown account %q", tenant.Name, account)
+			}
+		}
+	}
+
+	tokens := make(map[string]bool, len(c.APITokens))
+	for i, token := range c.APITokens {
+		if token.Name == "" {
+			return fmt.Errorf("API token #%d: name is required", i+1)
+		}
+		if tokens[token.Name] {
+			return fmt.Errorf("API token %q: duplicate name", token.Name)
+		}
+		tokens[token.Name] = true
+
+		if countSet(token.Token, token.TokenFile) != 1 {
+			return fmt.Errorf("API token %q: exactly one of token and token_file is required", token.Name)
+		}
+		if len(token.Scopes) == 0 {
+			return fmt.Errorf("API token %q: no scopes", token.Name)
+		}
+		for _, scope := range token.Scopes {
+			if !slices.Contains(apiTokenScopes, scope) {
+				return fmt.Errorf("API token %q: unknown scope %q, must be one of %s", token.Name, scope, strings.Join(apiTokenScopes, ", "))
+			}
+		}
+	}
+
+	return nil
+}
+
+// countSet returns how many of values are not empty.
+func countSet(values ...string) int {
+	n := 0
+	for _, value := range values {
+		if value != "" {
+			n++
+		}
+	}
+	return n
+}
+
+func (a *AccountConfig) authHeader() string {
+	if a.AuthHeader == "" {
+		return "Authorization"
+	}
+	return http.CanonicalHeaderKey(a.AuthHeader)
+}
+
+// resolveAPIKey returns the API key of the account from whichever source is
+// configured.
+func (a *AccountConfig) resolveAPIKey() (string, error) {
+	return a.resolveKey("api_key", a.APIKey, a.APIKeyFile, a.APIKeyEnv)
+}
+
+// resolveBackupAPIKey returns the backup API key of the account, or an empty
+// string if none is configured.
+func (a *AccountConfig) resolveBackupAPIKey() (string, error) {
+	return a.resolveKey("backup_api_key", a.BackupAPIKey, a.BackupAPIKeyFile, a.BackupAPIKeyEnv)
+}
+
+// resolveKey reads a key given inline, from a file or from an environment
+// variable. field is the name of the inline option, used in errors.
+func (a *AccountConfig) resolveKey(field, key, file, env string) (string, error) {
+	switch {
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("account %q: failed to read %s_file: %w", a.Name, field, err)
+		}
+		key := strings.TrimSpace(string(data))
+		if key == "" {
+			return "", fmt.Errorf("account %q: %s_file %s is empty", a.Name, field, file)
+		}
+		return key, nil
+	case env != "":
+		key := os.Getenv(env)
+		if key == "" {
+			return "", fmt.Errorf("account %q: environment variable %s is not set", a.Name, env)
+		}
+		return key, nil
+	default:
+		return key, nil
+	}
+}
+
+// clientConfig resolves the API key of the account and returns the settings
+// for its Client. defaults provides the values of the global flags.
+func (a *AccountConfig) clientConfig(defaults deepl.ClientConfig) (deepl.ClientConfig, error) {
+	apiKey, err := a.resolveAPIKey()
+	if err != nil {
+		return deepl.ClientConfig{}, err
+	}
+
+	backupAPIKey, err := a.resolveBackupAPIKey()
+	if err != nil {
+		return deepl.ClientConfig{}, err
+	}
+
+	cfg := defaults
+	cfg.Name = a.Name
+	cfg.APIKey = apiKey
+	cfg.BackupAPIKey = backupAPIKey
+	if a.APIType != "" {
+		cfg.APIType = a.APIType
+	}
+	if a.ServerURL != "" {
+		cfg.ServerURL = a.ServerURL
+	}
+	if a.ProxyURL != "" {
+		cfg.ProxyURL = a.ProxyURL
+	}
+	if a.CAFile != "" {
+		cfg.CAFile = a.CAFile
+	}
+	cfg.InsecureSkipVerify = cfg.InsecureSkipVerify || a.InsecureSkipVerify
+	if a.RateLimit > 0 {
+		cfg.RateLimit = a.RateLimit
+	}
+	cfg.AuthHeader = a.AuthHeader
+	cfg.AuthScheme = a.AuthScheme
+	cfg.Headers = a.Headers
+	cfg.CostCenter = a.CostCenter
+	cfg.Project = a.Project
+
+	return cfg, nil
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+// writeConfig writes content to a config file in a temporary directory and
+// returns its path.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `
+accounts:
+  - name: team-a
+    api_key: key-a:fx
+    auth_header: X-Gateway-Key
+    auth_scheme: ""
+    headers:
+      X-Tenant: team-a
+  - name: team-b
+    api_key_env: TEAM_B_KEY
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Accounts) != 2 {
+		t.Fatalf("expected 2 accounts, got %d", len(cfg.Accounts))
+	}
+
+	account := cfg.Accounts[0]
+	if account.AuthScheme == nil || *account.AuthScheme != "" {
+		t.Errorf("expected empty auth scheme, got %v", account.AuthScheme)
+	}
+	if account.Headers["X-Tenant"] != "team-a" {
+		t.Errorf("expected X-Tenant header, got %v", account.Headers)
+	}
+	if cfg.Accounts[1].AuthScheme != nil {
+		t.Errorf("expected unset auth scheme, got %q", *cfg.Accounts[1].AuthScheme)
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errMsg  string
+	}{
+		{
+			name:    "No accounts",
+			content: "accounts: []",
+			errMsg:  "no accounts configured",
+		},
+		{
+			name:    "Missing name",
+			content: "accounts: [{api_key: abc}]",
+			errMsg:  "name is required",
+		},
+		{
+			name:    "Duplicate name",
+			content: "accounts: [{name: a, api_key: abc}, {name: a, api_key: def}]",
+			errMsg:  "duplicate name",
+		},
+		{
+			name:    "No key source",
+			content: "accounts: [{name: a}]",
+			errMsg:  "exactly one of",
+		},
+		{
+			name:    "Multiple key sources",
+			content: "accounts: [{name: a, api_key: abc, api_key_env: KEY}]",
+			errMsg:  "exactly one of",
+		},
+		{
+			name:    "Multiple backup key sources",
+			content: "accounts: [{name: a, api_key: abc, backup_api_key: def, backup_api_key_env: KEY}]",
+			errMsg:  "at most one of",
+		},
+		{
+			name:    "Unsorted latency buckets",
+			content: "accounts: [{name: a, api_key: abc}]\nmetrics: {latency_buckets: [5, 1]}",
+			errMsg:  "strictly increasing",
+		},
+		{
+			name:    "Header conflicts with auth header",
+			content: "accounts: [{name: a, api_key: abc, headers: {authorization: x}}]",
+			errMsg:  "conflicts with the auth header",
+		},
+		{
+			name:    "Tenant with unknown account",
+			content: "accounts: [{name: a, api_key: abc}]\ntenants: [{name: t, accounts: [b], token: x}]",
+			errMsg:  `unknown account "b"`,
+		},
+		{
+			name:    "Tenant without token",
+			content: "accounts: [{name: a, api_key: abc}]\ntenants: [{name: t, accounts: [a]}]",
+			errMsg:  "exactly one of token and token_file",
+		},
+		{
+			name:    "API token with unknown scope",
+			content: "accounts: [{name: a, api_key: abc}]\napi_tokens: [{name: ci, token: x, scopes: [delete-everything]}]",
+			errMsg:  `unknown scope "delete-everything"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfig(t, tt.content))
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestAccountConfig_resolveAPIKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte("file-key\n"), 0o600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+	t.Setenv("TEST_DEEPL_KEY", "env-key")
+
+	tests := []struct {
+		name     string
+		account  AccountConfig
+		expected string
+	}{
+		{name: "Inline", account: AccountConfig{APIKey: "inline-key"}, expected: "inline-key"},
+		{name: "File", account: AccountConfig{APIKeyFile: keyFile}, expected: "file-key"},
+		{name: "Env", account: AccountConfig{APIKeyEnv: "TEST_DEEPL_KEY"}, expected: "env-key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := tt.account.resolveAPIKey()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if key != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, key)
+			}
+		})
+	}
+
+	if _, err := (&AccountConfig{APIKeyEnv: "TEST_DEEPL_KEY_UNSET"}).resolveAPIKey(); err == nil {
+		t.Error("expected error for unset environment variable")
+	}
+}
+
+func TestAccountConfig_clientConfig_BackupAPIKey(t *testing.T) {
+	t.Setenv("TEST_DEEPL_BACKUP_KEY", "backup-key")
+
+	cfg, err := (&AccountConfig{Name: "a", APIKey: "key", BackupAPIKeyEnv: "TEST_DEEPL_BACKUP_KEY"}).clientConfig(deepl.ClientConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.BackupAPIKey != "backup-key" {
+		t.Errorf("expected backup key %q, got %q", "backup-key", cfg.BackupAPIKey)
+	}
+
+	if _, err := (&AccountConfig{Name: "a", APIKey: "key", BackupAPIKeyEnv: "TEST_DEEPL_KEY_UNSET"}).clientConfig(deepl.ClientConfig{}); err == nil {
+		t.Error("expected error for unset backup key environment variable")
+	}
+}
+
+func TestAccountConfig_clientConfig_CostAllocation(t *testing.T) {
+	cfg, err := (&AccountConfig{Name: "a", APIKey: "key", CostCenter: "CC-42", Project: "website"}).clientConfig(deepl.ClientConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.CostCenter != "CC-42" || cfg.Project != "website" {
+		t.Errorf("expected cost center CC-42 and project website, got %q and %q", cfg.CostCenter, cfg.Project)
+	}
+}
+
+func TestLoadConfigExpandsEnv(t *testing.T) {
+	t.Setenv("DEEPL_ENV", "staging")
+	t.Setenv("DEEPL_GATEWAY", "https://gateway.example.com")
+	path := writeConfig(t, `
+accounts:
+  - name: team-a-${DEEPL_ENV}
+    api_key_env: TEAM_A_KEY_${DEEPL_ENV}
+    server_url: ${DEEPL_GATEWAY}/deepl
+    headers:
+      X-Tenant: ${DEEPL_TENANT:-team-a}
+      X-Template: $${DEEPL_ENV}
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	account := cfg.Accounts[0]
+	if account.Name != "team-a-staging" || account.APIKeyEnv != "TEAM_A_KEY_staging" {
+		t.Errorf("expected variables to be expanded, got %+v", account)
+	}
+	if account.ServerURL != "https://gateway.example.com/deepl" {
+		t.Errorf("unexpected server URL %q", account.ServerURL)
+	}
+	if account.Headers["X-Tenant"] != "team-a" {
+		t.Errorf("expected the default to be used, got %q", account.Headers["X-Tenant"])
+	}
+	if account.Headers["X-Template"] != "${DEEPL_ENV}" {
+		t.Errorf("expected the escaped reference to be kept, got %q", account.Headers["X-Template"])
+	}
+}
+
+func TestLoadConfigMissingEnv(t *testing.T) {
+	path := writeConfig(t, `
+accounts:
+  - name: team-a
+    api_key: ${DEEPL_UNSET_KEY}
+`)
+
+	_, err := LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "DEEPL_UNSET_KEY") {
+		t.Errorf("expected an error naming the unset variable, got %v", err)
+	}
+}
+
+func TestLoadConfigUnknownField(t *testing.T) {
+	path := writeConfig(t, `
+accounts:
+  - name: team-a
+    api_kye: key-a
+`)
+
+	_, err := LoadConfig(path)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "line 4: unknown field api_kye, did you mean api_key?") {
+		t.Errorf("expected the error to point to the line and suggest api_key, got %q", msg)
+	}
+}
+
+func TestLoadConfigUnknownFieldInSections(t *testing.T) {
+	tests := map[string]struct {
+		config string
+		want   string
+	}{
+		"api_tokens": {
+			config: `
+accounts:
+  - name: team-a
+    api_key: key-a
+api_tokens:
+  - name: ci
+    tokne: ci-secret
+    scopes: [reload]
+`,
+			want: "line 7: unknown field tokne, did you mean token?",
+		},
+		"tenants": {
+			config: `
+accounts:
+  - name: team-a
+    api_key: key-a
+tenants:
+  - name: team-a
+    acounts: [team-a]
+    token: tenant-secret
+`,
+			want: "line 7: unknown field acounts, did you mean accounts?",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfig(t, tt.config))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// TestConfigTypes checks that every section of the config file gets
+// suggestions for its unknown fields.
+func TestConfigTypes(t *testing.T) {
+	var walk func(reflect.Type)
+	walk = func(typ reflect.Type) {
+		for typ.Kind() == reflect.Slice || typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct || typ.PkgPath() != reflect.TypeFor[Config]().PkgPath() {
+			return
+		}
+		if configTypes[typ.Name()] != typ {
+			t.Errorf("expected configTypes to contain %s", typ.Name())
+		}
+		for field := range typ.Fields() {
+			walk(field.Type)
+		}
+	}
+	walk(reflect.TypeFor[Config]())
+}
+
+func TestClosestField(t *testing.T) {
+	fields := []string{"api_key", "api_key_file", "api_key_env", "name", "headers"}
+	tests := map[string]string{
+		"api_kye":     "api_key",
+		"apikey_file": "api_key_file",
+		"header":      "headers",
+		"nmae":        "name",
+		"completely":  "",
+	}
+	for name, want := range tests {
+		if got := closestField(name, fields); got != want {
+			t.Errorf("closestField(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestLoadConfigInclude(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "conf.d"), 0o700); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	files := map[string]string{
+		"config.yaml":   "include: conf.d/*.yaml\naccounts:\n  - name: main\n    api_key: key-main\n",
+		"conf.d/b.yaml": "accounts:\n  - name: team-b\n    api_key: key-b\n",
+		"conf.d/a.yaml": "accounts:\n  - name: team-a\n    api_key: key-a\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	cfg, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, account := range cfg.Accounts {
+		names = append(names, account.Name)
+	}
+	if got := strings.Join(names, ","); got != "main,team-a,team-b" {
+		t.Errorf("expected the included accounts in file order, got %s", got)
+	}
+}
+
+func TestLoadConfigIncludeInvalid(t *testing.T) {
+	tests := map[string]string{
+		"duplicate": "accounts:\n  - name: main\n    api_key: key\n",
+		"flags":     "flags:\n  deepl.rate-limit: 5\n",
+	}
+	for name, included := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "team.yaml"), []byte(included), 0o600); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			path := filepath.Join(dir, "config.yaml")
+			if err := os.WriteFile(path, []byte("include: [team.yaml]\naccounts:\n  - name: main\n    api_key: key-main\n"), 0o600); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := LoadConfig(path); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
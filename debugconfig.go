@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+// EffectiveConfig is the configuration a running exporter uses, as served by
+// /debug/config with credentials masked.
+type EffectiveConfig struct {
+	Settings   []Setting        `json:"settings"`
+	Collectors []string         `json:"collectors"`
+	Accounts   []AccountSummary `json:"accounts"`
+	Metrics    MetricsConfig    `json:"metrics"`
+}
+
+// AccountSummary describes a configured account without its credentials.
+type AccountSummary struct {
+	Name string `json:"name"`
+	// APIKey tells where the key comes from and shows its last characters
+	// only, e.g. "inline ...a1b2" or "env DEEPL_TEAM_B_KEY".
+	APIKey             string            `json:"api_key"`
+	BackupAPIKey       string            `json:"backup_api_key,omitempty"`
+	APIType            string            `json:"api_type,omitempty"`
+	ServerURL          string            `json:"server_url,omitempty"`
+	AuthHeader         string            `json:"auth_header"`
+	Headers            map[string]string `json:"headers,omitempty"`
+	ProxyURL           string            `json:"proxy_url,omitempty"`
+	CAFile             string            `json:"ca_file,omitempty"`
+	InsecureSkipVerify bool              `json:"insecure_skip_verify,omitempty"`
+	RateLimit          int               `json:"rate_limit,omitempty"`
+}
+
+// newEffectiveConfig summarizes cfg, which is nil when DEEPL_API_KEY is used,
+// together with the effective settings and enabled collectors.
+func newEffectiveConfig(cfg *Config, settings []Setting, collectors []string) EffectiveConfig {
+	effective := EffectiveConfig{Settings: settings, Collectors: collectors}
+	if cfg == nil {
+		effective.Accounts = []AccountSummary{{
+			Name:       defaultAccountName,
+			APIKey:     "env DEEPL_API_KEY",
+			AuthHeader: deepl.DefaultAuthHeader,
+		}}
+		return effective
+	}
+
+	effective.Metrics = cfg.Metrics
+	for _, account := range cfg.Accounts {
+		summary := AccountSummary{
+			Name:               account.Name,
+			APIKey:             describeKey(account.APIKey, account.APIKeyFile, account.APIKeyEnv),
+			BackupAPIKey:       describeKey(account.BackupAPIKey, account.BackupAPIKeyFile, account.BackupAPIKeyEnv),
+			APIType:            account.APIType,
+			ServerURL:          account.ServerURL,
+			AuthHeader:         account.authHeader(),
+			CAFile:             account.CAFile,
+			InsecureSkipVerify: account.InsecureSkipVerify,
+			RateLimit:          account.RateLimit,
+		}
+		if u, err := url.Parse(account.ProxyURL); err == nil {
+			summary.ProxyURL = u.Redacted()
+		}
+		if len(account.Headers) > 0 {
+			// Gateways often expect credentials in custom headers.
+			summary.Headers = make(map[string]string, len(account.Headers))
+			for name := range account.Headers {
+				summary.Headers[name] = deepl.Redacted
+			}
+		}
+		effective.Accounts = append(effective.Accounts, summary)
+	}
+	sort.Slice(effective.Accounts, func(i, j int) bool { return effective.Accounts[i].Name < effective.Accounts[j].Name })
+	return effective
+}
+
+// describeKey tells where a key is configured without revealing it.
+func describeKey(key, file, env string) string {
+	switch {
+	case file != "":
+		return "file " + file
+	case env != "":
+		return "env " + env
+	case key != "":
+		return "inline " + deepl.MaskKey(key)
+	default:
+		return ""
+	}
+}
+
+// configHandler serves the effective configuration as JSON.
+func configHandler(effective EffectiveConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, effective)
+	})
+}
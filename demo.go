@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+// demoAccount is a fake DeepL account served in demo mode.
+type demoAccount struct {
+	name   string
+	apiKey string
+	limit  int64
+	// share is the part of the limit used by the end of the month.
+	share float64
+}
+
+var demoAccounts = []demoAccount{
+	{name: "demo-free", apiKey: "demo-free-key:fx", limit: 500_000, share: 0.6},
+	{name: "demo-pro", apiKey: "demo-pro-key", limit: 20_000_000, share: 0.97},
+}
+
+var demoGlossaries = []deepl.DeepLGlossary{
+	{GlossaryID: "demo-glossary-en-de", Name: "Product terms", Ready: true, SourceLang: "en", TargetLang: "de", EntryCount: 120},
+	{GlossaryID: "demo-glossary-en-fr", Name: "Legal terms", Ready: true, SourceLang: "en", TargetLang: "fr", EntryCount: 48},
+}
+
+var demoGlossaryLanguagePairs = []deepl.DeepLGlossaryLanguagePair{
+	{SourceLang: "de", TargetLang: "en"},
+	{SourceLang: "en", TargetLang: "de"},
+	{SourceLang: "en", TargetLang: "fr"},
+	{SourceLang: "fr", TargetLang: "en"},
+}
+
+var demoLanguages = map[string][]deepl.DeepLLanguage{
+	"source": {
+		{Language: "DE", Name: "German"},
+		{Language: "EN", Name: "English"},
+		{Language: "FR", Name: "French"},
+	},
+	"target": {
+		{Language: "DE", Name: "German", SupportsFormality: true},
+		{Language: "EN-GB", Name: "English (British)"},
+		{Language: "EN-US", Name: "English (American)"},
+		{Language: "FR", Name: "French", SupportsFormality: true},
+	},
+}
+
+// demoUsage returns the characters used by account at now. Usage grows
+// through the month with a daily rhythm and resets at the start of the next
+// one, like a real account.
+func demoUsage(account demoAccount, now time.Time) int64 {
+	now = now.UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	elapsed := now.Sub(start).Seconds() / start.AddDate(0, 1, 0).Sub(start).Seconds()
+
+	// Most translations happen during the day: modulate the growth over the
+	// day, slowly enough for usage to never decrease.
+	day := float64(now.Hour()*3600+now.Minute()*60+now.Second()) / 86400
+	elapsed += math.Sin(2*math.Pi*day) / 31 / 4 / math.Pi
+
+	count := int64(float64(account.limit) * account.share * math.Max(elapsed, 0))
+	return min(count, account.limit)
+}
+
+// demoHandler serves the parts of the DeepL API used by the collectors with
+// synthetic data for demoAccounts.
+func demoHandler(now func() time.Time) http.Handler {
+	accounts := make(map[string]demoAccount, len(demoAccounts))
+	keys := make(map[string]string, len(demoAccounts))
+	for _, account := range demoAccounts {
+		accounts[account.name] = account
+		keys[account.apiKey] = account.name
+	}
+	return fakeAPIHandler(keys, func(name string) (*deepl.DeepLUsage, error) {
+		account := accounts[name]
+		return &deepl.DeepLUsage{CharacterCount: demoUsage(account, now()), CharacterLimit: account.limit}, nil
+	})
+}
+
+// fakeAPIHandler serves the parts of the DeepL API used by the collectors
+// for the accounts identified by the API keys in keys. Usage is returned by
+// usage, glossaries, glossary language pairs and languages are the same for every account and
+// translations just prefix the text with the target language.
+func fakeAPIHandler(keys map[string]string, usage func(account string) (*deepl.DeepLUsage, error)) http.Handler {
+	mux := http.NewServeMux()
+	authorized := func(h func(http.ResponseWriter, *http.Request, string)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			account, ok := keys[strings.TrimPrefix(r.Header.Get(deepl.DefaultAuthHeader), deepl.DefaultAuthScheme+" ")]
+			if !ok {
+				writeJSON(w, http.StatusForbidden, map[string]string{"message": "Wrong auth key"})
+				return
+			}
+			h(w, r, account)
+		}
+	}
+	mux.HandleFunc("GET "+deepl.UsagePath, authorized(func(w http.ResponseWriter, _ *http.Request, account string) {
+		result, err := usage(account)
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"message": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}))
+	mux.HandleFunc("GET "+deepl.GlossariesPath, authorized(func(w http.ResponseWriter, _ *http.Request, _ string) {
+		writeJSON(w, http.StatusOK, map[string][]deepl.DeepLGlossary{"glossaries": demoGlossaries})
+	}))
+	mux.HandleFunc("GET "+deepl.GlossaryLanguagePairsPath, authorized(func(w http.ResponseWriter, _ *http.Request, _ string) {
+		writeJSON(w, http.StatusOK, map[string][]deepl.DeepLGlossaryLanguagePair{"supported_languages": demoGlossaryLanguagePairs})
+	}))
+	mux.HandleFunc("GET "+deepl.LanguagesPath, authorized(func(w http.ResponseWriter, r *http.Request, _ string) {
+		langType := r.URL.Query().Get("type")
+		if langType == "" {
+			langType = "source"
+		}
+		languages, ok := demoLanguages[langType]
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Value for 'type' not supported."})
+			return
+		}
+		writeJSON(w, http.StatusOK, languages)
+	}))
+	mux.HandleFunc("POST "+translatePath, authorized(func(w http.ResponseWriter, r *http.Request, _ string) {
+		var req translateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Text) == 0 || req.TargetLang == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+			return
+		}
+		var resp translateResponse
+		for _, text := range req.Text {
+			resp.Translations = append(resp.Translations, translation{DetectedSourceLanguage: "EN", Text: "[" + req.TargetLang + "] " + text})
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}))
+	return mux
+}
+
+// serveFakeAPI serves handler on a loopback port and returns its URL.
+func serveFakeAPI(handler http.Handler) (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Fake DeepL API server failed: %v", err)
+		}
+	}()
+	return "http://" + listener.Addr().String(), nil
+}
+
+// startDemo serves demoHandler on a loopback port and returns the
+// configuration of the demo accounts together with the URL to reach it.
+func startDemo() (*Config, string, error) {
+	url, err := serveFakeAPI(demoHandler(time.Now))
+	if err != nil {
+		return nil, "", err
+	}
+
+	cfg := &Config{}
+	for _, account := range demoAccounts {
+		cfg.Accounts = append(cfg.Accounts, AccountConfig{Name: account.name, APIKey: account.apiKey})
+	}
+	return cfg, url, nil
+}
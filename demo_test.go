@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+func TestDemoUsage(t *testing.T) {
+	account := demoAccounts[0]
+
+	start := demoUsage(account, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	if start != 0 {
+		t.Errorf("expected usage to reset at the start of the month, got %d", start)
+	}
+
+	var last int64
+	for hour := 1; hour < 31*24; hour++ {
+		usage := demoUsage(account, time.Date(2026, 3, 1, hour, 0, 0, 0, time.UTC))
+		if usage < last {
+			t.Fatalf("usage decreased from %d to %d at hour %d", last, usage, hour)
+		}
+		last = usage
+	}
+	if want := int64(float64(account.limit) * account.share); last < want*9/10 || last > account.limit {
+		t.Errorf("expected usage near %d at the end of the month, got %d", want, last)
+	}
+
+	if next := demoUsage(account, time.Date(2026, 4, 1, 0, 30, 0, 0, time.UTC)); next >= last {
+		t.Errorf("expected usage to reset in the next month, got %d", next)
+	}
+}
+
+func TestDemoHandler(t *testing.T) {
+	now := time.Date(2026, 3, 16, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(demoHandler(func() time.Time { return now }))
+	defer server.Close()
+
+	for _, account := range demoAccounts {
+		client, err := deepl.NewClient(deepl.ClientConfig{Name: account.name, APIKey: account.apiKey, ServerURL: server.URL})
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		usage, err := deepl.FetchUsage(context.Background(), client)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if usage.CharacterLimit != account.limit || usage.CharacterCount != demoUsage(account, now) {
+			t.Errorf("unexpected usage of %s: %+v", account.name, usage)
+		}
+	}
+
+	client := newTestClient(t, server.URL)
+	if _, err := deepl.FetchUsage(context.Background(), client); err == nil {
+		t.Error("expected an unknown key to be rejected")
+	}
+}
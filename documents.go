@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const documentPath = "/v2/document/"
+
+// documentStatuses are the states of a DeepL document translation.
+var documentStatuses = []string{"queued", "translating", "done", "error"}
+
+// DeepLDocumentStatus is the status of a document translation as returned by
+// DeepL.
+type DeepLDocumentStatus struct {
+	DocumentID       string `json:"document_id"`
+	Status           string `json:"status"`
+	SecondsRemaining *int64 `json:"seconds_remaining,omitempty"`
+	BilledCharacters int64  `json:"billed_characters,omitempty"`
+	ErrorMessage     string `json:"error_message,omitempty"`
+}
+
+// DocumentRegistration registers a document translation for monitoring.
+type DocumentRegistration struct {
+	Account     string `json:"account"`
+	DocumentID  string `json:"document_id"`
+	DocumentKey string `json:"document_key"`
+}
+
+// TrackedDocument is a monitored document translation as served by the
+// documents API, without its key.
+type TrackedDocument struct {
+	Account    string              `json:"account"`
+	DocumentID string              `json:"document_id"`
+	Registered time.Time           `json:"registered"`
+	Started    *time.Time          `json:"started,omitempty"`
+	Finished   *time.Time          `json:"finished,omitempty"`
+	Status     DeepLDocumentStatus `json:"status"`
+	// Error is set if the status could not be fetched the last time.
+	Error string `json:"error,omitempty"`
+
+	key string
+}
+
+// queueTime is how long the document waited to be translated, so far if it
+// is still queued.
+func (d *TrackedDocument) queueTime(now time.Time) time.Duration {
+	if d.Started != nil {
+		return d.Started.Sub(d.Registered)
+	}
+	if d.Finished != nil {
+		return d.Finished.Sub(d.Registered)
+	}
+	return now.Sub(d.Registered)
+}
+
+// DocumentMonitor polls the status of registered document translations
+// until they are done or failed, and keeps exporting them for the retention
+// period afterwards.
+type DocumentMonitor struct {
+	collector *deepl.DeepLCollector
+	interval  time.Duration
+	retention time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	documents map[string]*TrackedDocument
+
+	status           *prometheus.Desc
+	queueSeconds     *prometheus.Desc
+	secondsRemaining *prometheus.Desc
+	billed           *prometheus.Desc
+}
+
+func NewDocumentMonitor(collector *deepl.DeepLCollector, interval, retention time.Duration) *DocumentMonitor {
+	labels := []string{"account", "document_id"}
+	return &DocumentMonitor{
+		collector: collector,
+		interval:  interval,
+		retention: retention,
+		now:       time.Now,
+		documents: make(map[string]*TrackedDocument),
+		status: prometheus.NewDesc(
+			"deepl_document_status",
+			"Status of a monitored document translation, 1 for the current one",
+			append(labels, "status"),
+			nil,
+		),
+		queueSeconds: prometheus.NewDesc(
+			"deepl_document_queue_seconds",
+			"How long a monitored document waited for its translation to start",
+			labels,
+			nil,
+		),
+		secondsRemaining: prometheus.NewDesc(
+			"deepl_document_seconds_remaining",
+			"Estimated seconds until a monitored document translation is done",
+			labels,
+			nil,
+		),
+		billed: prometheus.NewDesc(
+			"deepl_document_billed_characters",
+			"Characters billed for a monitored document translation",
+			labels,
+			nil,
+		),
+	}
+}
+
+// Register starts monitoring a document translation of an account.
+// Registering a document again returns the one being monitored.
+func (m *DocumentMonitor) Register(reg DocumentRegistration) (TrackedDocument, error) {
+	if m.collector.Client(reg.Account) == nil {
+		return TrackedDocument{}, fmt.Errorf("unknown account %q", reg.Account)
+	}
+	if reg.DocumentID == "" || reg.DocumentKey == "" {
+		return TrackedDocument{}, errors.New("document_id and document_key are required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := reg.Account + "/" + reg.DocumentID
+	if doc, ok := m.documents[id]; ok {
+		return *doc, nil
+	}
+	doc := &TrackedDocument{
+		Account:    reg.Account,
+		DocumentID: reg.DocumentID,
+		Registered: m.now(),
+		Status:     DeepLDocumentStatus{DocumentID: reg.DocumentID, Status: "queued"},
+		key:        reg.DocumentKey,
+	}
+	m.documents[id] = doc
+	return *doc, nil
+}
+
+// Documents returns copies of the monitored documents sorted by
+// registration.
+func (m *DocumentMonitor) Documents() []TrackedDocument {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	documents := make([]TrackedDocument, 0, len(m.documents))
+	for _, doc := range m.documents {
+		documents = append(documents, *doc)
+	}
+	slices.SortFunc(documents, func(a, b TrackedDocument) int {
+		return a.Registered.Compare(b.Registered)
+	})
+	return documents
+}
+
+// Run polls the documents every interval until ctx is canceled.
+func (m *DocumentMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		m.Poll(ctx)
+	}
+}
+
+// Poll fetches the status of every unfinished document and forgets the ones
+// finished longer than the retention ago.
+func (m *DocumentMonitor) Poll(ctx context.Context) {
+	m.mu.Lock()
+	var pending []*TrackedDocument
+	for id, doc := range m.documents {
+		switch {
+		case doc.Finished == nil:
+			pending = append(pending, doc)
+		case m.now().Sub(*doc.Finished) > m.retention:
+			delete(m.documents, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, doc := range pending {
+		status, err := m.fetch(ctx, doc)
+		now := m.now()
+
+		m.mu.Lock()
+		if err != nil {
+			doc.Error = err.Error()
+			log.Printf("Failed to fetch the status of document %s of account %s: %v", doc.DocumentID, doc.Account, err)
+		} else {
+			doc.Error, doc.Status = "", *status
+			if doc.Started == nil && status.Status != "queued" {
+				doc.Started = &now
+			}
+			if status.Status == "done" || status.Status == "error" {
+				doc.Finished = &now
+			}
+		}
+		m.mu.Unlock()
+	}
+}
+
+func (m *DocumentMonitor) fetch(ctx context.Context, doc *TrackedDocument) (*DeepLDocumentStatus, error) {
+	client := m.collector.Client(doc.Account)
+	if client == nil {
+		return nil, fmt.Errorf("unknown account %q", doc.Account)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var status DeepLDocumentStatus
+	err := client.PostJSON(ctx, documentPath+url.PathEscape(doc.DocumentID), map[string]string{"document_key": doc.key}, &status)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(documentStatuses, status.Status) {
+		return nil, fmt.Errorf("unknown document status %q", status.Status)
+	}
+	return &status, nil
+}
+
+func (m *DocumentMonitor) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.status
+	ch <- m.queueSeconds
+	ch <- m.secondsRemaining
+	ch <- m.billed
+}
+
+func (m *DocumentMonitor) Collect(ch chan<- prometheus.Metric) {
+	now := m.now()
+	for _, doc := range m.Documents() {
+		for _, status := range documentStatuses {
+			value := 0.0
+			if doc.Status.Status == status {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(m.status, prometheus.GaugeValue, value, doc.Account, doc.DocumentID, status)
+		}
+		ch <- prometheus.MustNewConstMetric(m.queueSeconds, prometheus.GaugeValue, doc.queueTime(now).Seconds(), doc.Account, doc.DocumentID)
+		if doc.Status.SecondsRemaining != nil && doc.Finished == nil {
+			ch <- prometheus.MustNewConstMetric(m.secondsRemaining, prometheus.GaugeValue, float64(*doc.Status.SecondsRemaining), doc.Account, doc.DocumentID)
+		}
+		if doc.Status.Status == "done" {
+			ch <- prometheus.MustNewConstMetric(m.billed, prometheus.GaugeValue, float64(doc.Status.BilledCharacters), doc.Account, doc.DocumentID)
+		}
+	}
+}
+
+// documentsHandler lists the monitored documents on GET and registers a
+// document on POST.
+func documentsHandler(monitor *DocumentMonitor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, map[string][]TrackedDocument{"documents": monitor.Documents()})
+		case http.MethodPost:
+			if !acceptJSON(w, r) {
+				return
+			}
+			var reg DocumentRegistration
+			decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&reg); err != nil {
+				http.Error(w, "invalid registration: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			doc, err := monitor.Register(reg)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, http.StatusCreated, doc)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
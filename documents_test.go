@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDocumentMonitor(t *testing.T) {
+	statuses := []string{
+		`{"document_id": "DOC1", "status": "queued"}`,
+		`{"document_id": "DOC1", "status": "translating", "seconds_remaining": 20}`,
+		`{"document_id": "DOC1", "status": "done", "billed_characters": 1337}`,
+	}
+	var polls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if r.Method != http.MethodPost || r.URL.Path != "/v2/document/DOC1" || body["document_key"] != "KEY1" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(statuses[min(polls, len(statuses)-1)]))
+		polls++
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL)
+	collector, err := deepl.NewDeepLCollector([]*deepl.Client{client}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	monitor := NewDocumentMonitor(collector, time.Second, time.Hour)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	monitor.now = func() time.Time { return now }
+	handler := documentsHandler(monitor)
+
+	for body, code := range map[string]int{
+		`{"account": "default", "document_id": "DOC1", "document_key": "KEY1"}`: http.StatusCreated,
+		`{"account": "unknown", "document_id": "DOC2", "document_key": "KEY2"}`: http.StatusBadRequest,
+		`{"account": "default", "document_id": "DOC2"}`:                         http.StatusBadRequest,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/documents", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json; charset=utf-8")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if rec.Code != code {
+			t.Errorf("%s: expected %d, got %d: %s", body, code, rec.Code, rec.Body.String())
+		}
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/documents", strings.NewReader(`{"account": "default", "document_id": "DOC3", "document_key": "KEY3"}`)))
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415 without a JSON Content-Type, got %d", rec.Code)
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(monitor)
+
+	// Queued for 30s, then translating for 30s, then done.
+	for range 3 {
+		now = now.Add(30 * time.Second)
+		monitor.Poll(context.Background())
+	}
+	now = now.Add(time.Minute)
+	monitor.Poll(context.Background())
+	if polls != 3 {
+		t.Errorf("expected the document to be polled until done, got %d polls", polls)
+	}
+
+	expected := `
+# HELP deepl_document_billed_characters Characters billed for a monitored document translation
+# TYPE deepl_document_billed_characters gauge
+deepl_document_billed_characters{account="default",document_id="DOC1"} 1337
+# HELP deepl_document_queue_seconds How long a monitored document waited for its translation to start
+# TYPE deepl_document_queue_seconds gauge
+deepl_document_queue_seconds{account="default",document_id="DOC1"} 60
+# HELP deepl_document_status Status of a monitored document translation, 1 for the current one
+# TYPE deepl_document_status gauge
+deepl_document_status{account="default",document_id="DOC1",status="done"} 1
+deepl_document_status{account="default",document_id="DOC1",status="error"} 0
+deepl_document_status{account="default",document_id="DOC1",status="queued"} 0
+deepl_document_status{account="default",document_id="DOC1",status="translating"} 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/documents", nil))
+	if bytes.Contains(rec.Body.Bytes(), []byte("KEY1")) || !bytes.Contains(rec.Body.Bytes(), []byte(`"status":"done"`)) {
+		t.Errorf("unexpected document list: %s", rec.Body.String())
+	}
+
+	// Finished documents are forgotten after the retention.
+	now = now.Add(2 * time.Hour)
+	monitor.Poll(context.Background())
+	if documents := monitor.Documents(); len(documents) != 0 {
+		t.Errorf("expected the document to be forgotten, got %+v", documents)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+// dumpState writes the internal state of the exporter for diagnosing stuck
+// instances: the freshness of the cached metrics, the state of every account
+// and the last outcome of every module. isLeader is nil without leader
+// election.
+func dumpState(w io.Writer, c *deepl.DeepLCollector, isLeader func() bool, now time.Time) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	_, _ = fmt.Fprintf(w, "State dump: %d goroutines, %d MiB heap in use, polling %t\n",
+		runtime.NumGoroutine(), mem.HeapInuse>>20, c.Polling())
+	if isLeader != nil {
+		_, _ = fmt.Fprintf(w, "State dump: leader %t\n", isLeader())
+	}
+	c.DumpState(w, now)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+func TestDumpState(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == deepl.GlossariesPath {
+			http.Error(w, "glossaries unavailable", http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"character_count": 1, "character_limit": 2}`))
+	}))
+	defer server.Close()
+
+	client, err := deepl.NewClient(deepl.ClientConfig{Name: "team-a", APIKey: "test-key", ServerURL: server.URL, RateLimit: 60})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	c, err := deepl.NewDeepLCollector([]*deepl.Client{client}, []string{"glossaries", "usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.EnablePolling()
+	c.Refresh(context.Background(), client)
+
+	var dump strings.Builder
+	dumpState(&dump, c, func() bool { return true }, time.Now().Add(time.Minute))
+	out := dump.String()
+
+	for _, want := range []string{
+		"goroutines",
+		"leader true",
+		"account team-a: endpoint " + server.URL,
+		"rate limit tokens [57.",
+		"refreshed 1m0s ago",
+		"collector usage last succeeded 1m0s ago\n",
+		"collector glossaries last succeeded never, last failed 1m0s ago: API returned status 500",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected dump to contain %q, got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package main
+
+import "context"
+
+// notifyDump does nothing, Windows has no SIGUSR1.
+func notifyDump(ctx context.Context, dump func()) {}
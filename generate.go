@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// generators are the artifacts `deepl-exporter generate` can produce.
+var generators = map[string]func(args []string, out io.Writer) error{
+	"alerts":        generateAlerts,
+	"dashboard":     generateDashboard,
+	"scrape-config": generateScrapeConfig,
+}
+
+// runGenerate implements `deepl-exporter generate <artifact>`, printing
+// configuration for other tools matching the metrics of this exporter. It
+// returns the exit code.
+func runGenerate(args []string, out, errOut io.Writer) int {
+	if len(args) == 0 || generators[args[0]] == nil {
+		_, _ = fmt.Fprintf(errOut, "Usage: deepl-exporter generate <%s> [flags]\n", strings.Join(generatorNames(), "|"))
+		return 2
+	}
+
+	if err := generators[args[0]](args[1:], out); err != nil {
+		_, _ = fmt.Fprintf(errOut, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func generatorNames() []string {
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newGenerateFlagSet returns the flag set of a generator, which also accepts
+// the global flags so the output matches the enabled collectors.
+func newGenerateFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("generate "+name, flag.ContinueOnError)
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	return fs
+}
+
+type dashboard struct {
+	Title         string            `json:"title"`
+	UID           string            `json:"uid"`
+	Tags          []string          `json:"tags"`
+	Timezone      string            `json:"timezone"`
+	SchemaVersion int               `json:"schemaVersion"`
+	Refresh       string            `json:"refresh"`
+	Time          map[string]string `json:"time"`
+	Templating    struct {
+		List []dashboardVariable `json:"list"`
+	} `json:"templating"`
+	Panels []dashboardPanel `json:"panels"`
+}
+
+type dashboardVariable struct {
+	Name       string               `json:"name"`
+	Label      string               `json:"label"`
+	Type       string               `json:"type"`
+	Query      any                  `json:"query"`
+	Datasource *dashboardDatasource `json:"datasource,omitempty"`
+	Multi      bool                 `json:"multi,omitempty"`
+	IncludeAll bool                 `json:"includeAll,omitempty"`
+	Refresh    int                  `json:"refresh,omitempty"`
+}
+
+type dashboardDatasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type dashboardPanel struct {
+	ID          int                  `json:"id"`
+	Title       string               `json:"title"`
+	Type        string               `json:"type"`
+	Datasource  dashboardDatasource  `json:"datasource"`
+	GridPos     dashboardGridPos     `json:"gridPos"`
+	Targets     []dashboardTarget    `json:"targets"`
+	FieldConfig dashboardFieldConfig `json:"fieldConfig"`
+}
+
+type dashboardGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type dashboardTarget struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+}
+
+type dashboardFieldConfig struct {
+	Defaults struct {
+		Unit string `json:"unit,omitempty"`
+		Max  *int   `json:"max,omitempty"`
+	} `json:"defaults"`
+	Overrides []any `json:"overrides"`
+}
+
+// panelSpec describes a panel before it is laid out on the dashboard.
+type panelSpec struct {
+	title   string
+	kind    string
+	unit    string
+	max     *int
+	targets []dashboardTarget
+}
+
+// dashboardPanels returns the panels for the given enabled collectors.
+func dashboardPanels(collectors []string) []panelSpec {
+	hundred := 100
+	enabled := make(map[string]bool, len(collectors))
+	for _, name := range collectors {
+		enabled[name] = true
+	}
+
+	var panels []panelSpec
+	if enabled["usage"] {
+		panels = append(panels,
+			panelSpec{
+				title: "Character usage",
+				kind:  "gauge",
+				unit:  "percent",
+				max:   &hundred,
+				targets: []dashboardTarget{
+					{Expr: `deepl_character_usage_percent{account=~"$account"}`, LegendFormat: "{{account}}"},
+				},
+			},
+			panelSpec{
+				title: "Characters used",
+				kind:  "timeseries",
+				unit:  "short",
+				targets: []dashboardTarget{
+					{Expr: `deepl_character_count{account=~"$account"}`, LegendFormat: "{{account}} used"},
+					{Expr: `deepl_character_limit{account=~"$account"}`, LegendFormat: "{{account}} limit"},
+				},
+			},
+		)
+	}
+	if enabled["glossaries"] {
+		panels = append(panels, panelSpec{
+			title: "Glossaries",
+			kind:  "stat",
+			unit:  "short",
+			targets: []dashboardTarget{
+				{Expr: `deepl_glossary_count{account=~"$account"}`, LegendFormat: "{{account}}"},
+			},
+		})
+	}
+	if enabled["admin"] {
+		panels = append(panels, panelSpec{
+			title: "Characters used by organization key",
+			kind:  "timeseries",
+			unit:  "short",
+			targets: []dashboardTarget{
+				{Expr: `deepl_admin_key_character_count{account=~"$account"}`, LegendFormat: "{{account}} {{label}}"},
+			},
+		})
+	}
+	if enabled["languages"] {
+		panels = append(panels, panelSpec{
+			title: "Supported languages",
+			kind:  "stat",
+			unit:  "short",
+			targets: []dashboardTarget{
+				{Expr: `deepl_language_count{account=~"$account"}`, LegendFormat: "{{account}} {{type}}"},
+			},
+		})
+	}
+
+	return append(panels,
+		panelSpec{
+			title: "Collector success",
+			kind:  "timeseries",
+			unit:  "bool",
+			targets: []dashboardTarget{
+				{Expr: `deepl_scrape_collector_success{account=~"$account"}`, LegendFormat: "{{account}} {{collector}}"},
+			},
+		},
+		panelSpec{
+			title: "DeepL API errors",
+			kind:  "timeseries",
+			unit:  "reqps",
+			targets: []dashboardTarget{
+				{Expr: `sum by (account, collector) (rate(deepl_api_errors_total{account=~"$account"}[$__rate_interval]))`, LegendFormat: "{{account}} {{collector}}"},
+			},
+		},
+		panelSpec{
+			title: "DeepL API latency (p95)",
+			kind:  "timeseries",
+			unit:  "s",
+			targets: []dashboardTarget{
+				{Expr: `histogram_quantile(0.95, sum by (le, account) (rate(deepl_api_request_duration_seconds_bucket{account=~"$account"}[$__rate_interval])))`, LegendFormat: "{{account}}"},
+			},
+		},
+		panelSpec{
+			title: "Key failover and endpoint mismatch",
+			kind:  "timeseries",
+			unit:  "bool",
+			targets: []dashboardTarget{
+				{Expr: `deepl_api_key_failover{account=~"$account"}`, LegendFormat: "{{account}} failover"},
+				{Expr: `deepl_api_endpoint_mismatch{account=~"$account"}`, LegendFormat: "{{account}} mismatch"},
+			},
+		},
+	)
+}
+
+// newDashboard returns a Grafana dashboard for the given enabled collectors.
+func newDashboard(collectors []string) dashboard {
+	datasource := dashboardDatasource{Type: "prometheus", UID: "${datasource}"}
+
+	d := dashboard{
+		Title:         "DeepL API usage",
+		UID:           "deepl-exporter",
+		Tags:          []string{"deepl"},
+		Timezone:      "browser",
+		SchemaVersion: 39,
+		Refresh:       "5m",
+		Time:          map[string]string{"from": "now-7d", "to": "now"},
+	}
+	d.Templating.List = []dashboardVariable{
+		{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+		{
+			Name:       "account",
+			Label:      "Account",
+			Type:       "query",
+			Query:      "label_values(deepl_scrape_collector_success, account)",
+			Datasource: &datasource,
+			Multi:      true,
+			IncludeAll: true,
+			Refresh:    2,
+		},
+	}
+
+	// Lay the panels out in rows of two.
+	for i, spec := range dashboardPanels(collectors) {
+		panel := dashboardPanel{
+			ID:         i + 1,
+			Title:      spec.title,
+			Type:       spec.kind,
+			Datasource: datasource,
+			GridPos:    dashboardGridPos{H: 8, W: 12, X: (i % 2) * 12, Y: (i / 2) * 8},
+			Targets:    spec.targets,
+		}
+		for j := range panel.Targets {
+			panel.Targets[j].RefID = string(rune('A' + j))
+		}
+		panel.FieldConfig.Defaults.Unit = spec.unit
+		panel.FieldConfig.Defaults.Max = spec.max
+		panel.FieldConfig.Overrides = []any{}
+		d.Panels = append(d.Panels, panel)
+	}
+
+	return d
+}
+
+// generateDashboard prints a Grafana dashboard for the enabled collectors.
+func generateDashboard(args []string, out io.Writer) error {
+	fs := newGenerateFlagSet("dashboard")
+	fs.SetOutput(out)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := settings.resolve(fs, os.Getenv); err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(newDashboard(enabledCollectors()))
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.yaml.in/yaml/v2"
+)
+
+// exportedMetricNames returns the names of all metrics the exporter can
+// export about DeepL, with all collectors enabled.
+func exportedMetricNames(t *testing.T) map[string]bool {
+	t.Helper()
+
+	var all []string
+	for name := range deepl.Collectors() {
+		all = append(all, name)
+	}
+	sort.Strings(all)
+	c, err := deepl.NewDeepLCollector([]*deepl.Client{newTestClient(t, "")}, all)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(ch)
+		deepl.NewRequestDurationHistogram(prometheus.DefBuckets, false).Describe(ch)
+		close(ch)
+	}()
+
+	fqName := regexp.MustCompile(`fqName: "([^"]+)"`)
+	names := make(map[string]bool)
+	for desc := range ch {
+		if m := fqName.FindStringSubmatch(desc.String()); m != nil {
+			names[m[1]] = true
+		}
+	}
+	return names
+}
+
+// checkMetricNames fails if expr references a DeepL metric the exporter does
+// not export.
+func checkMetricNames(t *testing.T, names map[string]bool, expr string) {
+	t.Helper()
+	for _, name := range regexp.MustCompile(`deepl_[a-z_]+`).FindAllString(expr, -1) {
+		base := name
+		for _, suffix := range []string{"_bucket", "_count", "_sum"} {
+			if trimmed := strings.TrimSuffix(name, suffix); names[trimmed] {
+				base = trimmed
+			}
+		}
+		if !names[base] {
+			t.Errorf("%q references unknown metric %s", expr, name)
+		}
+	}
+}
+
+func TestGenerateDashboard(t *testing.T) {
+	names := exportedMetricNames(t)
+
+	defer func() { *collectorState["glossaries"] = false }()
+	var out bytes.Buffer
+	if code := runGenerate([]string{"dashboard", "--collector.glossaries=true"}, &out, &out); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, out.String())
+	}
+
+	var d dashboard
+	if err := json.Unmarshal(out.Bytes(), &d); err != nil {
+		t.Fatalf("invalid dashboard JSON: %v", err)
+	}
+
+	titles := make(map[string]bool)
+	for _, panel := range d.Panels {
+		titles[panel.Title] = true
+		for _, target := range panel.Targets {
+			checkMetricNames(t, names, target.Expr)
+		}
+	}
+	if !titles["Glossaries"] || !titles["Character usage"] {
+		t.Errorf("expected panels of the enabled collectors, got %v", titles)
+	}
+	if titles["Supported languages"] {
+		t.Error("unexpected panel of the disabled languages collector")
+	}
+}
+
+func TestRunGenerate_Unknown(t *testing.T) {
+	var out bytes.Buffer
+	if code := runGenerate([]string{"unknown"}, &out, &out); code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(out.String(), "dashboard") {
+		t.Errorf("expected usage listing the generators, got %q", out.String())
+	}
+}
+
+func TestGenerateAlerts(t *testing.T) {
+	names := exportedMetricNames(t)
+
+	var out bytes.Buffer
+	if code := runGenerate([]string{"alerts", "--threshold", "95,80", "--job", "deepl-prod"}, &out, &out); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, out.String())
+	}
+
+	var rules ruleGroups
+	if err := yaml.UnmarshalStrict(out.Bytes(), &rules); err != nil {
+		t.Fatalf("invalid rules YAML: %v", err)
+	}
+	if len(rules.Groups) != 1 {
+		t.Fatalf("expected 1 group, got %d", len(rules.Groups))
+	}
+
+	alerts := make(map[string]alertRule)
+	for _, rule := range rules.Groups[0].Rules {
+		alerts[rule.Alert] = rule
+		checkMetricNames(t, names, rule.Expr)
+	}
+	if rule := alerts["DeepLUsageAbove80Percent"]; rule.Expr != "deepl_character_usage_percent >= 80 < 95" || rule.Labels["severity"] != "warning" {
+		t.Errorf("unexpected 80%% rule: %+v", rule)
+	}
+	if rule := alerts["DeepLUsageAbove95Percent"]; rule.Expr != "deepl_character_usage_percent >= 95" || rule.Labels["severity"] != "critical" {
+		t.Errorf("unexpected 95%% rule: %+v", rule)
+	}
+	if rule := alerts["DeepLExporterDown"]; rule.Expr != `up{job="deepl-prod"} == 0` {
+		t.Errorf("unexpected exporter down rule: %+v", rule)
+	}
+	for _, name := range []string{"DeepLMetricsStale", "DeepLRefreshStale", "DeepLAPIKeyFailover"} {
+		if _, ok := alerts[name]; !ok {
+			t.Errorf("missing alert %s", name)
+		}
+	}
+}
+
+func TestGenerateAlertsPrometheusRule(t *testing.T) {
+	var out bytes.Buffer
+	if code := runGenerate([]string{"alerts", "--format", "prometheusrule"}, &out, &out); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, out.String())
+	}
+
+	var resource prometheusRule
+	if err := yaml.UnmarshalStrict(out.Bytes(), &resource); err != nil {
+		t.Fatalf("invalid PrometheusRule YAML: %v", err)
+	}
+	if resource.Kind != "PrometheusRule" || len(resource.Spec.Groups) != 1 {
+		t.Errorf("unexpected resource: %+v", resource)
+	}
+}
+
+func TestGenerateAlertsInvalidThreshold(t *testing.T) {
+	for _, threshold := range []string{"abc", "0", "120"} {
+		var out bytes.Buffer
+		if code := runGenerate([]string{"alerts", "--threshold", threshold}, &out, &out); code == 0 {
+			t.Errorf("expected threshold %q to be rejected", threshold)
+		}
+	}
+}
+
+func TestGenerateScrapeConfig(t *testing.T) {
+	var out bytes.Buffer
+	if code := runGenerate([]string{"scrape-config", "--job", "deepl-prod", "--address", "exporter:1818"}, &out, &out); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, out.String())
+	}
+
+	var cfg scrapeConfigs
+	if err := yaml.UnmarshalStrict(out.Bytes(), &cfg); err != nil {
+		t.Fatalf("invalid scrape config YAML: %v", err)
+	}
+	if len(cfg.ScrapeConfigs) != 1 {
+		t.Fatalf("expected 1 scrape config, got %d", len(cfg.ScrapeConfigs))
+	}
+	job := cfg.ScrapeConfigs[0]
+	if job.JobName != "deepl-prod" || job.MetricsPath != "" || job.ScrapeInterval != "5m" {
+		t.Errorf("unexpected scrape config: %+v", job)
+	}
+	if len(job.StaticConfigs) != 1 || strings.Join(job.StaticConfigs[0].Targets, ",") != "exporter:1818" {
+		t.Errorf("unexpected targets: %+v", job.StaticConfigs)
+	}
+}
+
+func TestGenerateScrapeConfigProbe(t *testing.T) {
+	path := writeConfig(t, `
+accounts:
+  - name: team-a
+    api_key: key-a
+  - name: team-b
+    api_key: key-b
+`)
+
+	var out bytes.Buffer
+	args := []string{"scrape-config", "--probe", "--config.file", path, "--module", "glossaries", "--address", "exporter:1818"}
+	defer func() { settings.ConfigFile = "" }()
+	if code := runGenerate(args, &out, &out); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, out.String())
+	}
+
+	var cfg scrapeConfigs
+	if err := yaml.UnmarshalStrict(out.Bytes(), &cfg); err != nil {
+		t.Fatalf("invalid scrape config YAML: %v", err)
+	}
+	job := cfg.ScrapeConfigs[0]
+	if job.MetricsPath != "/probe" || strings.Join(job.Params["module"], ",") != "glossaries" {
+		t.Errorf("unexpected probe config: %+v", job)
+	}
+	if strings.Join(job.StaticConfigs[0].Targets, ",") != "team-a,team-b" {
+		t.Errorf("unexpected targets: %+v", job.StaticConfigs)
+	}
+	last := job.RelabelConfigs[len(job.RelabelConfigs)-1]
+	if last.TargetLabel != "__address__" || last.Replacement != "exporter:1818" {
+		t.Errorf("expected the address to be rewritten to the exporter, got %+v", last)
+	}
+}
+
+func TestGenerateScrapeConfigProbeRequiresConfig(t *testing.T) {
+	var out bytes.Buffer
+	if code := runGenerate([]string{"scrape-config", "--probe"}, &out, &out); code == 0 {
+		t.Error("expected --probe without a config file to fail")
+	}
+}
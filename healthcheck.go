@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// runHealthcheck implements `deepl-exporter healthcheck`, which checks that
+// the exporter running in the same container answers on /healthz, for
+// Docker HEALTHCHECKs in images without curl. It returns the exit code.
+func runHealthcheck(args []string, out io.Writer) int {
+	// The listen address and TLS settings are read from the environment
+	// the exporter was started with.
+	if err := settings.resolve(flag.CommandLine, os.Getenv); err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL %v\n", err)
+		return 2
+	}
+	fs := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	fs.SetOutput(out)
+	url := fs.String("url", defaultHealthcheckURL(), "URL of the health endpoint, derived from the listen address and TLS settings of the exporter if unset.")
+	timeout := fs.Duration("timeout", 5*time.Second, "Time to wait for the response.")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(out, "Usage: deepl-exporter healthcheck [--url URL] [--timeout DURATION]")
+		_, _ = fmt.Fprintln(out, "Exits with 0 if the local exporter is healthy, 1 otherwise.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	client := &http.Client{
+		Timeout: *timeout,
+		Transport: &http.Transport{
+			// The certificate is issued for the public name of the exporter,
+			// not for the loopback address the check connects to.
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec // only checks the local process
+		},
+	}
+	resp, err := client.Get(*url)
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_, _ = fmt.Fprintf(out, "FAIL %s returned status %d\n", *url, resp.StatusCode)
+		return 1
+	}
+	_, _ = fmt.Fprintln(out, "OK")
+	return 0
+}
+
+// defaultHealthcheckURL returns the /healthz URL of the exporter started
+// with the same environment, on its first listen address.
+func defaultHealthcheckURL() string {
+	scheme := "http"
+	if settings.WebTLSCertFile != "" {
+		scheme = "https"
+	}
+	return scheme + "://" + healthcheckHost(listenAddresses(settings.WebListenAddresses.values, settings.WebPort)[0]) + "/healthz"
+}
+
+// healthcheckHost returns the address to connect to for a listen address,
+// the loopback interface if it listens on all interfaces.
+func healthcheckHost(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
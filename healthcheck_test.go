@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunHealthcheck(t *testing.T) {
+	healthy := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" || !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	var out bytes.Buffer
+	if code := runHealthcheck([]string{"--url", ts.URL + "/healthz"}, &out); code != 0 {
+		t.Errorf("expected exit code 0, got %d: %s", code, out.String())
+	}
+
+	healthy = false
+	out.Reset()
+	if code := runHealthcheck([]string{"--url", ts.URL + "/healthz"}, &out); code != 1 || !strings.Contains(out.String(), "status 503") {
+		t.Errorf("expected exit code 1 for status 503, got %d: %s", code, out.String())
+	}
+
+	ts.Close()
+	out.Reset()
+	if code := runHealthcheck([]string{"--url", ts.URL + "/healthz"}, &out); code != 1 || !strings.HasPrefix(out.String(), "FAIL") {
+		t.Errorf("expected exit code 1 without a server, got %d: %s", code, out.String())
+	}
+}
+
+func TestRunHealthcheck_TLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	var out bytes.Buffer
+	if code := runHealthcheck([]string{"--url", ts.URL + "/healthz"}, &out); code != 0 {
+		t.Errorf("expected exit code 0 with a self-signed certificate, got %d: %s", code, out.String())
+	}
+}
+
+func TestHealthcheckHost(t *testing.T) {
+	for address, want := range map[string]string{
+		":1818":          "localhost:1818",
+		"0.0.0.0:1818":   "localhost:1818",
+		"[::]:1818":      "localhost:1818",
+		"127.0.0.1:9000": "127.0.0.1:9000",
+		"[::1]:9000":     "[::1]:9000",
+		"10.0.3.7:1818":  "10.0.3.7:1818",
+	} {
+		if got := healthcheckHost(address); got != want {
+			t.Errorf("healthcheckHost(%q) = %q, want %q", address, got, want)
+		}
+	}
+}
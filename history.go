@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+// HistorySample is the usage of an account at one point in time.
+type HistorySample struct {
+	Time           time.Time `json:"time"`
+	Account        string    `json:"account"`
+	CharacterCount int64     `json:"character_count"`
+	CharacterLimit int64     `json:"character_limit"`
+	// Hourly is set on rollups, which keep the last sample of an hour.
+	Hourly bool `json:"hourly,omitempty"`
+}
+
+// HistoryRetention configures how long the usage history is kept.
+type HistoryRetention struct {
+	// Raw is how long every sample is kept before it is rolled up.
+	Raw time.Duration
+	// Rollup is how long the hourly rollups are kept, 0 keeps them forever.
+	Rollup time.Duration
+}
+
+// apply rolls samples older than the raw retention up into hourly ones and
+// drops rollups older than the rollup retention. Since the character count
+// only grows within a billing period, the last sample of an hour represents
+// it. The result is sorted by time.
+func (r HistoryRetention) apply(samples []HistorySample, now time.Time) []HistorySample {
+	type hour struct {
+		account string
+		start   time.Time
+	}
+	rollups := make(map[hour]int)
+
+	var kept []HistorySample
+	for _, sample := range samples {
+		if r.Rollup > 0 && sample.Time.Before(now.Add(-r.Rollup)) {
+			continue
+		}
+		if !sample.Hourly && !sample.Time.Before(now.Add(-r.Raw)) {
+			kept = append(kept, sample)
+			continue
+		}
+
+		key := hour{account: sample.Account, start: sample.Time.Truncate(time.Hour)}
+		sample.Hourly = true
+		if i, ok := rollups[key]; !ok {
+			rollups[key] = len(kept)
+			kept = append(kept, sample)
+		} else if kept[i].Time.Before(sample.Time) {
+			kept[i] = sample
+		}
+	}
+
+	slices.SortStableFunc(kept, func(a, b HistorySample) int {
+		return a.Time.Compare(b.Time)
+	})
+	return kept
+}
+
+// HistoryStore persists the usage history of the accounts.
+type HistoryStore interface {
+	Append(ctx context.Context, samples []HistorySample) error
+	// Query returns the samples of account, or of all accounts if empty,
+	// taken in [from, to), sorted by time.
+	Query(ctx context.Context, account string, from, to time.Time) ([]HistorySample, error)
+	// Compact applies the retention.
+	Compact(ctx context.Context, retention HistoryRetention, now time.Time) error
+	Close() error
+}
+
+// openHistory opens the history in the file at path or the PostgreSQL
+// database at postgresURL, which are mutually exclusive, and returns where
+// it is stored.
+func openHistory(ctx context.Context, path, postgresURL string) (HistoryStore, string, error) {
+	switch {
+	case path != "" && postgresURL != "":
+		return nil, "", errors.New("--history.path and --history.postgres-url are mutually exclusive")
+	case postgresURL != "":
+		ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+		history, err := openPostgresHistory(ctx, postgresURL)
+		if err != nil {
+			return nil, "", err
+		}
+		return history, "PostgreSQL", nil
+	case path != "":
+		history, err := openFileHistory(path)
+		if err != nil {
+			return nil, "", err
+		}
+		return history, path, nil
+	default:
+		return nil, "", errors.New("no history configured, set --history.path or --history.postgres-url")
+	}
+}
+
+// fileHistory is a HistoryStore in a file of JSON lines. The samples are held
+// in memory, appended to the file as they are recorded, and the file is
+// rewritten on compaction.
+type fileHistory struct {
+	path string
+
+	mu      sync.Mutex
+	file    *os.File
+	samples []HistorySample
+}
+
+// openFileHistory loads the history in path, creating the file if needed.
+func openFileHistory(path string) (*fileHistory, error) {
+	h := &fileHistory{path: path}
+
+	f, err := os.Open(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	default:
+		scanner := bufio.NewScanner(f)
+		for line := 1; scanner.Scan(); line++ {
+			var sample HistorySample
+			if err := json.Unmarshal(scanner.Bytes(), &sample); err != nil {
+				_ = f.Close()
+				return nil, fmt.Errorf("invalid history %s, line %d: %w", path, line, err)
+			}
+			h.samples = append(h.samples, sample)
+		}
+		_ = f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+	}
+
+	if h.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	return h, nil
+}
+
+func (h *fileHistory) Append(_ context.Context, samples []HistorySample) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var buf []byte
+	for _, sample := range samples {
+		data, err := json.Marshal(sample)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+	if _, err := h.file.Write(buf); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	h.samples = append(h.samples, samples...)
+	return nil
+}
+
+func (h *fileHistory) Query(_ context.Context, account string, from, to time.Time) ([]HistorySample, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var samples []HistorySample
+	for _, sample := range h.samples {
+		if (account == "" || sample.Account == account) && !sample.Time.Before(from) && sample.Time.Before(to) {
+			samples = append(samples, sample)
+		}
+	}
+	slices.SortStableFunc(samples, func(a, b HistorySample) int {
+		return a.Time.Compare(b.Time)
+	})
+	return samples, nil
+}
+
+// Compact applies the retention and atomically rewrites the file.
+func (h *fileHistory) Compact(_ context.Context, retention HistoryRetention, now time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	samples := retention.apply(h.samples, now)
+
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), filepath.Base(h.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	w := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(w)
+	for _, sample := range samples {
+		if err := encoder.Encode(sample); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+
+	file, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to reopen history: %w", err)
+	}
+	_ = h.file.Close()
+	h.file = file
+	h.samples = samples
+	return nil
+}
+
+func (h *fileHistory) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.file.Close()
+}
+
+// HistoryRecorderConfig configures a HistoryRecorder.
+type HistoryRecorderConfig struct {
+	Interval        time.Duration
+	CompactInterval time.Duration
+	Retention       HistoryRetention
+}
+
+// HistoryRecorder periodically records the usage of all accounts in a
+// HistoryStore and compacts it.
+type HistoryRecorder struct {
+	store     HistoryStore
+	collector *deepl.DeepLCollector
+	cfg       HistoryRecorderConfig
+	now       func() time.Time
+
+	// mu is held while writing to the store, paused stops the writes, see
+	// pause.
+	mu     sync.Mutex
+	paused bool
+}
+
+func NewHistoryRecorder(store HistoryStore, collector *deepl.DeepLCollector, cfg HistoryRecorderConfig) (*HistoryRecorder, error) {
+	if cfg.Interval <= 0 || cfg.CompactInterval <= 0 {
+		return nil, errors.New("history intervals must be positive")
+	}
+	if cfg.Retention.Raw <= 0 {
+		return nil, fmt.Errorf("invalid raw history retention %s: must be positive", cfg.Retention.Raw)
+	}
+	if cfg.Retention.Rollup != 0 && cfg.Retention.Rollup < cfg.Retention.Raw {
+		return nil, fmt.Errorf("rollup history retention %s must not be shorter than the raw retention %s", cfg.Retention.Rollup, cfg.Retention.Raw)
+	}
+	return &HistoryRecorder{store: store, collector: collector, cfg: cfg, now: time.Now}, nil
+}
+
+// Run records the usage every interval and compacts the history every
+// compact interval until ctx is canceled. With leader election only the
+// leader records, isLeader is nil otherwise.
+func (r *HistoryRecorder) Run(ctx context.Context, isLeader func() bool) {
+	if err := r.compact(ctx); err != nil {
+		log.Printf("Failed to compact usage history: %v", err)
+	}
+
+	record := time.NewTicker(r.cfg.Interval)
+	defer record.Stop()
+	compact := time.NewTicker(r.cfg.CompactInterval)
+	defer compact.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-record.C:
+			if isLeader != nil && !isLeader() {
+				continue
+			}
+			if err := r.Record(ctx); err != nil {
+				log.Printf("Failed to record usage history: %v", err)
+			}
+		case <-compact.C:
+			if err := r.compact(ctx); err != nil {
+				log.Printf("Failed to compact usage history: %v", err)
+			}
+		}
+	}
+}
+
+// Record appends the current usage of every account that could be fetched.
+func (r *HistoryRecorder) Record(ctx context.Context) error {
+	now := r.now().UTC()
+	var samples []HistorySample
+	for _, usage := range usageSnapshot(ctx, r.collector) {
+		if usage.Error != "" {
+			continue
+		}
+		samples = append(samples, HistorySample{
+			Time:           now,
+			Account:        usage.Account,
+			CharacterCount: usage.CharacterCount,
+			CharacterLimit: usage.CharacterLimit,
+		})
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.paused {
+		return nil
+	}
+	return r.store.Append(ctx, samples)
+}
+
+func (r *HistoryRecorder) compact(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.paused {
+		return nil
+	}
+	return r.store.Compact(ctx, r.cfg.Retention, r.now())
+}
+
+// pause waits for a write in progress and skips later ones until resume is
+// called, so a new process taking over on restart is the only writer of the
+// store. It does nothing on a nil recorder.
+func (r *HistoryRecorder) pause() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused = true
+}
+
+// resume undoes pause.
+func (r *HistoryRecorder) resume() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused = false
+}
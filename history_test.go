@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+func TestHistoryRetentionApply(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	at := func(ago time.Duration, count int64) HistorySample {
+		return HistorySample{Time: now.Add(-ago), Account: "team-a", CharacterCount: count, CharacterLimit: 1000}
+	}
+	samples := []HistorySample{
+		at(3*time.Hour+50*time.Minute, 10), // rolled up into 08:00
+		at(3*time.Hour+10*time.Minute, 20), // rolled up into 08:00, last of the hour
+		at(2*time.Hour+30*time.Minute, 30), // rolled up into 09:00
+		at(30*time.Minute, 40),             // raw
+		at(10*time.Minute, 50),             // raw
+		at(48*time.Hour, 5),                // dropped
+	}
+
+	got := HistoryRetention{Raw: time.Hour, Rollup: 24 * time.Hour}.apply(samples, now)
+	want := []HistorySample{
+		{Time: now.Add(-3*time.Hour - 10*time.Minute), Account: "team-a", CharacterCount: 20, CharacterLimit: 1000, Hourly: true},
+		{Time: now.Add(-2*time.Hour - 30*time.Minute), Account: "team-a", CharacterCount: 30, CharacterLimit: 1000, Hourly: true},
+		at(30*time.Minute, 40),
+		at(10*time.Minute, 50),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d samples, got %d: %+v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("sample %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+
+	// Compacting again keeps the rollups as they are.
+	again := HistoryRetention{Raw: time.Hour, Rollup: 24 * time.Hour}.apply(got, now)
+	if len(again) != len(got) {
+		t.Errorf("expected compaction to be idempotent, got %+v", again)
+	}
+}
+
+func TestFileHistory(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	store, err := openFileHistory(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = store.Append(ctx, []HistorySample{
+		{Time: now.Add(-2 * time.Hour), Account: "team-a", CharacterCount: 100},
+		{Time: now.Add(-2 * time.Hour), Account: "team-b", CharacterCount: 200},
+		{Time: now.Add(-time.Minute), Account: "team-a", CharacterCount: 150},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Compact(ctx, HistoryRetention{Raw: time.Hour}, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Append(ctx, []HistorySample{{Time: now, Account: "team-a", CharacterCount: 160}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := openFileHistory(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		_ = reopened.Close()
+	}()
+	samples, err := reopened.Query(ctx, "team-a", now.Add(-24*time.Hour), now.Add(time.Second))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(samples) != 3 || !samples[0].Hourly || samples[1].Hourly || samples[2].CharacterCount != 160 {
+		t.Errorf("unexpected samples: %+v", samples)
+	}
+}
+
+func TestHistoryRecorderRecord(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "DeepL-Auth-Key bad" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		writeJSON(w, http.StatusOK, deepl.DeepLUsage{CharacterCount: 300, CharacterLimit: 1000})
+	}))
+	defer server.Close()
+
+	var clients []*deepl.Client
+	for name, key := range map[string]string{"team-a": "good", "team-b": "bad"} {
+		client, err := deepl.NewClient(deepl.ClientConfig{Name: name, APIKey: key, ServerURL: server.URL})
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		clients = append(clients, client)
+	}
+	collector, err := deepl.NewDeepLCollector(clients, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	store, err := openFileHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		_ = store.Close()
+	}()
+	recorder, err := NewHistoryRecorder(store, collector, HistoryRecorderConfig{
+		Interval:        time.Minute,
+		CompactInterval: time.Hour,
+		Retention:       HistoryRetention{Raw: time.Hour},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	recorder.now = func() time.Time { return now }
+
+	if err := recorder.Record(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	samples, _ := store.Query(context.Background(), "", now, now.Add(time.Second))
+	if len(samples) != 1 || samples[0].Account != "team-a" || samples[0].CharacterCount != 300 {
+		t.Errorf("expected only the usage of team-a, got %+v", samples)
+	}
+
+	// A paused recorder leaves the store to the process taking over.
+	recorder.pause()
+	now = now.Add(time.Minute)
+	if err := recorder.Record(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	recorder.resume()
+	now = now.Add(time.Minute)
+	if err := recorder.Record(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	samples, _ = store.Query(context.Background(), "", now.Add(-2*time.Minute), now.Add(time.Second))
+	if len(samples) != 2 || !samples[1].Time.Equal(now) {
+		t.Errorf("expected no sample while paused, got %+v", samples)
+	}
+}
+
+func TestNewHistoryRecorderValidation(t *testing.T) {
+	_, err := NewHistoryRecorder(nil, nil, HistoryRecorderConfig{
+		Interval:        time.Minute,
+		CompactInterval: time.Hour,
+		Retention:       HistoryRetention{Raw: 48 * time.Hour, Rollup: 24 * time.Hour},
+	})
+	if err == nil {
+		t.Error("expected error for a rollup retention shorter than the raw one")
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+// importBatchSize bounds the samples appended to the history at once.
+const importBatchSize = 1000
+
+// importColumns are the accepted header names of the columns of a usage CSV,
+// compared case-insensitively.
+var importColumns = map[string][]string{
+	"time":    {"date", "day", "time", "timestamp"},
+	"count":   {"characters", "character_count", "character count", "billed characters", "billed_characters"},
+	"limit":   {"character_limit", "character limit", "limit"},
+	"account": {"account"},
+}
+
+// runImportHistory implements `deepl-exporter import`, which loads usage
+// exported as CSV, e.g. from the statements of a DeepL account, into the
+// usage history, so forecasts and reports cover the time before the exporter
+// was deployed. It returns the exit code.
+func runImportHistory(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(out)
+	file := fs.String("file", "", "CSV file to import, - for stdin.")
+	account := fs.String("account", defaultAccountName, "Account of the rows, unless the file has an account column.")
+	limit := fs.Int64("limit", 0, "Character limit of the rows, unless the file has a character_limit column.")
+	cumulative := fs.Bool("cumulative", false, "The characters are the running count of the billing period, not the consumption of every row.")
+	anchor := fs.String("billing-anchor", "", "Start of the billing cycle the consumption is summed over, see --collector.usage.billing-anchor. The 1st of the month if unset.")
+	// The global flags are accepted too, so the history is written to the
+	// same --history.path or --history.postgres-url as the exporter.
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(out, "Usage: deepl-exporter import --file FILE [--account NAME] [flags]")
+		_, _ = fmt.Fprintln(out, "Imports usage from a CSV file with a date and a characters column into the usage history.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := settings.resolve(fs, os.Getenv); err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL %v\n", err)
+		return 2
+	}
+	if *file == "" {
+		_, _ = fmt.Fprintln(out, "FAIL --file is required")
+		return 2
+	}
+	if *anchor == "" {
+		*anchor = settings.UsageBillingAnchor
+	}
+	billingAnchor, err := deepl.ParseBillingAnchor(*anchor)
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL %v\n", err)
+		return 2
+	}
+	if billingAnchor.IsZero() {
+		billingAnchor, _ = deepl.ParseBillingAnchor("1")
+	}
+
+	in := io.Reader(os.Stdin)
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			_, _ = fmt.Fprintf(out, "FAIL %v\n", err)
+			return 1
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		in = f
+	}
+	samples, err := parseUsageCSV(in, usageCSVOptions{
+		Account:       *account,
+		Limit:         *limit,
+		Cumulative:    *cumulative,
+		BillingAnchor: billingAnchor,
+	})
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL invalid CSV %s: %v\n", *file, err)
+		return 1
+	}
+
+	ctx := context.Background()
+	history, location, err := openHistory(ctx, settings.HistoryPath, settings.HistoryPostgresURL)
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL %v\n", err)
+		return 1
+	}
+	defer func() {
+		_ = history.Close()
+	}()
+	imported, skipped, err := importHistory(ctx, history, samples)
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "FAIL imported %d of %d samples: %v\n", imported, len(samples), err)
+		return 1
+	}
+	_, _ = fmt.Fprintf(out, "OK   imported %d samples into %s", imported, location)
+	if skipped > 0 {
+		_, _ = fmt.Fprintf(out, ", skipped %d overlapping the recorded history", skipped)
+	}
+	_, _ = fmt.Fprintln(out)
+	return 0
+}
+
+// usageCSVOptions configures how the rows of a usage CSV become samples.
+type usageCSVOptions struct {
+	// Account and Limit are used for rows without these columns.
+	Account string
+	Limit   int64
+	// Cumulative is set if the characters are the running count of the
+	// billing period. Otherwise they are the consumption of every row and
+	// summed up per billing period, starting at BillingAnchor.
+	Cumulative    bool
+	BillingAnchor time.Time
+}
+
+// parseUsageCSV returns the samples of the rows of a CSV with a header. A
+// row with a date only is the usage at the end of that day in UTC.
+func parseUsageCSV(r io.Reader, opts usageCSVOptions) ([]HistorySample, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for column, names := range importColumns {
+			if _, ok := columns[column]; !ok && slices.Contains(names, name) {
+				columns[column] = i
+			}
+		}
+	}
+	for _, required := range []string{"time", "count"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("no %s column, expected one of %s", required, strings.Join(importColumns[required], ", "))
+		}
+	}
+
+	var samples []HistorySample
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		sample := HistorySample{Account: opts.Account, CharacterLimit: opts.Limit}
+		if sample.Time, err = parseImportTime(field("time")); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if sample.CharacterCount, err = parseImportCount(field("count")); err != nil {
+			return nil, fmt.Errorf("line %d: invalid characters: %w", line, err)
+		}
+		if value := field("limit"); value != "" {
+			if sample.CharacterLimit, err = parseImportCount(value); err != nil {
+				return nil, fmt.Errorf("line %d: invalid character limit: %w", line, err)
+			}
+		}
+		if value := field("account"); value != "" {
+			sample.Account = value
+		}
+		samples = append(samples, sample)
+	}
+
+	slices.SortStableFunc(samples, func(a, b HistorySample) int {
+		return a.Time.Compare(b.Time)
+	})
+	if !opts.Cumulative {
+		accumulateUsage(samples, opts.BillingAnchor)
+	}
+	return samples, nil
+}
+
+// accumulateUsage turns the consumption of every sample, sorted by time,
+// into the running count of its account in the billing period.
+func accumulateUsage(samples []HistorySample, anchor time.Time) {
+	type period struct {
+		reset time.Time
+		count int64
+	}
+	periods := make(map[string]*period)
+	for i, sample := range samples {
+		p, ok := periods[sample.Account]
+		if !ok || !sample.Time.Before(p.reset) {
+			p = &period{reset: deepl.NextBillingReset(anchor, sample.Time)}
+			periods[sample.Account] = p
+		}
+		p.count += sample.CharacterCount
+		samples[i].CharacterCount = p.count
+	}
+}
+
+// parseImportTime parses an RFC 3339 timestamp, Unix seconds or a date,
+// which stands for the end of the day.
+func parseImportTime(value string) (time.Time, error) {
+	if day, err := time.Parse(time.DateOnly, value); err == nil {
+		return day.Add(24*time.Hour - time.Second), nil
+	}
+	return parseTimestamp(value)
+}
+
+// parseImportCount parses a number of characters, which may use thousands
+// separators.
+func parseImportCount(value string) (int64, error) {
+	return strconv.ParseInt(strings.NewReplacer(",", "", "_", "", " ", "").Replace(value), 10, 64)
+}
+
+// importHistory appends the samples that are older than the first sample
+// already recorded for their account, so imports do not overlap the usage
+// recorded by the exporter and can be repeated with a longer file. It
+// returns the numbers of imported and skipped samples.
+func importHistory(ctx context.Context, history HistoryStore, samples []HistorySample) (int, int, error) {
+	recorded, err := history.Query(ctx, "", time.Time{}, time.Now().Add(24*time.Hour))
+	if err != nil {
+		return 0, 0, err
+	}
+	first := make(map[string]time.Time)
+	for _, sample := range recorded {
+		if t, ok := first[sample.Account]; !ok || sample.Time.Before(t) {
+			first[sample.Account] = sample.Time
+		}
+	}
+
+	var kept []HistorySample
+	for _, sample := range samples {
+		if t, ok := first[sample.Account]; ok && !sample.Time.Before(t) {
+			continue
+		}
+		kept = append(kept, sample)
+	}
+	imported := 0
+	for batch := range slices.Chunk(kept, importBatchSize) {
+		if err := history.Append(ctx, batch); err != nil {
+			return imported, len(samples) - len(kept), err
+		}
+		imported += len(batch)
+	}
+	return imported, len(samples) - len(kept), nil
+}
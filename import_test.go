@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseUsageCSV(t *testing.T) {
+	anchor := time.Date(2000, time.January, 15, 0, 0, 0, 0, time.UTC)
+	csv := "\ufeffDate,Billed Characters\n" +
+		"2026-09-14,\"1,000\"\n" +
+		"2026-09-13,500\n" +
+		"2026-09-15,200\n" +
+		"2026-09-16,300\n"
+
+	samples, err := parseUsageCSV(strings.NewReader(csv), usageCSVOptions{Account: "team-a", Limit: 5000, BillingAnchor: anchor})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	endOfDay := func(day int) time.Time {
+		return time.Date(2026, 9, day, 23, 59, 59, 0, time.UTC)
+	}
+	want := []HistorySample{
+		{Time: endOfDay(13), Account: "team-a", CharacterCount: 500, CharacterLimit: 5000},
+		{Time: endOfDay(14), Account: "team-a", CharacterCount: 1500, CharacterLimit: 5000},
+		// The billing period restarts on the 15th.
+		{Time: endOfDay(15), Account: "team-a", CharacterCount: 200, CharacterLimit: 5000},
+		{Time: endOfDay(16), Account: "team-a", CharacterCount: 500, CharacterLimit: 5000},
+	}
+	if len(samples) != len(want) {
+		t.Fatalf("expected %d samples, got %+v", len(want), samples)
+	}
+	for i := range want {
+		if samples[i] != want[i] {
+			t.Errorf("sample %d: expected %+v, got %+v", i, want[i], samples[i])
+		}
+	}
+}
+
+func TestParseUsageCSVCumulative(t *testing.T) {
+	csv := "timestamp,account,characters,character_limit\n" +
+		"2026-10-01T12:00:00Z,team-a,100,1000\n" +
+		"2026-10-01T12:00:00Z,team-b,40,\n" +
+		"2026-10-02T12:00:00Z,team-a,250,1000\n"
+
+	samples, err := parseUsageCSV(strings.NewReader(csv), usageCSVOptions{Account: "default", Limit: 500, Cumulative: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(samples) != 3 {
+		t.Fatalf("expected 3 samples, got %+v", samples)
+	}
+	if samples[1].Account != "team-b" || samples[1].CharacterCount != 40 || samples[1].CharacterLimit != 500 {
+		t.Errorf("unexpected sample of team-b: %+v", samples[1])
+	}
+	if samples[2].CharacterCount != 250 {
+		t.Errorf("expected the count to be kept, got %+v", samples[2])
+	}
+}
+
+func TestParseUsageCSVErrors(t *testing.T) {
+	for name, csv := range map[string]string{
+		"no count column": "date,amount\n2026-10-01,100\n",
+		"invalid date":    "date,characters\nyesterday,100\n",
+		"invalid count":   "date,characters\n2026-10-01,many\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseUsageCSV(strings.NewReader(csv), usageCSVOptions{}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestImportHistory(t *testing.T) {
+	ctx := context.Background()
+	store, err := openFileHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		_ = store.Close()
+	}()
+	deployed := time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC)
+	if err := store.Append(ctx, []HistorySample{{Time: deployed, Account: "team-a", CharacterCount: 900}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	samples := []HistorySample{
+		{Time: deployed.Add(-48 * time.Hour), Account: "team-a", CharacterCount: 700},
+		{Time: deployed.Add(-24 * time.Hour), Account: "team-a", CharacterCount: 800},
+		{Time: deployed.Add(24 * time.Hour), Account: "team-a", CharacterCount: 1000},
+		{Time: deployed.Add(24 * time.Hour), Account: "team-b", CharacterCount: 50},
+	}
+	imported, skipped, err := importHistory(ctx, store, samples)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if imported != 3 || skipped != 1 {
+		t.Errorf("expected 3 imported and 1 skipped, got %d and %d", imported, skipped)
+	}
+
+	// Importing the same samples again adds nothing.
+	imported, skipped, err = importHistory(ctx, store, samples)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if imported != 0 || skipped != 4 {
+		t.Errorf("expected the import to be idempotent, got %d imported and %d skipped", imported, skipped)
+	}
+
+	recorded, err := store.Query(ctx, "team-a", time.Time{}, deployed.Add(48*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recorded) != 3 || recorded[0].CharacterCount != 700 || recorded[2].CharacterCount != 900 {
+		t.Errorf("unexpected history: %+v", recorded)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+// KeyRequest adds an account at runtime, see keyManager.
+type KeyRequest struct {
+	Name         string `json:"name"`
+	APIKey       string `json:"api_key"`
+	BackupAPIKey string `json:"backup_api_key,omitempty"`
+	// APIType overrides --deepl.api-type for the account.
+	APIType string `json:"api_type,omitempty"`
+}
+
+// errKeyConflict is returned by keyManager when the account to add exists
+// or the one to remove is the last one.
+var errKeyConflict = errors.New("conflict")
+
+// keyManager adds and removes accounts at runtime, for the key management
+// API and page. Changes are not written back to the configuration file, so
+// they last until the exporter restarts.
+type keyManager struct {
+	collector *deepl.DeepLCollector
+	// poller, if set, polls the added accounts.
+	poller *Poller
+	// defaults configures the clients of the added accounts like the
+	// flags do for the configured ones.
+	defaults deepl.ClientConfig
+}
+
+// add creates the client of the account and starts exporting its metrics.
+func (m *keyManager) add(req KeyRequest) (*deepl.Client, error) {
+	if req.Name == "" || req.APIKey == "" {
+		return nil, errors.New("name and api_key are required")
+	}
+	account := AccountConfig{Name: req.Name, APIKey: req.APIKey, BackupAPIKey: req.BackupAPIKey, APIType: req.APIType}
+	cfg, err := account.clientConfig(m.defaults)
+	if err != nil {
+		return nil, err
+	}
+	client, err := deepl.NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("account %q: %w", req.Name, err)
+	}
+	if err := m.collector.AddClient(client); err != nil {
+		return nil, fmt.Errorf("%w: %w", errKeyConflict, err)
+	}
+	if m.poller != nil {
+		m.poller.Add(client)
+	}
+	log.Printf("Added account %s with key %s at runtime", client.Name(), client.MaskedAPIKey())
+	return client, nil
+}
+
+// remove stops exporting the metrics of the account with the given name.
+// It returns false if there is no such account.
+func (m *keyManager) remove(name string) (bool, error) {
+	if m.collector.Client(name) == nil {
+		return false, nil
+	}
+	if _, err := m.collector.RemoveClient(name); err != nil {
+		return true, fmt.Errorf("%w: %w", errKeyConflict, err)
+	}
+	if m.poller != nil {
+		m.poller.Remove(name)
+	}
+	log.Printf("Removed account %s at runtime", name)
+	return true, nil
+}
+
+// keyErrorStatus returns the HTTP status of an error of keyManager.
+func keyErrorStatus(err error) int {
+	if errors.Is(err, errKeyConflict) {
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// keysPage is the data of the keys page.
+type keysPage struct {
+	Generated time.Time
+	Polling   bool
+	Accounts  []deepl.AccountStatus
+	Error     string
+}
+
+var keysTemplate = template.Must(template.New("keys").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>DeepL API keys</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.4em 0.8em; text-align: left; }
+.healthy { color: #1a7f37; }
+.unhealthy { color: #cf222e; }
+.error { color: #cf222e; font-weight: bold; }
+form.inline { display: inline; }
+</style>
+</head>
+<body>
+<h1>DeepL API keys</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}. Keys added or removed here last until the exporter restarts, keep the configuration in sync.</p>
+{{- if .Error}}
+<p class="error">{{.Error}}</p>
+{{- end}}
+<table>
+<tr><th>Account</th><th>Key</th><th>Backup key</th><th>Endpoint</th><th>Health</th>{{if .Polling}}<th>Refreshed</th>{{end}}<th></th></tr>
+{{- range .Accounts}}
+<tr><td>{{.Account}}</td><td><code>{{.APIKey}}</code></td><td>{{if .Failover}}in use{{else if .BackupAPIKey}}configured{{else}}-{{end}}</td><td>{{.Endpoint}}{{if .EndpointMismatch}} (detected endpoint rejected the key){{end}}</td><td>{{if .Healthy}}<span class="healthy">healthy</span>{{else}}<span class="unhealthy">unhealthy</span>{{end}}</td>{{if $.Polling}}<td>{{if .Refreshed.IsZero}}never{{else}}{{.Refreshed.Format "2006-01-02 15:04:05 MST"}}{{end}}</td>{{end}}<td><form class="inline" method="post" action="/admin/keys/remove"><input type="hidden" name="name" value="{{.Account}}"><button type="submit">Remove</button></form></td></tr>
+{{- end}}
+</table>
+<h2>Add a key</h2>
+<form method="post" action="/admin/keys/add">
+<label>Account <input name="name" required></label>
+<label>Key <input name="api_key" type="password" autocomplete="off" required></label>
+<label>Backup key <input name="backup_api_key" type="password" autocomplete="off"></label>
+<label>API type <select name="api_type"><option value="">default</option><option value="free">free</option><option value="pro">pro</option></select></label>
+<button type="submit">Add</button>
+</form>
+{{- range .Accounts}}
+<h2>{{.Account}}</h2>
+<table>
+<tr><th>Collector</th><th>Last success</th><th>Last failure</th><th>Last error</th></tr>
+{{- range .Collectors}}
+<tr class="{{if .Healthy}}healthy{{else}}unhealthy{{end}}"><td>{{.Name}}</td><td>{{if .LastSuccess.IsZero}}never{{else}}{{.LastSuccess.Format "2006-01-02 15:04:05 MST"}}{{end}}</td><td>{{if .LastFailure.IsZero}}never{{else}}{{.LastFailure.Format "2006-01-02 15:04:05 MST"}}{{end}}</td><td>{{.LastError}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`))
+
+// keysHandler serves an overview of the API key of every account, masked,
+// and whether it is healthy, with forms adding and removing keys through
+// keyFormHandler, for operators who prefer a browser over curl.
+func keysHandler(collector *deepl.DeepLCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		renderKeysPage(w, http.StatusOK, collector, "")
+	})
+}
+
+func renderKeysPage(w http.ResponseWriter, status int, collector *deepl.DeepLCollector, errMsg string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = keysTemplate.Execute(w, keysPage{
+		Generated: time.Now().UTC(),
+		Polling:   collector.Polling(),
+		Accounts:  collector.Status(),
+		Error:     errMsg,
+	})
+}
+
+// keyFormHandler handles the forms of the keys page, POST /admin/keys/add
+// with the fields of a KeyRequest or POST /admin/keys/remove with the name,
+// and redirects back to the page. Browsers send the basic auth credentials
+// along with forms posted by any site, so only the page itself may post
+// them.
+func keyFormHandler(m *keyManager, remove bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if crossOrigin(r) {
+			http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, 1<<16)
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid form: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var err error
+		if remove {
+			var found bool
+			if found, err = m.remove(r.PostForm.Get("name")); err == nil && !found {
+				renderKeysPage(w, http.StatusNotFound, m.collector, fmt.Sprintf("unknown account %q", r.PostForm.Get("name")))
+				return
+			}
+		} else {
+			_, err = m.add(KeyRequest{
+				Name:         r.PostForm.Get("name"),
+				APIKey:       r.PostForm.Get("api_key"),
+				BackupAPIKey: r.PostForm.Get("backup_api_key"),
+				APIType:      r.PostForm.Get("api_type"),
+			})
+		}
+		if err != nil {
+			renderKeysPage(w, keyErrorStatus(err), m.collector, err.Error())
+			return
+		}
+		http.Redirect(w, r, "/admin/keys", http.StatusSeeOther)
+	})
+}
+
+// keysAPIHandler serves the key management API: GET /api/v1/keys lists the
+// accounts like the keys page, POST /api/v1/keys adds the account of a
+// KeyRequest and DELETE /api/v1/keys/{name} removes one.
+func keysAPIHandler(m *keyManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		switch {
+		case name == "" && r.Method == http.MethodGet:
+			writeJSON(w, http.StatusOK, map[string][]deepl.AccountStatus{"accounts": m.collector.Status()})
+		case name == "" && r.Method == http.MethodPost:
+			if !acceptJSON(w, r) {
+				return
+			}
+			var req KeyRequest
+			decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&req); err != nil {
+				http.Error(w, "invalid key: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			client, err := m.add(req)
+			if err != nil {
+				http.Error(w, err.Error(), keyErrorStatus(err))
+				return
+			}
+			writeJSON(w, http.StatusCreated, map[string]string{"account": client.Name(), "api_key": client.MaskedAPIKey()})
+		case name != "" && r.Method == http.MethodDelete:
+			found, err := m.remove(name)
+			if err != nil {
+				http.Error(w, err.Error(), keyErrorStatus(err))
+				return
+			}
+			if !found {
+				http.Error(w, fmt.Sprintf("unknown account %q", name), http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case name == "":
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		default:
+			w.Header().Set("Allow", http.MethodDelete)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
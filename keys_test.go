@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+func TestKeysHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == deepl.GlossariesPath {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, http.StatusOK, deepl.DeepLUsage{CharacterCount: 1000, CharacterLimit: 10000})
+	}))
+	defer server.Close()
+
+	client, err := deepl.NewClient(deepl.ClientConfig{Name: "team-a", APIKey: "0123456789abcdef:fx", ServerURL: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	collector, err := deepl.NewDeepLCollector([]*deepl.Client{client}, []string{"glossaries", "usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	collector.EnablePolling()
+	collector.Refresh(t.Context(), client)
+	handler := keysHandler(collector)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/keys", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for PUT, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/keys", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, "0123456789abcdef") {
+		t.Error("expected the key to be masked")
+	}
+	for _, want := range []string{"<td>team-a</td>", "<code>...f:fx</code>", "unhealthy", "<td>glossaries</td>", "503", `action="/admin/keys/add"`, `name="name" value="team-a"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the page to contain %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestKeysAPIHandler(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		writeJSON(w, http.StatusOK, deepl.DeepLUsage{CharacterCount: 1000, CharacterLimit: 10000})
+	}))
+	defer server.Close()
+
+	collector, err := deepl.NewDeepLCollector([]*deepl.Client{newTestClient(t, server.URL)}, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	poller := NewPoller(collector, PollerConfig{Interval: time.Hour})
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go poller.Run(ctx)
+
+	keys := &keyManager{collector: collector, poller: poller, defaults: deepl.ClientConfig{ServerURL: server.URL}}
+	mux := http.NewServeMux()
+	mux.Handle("/api/v1/keys", keysAPIHandler(keys))
+	mux.Handle("/api/v1/keys/{name}", keysAPIHandler(keys))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, r)
+		return rec
+	}
+
+	// A form posted by another site can carry a JSON body as text/plain.
+	for header, code := range map[[2]string]int{
+		{"Content-Type", "text/plain"}:         http.StatusUnsupportedMediaType,
+		{"Origin", "https://evil.example.com"}: http.StatusForbidden,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/keys", strings.NewReader(`{"name": "team-b", "api_key": "0123456789abcdef:fx"}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(header[0], header[1])
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, r)
+		if rec.Code != code || collector.Client("team-b") != nil {
+			t.Errorf("%s %s: expected status %d, got %d", header[0], header[1], code, rec.Code)
+		}
+	}
+
+	rec := do(http.MethodPost, "/api/v1/keys", `{"name": "team-b", "api_key": "0123456789abcdef:fx"}`)
+	if rec.Code != http.StatusCreated || strings.Contains(rec.Body.String(), "0123456789abcdef") {
+		t.Fatalf("expected the key to be added, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if collector.Client("team-b") == nil {
+		t.Fatal("expected the account to be exported")
+	}
+	// The poller picks up the new account right away.
+	deadline := time.Now().Add(5 * time.Second)
+	for requests.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the new account to be polled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, tt := range []struct {
+		method, path, body string
+		code               int
+	}{
+		{http.MethodPost, "/api/v1/keys", `{"name": "team-b", "api_key": "other:fx"}`, http.StatusConflict},
+		{http.MethodPost, "/api/v1/keys", `{"name": "team-c"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/keys", `{"name": "team-c", "api_key": "key", "unknown": true}`, http.StatusBadRequest},
+		{http.MethodDelete, "/api/v1/keys/unknown", "", http.StatusNotFound},
+		{http.MethodPut, "/api/v1/keys/team-b", "", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/api/v1/keys/team-b", "", http.StatusNoContent},
+		{http.MethodDelete, "/api/v1/keys/default", "", http.StatusConflict},
+	} {
+		if rec := do(tt.method, tt.path, tt.body); rec.Code != tt.code {
+			t.Errorf("%s %s %s: expected status %d, got %d: %s", tt.method, tt.path, tt.body, tt.code, rec.Code, rec.Body.String())
+		}
+	}
+	if collector.Client("team-b") != nil {
+		t.Error("expected the account to be removed")
+	}
+
+	rec = do(http.MethodGet, "/api/v1/keys", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"account":"default"`) || strings.Contains(rec.Body.String(), "team-b") {
+		t.Errorf("unexpected list of keys %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestKeyFormHandler(t *testing.T) {
+	collector, err := deepl.NewDeepLCollector([]*deepl.Client{newTestClient(t, "")}, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keys := &keyManager{collector: collector}
+	post := func(handler http.Handler, form url.Values, origin string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "http://exporter:1818/admin/keys/add", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	form := url.Values{"name": {"team-b"}, "api_key": {"key:fx"}}
+	if rec := post(keyFormHandler(keys, false), form, "https://evil.example.com"); rec.Code != http.StatusForbidden || collector.Client("team-b") != nil {
+		t.Errorf("expected a cross-origin form to be rejected, got %d", rec.Code)
+	}
+	rec := post(keyFormHandler(keys, false), form, "http://exporter:1818")
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/admin/keys" || collector.Client("team-b") == nil {
+		t.Errorf("expected the key to be added, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = post(keyFormHandler(keys, false), form, "")
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "already exists") {
+		t.Errorf("expected the page with the error, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := post(keyFormHandler(keys, true), url.Values{"name": {"unknown"}}, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown account, got %d", rec.Code)
+	}
+	rec = post(keyFormHandler(keys, true), url.Values{"name": {"team-b"}}, "")
+	if rec.Code != http.StatusSeeOther || collector.Client("team-b") != nil {
+		t.Errorf("expected the key to be removed, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	defaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+
+	// leaseTimeFormat is the MicroTime format used by the Kubernetes API.
+	leaseTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// LeaseConfig configures a LeaseElector.
+type LeaseConfig struct {
+	// APIServer is the base URL of the Kubernetes API server and Token the
+	// bearer token used to authenticate against it.
+	APIServer string
+	Token     string
+	// TokenFile, if set, holds the token and is read again whenever it
+	// changes or on a reload signal (SIGHUP), as the kubelet rotates the
+	// projected service account token.
+	TokenFile  string
+	HTTPClient *http.Client
+
+	Namespace string
+	Name      string
+	// Identity must be unique among the replicas, e.g. the pod name.
+	Identity string
+
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// LeaseElector elects a single leader among exporter replicas through a
+// Kubernetes coordination.k8s.io/v1 Lease, following the same protocol as
+// client-go's leader election.
+type LeaseElector struct {
+	cfg       LeaseConfig
+	leader    atomic.Bool
+	now       func() time.Time
+	mu        sync.Mutex
+	lastRenew time.Time
+
+	// observed is the lease as last seen changed, at observedTime by the
+	// local clock, so that clock skew between the replicas does not matter.
+	observed     leaseSpec
+	observedTime time.Time
+
+	tokenMu      sync.Mutex
+	token        string
+	tokenModTime time.Time
+	tokenErr     string
+}
+
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions"`
+}
+
+// inClusterLeaseConfig returns a LeaseConfig using the service account of
+// the pod. namespace defaults to the namespace of the pod.
+func inClusterLeaseConfig(namespace, name, identity string) (LeaseConfig, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return LeaseConfig{}, errors.New("leader election requires running in Kubernetes: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+
+	tokenFile := serviceAccountDir + "/token"
+	token, err := readTokenFile(tokenFile)
+	if err != nil {
+		return LeaseConfig{}, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	caPEM, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return LeaseConfig{}, fmt.Errorf("failed to read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return LeaseConfig{}, errors.New("no certificates found in service account CA")
+	}
+
+	if namespace == "" {
+		ns, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return LeaseConfig{}, fmt.Errorf("failed to read service account namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+
+	if identity == "" {
+		if identity, err = os.Hostname(); err != nil {
+			return LeaseConfig{}, fmt.Errorf("failed to determine identity: %w", err)
+		}
+	}
+
+	return LeaseConfig{
+		APIServer: "https://" + net.JoinHostPort(host, port),
+		Token:     token,
+		TokenFile: tokenFile,
+		HTTPClient: &http.Client{
+			Timeout: defaultTimeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool},
+			},
+		},
+		Namespace: namespace,
+		Name:      name,
+		Identity:  identity,
+	}, nil
+}
+
+func NewLeaseElector(cfg LeaseConfig) *LeaseElector {
+	if cfg.LeaseDuration <= 0 {
+		cfg.LeaseDuration = defaultLeaseDuration
+	}
+	if cfg.RenewDeadline <= 0 {
+		cfg.RenewDeadline = defaultRenewDeadline
+	}
+	if cfg.RetryPeriod <= 0 {
+		cfg.RetryPeriod = defaultRetryPeriod
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: defaultTimeout}
+	}
+	return &LeaseElector{cfg: cfg, now: time.Now, token: cfg.Token}
+}
+
+// IsLeader reports whether this replica currently holds the lease.
+func (e *LeaseElector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run tries to acquire or renew the lease every retry period until ctx is
+// canceled, then releases it if held. The token file is read again on every
+// reload signal (SIGHUP).
+func (e *LeaseElector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.RetryPeriod)
+	defer ticker.Stop()
+	signals := reloadSignals()
+	defer stopReloadSignals(signals)
+
+	e.tryAcquireOrRenew(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			e.release()
+			return
+		case <-ticker.C:
+			e.tryAcquireOrRenew(ctx)
+		case <-signals:
+			if e.cfg.TokenFile != "" {
+				e.reloadToken(true)
+			}
+		}
+	}
+}
+
+// bearerToken returns the token to authenticate with, read again from the
+// token file if it changed since.
+func (e *LeaseElector) bearerToken() string {
+	if e.cfg.TokenFile != "" {
+		e.reloadToken(false)
+	}
+	e.tokenMu.Lock()
+	defer e.tokenMu.Unlock()
+	return e.token
+}
+
+// reloadToken reads the token file again if it was modified since the last
+// read, or unconditionally if force is set. On failure, e.g. while the file
+// is being replaced, the previous token is kept and the error logged once.
+func (e *LeaseElector) reloadToken(force bool) {
+	e.tokenMu.Lock()
+	defer e.tokenMu.Unlock()
+
+	info, err := os.Stat(e.cfg.TokenFile)
+	if err == nil && !force && info.ModTime().Equal(e.tokenModTime) {
+		return
+	}
+	var token string
+	if err == nil {
+		token, err = readTokenFile(e.cfg.TokenFile)
+	}
+	if err != nil {
+		if err.Error() != e.tokenErr || force {
+			log.Printf("Leader election: keeping the previous token: %v", err)
+		}
+		e.tokenErr = err.Error()
+		return
+	}
+	if token != e.token {
+		log.Printf("Leader election: reloaded the token from %s", e.cfg.TokenFile)
+	}
+	e.token, e.tokenModTime, e.tokenErr = token, info.ModTime(), ""
+}
+
+func (e *LeaseElector) tryAcquireOrRenew(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, e.cfg.RenewDeadline)
+	defer cancel()
+
+	leader, err := e.acquireOrRenew(ctx)
+	if err != nil {
+		log.Printf("Leader election: %v", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if leader {
+		e.lastRenew = e.now()
+	} else if e.leader.Load() && err != nil && e.now().Sub(e.lastRenew) < e.cfg.RenewDeadline {
+		// Keep leading through transient API errors until the renew
+		// deadline passes, like client-go does.
+		return
+	}
+
+	if e.leader.Swap(leader) != leader {
+		if leader {
+			log.Printf("Leader election: %s became the leader of lease %s/%s", e.cfg.Identity, e.cfg.Namespace, e.cfg.Name)
+		} else {
+			log.Printf("Leader election: %s is no longer the leader of lease %s/%s", e.cfg.Identity, e.cfg.Namespace, e.cfg.Name)
+		}
+	}
+}
+
+// acquireOrRenew performs one round of the election and reports whether
+// this replica holds the lease afterwards.
+func (e *LeaseElector) acquireOrRenew(ctx context.Context) (bool, error) {
+	now := e.now()
+	current, err := e.getLease(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if current == nil {
+		e.observed, e.observedTime = leaseSpec{}, now
+		l := e.newLease(now)
+		l.Spec.AcquireTime = now.UTC().Format(leaseTimeFormat)
+		return e.writeLease(ctx, http.MethodPost, l)
+	}
+
+	spec := current.Spec
+	if spec != e.observed {
+		e.observed, e.observedTime = spec, now
+	}
+	if spec.HolderIdentity != e.cfg.Identity && spec.HolderIdentity != "" && !e.expired(spec, now) {
+		return false, nil
+	}
+
+	l := e.newLease(now)
+	l.Metadata.ResourceVersion = current.Metadata.ResourceVersion
+	l.Spec.LeaseTransitions = spec.LeaseTransitions
+	if spec.HolderIdentity == e.cfg.Identity {
+		l.Spec.AcquireTime = spec.AcquireTime
+	} else {
+		l.Spec.AcquireTime = now.UTC().Format(leaseTimeFormat)
+		l.Spec.LeaseTransitions++
+	}
+	return e.writeLease(ctx, http.MethodPut, l)
+}
+
+// expired reports whether the holder of the lease failed to renew it in time.
+// Like client-go, the lease duration is measured from when this replica last
+// saw the lease change rather than from its renewTime, which was taken from
+// the clock of the holder.
+func (e *LeaseElector) expired(spec leaseSpec, now time.Time) bool {
+	return e.observedTime.Add(time.Duration(spec.LeaseDurationSeconds) * time.Second).Before(now)
+}
+
+// release gives up the lease so another replica can take over immediately.
+func (e *LeaseElector) release() {
+	if !e.leader.Swap(false) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.cfg.RenewDeadline)
+	defer cancel()
+
+	current, err := e.getLease(ctx)
+	if err != nil || current == nil || current.Spec.HolderIdentity != e.cfg.Identity {
+		return
+	}
+
+	l := e.newLease(e.now())
+	l.Metadata.ResourceVersion = current.Metadata.ResourceVersion
+	l.Spec.HolderIdentity = ""
+	l.Spec.LeaseDurationSeconds = 1
+	l.Spec.LeaseTransitions = current.Spec.LeaseTransitions
+	if _, err := e.writeLease(ctx, http.MethodPut, l); err != nil {
+		log.Printf("Leader election: failed to release lease: %v", err)
+		return
+	}
+	log.Printf("Leader election: released lease %s/%s", e.cfg.Namespace, e.cfg.Name)
+}
+
+func (e *LeaseElector) newLease(now time.Time) *lease {
+	return &lease{
+		APIVersion: "coordination.k8s.io/v1",
+		Kind:       "Lease",
+		Metadata: leaseMetadata{
+			Name:      e.cfg.Name,
+			Namespace: e.cfg.Namespace,
+		},
+		Spec: leaseSpec{
+			HolderIdentity:       e.cfg.Identity,
+			LeaseDurationSeconds: int(e.cfg.LeaseDuration.Seconds()),
+			RenewTime:            now.UTC().Format(leaseTimeFormat),
+		},
+	}
+}
+
+func (e *LeaseElector) leasesURL() string {
+	return fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", e.cfg.APIServer, e.cfg.Namespace)
+}
+
+// getLease returns the current lease, or nil if it does not exist yet.
+func (e *LeaseElector) getLease(ctx context.Context) (*lease, error) {
+	status, body, err := e.do(ctx, http.MethodGet, e.leasesURL()+"/"+e.cfg.Name, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case http.StatusOK:
+		var l lease
+		if err := json.Unmarshal(body, &l); err != nil {
+			return nil, fmt.Errorf("failed to parse lease: %w", err)
+		}
+		return &l, nil
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("failed to get lease: status %d: %s", status, body)
+	}
+}
+
+// writeLease creates (POST) or updates (PUT) the lease. A conflict means
+// another replica won the race and is not an error.
+func (e *LeaseElector) writeLease(ctx context.Context, method string, l *lease) (bool, error) {
+	url := e.leasesURL()
+	if method == http.MethodPut {
+		url += "/" + e.cfg.Name
+	}
+
+	payload, err := json.Marshal(l)
+	if err != nil {
+		return false, err
+	}
+
+	status, body, err := e.do(ctx, method, url, payload)
+	if err != nil {
+		return false, err
+	}
+	switch status {
+	case http.StatusOK, http.StatusCreated:
+		return l.Spec.HolderIdentity == e.cfg.Identity, nil
+	case http.StatusConflict:
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to write lease: status %d: %s", status, body)
+	}
+}
+
+func (e *LeaseElector) do(ctx context.Context, method, url string, payload []byte) (int, []byte, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token := e.bearerToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := e.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("request to Kubernetes API failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read Kubernetes API response: %w", err)
+	}
+	return resp.StatusCode, body, nil
+}
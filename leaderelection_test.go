@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLeaseAPI serves the coordination.k8s.io/v1 Lease endpoints of a
+// Kubernetes API server with optimistic concurrency on resourceVersion.
+type fakeLeaseAPI struct {
+	mu      sync.Mutex
+	lease   *lease
+	version int
+}
+
+func (f *fakeLeaseAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	const base = "/apis/coordination.k8s.io/v1/namespaces/monitoring/leases"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == base+"/deepl-exporter":
+		if f.lease == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f.lease)
+	case r.Method == http.MethodPost && r.URL.Path == base:
+		if f.lease != nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.store(w, r, http.StatusCreated)
+	case r.Method == http.MethodPut && r.URL.Path == base+"/deepl-exporter":
+		var l lease
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &l)
+		if f.lease == nil || l.Metadata.ResourceVersion != f.lease.Metadata.ResourceVersion {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.save(w, &l, http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeLeaseAPI) store(w http.ResponseWriter, r *http.Request, status int) {
+	var l lease
+	body, _ := io.ReadAll(r.Body)
+	_ = json.Unmarshal(body, &l)
+	f.save(w, &l, status)
+}
+
+func (f *fakeLeaseAPI) save(w http.ResponseWriter, l *lease, status int) {
+	f.version++
+	l.Metadata.ResourceVersion = strconv.Itoa(f.version)
+	f.lease = l
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(l)
+}
+
+func (f *fakeLeaseAPI) holder() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.lease == nil {
+		return ""
+	}
+	return f.lease.Spec.HolderIdentity
+}
+
+func newTestElector(serverURL, identity string) *LeaseElector {
+	return NewLeaseElector(LeaseConfig{
+		APIServer: serverURL,
+		Namespace: "monitoring",
+		Name:      "deepl-exporter",
+		Identity:  identity,
+	})
+}
+
+func TestLeaseElectorSingleLeader(t *testing.T) {
+	api := &fakeLeaseAPI{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	a := newTestElector(server.URL, "pod-a")
+	b := newTestElector(server.URL, "pod-b")
+
+	a.tryAcquireOrRenew(context.Background())
+	b.tryAcquireOrRenew(context.Background())
+
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("expected only pod-a to lead, got a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+	if got := api.holder(); got != "pod-a" {
+		t.Errorf("expected lease holder pod-a, got %q", got)
+	}
+
+	// Renewing keeps the lease.
+	a.tryAcquireOrRenew(context.Background())
+	if !a.IsLeader() {
+		t.Error("expected pod-a to keep the lease after renewing")
+	}
+}
+
+func TestLeaseElectorTakesOverExpiredLease(t *testing.T) {
+	api := &fakeLeaseAPI{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	a := newTestElector(server.URL, "pod-a")
+	b := newTestElector(server.URL, "pod-b")
+
+	a.tryAcquireOrRenew(context.Background())
+	b.tryAcquireOrRenew(context.Background())
+	if b.IsLeader() {
+		t.Fatal("expected pod-b not to take over a lease that was just renewed")
+	}
+	b.now = func() time.Time { return time.Now().Add(time.Minute) }
+	b.tryAcquireOrRenew(context.Background())
+
+	if !b.IsLeader() {
+		t.Fatal("expected pod-b to take over the expired lease")
+	}
+	if api.lease.Spec.LeaseTransitions != 1 {
+		t.Errorf("expected 1 lease transition, got %d", api.lease.Spec.LeaseTransitions)
+	}
+
+	a.tryAcquireOrRenew(context.Background())
+	if a.IsLeader() {
+		t.Error("expected pod-a to lose the lease")
+	}
+}
+
+func TestLeaseElectorIgnoresClockSkew(t *testing.T) {
+	api := &fakeLeaseAPI{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	// The clock of pod-a is an hour behind, so every renewTime it writes
+	// looks expired to pod-b.
+	a := newTestElector(server.URL, "pod-a")
+	a.now = func() time.Time { return time.Now().Add(-time.Hour) }
+	b := newTestElector(server.URL, "pod-b")
+
+	start := time.Now()
+	for i := range 3 {
+		a.tryAcquireOrRenew(context.Background())
+		b.now = func() time.Time { return start.Add(time.Duration(i) * 10 * time.Second) }
+		b.tryAcquireOrRenew(context.Background())
+		if !a.IsLeader() || b.IsLeader() {
+			t.Fatalf("round %d: expected only pod-a to lead, got a=%v b=%v", i, a.IsLeader(), b.IsLeader())
+		}
+	}
+}
+
+func TestLeaseElectorReleasesOnShutdown(t *testing.T) {
+	api := &fakeLeaseAPI{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	a := newTestElector(server.URL, "pod-a")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !a.IsLeader() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !a.IsLeader() {
+		t.Fatal("expected pod-a to become the leader")
+	}
+
+	cancel()
+	<-done
+
+	if a.IsLeader() {
+		t.Error("expected pod-a to step down on shutdown")
+	}
+	if got := api.holder(); got != "" {
+		t.Errorf("expected the lease to be released, held by %q", got)
+	}
+
+	b := newTestElector(server.URL, "pod-b")
+	b.tryAcquireOrRenew(context.Background())
+	if !b.IsLeader() {
+		t.Error("expected pod-b to acquire the released lease immediately")
+	}
+}
+
+func TestLeaseElectorKeepsLeadingThroughTransientErrors(t *testing.T) {
+	api := &fakeLeaseAPI{}
+	var failing sync.Mutex
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failing.Lock()
+		f := fail
+		failing.Unlock()
+		if f {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		api.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	a := newTestElector(server.URL, "pod-a")
+	a.tryAcquireOrRenew(context.Background())
+
+	failing.Lock()
+	fail = true
+	failing.Unlock()
+
+	a.tryAcquireOrRenew(context.Background())
+	if !a.IsLeader() {
+		t.Error("expected pod-a to keep leading within the renew deadline")
+	}
+
+	a.now = func() time.Time { return time.Now().Add(time.Minute) }
+	a.tryAcquireOrRenew(context.Background())
+	if a.IsLeader() {
+		t.Error("expected pod-a to step down after the renew deadline")
+	}
+}
+
+func TestLeaseElectorReloadsTokenFile(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		auth string
+	)
+	api := &fakeLeaseAPI{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		auth = r.Header.Get("Authorization")
+		mu.Unlock()
+		api.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	lastAuth := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return auth
+	}
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	writeToken := func(token string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(tokenFile, []byte(token+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(tokenFile, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now()
+	writeToken("first", start)
+
+	e := NewLeaseElector(LeaseConfig{
+		APIServer: server.URL,
+		Token:     "first",
+		TokenFile: tokenFile,
+		Namespace: "monitoring",
+		Name:      "deepl-exporter",
+		Identity:  "pod-a",
+	})
+	e.tryAcquireOrRenew(context.Background())
+	if got := lastAuth(); got != "Bearer first" {
+		t.Fatalf("expected the initial token, got %q", got)
+	}
+
+	// The kubelet rotated the token.
+	writeToken("second", start.Add(time.Hour))
+	e.tryAcquireOrRenew(context.Background())
+	if got := lastAuth(); got != "Bearer second" {
+		t.Errorf("expected the rotated token, got %q", got)
+	}
+
+	// A file being replaced keeps the previous token.
+	writeToken("", start.Add(2*time.Hour))
+	e.tryAcquireOrRenew(context.Background())
+	if got := lastAuth(); got != "Bearer second" {
+		t.Errorf("expected the previous token to be kept, got %q", got)
+	}
+
+	// A reload signal reads the file even if its modification time is the
+	// one of the last token read.
+	writeToken("third", start.Add(time.Hour))
+	e.reloadToken(true)
+	e.tryAcquireOrRenew(context.Background())
+	if got := lastAuth(); got != "Bearer third" {
+		t.Errorf("expected the token read on reload, got %q", got)
+	}
+	if !e.IsLeader() {
+		t.Error("expected pod-a to lead throughout")
+	}
+}
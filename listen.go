@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// listenAddresses returns the addresses the server listens on: the given
+// ones, or all interfaces on port if there are none.
+func listenAddresses(addresses []string, port string) []string {
+	if len(addresses) == 0 {
+		return []string{":" + port}
+	}
+	return addresses
+}
+
+// listen opens a TCP listener on every address, with SO_REUSEPORT if
+// reusePort is set. If one of them fails, the ones already opened are
+// closed again.
+func listen(addresses []string, reusePort bool) ([]net.Listener, error) {
+	var lc net.ListenConfig
+	if reusePort {
+		lc.Control = reusePortControl
+	}
+
+	listeners := make([]net.Listener, 0, len(addresses))
+	for _, address := range addresses {
+		ln, err := lc.Listen(context.Background(), listenNetwork(address), address)
+		if err != nil {
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+			return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// listenNetwork returns the network to listen on for address. A literal IP
+// address binds only its own family, so 0.0.0.0:1818 and [::]:1818 can be
+// given together, while a host name or an empty host listens dual-stack.
+func listenNetwork(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return "tcp"
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return "tcp"
+	case ip.To4() != nil:
+		return "tcp4"
+	default:
+		return "tcp6"
+	}
+}
+
+// validateListenAddresses checks that every address has a port.
+func validateListenAddresses(addresses []string) error {
+	var errs []error
+	for _, address := range addresses {
+		if _, port, err := net.SplitHostPort(address); err != nil {
+			errs = append(errs, fmt.Errorf("invalid listen address %q: %w", address, err))
+		} else if port == "" {
+			errs = append(errs, fmt.Errorf("invalid listen address %q: missing port", address))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestListenAddresses(t *testing.T) {
+	if got := listenAddresses(nil, "1818"); len(got) != 1 || got[0] != ":1818" {
+		t.Errorf("listenAddresses(nil) = %v, want [:1818]", got)
+	}
+	given := []string{"127.0.0.1:9000", "[::1]:9000"}
+	if got := listenAddresses(given, "1818"); len(got) != 2 || got[0] != given[0] || got[1] != given[1] {
+		t.Errorf("listenAddresses(%v) = %v", given, got)
+	}
+}
+
+func TestListenNetwork(t *testing.T) {
+	for address, want := range map[string]string{
+		":1818":          "tcp",
+		"localhost:1818": "tcp",
+		"0.0.0.0:1818":   "tcp4",
+		"10.0.0.5:1818":  "tcp4",
+		"[::]:1818":      "tcp6",
+		"[fd00::1]:1818": "tcp6",
+	} {
+		if got := listenNetwork(address); got != want {
+			t.Errorf("listenNetwork(%q) = %q, want %q", address, got, want)
+		}
+	}
+}
+
+func TestValidateListenAddresses(t *testing.T) {
+	if err := validateListenAddresses([]string{":1818", "127.0.0.1:9000", "[::1]:9000"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err := validateListenAddresses([]string{"localhost", "127.0.0.1:"})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{`"localhost"`, `"127.0.0.1:": missing port`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestListen(t *testing.T) {
+	listeners, err := listen([]string{"127.0.0.1:0", "127.0.0.1:0"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != 2 {
+		t.Fatalf("got %d listeners, want 2", len(listeners))
+	}
+	taken := listeners[0].Addr().String()
+	for _, ln := range listeners {
+		_ = ln.Close()
+	}
+
+	blocker, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blocker.Close()
+
+	// The first listener must be released when a later one fails.
+	if _, err := listen([]string{taken, blocker.Addr().String()}, false); err == nil {
+		t.Fatal("expected an error for an address in use")
+	}
+	ln, err := net.Listen("tcp4", taken)
+	if err != nil {
+		t.Fatalf("first listener was not closed: %v", err)
+	}
+	_ = ln.Close()
+}
+
+func TestListen_ReusePort(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SO_REUSEPORT is not available on Windows")
+	}
+	first, err := listen([]string{"127.0.0.1:0"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first[0].Close()
+
+	// A second process binding the same address, e.g. the new binary
+	// started while the old one still serves.
+	second, err := listen([]string{first[0].Addr().String()}, true)
+	if err != nil {
+		t.Fatalf("binding the address twice with SO_REUSEPORT failed: %v", err)
+	}
+	_ = second[0].Close()
+}
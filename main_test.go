@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+func newTestClient(t *testing.T, serverURL string) *deepl.Client {
+	t.Helper()
+	c, err := deepl.NewClient(deepl.ClientConfig{APIKey: "test-key", ServerURL: serverURL})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return c
+}
+
+func TestNewRegistry(t *testing.T) {
+	tests := []struct {
+		name             string
+		goCollector      bool
+		runtimeMetrics   bool
+		processCollector bool
+		want             []string
+		notWant          []string
+	}{
+		{
+			name:        "Go only",
+			goCollector: true,
+			want:        []string{"go_goroutines"},
+			notWant:     []string{"go_sched_latencies_seconds", "process_"},
+		},
+		{
+			name:           "Runtime metrics",
+			goCollector:    true,
+			runtimeMetrics: true,
+			want:           []string{"go_goroutines", "go_sched_latencies_seconds"},
+		},
+		{
+			name:             "Process only",
+			processCollector: true,
+			want:             []string{"process_"},
+			notWant:          []string{"go_goroutines"},
+		},
+		{
+			name:    "Nothing",
+			notWant: []string{"go_", "process_"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			families, err := newRegistry(tt.goCollector, tt.runtimeMetrics, tt.processCollector).Gather()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var names []string
+			for _, family := range families {
+				names = append(names, family.GetName())
+			}
+			all := strings.Join(names, " ")
+
+			for _, name := range tt.want {
+				if !strings.Contains(all, name) {
+					t.Errorf("expected %s in %v", name, names)
+				}
+			}
+			for _, name := range tt.notWant {
+				if strings.Contains(all, name) {
+					t.Errorf("unexpected %s in %v", name, names)
+				}
+			}
+		})
+	}
+}
+
+func TestParseUsageThresholds(t *testing.T) {
+	if thresholds, err := parseUsageThresholds(" "); err != nil || thresholds != nil {
+		t.Errorf("expected no thresholds, got %v, %v", thresholds, err)
+	}
+	if _, err := parseUsageThresholds("80,150"); err == nil {
+		t.Error("expected error for a threshold above 100")
+	}
+}
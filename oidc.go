@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// jwtLeeway tolerates clock skew between the identity provider and the
+	// exporter when checking the validity of a token.
+	jwtLeeway = time.Minute
+	// jwksMinRefresh limits how often unknown key IDs trigger a fetch of the
+	// key set, so forged tokens cannot make the exporter hammer the
+	// identity provider.
+	jwksMinRefresh = time.Minute
+)
+
+// JWTClaims are the claims of a verified token the exporter cares about.
+type JWTClaims struct {
+	Subject string
+	// Scopes are the values of the scope claim of the verifier, see
+	// NewJWTVerifier.
+	Scopes []string
+}
+
+// jwtAlgorithms are the signing algorithms that can be allowed with
+// --web.oidc-algorithms, those of the RSA and P-256 keys of a JWKS. HMAC and
+// none are never accepted: their key would be the public one.
+var jwtAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256"}
+
+// JWTVerifier validates JWTs issued by an OpenID Connect provider with the
+// keys of its JWKS endpoint, which are refetched when a token is signed by
+// an unknown key.
+type JWTVerifier struct {
+	issuer     string
+	audience   string
+	jwksURL    string
+	scopeClaim string
+	algorithms []string
+	http       *http.Client
+	now        func() time.Time
+
+	mu   sync.Mutex
+	keys map[string]crypto.PublicKey
+	// attempted is when the key set was last fetched, successfully or not.
+	attempted time.Time
+	fetch     singleflight.Group
+}
+
+// NewJWTVerifier returns a verifier for the tokens of issuer intended for
+// audience. If jwksURL is empty, it is discovered from the OpenID
+// configuration of the issuer. The scopes the tokens grant are read from
+// scopeClaim, e.g. scope, scp or groups, given either as a space-separated
+// string or as a list. Only tokens signed with one of algorithms, see
+// jwtAlgorithms, are accepted.
+func NewJWTVerifier(ctx context.Context, issuer, audience, jwksURL, scopeClaim string, algorithms []string) (*JWTVerifier, error) {
+	if issuer == "" || audience == "" {
+		return nil, errors.New("OIDC issuer and audience are required")
+	}
+	if scopeClaim == "" {
+		return nil, errors.New("OIDC scope claim is required")
+	}
+	if len(algorithms) == 0 {
+		return nil, errors.New("OIDC algorithms are required")
+	}
+	for _, alg := range algorithms {
+		if !slices.Contains(jwtAlgorithms, alg) {
+			return nil, fmt.Errorf("unsupported OIDC algorithm %q, must be one of %s", alg, strings.Join(jwtAlgorithms, ", "))
+		}
+	}
+	v := &JWTVerifier{
+		issuer:     issuer,
+		audience:   audience,
+		jwksURL:    jwksURL,
+		scopeClaim: scopeClaim,
+		algorithms: algorithms,
+		http:       &http.Client{Timeout: defaultTimeout},
+		now:        time.Now,
+	}
+
+	if v.jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, fmt.Errorf("failed to discover the JWKS URL of %s: %w", issuer, err)
+		}
+		if discovery.JWKSURI == "" {
+			return nil, fmt.Errorf("OpenID configuration of %s has no jwks_uri", issuer)
+		}
+		v.jwksURL = discovery.JWKSURI
+	}
+
+	v.attempted = v.now()
+	if err := v.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Verify checks the signature, issuer, audience and validity period of
+// token and returns its claims.
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*JWTClaims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(v.algorithms),
+		jwt.WithIssuer(v.issuer),
+		jwt.WithAudience(v.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(jwtLeeway),
+		jwt.WithTimeFunc(v.now),
+	)
+	claims := jwt.MapClaims{}
+	if _, err := parser.ParseWithClaims(token, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := v.key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			if _, ok := key.(*rsa.PublicKey); !ok {
+				return nil, fmt.Errorf("key %q is no RSA key", kid)
+			}
+		case *jwt.SigningMethodECDSA:
+			if _, ok := key.(*ecdsa.PublicKey); !ok {
+				return nil, fmt.Errorf("key %q is no EC key", kid)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported algorithm %q", token.Method.Alg())
+		}
+		return key, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil {
+		return nil, err
+	}
+	scopes, err := v.scopes(claims)
+	if err != nil {
+		return nil, err
+	}
+	return &JWTClaims{Subject: subject, Scopes: scopes}, nil
+}
+
+// scopes returns the values of the scope claim of the verified claims,
+// none if the token has no such claim.
+func (v *JWTVerifier) scopes(claims jwt.MapClaims) ([]string, error) {
+	switch value := claims[v.scopeClaim].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return strings.Fields(value), nil
+	case []any:
+		scopes := make([]string, len(value))
+		for i, scope := range value {
+			s, ok := scope.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s claim: %v is no string", v.scopeClaim, scope)
+			}
+			scopes[i] = s
+		}
+		return scopes, nil
+	default:
+		return nil, fmt.Errorf("invalid %s claim: %v", v.scopeClaim, value)
+	}
+}
+
+// key returns the public key with the given ID, refetching the key set
+// once if it is unknown, e.g. after the provider rotated its keys. The fetch
+// runs without holding v.mu and is shared by concurrent callers, so a slow
+// identity provider does not block tokens signed by known keys.
+func (v *JWTVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if key, ok := v.knownKey(kid); ok {
+		return key, nil
+	}
+	// The fetch outlives a caller that gives up, it is bounded by the timeout
+	// of v.http.
+	if _, err, _ := v.fetch.Do("", func() (any, error) {
+		v.mu.Lock()
+		due := v.now().Sub(v.attempted) >= jwksMinRefresh
+		if due {
+			v.attempted = v.now()
+		}
+		v.mu.Unlock()
+		if !due {
+			return nil, nil
+		}
+		return nil, v.refreshKeys(context.WithoutCancel(ctx))
+	}); err != nil {
+		return nil, err
+	}
+	if key, ok := v.knownKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (v *JWTVerifier) knownKey(kid string) (crypto.PublicKey, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+// refreshKeys fetches the key set and replaces the known keys with it.
+func (v *JWTVerifier) refreshKeys(ctx context.Context) error {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.getJSON(ctx, v.jwksURL, &set); err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Keys of unsupported types are skipped, tokens signed with them
+		// are rejected as signed by an unknown key.
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	v.mu.Lock()
+	v.keys = keys
+	v.mu.Unlock()
+	return nil
+}
+
+func (v *JWTVerifier) getJSON(ctx context.Context, url string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
+
+// jsonWebKey is a public key of a JWKS as defined by RFC 7517.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey returns the RSA or P-256 key described by k.
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid P-256 point")
+		}
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// signJWT returns a token with claims signed by key, an RSA or P-256 key.
+func signJWT(t *testing.T, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+	alg := "RS256"
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		alg = "ES256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func TestJWTVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecBytes, err := ecKey.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rotated atomic.Bool
+	var fetches atomic.Int64
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			writeJSON(w, http.StatusOK, map[string]string{"issuer": server.URL, "jwks_uri": server.URL + "/jwks"})
+		case "/jwks":
+			fetches.Add(1)
+			keys := []map[string]string{rsaJWK("rsa-1", &rsaKey.PublicKey)}
+			if rotated.Load() {
+				keys = append(keys, map[string]string{
+					"kty": "EC",
+					"kid": "ec-1",
+					"crv": "P-256",
+					"x":   base64.RawURLEncoding.EncodeToString(ecBytes[1:33]),
+					"y":   base64.RawURLEncoding.EncodeToString(ecBytes[33:]),
+				})
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"keys": keys})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	verifier, err := NewJWTVerifier(context.Background(), server.URL, "deepl-exporter", "", "scope", []string{"RS256", "ES256"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{"iss": server.URL, "sub": "alice", "aud": []string{"other", "deepl-exporter"}, "exp": now.Add(time.Hour).Unix()}
+		for name, value := range overrides {
+			c[name] = value
+		}
+		return c
+	}
+
+	got, err := verifier.Verify(context.Background(), signJWT(t, "rsa-1", rsaKey, claims(nil)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Subject != "alice" {
+		t.Errorf("expected subject alice, got %q", got.Subject)
+	}
+
+	for name, token := range map[string]string{
+		"wrong issuer":   signJWT(t, "rsa-1", rsaKey, claims(map[string]any{"iss": "https://evil.example.com"})),
+		"wrong audience": signJWT(t, "rsa-1", rsaKey, claims(map[string]any{"aud": "other"})),
+		"expired":        signJWT(t, "rsa-1", rsaKey, claims(map[string]any{"exp": now.Add(-2 * time.Minute).Unix()})),
+		"not yet valid":  signJWT(t, "rsa-1", rsaKey, claims(map[string]any{"nbf": now.Add(2 * time.Minute).Unix()})),
+		"tampered":       signJWT(t, "rsa-1", rsaKey, claims(nil))[:40] + "x" + signJWT(t, "rsa-1", rsaKey, claims(nil))[41:],
+		"malformed":      "not-a-jwt",
+	} {
+		if _, err := verifier.Verify(context.Background(), token); err == nil {
+			t.Errorf("%s: expected the token to be rejected", name)
+		}
+	}
+
+	// A key added by the provider is picked up once the key set may be
+	// refetched.
+	ecToken := signJWT(t, "ec-1", ecKey, claims(nil))
+	rotated.Store(true)
+	if _, err := verifier.Verify(context.Background(), ecToken); err == nil {
+		t.Error("expected the key set not to be refetched within a minute")
+	}
+	now = now.Add(jwksMinRefresh)
+	if _, err := verifier.Verify(context.Background(), ecToken); err != nil {
+		t.Errorf("unexpected error for the rotated key: %v", err)
+	}
+	if fetches.Load() != 2 {
+		t.Errorf("expected 2 fetches of the key set, got %d", fetches.Load())
+	}
+}
+
+// rawJWT returns a token with header and claims, signed by sign.
+func rawJWT(header, claims map[string]any, sign func(signed string) []byte) string {
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign(signed))
+}
+
+func TestJWTVerifierAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{rsaJWK("rsa-1", &rsaKey.PublicKey)}})
+	}))
+	defer jwks.Close()
+	newVerifier := func(algorithms ...string) (*JWTVerifier, error) {
+		return NewJWTVerifier(context.Background(), "https://idp.example.com", "deepl-exporter", jwks.URL, "scope", algorithms)
+	}
+
+	for _, algorithms := range [][]string{nil, {"none"}, {"HS256"}, {"RS256", ""}} {
+		if _, err := newVerifier(algorithms...); err == nil {
+			t.Errorf("expected the algorithms %q to be rejected", algorithms)
+		}
+	}
+
+	verifier, err := newVerifier("RS256", "ES256")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	claims := map[string]any{"iss": "https://idp.example.com", "sub": "mallory", "aud": "deepl-exporter", "exp": time.Now().Add(time.Hour).Unix()}
+	if _, err := verifier.Verify(context.Background(), signJWT(t, "rsa-1", rsaKey, claims)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The public key is known to everyone, so a token "signed" with it as
+	// HMAC secret must not pass as signed by the RSA key.
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	for name, token := range map[string]string{
+		"alg none": rawJWT(map[string]any{"alg": "none", "kid": "rsa-1"}, claims, func(string) []byte { return nil }),
+		"HMAC with the RSA key": rawJWT(map[string]any{"alg": "HS256", "kid": "rsa-1"}, claims, func(signed string) []byte {
+			return hmacSHA256(publicPEM, signed)
+		}),
+		"HMAC with the RSA key in DER": rawJWT(map[string]any{"alg": "HS256", "kid": "rsa-1"}, claims, func(signed string) []byte {
+			return hmacSHA256(der, signed)
+		}),
+	} {
+		if _, err := verifier.Verify(context.Background(), token); err == nil {
+			t.Errorf("%s: expected the token to be rejected", name)
+		}
+	}
+
+	pinned, err := newVerifier("ES256")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := pinned.Verify(context.Background(), signJWT(t, "rsa-1", rsaKey, claims)); err == nil {
+		t.Error("expected an RS256 token to be rejected by a verifier pinned to ES256")
+	}
+}
+
+func TestJWTVerifierUnavailableProvider(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches atomic.Int64
+	var down atomic.Bool
+	release := make(chan struct{})
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if down.Load() {
+			<-release
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{rsaJWK("rsa-1", &rsaKey.PublicKey)}})
+	}))
+	defer jwks.Close()
+
+	verifier, err := NewJWTVerifier(context.Background(), "https://idp.example.com", "deepl-exporter", jwks.URL, "scope", []string{"RS256"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Now().Add(jwksMinRefresh)
+	verifier.now = func() time.Time { return now }
+	claims := map[string]any{"iss": "https://idp.example.com", "sub": "alice", "aud": "deepl-exporter", "exp": now.Add(time.Hour).Unix()}
+
+	// A slow fetch for an unknown key does not block tokens of known keys.
+	down.Store(true)
+	failed := make(chan error)
+	go func() {
+		_, err := verifier.Verify(context.Background(), signJWT(t, "unknown", rsaKey, claims))
+		failed <- err
+	}()
+	for fetches.Load() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := verifier.Verify(context.Background(), signJWT(t, "rsa-1", rsaKey, claims)); err != nil {
+		t.Errorf("unexpected error during the fetch: %v", err)
+	}
+	close(release)
+	if err := <-failed; err == nil {
+		t.Error("expected the token to be rejected")
+	}
+
+	// The failed fetch counts against the refresh interval.
+	if _, err := verifier.Verify(context.Background(), signJWT(t, "other", rsaKey, claims)); err == nil {
+		t.Error("expected the token to be rejected")
+	}
+	if fetches.Load() != 2 {
+		t.Errorf("expected 2 fetches of the key set, got %d", fetches.Load())
+	}
+}
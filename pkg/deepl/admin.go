@@ -0,0 +1,152 @@
+package deepl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	adminKeysPath      = "/v2/admin/developer-keys"
+	adminAnalyticsPath = "/v2/admin/analytics"
+)
+
+// DeepLDeveloperKey is an API key of the organization as listed by the
+// admin API.
+type DeepLDeveloperKey struct {
+	KeyID         string `json:"key_id"`
+	Label         string `json:"label"`
+	IsDeactivated bool   `json:"is_deactivated"`
+	UsageLimits   struct {
+		// Characters is the monthly character limit of the key, nil when
+		// it is unlimited.
+		Characters *int64 `json:"characters"`
+	} `json:"usage_limits"`
+}
+
+// DeepLKeyUsage is the usage of one API key reported by the admin
+// analytics endpoint.
+type DeepLKeyUsage struct {
+	APIKey      string `json:"api_key"`
+	APIKeyLabel string `json:"api_key_label"`
+	Usage       struct {
+		TotalCharacters int64 `json:"total_characters"`
+	} `json:"usage"`
+}
+
+// AdminCollector uses an admin key to export the usage of every API key of
+// the organization, so they don't have to be configured one by one.
+type AdminCollector struct {
+	keys           *prometheus.Desc
+	keyCount       *prometheus.Desc
+	keyLimit       *prometheus.Desc
+	keyDeactivated *prometheus.Desc
+
+	now func() time.Time
+}
+
+func init() {
+	RegisterCollector("admin", false, func(Options) Collector {
+		return NewAdminCollector()
+	})
+}
+
+func NewAdminCollector() *AdminCollector {
+	return &AdminCollector{
+		keys: prometheus.NewDesc(
+			"deepl_admin_keys",
+			"Number of API keys of the organization",
+			[]string{"account"},
+			nil,
+		),
+		keyCount: prometheus.NewDesc(
+			"deepl_admin_key_character_count",
+			"Number of characters used by an API key of the organization in the current month",
+			[]string{"account", "api_key", "label"},
+			nil,
+		),
+		keyLimit: prometheus.NewDesc(
+			"deepl_admin_key_character_limit",
+			"Monthly character limit of an API key of the organization",
+			[]string{"account", "key_id", "label"},
+			nil,
+		),
+		keyDeactivated: prometheus.NewDesc(
+			"deepl_admin_key_deactivated",
+			"Whether an API key of the organization is deactivated",
+			[]string{"account", "key_id", "label"},
+			nil,
+		),
+		now: time.Now,
+	}
+}
+
+func (c *AdminCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.keys
+	ch <- c.keyCount
+	ch <- c.keyLimit
+	ch <- c.keyDeactivated
+}
+
+func (c *AdminCollector) Update(ctx context.Context, client *Client, ch chan<- prometheus.Metric) error {
+	var errs []error
+	if keys, err := fetchDeveloperKeys(ctx, client); err != nil {
+		errs = append(errs, fmt.Errorf("developer keys: %w", err))
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.keys, prometheus.GaugeValue, float64(len(keys)), client.Name())
+		for _, key := range keys {
+			deactivated := 0.0
+			if key.IsDeactivated {
+				deactivated = 1
+			}
+			ch <- prometheus.MustNewConstMetric(c.keyDeactivated, prometheus.GaugeValue, deactivated, client.Name(), key.KeyID, key.Label)
+			if key.UsageLimits.Characters != nil {
+				ch <- prometheus.MustNewConstMetric(c.keyLimit, prometheus.GaugeValue, float64(*key.UsageLimits.Characters), client.Name(), key.KeyID, key.Label)
+			}
+		}
+	}
+
+	if usages, err := fetchKeyUsages(ctx, client, c.now()); err != nil {
+		errs = append(errs, fmt.Errorf("analytics: %w", err))
+	} else {
+		for _, usage := range usages {
+			ch <- prometheus.MustNewConstMetric(c.keyCount, prometheus.GaugeValue, float64(usage.Usage.TotalCharacters), client.Name(), usage.APIKey, usage.APIKeyLabel)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func fetchDeveloperKeys(ctx context.Context, client *Client) ([]DeepLDeveloperKey, error) {
+	var keys []DeepLDeveloperKey
+	if err := client.GetJSON(ctx, adminKeysPath, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// fetchKeyUsages returns the usage of every API key from the start of the
+// month of now, in UTC, up to and including its day.
+func fetchKeyUsages(ctx context.Context, client *Client, now time.Time) ([]DeepLKeyUsage, error) {
+	now = now.UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	query := url.Values{
+		"start_date": {start.Format(time.DateOnly)},
+		"end_date":   {now.AddDate(0, 0, 1).Format(time.DateOnly)},
+		"group_by":   {"key"},
+	}
+
+	var resp struct {
+		UsageReport struct {
+			KeyUsages []DeepLKeyUsage `json:"key_usages"`
+		} `json:"usage_report"`
+	}
+	if err := client.GetJSON(ctx, adminAnalyticsPath+"?"+query.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	return resp.UsageReport.KeyUsages, nil
+}
@@ -0,0 +1,67 @@
+package deepl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAdminCollector(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case adminKeysPath:
+			_, _ = fmt.Fprintln(w, `[
+				{"key_id": "k1", "label": "Website", "is_deactivated": false, "usage_limits": {"characters": 100000}},
+				{"key_id": "k2", "label": "Old app", "is_deactivated": true, "usage_limits": {"characters": null}}
+			]`)
+		case adminAnalyticsPath:
+			query := r.URL.Query()
+			if query.Get("start_date") != "2026-03-01" || query.Get("end_date") != "2026-03-16" || query.Get("group_by") != "key" {
+				http.Error(w, "unexpected query "+r.URL.RawQuery, http.StatusBadRequest)
+				return
+			}
+			_, _ = fmt.Fprintln(w, `{"usage_report": {"key_usages": [
+				{"api_key": "dc88****3a2c", "api_key_label": "Website", "usage": {"total_characters": 4200}}
+			]}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	collector := NewAdminCollector()
+	collector.now = func() time.Time { return time.Date(2026, 3, 15, 23, 0, 0, 0, time.UTC) }
+	client := newTestClient(t, ts.URL)
+
+	expected := `
+# HELP deepl_admin_key_character_count Number of characters used by an API key of the organization in the current month
+# TYPE deepl_admin_key_character_count gauge
+deepl_admin_key_character_count{account="default",api_key="dc88****3a2c",label="Website"} 4200
+# HELP deepl_admin_key_character_limit Monthly character limit of an API key of the organization
+# TYPE deepl_admin_key_character_limit gauge
+deepl_admin_key_character_limit{account="default",key_id="k1",label="Website"} 100000
+# HELP deepl_admin_key_deactivated Whether an API key of the organization is deactivated
+# TYPE deepl_admin_key_deactivated gauge
+deepl_admin_key_deactivated{account="default",key_id="k1",label="Website"} 0
+deepl_admin_key_deactivated{account="default",key_id="k2",label="Old app"} 1
+# HELP deepl_admin_keys Number of API keys of the organization
+# TYPE deepl_admin_keys gauge
+deepl_admin_keys{account="default"} 2
+`
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.CollectorFunc(func(ch chan<- prometheus.Metric) {
+		if err := collector.Update(context.Background(), client, ch); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}))
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,68 @@
+package deepl
+
+import (
+	"sync"
+	"time"
+)
+
+// availabilityBucket counts the outcomes of the requests of one minute.
+type availabilityBucket struct {
+	minute  time.Time
+	success int
+	total   int
+}
+
+// availabilityWindow keeps per-minute outcomes of requests to DeepL for
+// rolling availability ratios, bounding memory regardless of how often
+// metrics are fetched. It is safe for concurrent use.
+type availabilityWindow struct {
+	maxAge time.Duration
+
+	mu sync.Mutex
+	// buckets are sorted by minute and span at most maxAge.
+	buckets []availabilityBucket
+}
+
+func newAvailabilityWindow(maxAge time.Duration) *availabilityWindow {
+	return &availabilityWindow{maxAge: maxAge}
+}
+
+// record counts the outcome of a request made at now.
+func (w *availabilityWindow) record(now time.Time, success bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	minute := now.Truncate(time.Minute)
+	if n := len(w.buckets); n == 0 || w.buckets[n-1].minute.Before(minute) {
+		w.buckets = append(w.buckets, availabilityBucket{minute: minute})
+	}
+	// Requests finishing out of order are counted in the latest minute.
+	bucket := &w.buckets[len(w.buckets)-1]
+	bucket.total++
+	if success {
+		bucket.success++
+	}
+
+	for len(w.buckets) > 0 && !w.buckets[0].minute.After(now.Add(-w.maxAge)) {
+		w.buckets = w.buckets[1:]
+	}
+}
+
+// ratio returns the share of successful requests during the window before
+// now, and false if there were none.
+func (w *availabilityWindow) ratio(now time.Time, window time.Duration) (float64, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var success, total int
+	for _, bucket := range w.buckets {
+		if bucket.minute.After(now.Add(-window)) {
+			success += bucket.success
+			total += bucket.total
+		}
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return float64(success) / float64(total), true
+}
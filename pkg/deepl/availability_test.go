@@ -0,0 +1,39 @@
+package deepl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAvailabilityWindow(t *testing.T) {
+	w := newAvailabilityWindow(24 * time.Hour)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	if _, ok := w.ratio(now, time.Hour); ok {
+		t.Error("expected no ratio without requests")
+	}
+
+	// Failures 3 hours ago, successes during the last hour.
+	for i := range 4 {
+		w.record(now.Add(-3*time.Hour+time.Duration(i)*time.Minute), false)
+	}
+	for i := range 4 {
+		w.record(now.Add(-30*time.Minute+time.Duration(i)*time.Second), true)
+	}
+
+	if ratio, ok := w.ratio(now, time.Hour); !ok || ratio != 1 {
+		t.Errorf("expected 1h ratio 1, got %v (%t)", ratio, ok)
+	}
+	if ratio, ok := w.ratio(now, 24*time.Hour); !ok || ratio != 0.5 {
+		t.Errorf("expected 24h ratio 0.5, got %v (%t)", ratio, ok)
+	}
+	if len(w.buckets) != 5 {
+		t.Errorf("expected 5 minute buckets, got %d", len(w.buckets))
+	}
+
+	// A day later, the old buckets are dropped.
+	w.record(now.Add(24*time.Hour), false)
+	if ratio, ok := w.ratio(now.Add(24*time.Hour), 24*time.Hour); !ok || ratio != 0 || len(w.buckets) != 1 {
+		t.Errorf("expected only the last failure, got ratio %v and %d buckets", ratio, len(w.buckets))
+	}
+}
@@ -0,0 +1,49 @@
+package deepl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseBillingAnchor parses the start of a billing cycle, either a day of
+// the month, e.g. 15, or the date or time a subscription started, e.g.
+// 2024-03-15 or 2024-03-15T09:18:42Z, whose day and time of day in UTC are
+// used. It returns the zero time for an empty string.
+func ParseBillingAnchor(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if day, err := strconv.Atoi(s); err == nil {
+		if day < 1 || day > 31 {
+			return time.Time{}, fmt.Errorf("invalid billing anchor %q: day of month must be between 1 and 31", s)
+		}
+		return time.Date(2000, time.January, day, 0, 0, 0, 0, time.UTC), nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if anchor, err := time.Parse(layout, s); err == nil {
+			return anchor.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid billing anchor %q: expected a day of month, a date or an RFC 3339 time", s)
+}
+
+// NextBillingReset returns the first reset of a monthly billing cycle
+// starting at anchor after now. In months shorter than the day of anchor,
+// the cycle resets on their last day.
+func NextBillingReset(anchor, now time.Time) time.Time {
+	now = now.UTC()
+	reset := billingReset(anchor, now.Year(), now.Month())
+	if !reset.After(now) {
+		reset = billingReset(anchor, now.Year(), now.Month()+1)
+	}
+	return reset
+}
+
+func billingReset(anchor time.Time, year int, month time.Month) time.Time {
+	// The day before the first of the next month is the last of month.
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	return time.Date(year, month, min(anchor.Day(), last), anchor.Hour(), anchor.Minute(), anchor.Second(), 0, time.UTC)
+}
@@ -0,0 +1,94 @@
+package deepl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestParseBillingAnchor(t *testing.T) {
+	for input, want := range map[string]time.Time{
+		"":                          {},
+		"15":                        time.Date(2000, 1, 15, 0, 0, 0, 0, time.UTC),
+		"2024-03-31":                time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+		"2024-03-15T11:18:42+02:00": time.Date(2024, 3, 15, 9, 18, 42, 0, time.UTC),
+	} {
+		got, err := ParseBillingAnchor(input)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParseBillingAnchor(%q) = %v, %v, expected %v", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"0", "32", "mid-month"} {
+		if _, err := ParseBillingAnchor(input); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func TestNextBillingReset(t *testing.T) {
+	anchor := time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		now, want time.Time
+	}{
+		{time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC), time.Date(2026, 10, 31, 9, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 10, 31, 9, 0, 0, 0, time.UTC), time.Date(2026, 11, 30, 9, 0, 0, 0, time.UTC)},
+		{time.Date(2027, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2027, 2, 28, 9, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 12, 31, 10, 0, 0, 0, time.UTC), time.Date(2027, 1, 31, 9, 0, 0, 0, time.UTC)},
+	} {
+		if got := NextBillingReset(anchor, tc.now); !got.Equal(tc.want) {
+			t.Errorf("NextBillingReset(%v) = %v, expected %v", tc.now, got, tc.want)
+		}
+	}
+}
+
+func TestUsageCollectorBillingPeriod(t *testing.T) {
+	response := `{"character_count": 1, "character_limit": 10}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer ts.Close()
+	client := newTestClient(t, ts.URL)
+
+	opts := DefaultUsageOptions()
+	opts.BillingAnchor = time.Date(2000, 1, 17, 0, 0, 0, 0, time.UTC)
+	collector := NewUsageCollector(opts)
+	collector.now = func() time.Time { return time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) }
+
+	remaining := func() float64 {
+		ch := make(chan prometheus.Metric, 20)
+		if err := collector.Update(context.Background(), client, ch); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		close(ch)
+		for metric := range ch {
+			if metricName(metric) == "deepl_billing_period_seconds_remaining" {
+				var m dto.Metric
+				if err := metric.Write(&m); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return m.GetGauge().GetValue()
+			}
+		}
+		t.Fatal("deepl_billing_period_seconds_remaining was not exported")
+		return 0
+	}
+
+	if got := remaining(); got != 12*3600 {
+		t.Errorf("expected 12h remaining until the anchor, got %vs", got)
+	}
+	response = `{"character_count": 1, "character_limit": 10, "start_time": "2026-09-20T06:00:00Z", "end_time": "2026-10-20T06:00:00Z"}`
+	if got := remaining(); got != 12*3600 {
+		t.Errorf("expected the anchor to win over the end reported by DeepL, got %vs", got)
+	}
+
+	collector.billingAnchor = time.Time{}
+	if got := remaining(); got != (3*24+18)*3600 {
+		t.Errorf("expected the end reported by DeepL to be used without an anchor, got %vs", got)
+	}
+}
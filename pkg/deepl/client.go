@@ -0,0 +1,751 @@
+package deepl
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// Defaults of the requests to the DeepL API and of TransportConfig.
+const (
+	DefaultTimeout = 10 * time.Second
+	proAPIBaseURL  = "https://api.deepl.com"
+	freeAPIBaseURL = "https://api-free.deepl.com"
+
+	DefaultIdleConnTimeout     = 5 * time.Minute
+	DefaultMaxIdleConnsPerHost = 4
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+)
+
+// API types of ClientConfig.APIType.
+const (
+	APITypeAuto = "auto"
+	APITypeFree = "free"
+	APITypePro  = "pro"
+)
+
+// Defaults of ClientConfig.AuthHeader, AuthScheme and UserAgent.
+const (
+	DefaultAuthHeader = "Authorization"
+	DefaultAuthScheme = "DeepL-Auth-Key"
+	defaultUserAgent  = "deepl-exporter"
+)
+
+// DefaultAccountName labels the metrics of a client configured without a
+// name.
+const DefaultAccountName = "default"
+
+// ClientConfig holds the settings used to build a Client.
+type ClientConfig struct {
+	// Name identifies the account in metrics and logs.
+	Name   string
+	APIKey string
+	// CostCenter and Project attribute the spend of the account for
+	// chargeback. Both are optional.
+	CostCenter string
+	Project    string
+	// BackupAPIKey, if set, replaces APIKey once DeepL rejects it with 401
+	// or 403, e.g. after it was revoked or rotated.
+	BackupAPIKey string
+	// ServerURL overrides the API endpoint, e.g. for a mock server or an
+	// API gateway. APIType is ignored when it is set.
+	ServerURL string
+	// APIType selects the Free or Pro endpoint. "auto" (or empty) detects
+	// it from the ":fx" suffix of APIKey.
+	APIType string
+	// AuthHeader is the header carrying the API key, "Authorization" if
+	// empty. The key is prefixed with AuthScheme, which defaults to
+	// "DeepL-Auth-Key" when nil.
+	AuthHeader string
+	AuthScheme *string
+	// Headers are added to every request, e.g. for API gateways.
+	Headers map[string]string
+	// RequestIDHeader, if set, carries the ID of the scrape or poll a
+	// request belongs to, so it can be found in the logs of proxies.
+	RequestIDHeader string
+	// UserAgent is sent with every request unless Headers set one,
+	// deepl-exporter if empty.
+	UserAgent string
+	// HTTPClient, if set, sends the requests instead of a client built
+	// from ProxyURL, CAFile, InsecureSkipVerify, Transport and Faults,
+	// which are ignored then.
+	HTTPClient *http.Client
+	// ProxyURL is the proxy used for all requests, optionally with
+	// credentials in its user info. HTTPS_PROXY, HTTP_PROXY and NO_PROXY are
+	// honored when it is empty.
+	ProxyURL string
+	// CAFile is a PEM bundle of additional CAs trusted for the API, e.g.
+	// for TLS-intercepting proxies.
+	CAFile string
+	// InsecureSkipVerify disables TLS certificate verification entirely.
+	InsecureSkipVerify bool
+	// Transport tunes connection reuse. Zero values select the defaults.
+	Transport TransportConfig
+	// RateLimit caps the requests per minute sent for this account, while
+	// SharedLimiter is shared by all accounts. Both are optional.
+	RateLimit     int
+	SharedLimiter *rate.Limiter
+	// SharedCache, if set, shares successful responses with other replicas
+	// for SharedCacheTTL, so only one of them calls DeepL per period.
+	SharedCache    SharedCache
+	SharedCacheTTL time.Duration
+	// IsLeader enables leader election: only the leader calls DeepL and
+	// the other replicas read its responses from SharedCache.
+	IsLeader func() bool
+	// RequestDuration, if set, observes the latency of every request sent
+	// to DeepL, labeled by account and path.
+	RequestDuration *prometheus.HistogramVec
+	// Recorder, if set, keeps redacted copies of the raw responses.
+	Recorder *ResponseRecorder
+	// Faults are injected into the requests, for testing only.
+	Faults FaultConfig
+}
+
+// TransportConfig controls how connections to the DeepL API are kept alive
+// and reused between scrapes.
+type TransportConfig struct {
+	// IdleConnTimeout should exceed the scrape interval, otherwise every
+	// scrape opens a new connection and repeats the TLS handshake.
+	IdleConnTimeout     time.Duration
+	MaxIdleConnsPerHost int
+	TLSHandshakeTimeout time.Duration
+	// DNSCacheTTL caches DNS lookups of the API host when positive.
+	DNSCacheTTL time.Duration
+	// StaticHosts maps host names to fixed addresses, bypassing DNS.
+	StaticHosts map[string][]string
+}
+
+// Client performs authenticated requests against the DeepL API. A single
+// Client is shared by all enabled collectors.
+type Client struct {
+	name            string
+	costCenter      string
+	project         string
+	authHeader      string
+	authScheme      string
+	headers         map[string]string
+	userAgent       string
+	requestIDHeader string
+	http            *http.Client
+	limiters        []*rate.Limiter
+	shared          *sharedFetcher
+	duration        prometheus.ObserverVec
+	recorder        *ResponseRecorder
+	// availability counts the outcome of every request to DeepL.
+	availability *availabilityWindow
+
+	mu      sync.RWMutex
+	baseURL string
+	// fallbackURL is the endpoint tried when baseURL rejects the key with
+	// 403. It is only set when the endpoint was detected from the key type.
+	fallbackURL string
+	mismatch    bool
+	// apiKey is the key in use, which is backupAPIKey after a failover.
+	apiKey       string
+	backupAPIKey string
+	failover     bool
+}
+
+// isFreeAPIKey reports whether apiKey belongs to the DeepL Free API, whose
+// keys carry the ":fx" suffix.
+func isFreeAPIKey(apiKey string) bool {
+	return len(apiKey) > 3 && apiKey[len(apiKey)-3:] == ":fx"
+}
+
+// NormalizeServerURL validates a user supplied DeepL API base URL and strips
+// any trailing slash so API paths can be appended to it.
+func NormalizeServerURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid DeepL server URL %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid DeepL server URL %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid DeepL server URL %q: missing host", raw)
+	}
+	return strings.TrimRight(raw, "/"), nil
+}
+
+// NewClient creates a Client from cfg. The API endpoint is taken from
+// cfg.ServerURL when set, otherwise it is chosen according to cfg.APIType.
+func NewClient(cfg ClientConfig) (*Client, error) {
+	name := cfg.Name
+	if name == "" {
+		name = DefaultAccountName
+	}
+
+	var baseURL, fallbackURL string
+	switch {
+	case cfg.ServerURL != "":
+		var err error
+		if baseURL, err = NormalizeServerURL(cfg.ServerURL); err != nil {
+			return nil, err
+		}
+		log.Printf("Account %s: using DeepL API at %s", name, baseURL)
+	case cfg.APIType == APITypeFree:
+		baseURL = freeAPIBaseURL
+		log.Printf("Account %s: using DeepL Free API as configured", name)
+	case cfg.APIType == APITypePro:
+		baseURL = proAPIBaseURL
+		log.Printf("Account %s: using DeepL Pro API as configured", name)
+	case cfg.APIType == APITypeAuto || cfg.APIType == "":
+		if isFreeAPIKey(cfg.APIKey) {
+			baseURL, fallbackURL = freeAPIBaseURL, proAPIBaseURL
+			log.Printf("Account %s: detected DeepL Free API key", name)
+		} else {
+			baseURL, fallbackURL = proAPIBaseURL, freeAPIBaseURL
+			log.Printf("Account %s: detected DeepL Pro API key", name)
+		}
+	default:
+		return nil, fmt.Errorf("invalid DeepL API type %q: must be one of %s, %s or %s", cfg.APIType, APITypeAuto, APITypeFree, APITypePro)
+	}
+
+	authHeader := cfg.AuthHeader
+	if authHeader == "" {
+		authHeader = DefaultAuthHeader
+	}
+	authScheme := DefaultAuthScheme
+	if cfg.AuthScheme != nil {
+		authScheme = *cfg.AuthScheme
+	}
+
+	userAgent := cfg.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		var err error
+		if httpClient, err = newHTTPClient(name, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	var limiters []*rate.Limiter
+	if cfg.RateLimit > 0 {
+		limiters = append(limiters, NewRateLimiter(cfg.RateLimit))
+	}
+	if cfg.SharedLimiter != nil {
+		limiters = append(limiters, cfg.SharedLimiter)
+	}
+
+	var shared *sharedFetcher
+	if cfg.SharedCache != nil {
+		shared = &sharedFetcher{cache: cfg.SharedCache, ttl: cfg.SharedCacheTTL, isLeader: cfg.IsLeader}
+	}
+
+	return &Client{
+		name:            name,
+		costCenter:      cfg.CostCenter,
+		project:         cfg.Project,
+		apiKey:          cfg.APIKey,
+		backupAPIKey:    cfg.BackupAPIKey,
+		authHeader:      authHeader,
+		authScheme:      authScheme,
+		headers:         cfg.Headers,
+		userAgent:       userAgent,
+		requestIDHeader: cfg.RequestIDHeader,
+		baseURL:         baseURL,
+		fallbackURL:     fallbackURL,
+		limiters:        limiters,
+		shared:          shared,
+		duration:        requestDuration(cfg.RequestDuration, name),
+		recorder:        cfg.Recorder,
+		availability:    newAvailabilityWindow(24 * time.Hour),
+		http:            httpClient,
+	}, nil
+}
+
+// newHTTPClient returns the client sending the requests of the account
+// name with the proxy, TLS, transport and fault settings of cfg.
+func newHTTPClient(name string, cfg ClientConfig) (*http.Client, error) {
+	transport := newTransport(cfg.Transport)
+	if cfg.ProxyURL != "" {
+		proxyURL, err := parseProxyURL(cfg.ProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+		log.Printf("Account %s: using proxy %s", name, proxyURL.Redacted())
+	}
+
+	if cfg.CAFile != "" || cfg.InsecureSkipVerify {
+		tlsConfig, err := newTLSConfig(cfg.CAFile, cfg.InsecureSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+		if cfg.InsecureSkipVerify {
+			log.Printf("WARNING: account %s: TLS certificate verification is disabled, requests to DeepL can be intercepted", name)
+		}
+	}
+
+	var roundTripper http.RoundTripper = transport
+	if cfg.Faults.enabled() {
+		if err := cfg.Faults.validate(); err != nil {
+			return nil, err
+		}
+		roundTripper = newFaultInjector(transport, cfg.Faults)
+		log.Printf("WARNING: account %s: injecting faults into DeepL requests: %+v", name, cfg.Faults)
+	}
+
+	return &http.Client{
+		Timeout:   DefaultTimeout,
+		Transport: roundTripper,
+	}, nil
+}
+
+// requestDuration curries the account label of the request duration
+// histogram, or returns nil if there is none.
+func requestDuration(histogram *prometheus.HistogramVec, account string) prometheus.ObserverVec {
+	if histogram == nil {
+		return nil
+	}
+	return histogram.MustCurryWith(prometheus.Labels{"account": account})
+}
+
+// NewRateLimiter returns a token bucket allowing perMinute requests per
+// minute, with a burst of up to a full minute's worth.
+func NewRateLimiter(perMinute int) *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(float64(perMinute)/60), perMinute)
+}
+
+// newTransport returns the HTTP transport for requests to DeepL, keeping
+// connections alive across scrapes according to cfg.
+func newTransport(cfg TransportConfig) *http.Transport {
+	if cfg.IdleConnTimeout <= 0 {
+		cfg.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	if cfg.MaxIdleConnsPerHost <= 0 {
+		cfg.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if cfg.TLSHandshakeTimeout <= 0 {
+		cfg.TLSHandshakeTimeout = DefaultTLSHandshakeTimeout
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   DefaultTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	dialContext := dialer.DialContext
+	if cfg.DNSCacheTTL > 0 || len(cfg.StaticHosts) > 0 {
+		dialContext = newDNSCache(cfg.DNSCacheTTL, cfg.StaticHosts).dialContext(dialer)
+	}
+
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConnsPerHost * 2,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// newTLSConfig returns the TLS configuration for requests to DeepL. The CAs
+// in caFile are trusted in addition to the system ones.
+func newTLSConfig(caFile string, insecureSkipVerify bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecureSkipVerify, //nolint:gosec // explicitly requested by the user
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// parseProxyURL validates a user supplied proxy URL.
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: scheme must be http, https, socks5 or socks5h", u.Redacted())
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", u.Redacted())
+	}
+	return u, nil
+}
+
+// APIError is returned when the DeepL API answers with a non-200 status.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// errorReason classifies err into a short, low-cardinality reason such as
+// "status_403" or "timeout".
+func errorReason(err error) string {
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr):
+		return fmt.Sprintf("status_%d", apiErr.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "request_failed"
+	}
+}
+
+// rateLimitTokens describes the requests the rate limiters of the client
+// currently allow without waiting, e.g. "[4.0 60.0]".
+func (c *Client) rateLimitTokens() string {
+	if len(c.limiters) == 0 {
+		return "unlimited"
+	}
+	tokens := make([]string, len(c.limiters))
+	for i, limiter := range c.limiters {
+		tokens[i] = strconv.FormatFloat(limiter.Tokens(), 'f', 1, 64)
+	}
+	return "[" + strings.Join(tokens, " ") + "]"
+}
+
+// Name returns the name of the account the client belongs to.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// CostAllocation returns the cost center and project the spend of the
+// account is attributed to, empty if not configured.
+func (c *Client) CostAllocation() (costCenter, project string) {
+	return c.costCenter, c.project
+}
+
+// Endpoints returns the API base URL currently in use and the one to fall
+// back to, if any.
+func (c *Client) Endpoints() (string, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.baseURL, c.fallbackURL
+}
+
+// EndpointMismatch reports whether the API key was rejected by the endpoint
+// detected from its type and the client switched to the other one.
+func (c *Client) EndpointMismatch() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.mismatch
+}
+
+// Failover reports whether the primary API key was rejected and the client
+// switched to the backup key.
+func (c *Client) Failover() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.failover
+}
+
+// MaskedAPIKey returns the API key currently in use masked with MaskKey.
+func (c *Client) MaskedAPIKey() string {
+	return MaskKey(c.activeAPIKey())
+}
+
+// HasBackupAPIKey reports whether a backup key is configured.
+func (c *Client) HasBackupAPIKey() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.backupAPIKey != ""
+}
+
+// MaskKey returns the last characters of key, enough to tell keys apart.
+func MaskKey(key string) string {
+	if len(key) < 12 {
+		return Redacted
+	}
+	return "..." + key[len(key)-4:]
+}
+
+// activeAPIKey returns the API key currently in use.
+func (c *Client) activeAPIKey() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.apiKey
+}
+
+// switchToBackupKey replaces the rejected key with the backup key, unless
+// there is none. It reports whether the active key now differs from
+// rejectedKey, which is also the case when a concurrent request already
+// switched.
+func (c *Client) switchToBackupKey(ctx context.Context, rejectedKey string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.apiKey != rejectedKey {
+		return true
+	}
+	if c.backupAPIKey == "" || c.failover {
+		return false
+	}
+
+	c.apiKey = c.backupAPIKey
+	c.failover = true
+	logf(ctx, "WARNING: account %s: primary DeepL API key was rejected, switching to the backup key. Replace the primary key and restart the exporter", c.name)
+	return true
+}
+
+// isAuthError reports whether err is DeepL rejecting the API key.
+func isAuthError(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden)
+}
+
+// GetJSON performs an authenticated GET request for the given API path and
+// decodes the JSON response body into v. If the key is rejected with 401 or
+// 403 and a backup key is configured, the request is retried once with the
+// backup key, which is kept for all further requests.
+func (c *Client) GetJSON(ctx context.Context, path string, v any) error {
+	apiKey := c.activeAPIKey()
+	err := c.getJSONWithFallback(ctx, path, apiKey, v)
+	if !isAuthError(err) || !c.switchToBackupKey(ctx, apiKey) {
+		return err
+	}
+	return c.getJSONWithFallback(ctx, path, c.activeAPIKey(), v)
+}
+
+// getJSONWithFallback requests path with apiKey. If the endpoint was
+// detected from the key type and rejects the key with 403, the request is
+// retried once against the other endpoint, which is kept for all further
+// requests if it accepts the key.
+func (c *Client) getJSONWithFallback(ctx context.Context, path, apiKey string, v any) error {
+	baseURL, fallbackURL := c.Endpoints()
+	err := c.get(ctx, baseURL+path, apiKey, v)
+
+	var apiErr *APIError
+	if fallbackURL == "" || !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		return err
+	}
+
+	if fallbackErr := c.get(ctx, fallbackURL+path, apiKey, v); fallbackErr != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if c.baseURL == baseURL {
+		c.baseURL, c.fallbackURL = fallbackURL, baseURL
+		c.mismatch = !c.mismatch
+		logf(ctx, "Account %s: DeepL API key was rejected by %s but accepted by %s, using it from now on. Set the API type to choose the endpoint explicitly", c.name, baseURL, fallbackURL)
+	}
+	c.mu.Unlock()
+
+	return nil
+}
+
+// get decodes the successful response for url into v, going through the
+// shared cache if one is configured. Without it, the response is decoded
+// while it streams in, which is the hot path of every scrape.
+func (c *Client) get(ctx context.Context, url, apiKey string, v any) error {
+	if c.shared == nil {
+		return c.do(ctx, http.MethodGet, url, apiKey, nil, func(body io.Reader) error {
+			return decodeResponse(body, v)
+		})
+	}
+
+	body, err := c.shared.fetch(ctx, sharedCacheKey(apiKey, url), func(ctx context.Context) ([]byte, error) {
+		return c.request(ctx, http.MethodGet, url, apiKey, nil)
+	})
+	if err != nil {
+		return err
+	}
+	return decodeResponse(bytes.NewReader(body), v)
+}
+
+func decodeResponse(body io.Reader, v any) error {
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// PostJSON posts payload as JSON to path with the active key and decodes
+// the response into v. Unlike GetJSON, it never goes through the shared
+// cache, as the request has side effects.
+func (c *Client) PostJSON(ctx context.Context, path string, payload, v any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	baseURL, _ := c.Endpoints()
+	return c.do(ctx, http.MethodPost, baseURL+path, c.activeAPIKey(), data, func(body io.Reader) error {
+		return decodeResponse(body, v)
+	})
+}
+
+// request sends a request authenticated with apiKey to DeepL, with payload
+// as JSON body if not nil, and returns a copy of the body of a successful
+// response, e.g. to store it in the shared cache.
+func (c *Client) request(ctx context.Context, method, url, apiKey string, payload []byte) ([]byte, error) {
+	var body []byte
+	err := c.do(ctx, method, url, apiKey, payload, func(r io.Reader) error {
+		var err error
+		body, err = io.ReadAll(r)
+		return err
+	})
+	return body, err
+}
+
+// responseBuffers recycles the buffers the bodies of failed responses, and
+// of all responses while recording, are read into.
+var responseBuffers = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+const (
+	// maxPooledBuffer keeps the buffers of unusually large responses out
+	// of the pool, so a single one doesn't pin its memory.
+	maxPooledBuffer = 1 << 20
+	// maxResponseSize bounds the body of a successful response, so a
+	// misbehaving upstream cannot make the exporter read without end.
+	maxResponseSize = 32 << 20
+	// maxErrorBodySize bounds the body of a failed response kept in its
+	// *APIError.
+	maxErrorBodySize = 64 << 10
+)
+
+// do sends a request authenticated with apiKey to DeepL, with payload as
+// JSON body if not nil, and passes the body of a successful response, up to
+// maxResponseSize, to handle while it streams in. Unsuccessful responses are
+// returned as *APIError.
+func (c *Client) do(ctx context.Context, method, url, apiKey string, payload []byte, handle func(body io.Reader) error) error {
+	for _, limiter := range c.limiters {
+		if err := limiter.Wait(ctx); err != nil {
+			return fmt.Errorf("rate limit exceeded: %w", err)
+		}
+	}
+
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	req.Header.Set("User-Agent", c.userAgent)
+	if id := requestID(ctx); c.requestIDHeader != "" && id != "" {
+		req.Header.Set(c.requestIDHeader, id)
+	}
+	if trace, ok := traceFrom(ctx); ok {
+		req.Header.Set("traceparent", trace.traceparent())
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	authValue := apiKey
+	if c.authScheme != "" {
+		authValue = c.authScheme + " " + apiKey
+	}
+	req.Header.Set(c.authHeader, authValue)
+
+	begin := time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// Requests canceled by the caller say nothing about DeepL.
+		if ctx.Err() == nil {
+			c.availability.record(begin, false)
+		}
+		return fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	c.availability.record(begin, resp.StatusCode == http.StatusOK)
+	if c.duration != nil {
+		observer := c.duration.WithLabelValues(req.URL.Path)
+		if trace, ok := sampledTrace(ctx); ok {
+			observer.(prometheus.ExemplarObserver).ObserveWithExemplar(time.Since(begin).Seconds(), prometheus.Labels{"trace_id": trace.traceID})
+		} else {
+			observer.Observe(time.Since(begin).Seconds())
+		}
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logf(ctx, "failed to close response body: %v", err)
+		}
+	}()
+
+	var r io.Reader = resp.Body
+	if resp.StatusCode != http.StatusOK || c.recorder != nil {
+		buf := responseBuffers.Get().(*bytes.Buffer)
+		buf.Reset()
+		defer func() {
+			if buf.Cap() <= maxPooledBuffer {
+				responseBuffers.Put(buf)
+			}
+		}()
+		if resp.StatusCode != http.StatusOK {
+			_, _ = buf.ReadFrom(io.LimitReader(resp.Body, maxErrorBodySize))
+			if c.recorder != nil {
+				c.recorder.Record(c.name, req, resp, buf.Bytes(), apiKey)
+			}
+			return &APIError{StatusCode: resp.StatusCode, Body: buf.String()}
+		}
+		// The recorder gets the body as far as it was read.
+		defer func() {
+			c.recorder.Record(c.name, req, resp, buf.Bytes(), apiKey)
+		}()
+		r = io.TeeReader(r, buf)
+	}
+
+	body := &io.LimitedReader{R: r, N: maxResponseSize + 1}
+	if err := handle(body); err != nil {
+		if body.N == 0 {
+			return fmt.Errorf("response exceeds %d bytes", maxResponseSize)
+		}
+		return err
+	}
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if body.N == 0 {
+		return fmt.Errorf("response exceeds %d bytes", maxResponseSize)
+	}
+	return nil
+}
@@ -0,0 +1,687 @@
+package deepl
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// newTestClient returns a Client using the "test-key" API key against
+// serverURL, or the detected DeepL endpoint if serverURL is empty.
+func newTestClient(t *testing.T, serverURL string) *Client {
+	t.Helper()
+	c, err := NewClient(ClientConfig{APIKey: "test-key", ServerURL: serverURL})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return c
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		apiKey   string
+		apiType  string
+		expected string
+	}{
+		{
+			name:     "Free API Key",
+			apiKey:   "test-key:fx",
+			expected: "https://api-free.deepl.com",
+		},
+		{
+			name:     "Pro API Key",
+			apiKey:   "test-key",
+			expected: "https://api.deepl.com",
+		},
+		{
+			name:     "Auto API Type",
+			apiKey:   "test-key:fx",
+			apiType:  "auto",
+			expected: "https://api-free.deepl.com",
+		},
+		{
+			name:     "Forced Free API Type",
+			apiKey:   "test-key:fx-with-metadata",
+			apiType:  "free",
+			expected: "https://api-free.deepl.com",
+		},
+		{
+			name:     "Forced Pro API Type",
+			apiKey:   "test-key:fx",
+			apiType:  "pro",
+			expected: "https://api.deepl.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient(ClientConfig{APIKey: tt.apiKey, APIType: tt.apiType})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.baseURL != tt.expected {
+				t.Errorf("expected URL %s, got %s", tt.expected, c.baseURL)
+			}
+		})
+	}
+}
+
+func TestNewClient_InvalidAPIType(t *testing.T) {
+	_, err := NewClient(ClientConfig{APIKey: "test-key", APIType: "enterprise"})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestClient_getJSON_Error(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintln(w, "internal error")
+	}))
+	defer ts.Close()
+
+	c := newTestClient(t, ts.URL)
+
+	var v map[string]any
+	err := c.GetJSON(context.Background(), UsagePath, &v)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "API returned status 500") {
+		t.Errorf("expected status 500 error, got %v", err)
+	}
+}
+
+func TestClient_Availability(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{}`)
+	}))
+	defer ts.Close()
+
+	c := newTestClient(t, ts.URL)
+	for range 3 {
+		var v map[string]any
+		_ = c.GetJSON(context.Background(), UsagePath, &v)
+	}
+	// A canceled request does not count.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = c.GetJSON(ctx, UsagePath, nil)
+
+	// Every request counts, not only whether a module failed.
+	if ratio, ok := c.availability.ratio(time.Now(), time.Hour); !ok || ratio != 2.0/3 {
+		t.Errorf("expected an availability of 2/3, got %v", ratio)
+	}
+}
+
+func TestClient_getJSON_ResponseLimits(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == GlossariesPath {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = io.Copy(w, io.LimitReader(neverEnding('x'), 2*maxErrorBodySize))
+			return
+		}
+		// A valid document followed by more than the limit.
+		_, _ = fmt.Fprint(w, `{"character_count": 1} `)
+		_, _ = io.Copy(w, io.LimitReader(neverEnding(' '), maxResponseSize))
+	}))
+	defer ts.Close()
+
+	c := newTestClient(t, ts.URL)
+	var usage DeepLUsage
+	if err := c.GetJSON(context.Background(), UsagePath, &usage); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("expected the response to exceed the limit, got %v", err)
+	}
+
+	var apiErr *APIError
+	if err := c.GetJSON(context.Background(), GlossariesPath, &usage); !errors.As(err, &apiErr) || len(apiErr.Body) != maxErrorBodySize {
+		t.Errorf("expected the error body to be truncated to %d bytes, got %v", maxErrorBodySize, err)
+	}
+}
+
+// neverEnding is an endless stream of the same byte.
+type neverEnding byte
+
+func (b neverEnding) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(b)
+	}
+	return len(p), nil
+}
+
+func TestNewClient_ServerURL(t *testing.T) {
+	c, err := NewClient(ClientConfig{APIKey: "test-key:fx", ServerURL: "http://localhost:3000/"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.baseURL != "http://localhost:3000" {
+		t.Errorf("expected URL http://localhost:3000, got %s", c.baseURL)
+	}
+}
+
+func TestNormalizeServerURL(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected string
+		wantErr  bool
+	}{
+		{raw: "https://gateway.example.com/deepl/", expected: "https://gateway.example.com/deepl"},
+		{raw: "http://localhost:3000", expected: "http://localhost:3000"},
+		{raw: "localhost:3000", wantErr: true},
+		{raw: "ftp://example.com", wantErr: true},
+		{raw: "https://", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := NormalizeServerURL(tt.raw)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestClient_getJSON_EndpointFallback(t *testing.T) {
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer rejecting.Close()
+
+	requests := 0
+	accepting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = fmt.Fprintln(w, `{"character_count": 1, "character_limit": 2}`)
+	}))
+	defer accepting.Close()
+
+	c := newTestClient(t, "")
+	c.baseURL, c.fallbackURL = rejecting.URL, accepting.URL
+
+	for range 2 {
+		var usage DeepLUsage
+		if err := c.GetJSON(context.Background(), UsagePath, &usage); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if !c.EndpointMismatch() {
+		t.Error("expected endpoint mismatch to be reported")
+	}
+	if c.baseURL != accepting.URL {
+		t.Errorf("expected client to switch to %s, got %s", accepting.URL, c.baseURL)
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests to the fallback endpoint, got %d", requests)
+	}
+}
+
+func TestClient_getJSON_NoFallbackWhenConfigured(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	c := newTestClient(t, ts.URL)
+
+	var usage DeepLUsage
+	err := c.GetJSON(context.Background(), UsagePath, &usage)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 API error, got %v", err)
+	}
+	if c.EndpointMismatch() {
+		t.Error("expected no endpoint mismatch")
+	}
+}
+
+func TestClient_getJSON_BackupKeyFailover(t *testing.T) {
+	var primaryRequests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "DeepL-Auth-Key backup-key":
+			_, _ = fmt.Fprintln(w, `{"character_count": 1, "character_limit": 2}`)
+		default:
+			primaryRequests.Add(1)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(ClientConfig{APIKey: "revoked-key", BackupAPIKey: "backup-key", ServerURL: ts.URL})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	for range 2 {
+		var usage DeepLUsage
+		if err := c.GetJSON(context.Background(), UsagePath, &usage); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if !c.Failover() {
+		t.Error("expected failover to be reported")
+	}
+	if n := primaryRequests.Load(); n != 1 {
+		t.Errorf("expected 1 request with the primary key, got %d", n)
+	}
+}
+
+func TestClient_getJSON_NoBackupKey(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	c := newTestClient(t, ts.URL)
+
+	var usage DeepLUsage
+	if err := c.GetJSON(context.Background(), UsagePath, &usage); !isAuthError(err) {
+		t.Fatalf("expected 401 API error, got %v", err)
+	}
+	if c.Failover() {
+		t.Error("expected no failover without a backup key")
+	}
+}
+
+func TestClient_getJSON_Headers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Gateway-Key") != "test-key" || r.Header.Get("X-Tenant") != "team-a" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{}`)
+	}))
+	defer ts.Close()
+
+	scheme := ""
+	c, err := NewClient(ClientConfig{
+		Name:       "team-a",
+		APIKey:     "test-key",
+		ServerURL:  ts.URL,
+		AuthHeader: "X-Gateway-Key",
+		AuthScheme: &scheme,
+		Headers:    map[string]string{"X-Tenant": "team-a"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var v map[string]any
+	if err := c.GetJSON(context.Background(), UsagePath, &v); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClient_getJSON_UserAgent(t *testing.T) {
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.UserAgent())
+		_, _ = fmt.Fprintln(w, `{}`)
+	}))
+	defer ts.Close()
+
+	for _, cfg := range []ClientConfig{
+		{},
+		{UserAgent: "deepl-exporter/v1.2.3 site/eu-1"},
+		{UserAgent: "deepl-exporter/v1.2.3", Headers: map[string]string{"User-Agent": "gateway-client"}},
+	} {
+		cfg.Name, cfg.APIKey, cfg.ServerURL = "default", "test-key", ts.URL
+		c, err := NewClient(cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var v map[string]any
+		if err := c.GetJSON(context.Background(), UsagePath, &v); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	want := []string{"deepl-exporter", "deepl-exporter/v1.2.3 site/eu-1", "gateway-client"}
+	if !slices.Equal(got, want) {
+		t.Errorf("User-Agents = %q, want %q", got, want)
+	}
+}
+
+func TestClient_getJSON_RequestIDHeader(t *testing.T) {
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("X-Request-ID"))
+		_, _ = fmt.Fprintln(w, `{}`)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(ClientConfig{Name: "default", APIKey: "test-key", ServerURL: ts.URL, RequestIDHeader: "X-Request-ID"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := withRequestID(context.Background())
+	var v map[string]any
+	for _, ctx := range []context.Context{ctx, context.Background()} {
+		if err := c.GetJSON(ctx, UsagePath, &v); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if want := []string{requestID(ctx), ""}; !slices.Equal(got, want) {
+		t.Errorf("X-Request-ID headers = %q, want %q", got, want)
+	}
+}
+
+func TestClient_getJSON_Proxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := parseProxyAuthorization(r.Header.Get("Proxy-Authorization"))
+		if !ok || user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		if r.URL.Host != "deepl.invalid" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{}`)
+	}))
+	defer proxy.Close()
+
+	c, err := NewClient(ClientConfig{
+		APIKey:    "test-key",
+		ServerURL: "http://deepl.invalid",
+		ProxyURL:  strings.Replace(proxy.URL, "http://", "http://user:secret@", 1),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var v map[string]any
+	if err := c.GetJSON(context.Background(), UsagePath, &v); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewClient_InvalidProxyURL(t *testing.T) {
+	for _, proxyURL := range []string{"proxy:3128", "ftp://proxy:21", "http://"} {
+		if _, err := NewClient(ClientConfig{APIKey: "test-key", ProxyURL: proxyURL}); err == nil {
+			t.Errorf("expected error for proxy URL %q", proxyURL)
+		}
+	}
+}
+
+// parseProxyAuthorization decodes a basic Proxy-Authorization header value.
+func parseProxyAuthorization(header string) (string, string, bool) {
+	r := &http.Request{Header: http.Header{"Authorization": {header}}}
+	return r.BasicAuth()
+}
+
+func TestClient_getJSON_CAFile(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `{}`)
+	}))
+	defer ts.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatalf("failed to write CA file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     ClientConfig
+		wantErr bool
+	}{
+		{name: "Untrusted", cfg: ClientConfig{}, wantErr: true},
+		{name: "CA file", cfg: ClientConfig{CAFile: caFile}},
+		{name: "Insecure", cfg: ClientConfig{InsecureSkipVerify: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.APIKey = "test-key"
+			tt.cfg.ServerURL = ts.URL
+			c, err := NewClient(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var v map[string]any
+			err = c.GetJSON(context.Background(), UsagePath, &v)
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error: %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNewClient_InvalidCAFile(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("failed to write CA file: %v", err)
+	}
+	if _, err := NewClient(ClientConfig{APIKey: "test-key", CAFile: caFile}); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestClient_getJSON_ReusesConnections(t *testing.T) {
+	var connections atomic.Int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `{}`)
+	}))
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	c := newTestClient(t, ts.URL)
+	for range 3 {
+		var v map[string]any
+		if err := c.GetJSON(context.Background(), UsagePath, &v); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if n := connections.Load(); n != 1 {
+		t.Errorf("expected 1 connection, got %d", n)
+	}
+}
+
+func TestNewTransport_Defaults(t *testing.T) {
+	transport := newTransport(TransportConfig{})
+	if transport.IdleConnTimeout != DefaultIdleConnTimeout {
+		t.Errorf("expected idle timeout %s, got %s", DefaultIdleConnTimeout, transport.IdleConnTimeout)
+	}
+	if transport.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost {
+		t.Errorf("expected %d idle connections per host, got %d", DefaultMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	}
+	if transport.TLSHandshakeTimeout != DefaultTLSHandshakeTimeout {
+		t.Errorf("expected TLS handshake timeout %s, got %s", DefaultTLSHandshakeTimeout, transport.TLSHandshakeTimeout)
+	}
+}
+
+func TestClient_getJSON_RateLimit(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = fmt.Fprintln(w, `{}`)
+	}))
+	defer ts.Close()
+
+	shared := NewRateLimiter(2)
+	newClient := func(name string) *Client {
+		c, err := NewClient(ClientConfig{
+			Name:          name,
+			APIKey:        "test-key",
+			ServerURL:     ts.URL,
+			RateLimit:     1,
+			SharedLimiter: shared,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return c
+	}
+	a, b, c := newClient("a"), newClient("b"), newClient("c")
+
+	get := func(client *Client) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		var v map[string]any
+		return client.GetJSON(ctx, UsagePath, &v)
+	}
+
+	if err := get(a); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := get(a); err == nil {
+		t.Error("expected per-account rate limit to reject the second request")
+	}
+	if err := get(b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := get(c); err == nil {
+		t.Error("expected shared rate limit to reject the third account")
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests to reach the API, got %d", requests)
+	}
+}
+
+func TestErrorReason(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{err: &APIError{StatusCode: http.StatusForbidden}, expected: "status_403"},
+		{err: fmt.Errorf("failed to fetch: %w", context.DeadlineExceeded), expected: "timeout"},
+		{err: context.Canceled, expected: "canceled"},
+		{err: errors.New("connection refused"), expected: "request_failed"},
+	}
+
+	for _, tt := range tests {
+		if got := errorReason(tt.err); got != tt.expected {
+			t.Errorf("%v: expected %q, got %q", tt.err, tt.expected, got)
+		}
+	}
+}
+
+func TestClient_getJSON_RequestDuration(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `{"character_count": 1, "character_limit": 2}`)
+	}))
+	defer ts.Close()
+
+	histogram := NewRequestDurationHistogram(prometheus.DefBuckets, true)
+	c, err := NewClient(ClientConfig{APIKey: "test-key", ServerURL: ts.URL, RequestDuration: histogram})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	var usage DeepLUsage
+	if err := c.GetJSON(context.Background(), UsagePath, &usage); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	metric := &dto.Metric{}
+	if err := histogram.WithLabelValues("default", UsagePath).(prometheus.Metric).Write(metric); err != nil {
+		t.Fatalf("failed to read histogram: %v", err)
+	}
+	h := metric.GetHistogram()
+	if h.GetSampleCount() != 1 {
+		t.Errorf("expected 1 observation, got %d", h.GetSampleCount())
+	}
+	if len(h.GetPositiveSpan()) == 0 && h.GetZeroCount() == 0 {
+		t.Error("expected native histogram buckets")
+	}
+}
+
+// cannedTransport answers every request with body, without a network
+// round trip, so benchmarks measure the client itself.
+type cannedTransport struct {
+	body []byte
+}
+
+func (t cannedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(t.body)),
+		Request:    r,
+	}, nil
+}
+
+func benchmarkGetJSON[T any](b *testing.B, path string, body []byte) {
+	c, err := NewClient(ClientConfig{Name: "default", APIKey: "test-key", ServerURL: "http://deepl.invalid"})
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	c.http.Transport = cannedTransport{body: body}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+	for b.Loop() {
+		var v T
+		if err := c.GetJSON(ctx, path, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkClient_getJSON_Usage(b *testing.B) {
+	body := []byte(`{"character_count":180118,"character_limit":1250000,"api_key_character_count":4000,"api_key_character_limit":50000,` +
+		`"products":[{"product_type":"translate","character_count":120000},{"product_type":"write","character_count":60118}],` +
+		`"model_types":[{"model_type":"quality_optimized","character_count":100000},{"model_type":"latency_optimized","character_count":80118}]}`)
+	benchmarkGetJSON[DeepLUsage](b, UsagePath, body)
+}
+
+func BenchmarkClient_getJSON_DeveloperKeys(b *testing.B) {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i := range 500 {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, `{"key_id":"key-%04d","label":"team %d","is_deactivated":false,"usage_limits":{"characters":1000000}}`, i, i)
+	}
+	buf.WriteString("]")
+	benchmarkGetJSON[[]DeepLDeveloperKey](b, adminKeysPath, buf.Bytes())
+}
@@ -0,0 +1,687 @@
+package deepl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+// Collector is implemented by every module that exports metrics for one
+// area of the DeepL API. Update is called once per configured account with
+// that account's Client and must label its metrics with client.Name().
+// Modules report failures through the returned error instead of logging
+// them themselves.
+type Collector interface {
+	Describe(ch chan<- *prometheus.Desc)
+	Update(ctx context.Context, client *Client, ch chan<- prometheus.Metric) error
+}
+
+// CollectorFactory creates a Collector module configured by opts.
+type CollectorFactory func(opts Options) Collector
+
+type registeredCollector struct {
+	factory          CollectorFactory
+	enabledByDefault bool
+}
+
+var collectorFactories = make(map[string]registeredCollector)
+
+// RegisterCollector makes a collector module available under the given
+// name, e.g. to Options.Collectors. It is meant to be called from init and
+// panics if the name is taken.
+func RegisterCollector(name string, enabledByDefault bool, factory CollectorFactory) {
+	if _, ok := collectorFactories[name]; ok {
+		panic(fmt.Sprintf("collector %q registered twice", name))
+	}
+	collectorFactories[name] = registeredCollector{factory: factory, enabledByDefault: enabledByDefault}
+}
+
+// Collectors returns the names of all registered collector modules, mapped
+// to whether they are enabled by default.
+func Collectors() map[string]bool {
+	collectors := make(map[string]bool, len(collectorFactories))
+	for name, registered := range collectorFactories {
+		collectors[name] = registered.enabledByDefault
+	}
+	return collectors
+}
+
+// DefaultCollectors returns the sorted names of the collector modules
+// enabled by default.
+func DefaultCollectors() []string {
+	var names []string
+	for name, registered := range collectorFactories {
+		if registered.enabledByDefault {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewModule creates the collector module registered under name, e.g. to
+// run it on its own for a probe.
+func NewModule(name string, opts Options) (Collector, error) {
+	registered, ok := collectorFactories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown collector %q", name)
+	}
+	return registered.factory(opts.withDefaults()), nil
+}
+
+// NewRequestDurationHistogram returns the histogram of the latency of the
+// requests sent to DeepL with the given classic buckets. With native set, it
+// is additionally exposed as a native histogram to clients scraping with the
+// protobuf format.
+func NewRequestDurationHistogram(buckets []float64, native bool) *prometheus.HistogramVec {
+	opts := prometheus.HistogramOpts{
+		Name:    "deepl_api_request_duration_seconds",
+		Help:    "Latency of the requests sent to the DeepL API",
+		Buckets: buckets,
+	}
+	if native {
+		opts.NativeHistogramBucketFactor = 1.1
+		opts.NativeHistogramMaxBucketNumber = 100
+		opts.NativeHistogramMinResetDuration = time.Hour
+	}
+	return prometheus.NewHistogramVec(opts, []string{"account", "path"})
+}
+
+// ParseBuckets parses a comma-separated list of histogram bucket upper
+// bounds.
+func ParseBuckets(list string) ([]float64, error) {
+	var buckets []float64
+	for _, value := range strings.Split(list, ",") {
+		bound, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid histogram bucket %q: %w", value, err)
+		}
+		buckets = append(buckets, bound)
+	}
+	if err := ValidateBuckets(buckets); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// ValidateBuckets checks that histogram bucket upper bounds are positive and
+// strictly increasing.
+func ValidateBuckets(buckets []float64) error {
+	for i, bound := range buckets {
+		if bound <= 0 {
+			return fmt.Errorf("histogram bucket %g must be positive", bound)
+		}
+		if i > 0 && bound <= buckets[i-1] {
+			return fmt.Errorf("histogram buckets must be strictly increasing, got %g after %g", bound, buckets[i-1])
+		}
+	}
+	return nil
+}
+
+// DeepLCollector is the prometheus.Collector registered with the registry.
+// It runs every enabled Collector module concurrently for every account and
+// exports per-module scrape duration, success and error metrics. In polling
+// mode the modules are run by a Poller instead and Collect serves the
+// metrics cached by Refresh.
+type DeepLCollector struct {
+	// clients are the accounts, which may be added and removed at runtime,
+	// see AddClient.
+	clientsMu       sync.RWMutex
+	clients         []*Client
+	names           []string
+	collectors      map[string]Collector
+	scrapeDuration  *prometheus.Desc
+	scrapeSuccess   *prometheus.Desc
+	mismatch        *prometheus.Desc
+	failover        *prometheus.Desc
+	lastRefresh     *prometheus.Desc
+	availability1h  *prometheus.Desc
+	availability24h *prometheus.Desc
+	apiErrors       *prometheus.CounterVec
+
+	// opts configures the modules created for probes, see Probe.
+	opts    Options
+	polling bool
+	// tracing propagates the trace of a scrape to DeepL, see
+	// ContextWithTraceparent.
+	tracing bool
+	mu      sync.RWMutex
+	cache   map[string]cachedMetrics
+
+	statusMu sync.Mutex
+	status   map[moduleKey]moduleStatus
+
+	// probeModules are the modules probes run that c does not, see
+	// probeModule.
+	probeMu      sync.Mutex
+	probeModules map[string]Collector
+}
+
+// moduleKey identifies a module run for one account.
+type moduleKey struct {
+	account, collector string
+}
+
+// moduleStatus is the outcome of the last runs of a module for one account,
+// kept for diagnostics.
+type moduleStatus struct {
+	lastSuccess time.Time
+	lastError   error
+	lastFailure time.Time
+}
+
+// cachedMetrics holds the metrics of one account fetched by Refresh, and
+// the usage they were derived from if the usage module succeeded.
+type cachedMetrics struct {
+	metrics   []prometheus.Metric
+	usage     *DeepLUsage
+	refreshed time.Time
+}
+
+// usageSlot receives the usage fetched by the usage module during a
+// Refresh, see reportUsage.
+type usageSlot struct {
+	mu    sync.Mutex
+	usage *DeepLUsage
+}
+
+type usageSlotKey struct{}
+
+// reportUsage hands the usage fetched within ctx to the Refresh it belongs
+// to, if any, to be cached along with the metrics.
+func reportUsage(ctx context.Context, usage *DeepLUsage) {
+	if slot, ok := ctx.Value(usageSlotKey{}).(*usageSlot); ok {
+		slot.mu.Lock()
+		slot.usage = usage
+		slot.mu.Unlock()
+	}
+}
+
+// Options configures the collector returned by New.
+type Options struct {
+	// Clients are the DeepL accounts to export metrics for, see NewClient
+	// for the HTTP client and API URL of each. At least one is required.
+	Clients []*Client
+	// Collectors are the names of the modules to run, DefaultCollectors()
+	// if nil.
+	Collectors []string
+	// Usage configures the usage module, DefaultUsageOptions() if nil.
+	Usage *UsageOptions
+	// Tracing propagates the W3C trace of a scrape, see ForRequest and
+	// ContextWithTraceparent, to DeepL and attaches the trace ID to the
+	// request metrics as exemplars if the trace is sampled.
+	Tracing bool
+	// Registerer, if set, registers the collector, e.g. with the registry
+	// of an existing /metrics endpoint.
+	Registerer prometheus.Registerer
+}
+
+func (o Options) withDefaults() Options {
+	if o.Usage == nil {
+		usage := DefaultUsageOptions()
+		o.Usage = &usage
+	}
+	return o
+}
+
+// New returns a collector exporting the DeepL usage metrics of the
+// accounts in opts, so other services can serve them from their own
+// /metrics endpoint:
+//
+//	client, err := deepl.NewClient(deepl.ClientConfig{Name: "default", APIKey: key})
+//	...
+//	_, err = deepl.New(deepl.Options{
+//		Clients:    []*deepl.Client{client},
+//		Registerer: prometheus.DefaultRegisterer,
+//	})
+//
+// Every scrape calls the DeepL API once per account and module.
+func New(opts Options) (*DeepLCollector, error) {
+	if len(opts.Clients) == 0 {
+		return nil, errors.New("at least one client is required")
+	}
+	opts = opts.withDefaults()
+	if opts.Collectors == nil {
+		opts.Collectors = DefaultCollectors()
+	}
+
+	c, err := newDeepLCollector(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Registerer != nil {
+		if err := opts.Registerer.Register(c); err != nil {
+			return nil, fmt.Errorf("failed to register the DeepL collector: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// NewDeepLCollector returns a collector running the named modules with
+// their default options for clients.
+func NewDeepLCollector(clients []*Client, names []string) (*DeepLCollector, error) {
+	return newDeepLCollector(Options{Clients: clients, Collectors: names}.withDefaults())
+}
+
+func newDeepLCollector(opts Options) (*DeepLCollector, error) {
+	clients, names := opts.Clients, opts.Collectors
+	collectors := make(map[string]Collector, len(names))
+	for _, name := range names {
+		module, err := NewModule(name, opts)
+		if err != nil {
+			return nil, err
+		}
+		collectors[name] = module
+	}
+
+	c := &DeepLCollector{
+		clients:    clients,
+		names:      names,
+		collectors: collectors,
+		opts:       opts,
+		tracing:    opts.Tracing,
+		cache:      make(map[string]cachedMetrics),
+		status:     make(map[moduleKey]moduleStatus),
+		scrapeDuration: prometheus.NewDesc(
+			"deepl_scrape_collector_duration_seconds",
+			"Duration of a collector scrape",
+			[]string{"account", "collector"},
+			nil,
+		),
+		scrapeSuccess: prometheus.NewDesc(
+			"deepl_scrape_collector_success",
+			"Whether a collector succeeded",
+			[]string{"account", "collector"},
+			nil,
+		),
+		mismatch: prometheus.NewDesc(
+			"deepl_api_endpoint_mismatch",
+			"Whether the API key was rejected by the endpoint detected from its type and the other endpoint is used instead",
+			[]string{"account"},
+			nil,
+		),
+		failover: prometheus.NewDesc(
+			"deepl_api_key_failover",
+			"Whether the primary API key was rejected and the backup key is used instead",
+			[]string{"account"},
+			nil,
+		),
+		lastRefresh: prometheus.NewDesc(
+			"deepl_last_refresh_timestamp_seconds",
+			"Unix timestamp of the last background refresh of the account's metrics in polling mode",
+			[]string{"account"},
+			nil,
+		),
+		availability1h: prometheus.NewDesc(
+			"deepl_api_availability_ratio_1h",
+			"Share of the requests to the DeepL API that succeeded during the last hour",
+			[]string{"account"},
+			nil,
+		),
+		availability24h: prometheus.NewDesc(
+			"deepl_api_availability_ratio_24h",
+			"Share of the requests to the DeepL API that succeeded during the last 24 hours",
+			[]string{"account"},
+			nil,
+		),
+		apiErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "deepl_api_errors_total",
+				Help: "Total number of failed DeepL API scrapes by collector",
+			},
+			[]string{"account", "collector"},
+		),
+	}
+
+	for _, client := range clients {
+		c.initClient(client)
+	}
+
+	return c, nil
+}
+
+// initClient prepares the per-account state of a new client.
+func (c *DeepLCollector) initClient(client *Client) {
+	for _, name := range c.names {
+		c.apiErrors.WithLabelValues(client.Name(), name)
+	}
+}
+
+func (c *DeepLCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, name := range c.names {
+		c.collectors[name].Describe(ch)
+	}
+	ch <- c.scrapeDuration
+	ch <- c.scrapeSuccess
+	ch <- c.mismatch
+	ch <- c.failover
+	ch <- c.lastRefresh
+	ch <- c.availability1h
+	ch <- c.availability24h
+	c.apiErrors.Describe(ch)
+}
+
+func (c *DeepLCollector) Collect(ch chan<- prometheus.Metric) {
+	c.collectClients(context.Background(), c.Clients(), ch)
+}
+
+// collectClients collects the metrics of the given accounts only, fetching
+// them within ctx unless polling.
+func (c *DeepLCollector) collectClients(ctx context.Context, clients []*Client, ch chan<- prometheus.Metric) {
+	if c.polling {
+		c.collectCached(clients, ch)
+	} else {
+		ctx, cancel := context.WithTimeout(c.scrapeContext(ctx), DefaultTimeout)
+		defer cancel()
+
+		var g errgroup.Group
+		for _, client := range clients {
+			g.Go(func() error {
+				c.collectAccount(ctx, client, ch)
+				return nil
+			})
+		}
+		_ = g.Wait()
+	}
+
+	for _, client := range clients {
+		mismatch := 0.0
+		if client.EndpointMismatch() {
+			mismatch = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.mismatch, prometheus.GaugeValue, mismatch, client.Name())
+
+		failover := 0.0
+		if client.Failover() {
+			failover = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.failover, prometheus.GaugeValue, failover, client.Name())
+	}
+	c.collectAvailability(clients, ch)
+
+	for _, client := range clients {
+		for _, name := range c.names {
+			ch <- c.apiErrors.WithLabelValues(client.Name(), name)
+		}
+	}
+}
+
+// collectAvailability exports the availability ratios of the accounts that
+// sent requests during the windows.
+func (c *DeepLCollector) collectAvailability(clients []*Client, ch chan<- prometheus.Metric) {
+	now := time.Now()
+	for _, client := range clients {
+		window := client.availability
+		if ratio, ok := window.ratio(now, time.Hour); ok {
+			ch <- prometheus.MustNewConstMetric(c.availability1h, prometheus.GaugeValue, ratio, client.Name())
+		}
+		if ratio, ok := window.ratio(now, 24*time.Hour); ok {
+			ch <- prometheus.MustNewConstMetric(c.availability24h, prometheus.GaugeValue, ratio, client.Name())
+		}
+	}
+}
+
+// EnablePolling switches c to polling mode, in which Collect serves the
+// metrics cached by Refresh instead of calling DeepL, e.g. for a poller
+// refreshing the accounts in the background. It must be called before c is
+// collected for the first time.
+func (c *DeepLCollector) EnablePolling() {
+	c.polling = true
+}
+
+// Polling reports whether c is in polling mode.
+func (c *DeepLCollector) Polling() bool {
+	return c.polling
+}
+
+// CollectorNames returns the names of the modules c runs.
+func (c *DeepLCollector) CollectorNames() []string {
+	return c.names
+}
+
+// CachedMetrics returns the metrics of the account cached by the last
+// Refresh and when it happened, ok is false if it was never refreshed.
+func (c *DeepLCollector) CachedMetrics(account string) (metrics []prometheus.Metric, refreshed time.Time, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cached, ok := c.cache[account]
+	return cached.metrics, cached.refreshed, ok
+}
+
+// CachedUsage returns the usage of the account fetched by the last Refresh
+// and when it happened, ok is false if it was never refreshed or the usage
+// module failed or is disabled.
+func (c *DeepLCollector) CachedUsage(account string) (usage DeepLUsage, refreshed time.Time, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cached, ok := c.cache[account]
+	if !ok || cached.usage == nil {
+		return DeepLUsage{}, cached.refreshed, false
+	}
+	return *cached.usage, cached.refreshed, true
+}
+
+// ForAccounts returns a prometheus.Collector exporting the same metrics as
+// c, restricted to the accounts with the given names, each exported once
+// even if repeated. It fails if one of them is not configured.
+func (c *DeepLCollector) ForAccounts(names []string) (prometheus.Collector, error) {
+	return c.ForRequest(context.Background(), names)
+}
+
+// ForRequest is like ForAccounts, for all accounts if names is empty, but
+// fetches the metrics within ctx, e.g. of the scrape request, so the DeepL
+// requests continue its trace.
+func (c *DeepLCollector) ForRequest(ctx context.Context, names []string) (prometheus.Collector, error) {
+	if len(names) == 0 {
+		return &accountsCollector{ctx: ctx, parent: c, clients: c.Clients()}, nil
+	}
+	clients := make([]*Client, 0, len(names))
+	for _, name := range names {
+		client := c.Client(name)
+		if client == nil {
+			return nil, fmt.Errorf("unknown account %q", name)
+		}
+		if !slices.Contains(clients, client) {
+			clients = append(clients, client)
+		}
+	}
+	return &accountsCollector{ctx: ctx, parent: c, clients: clients}, nil
+}
+
+// accountsCollector is a view of a DeepLCollector for some accounts only.
+type accountsCollector struct {
+	ctx     context.Context
+	parent  *DeepLCollector
+	clients []*Client
+}
+
+func (a *accountsCollector) Describe(ch chan<- *prometheus.Desc) {
+	a.parent.Describe(ch)
+}
+
+func (a *accountsCollector) Collect(ch chan<- prometheus.Metric) {
+	a.parent.collectClients(a.ctx, a.clients, ch)
+}
+
+// scrapeContext returns ctx identifying a scrape or poll by its request ID.
+// The trace ctx may carry is only kept if tracing is enabled.
+func (c *DeepLCollector) scrapeContext(ctx context.Context) context.Context {
+	ctx = withRequestID(ctx)
+	if !c.tracing {
+		ctx = withoutTrace(ctx)
+	}
+	return ctx
+}
+
+// collectAccount runs every enabled module concurrently for one account.
+// Failures are recorded per collector instead of aborting the others, so a
+// single failing endpoint never hides the results of the others. The error
+// of every module, nil on success, is returned by collector name.
+func (c *DeepLCollector) collectAccount(ctx context.Context, client *Client, ch chan<- prometheus.Metric) map[string]error {
+	ctx = c.scrapeContext(ctx)
+	var (
+		g    errgroup.Group
+		mu   sync.Mutex
+		errs = make(map[string]error, len(c.names))
+	)
+	for _, name := range c.names {
+		g.Go(func() error {
+			err := c.execute(ctx, client, name, c.collectors[name], ch)
+			mu.Lock()
+			errs[name] = err
+			mu.Unlock()
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return errs
+}
+
+// Refresh fetches the metrics of one account and caches them to be served
+// by Collect in polling mode. It returns the error of every module by
+// collector name, nil on success.
+func (c *DeepLCollector) Refresh(ctx context.Context, client *Client) map[string]error {
+	ch := make(chan prometheus.Metric)
+	done := make(chan []prometheus.Metric)
+	go func() {
+		var metrics []prometheus.Metric
+		for m := range ch {
+			metrics = append(metrics, m)
+		}
+		done <- metrics
+	}()
+
+	slot := &usageSlot{}
+	errs := c.collectAccount(context.WithValue(ctx, usageSlotKey{}, slot), client, ch)
+	close(ch)
+	metrics := <-done
+
+	c.mu.Lock()
+	c.cache[client.Name()] = cachedMetrics{metrics: metrics, usage: slot.usage, refreshed: time.Now()}
+	c.mu.Unlock()
+	return errs
+}
+
+func (c *DeepLCollector) collectCached(clients []*Client, ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, client := range clients {
+		cached, ok := c.cache[client.Name()]
+		if !ok {
+			continue
+		}
+		for _, m := range cached.metrics {
+			ch <- m
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.lastRefresh,
+			prometheus.GaugeValue,
+			float64(cached.refreshed.UnixNano())/1e9,
+			client.Name(),
+		)
+	}
+}
+
+// Clients returns the clients of the accounts c exports metrics for.
+func (c *DeepLCollector) Clients() []*Client {
+	c.clientsMu.RLock()
+	defer c.clientsMu.RUnlock()
+	return slices.Clone(c.clients)
+}
+
+// Client returns the client of the account with the given name, or nil.
+func (c *DeepLCollector) Client(name string) *Client {
+	c.clientsMu.RLock()
+	defer c.clientsMu.RUnlock()
+	for _, client := range c.clients {
+		if client.Name() == name {
+			return client
+		}
+	}
+	return nil
+}
+
+// AddClient adds an account at runtime, e.g. through the key management
+// API of the exporter. It fails if an account with the same name exists.
+func (c *DeepLCollector) AddClient(client *Client) error {
+	c.clientsMu.Lock()
+	defer c.clientsMu.Unlock()
+	if slices.ContainsFunc(c.clients, func(other *Client) bool { return other.Name() == client.Name() }) {
+		return fmt.Errorf("account %q already exists", client.Name())
+	}
+	c.initClient(client)
+	c.clients = append(c.clients, client)
+	return nil
+}
+
+// RemoveClient removes the account with the given name at runtime along
+// with its cached metrics and status, and returns its client. It fails if
+// the account does not exist or is the last one.
+func (c *DeepLCollector) RemoveClient(name string) (*Client, error) {
+	c.clientsMu.Lock()
+	defer c.clientsMu.Unlock()
+	i := slices.IndexFunc(c.clients, func(client *Client) bool { return client.Name() == name })
+	if i < 0 {
+		return nil, fmt.Errorf("unknown account %q", name)
+	}
+	if len(c.clients) == 1 {
+		return nil, errors.New("the last account cannot be removed")
+	}
+	client := c.clients[i]
+	c.clients = slices.Delete(slices.Clone(c.clients), i, i+1)
+
+	c.mu.Lock()
+	delete(c.cache, name)
+	c.mu.Unlock()
+	c.statusMu.Lock()
+	for _, collector := range c.names {
+		delete(c.status, moduleKey{name, collector})
+	}
+	c.statusMu.Unlock()
+	c.apiErrors.DeletePartialMatch(prometheus.Labels{"account": name})
+	return client, nil
+}
+
+// execute runs one module for one account and exports its scrape metrics.
+// The error of the module is returned after it has been recorded.
+func (c *DeepLCollector) execute(ctx context.Context, client *Client, name string, module Collector, ch chan<- prometheus.Metric) error {
+	begin := time.Now()
+	err := module.Update(ctx, client, ch)
+	duration := time.Since(begin)
+
+	c.statusMu.Lock()
+	status := c.status[moduleKey{client.Name(), name}]
+	if err != nil {
+		status.lastError, status.lastFailure = err, begin
+	} else {
+		status.lastSuccess = begin
+	}
+	c.status[moduleKey{client.Name(), name}] = status
+	c.statusMu.Unlock()
+
+	success := 1.0
+	if err != nil {
+		logf(ctx, "Error fetching DeepL %s for account %s: %v", name, client.Name(), err)
+		// The exemplar tells why the last scrape failed to OpenMetrics
+		// consumers.
+		c.apiErrors.WithLabelValues(client.Name(), name).(prometheus.ExemplarAdder).AddWithExemplar(
+			1,
+			traceExemplar(ctx, prometheus.Labels{"reason": errorReason(err)}),
+		)
+		success = 0
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.scrapeDuration, prometheus.GaugeValue, duration.Seconds(), client.Name(), name)
+	ch <- prometheus.MustNewConstMetric(c.scrapeSuccess, prometheus.GaugeValue, success, client.Name(), name)
+	return err
+}
@@ -0,0 +1,285 @@
+package deepl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNewDeepLCollector_UnknownCollector(t *testing.T) {
+	_, err := NewDeepLCollector([]*Client{newTestClient(t, "")}, []string{"unknown"})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Options{}); err == nil {
+		t.Error("expected error without clients")
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `{"character_count": 900, "character_limit": 1000}`)
+	}))
+	defer ts.Close()
+
+	registry := prometheus.NewRegistry()
+	c, err := New(Options{
+		Clients:    []*Client{newTestClient(t, ts.URL)},
+		Usage:      &UsageOptions{Thresholds: []float64{50}},
+		Registerer: registry,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := c.CollectorNames(); !slices.Equal(got, DefaultCollectors()) {
+		t.Errorf("CollectorNames() = %v, want the default collectors %v", got, DefaultCollectors())
+	}
+
+	expected := `
+# HELP deepl_usage_above_threshold Whether the percentage of the character limit used reached the threshold
+# TYPE deepl_usage_above_threshold gauge
+deepl_usage_above_threshold{account="default",threshold="50"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "deepl_usage_above_threshold"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeepLCollector_Collect(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "DeepL-Auth-Key test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case UsagePath:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintln(w, `{"character_count": 1000, "character_limit": 500000}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	client := newTestClient(t, ts.URL)
+
+	c, err := NewDeepLCollector([]*Client{client}, []string{"glossaries", "usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `
+# HELP deepl_character_count Current number of characters translated in the current billing period
+# TYPE deepl_character_count gauge
+deepl_character_count{account="default"} 1000
+# HELP deepl_scrape_collector_success Whether a collector succeeded
+# TYPE deepl_scrape_collector_success gauge
+deepl_scrape_collector_success{account="default",collector="glossaries"} 0
+deepl_scrape_collector_success{account="default",collector="usage"} 1
+# HELP deepl_api_errors_total Total number of failed DeepL API scrapes by collector
+# TYPE deepl_api_errors_total counter
+deepl_api_errors_total{account="default",collector="glossaries"} 1
+deepl_api_errors_total{account="default",collector="usage"} 0
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"deepl_character_count", "deepl_scrape_collector_success", "deepl_api_errors_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeepLCollector_Status(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != UsagePath {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"character_count": 1000, "character_limit": 500000}`)
+	}))
+	defer ts.Close()
+
+	c, err := NewDeepLCollector([]*Client{newTestClient(t, ts.URL)}, []string{"glossaries", "usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status := c.Status(); len(status) != 1 || status[0].Healthy() {
+		t.Fatalf("expected an unhealthy account before the first scrape, got %+v", status)
+	}
+	_ = testutil.CollectAndCount(c)
+
+	status := c.Status()[0]
+	if status.Account != "default" || status.APIKey != Redacted || status.BackupAPIKey || status.Endpoint != ts.URL {
+		t.Errorf("unexpected status: %+v", status)
+	}
+	if len(status.Collectors) != 2 {
+		t.Fatalf("expected the status of 2 collectors, got %+v", status.Collectors)
+	}
+	glossaries, usage := status.Collectors[0], status.Collectors[1]
+	if glossaries.Healthy() || glossaries.LastError == "" {
+		t.Errorf("expected the glossaries collector to have failed, got %+v", glossaries)
+	}
+	if !usage.Healthy() || usage.LastError != "" {
+		t.Errorf("expected the usage collector to be healthy, got %+v", usage)
+	}
+	if status.Healthy() {
+		t.Error("expected the account to be unhealthy while a collector fails")
+	}
+}
+
+func TestDeepLCollector_AddRemoveClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	c, err := NewDeepLCollector([]*Client{newTestClient(t, ts.URL)}, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	added, err := NewClient(ClientConfig{Name: "team-b", APIKey: "key-b", ServerURL: ts.URL})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if err := c.AddClient(added); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.AddClient(added); err == nil {
+		t.Error("expected an error adding an existing account")
+	}
+	c.EnablePolling()
+	c.Refresh(t.Context(), added)
+
+	const apiErrors = "deepl_api_errors_total"
+	if n := testutil.CollectAndCount(c, apiErrors); n != 2 {
+		t.Fatalf("expected the errors of both accounts, got %d", n)
+	}
+	if n := testutil.CollectAndCount(c, "deepl_scrape_collector_success"); n != 1 {
+		t.Fatalf("expected the cached metrics of the added account, got %d", n)
+	}
+
+	if _, err := c.RemoveClient("unknown"); err == nil {
+		t.Error("expected an error removing an unknown account")
+	}
+	if removed, err := c.RemoveClient("team-b"); err != nil || removed != added {
+		t.Fatalf("unexpected result %v, %v", removed, err)
+	}
+	if _, err := c.RemoveClient("default"); err == nil {
+		t.Error("expected an error removing the last account")
+	}
+	if c.Client("team-b") != nil || len(c.Status()) != 1 {
+		t.Error("expected the account to be gone")
+	}
+	if n := testutil.CollectAndCount(c, apiErrors, "deepl_scrape_collector_success"); n != 1 {
+		t.Errorf("expected no metrics of the removed account, got %d", n)
+	}
+}
+
+func TestDeepLCollector_CachedUsage(t *testing.T) {
+	var fail atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"character_count": 1000, "character_limit": 500000}`)
+	}))
+	defer ts.Close()
+
+	client := newTestClient(t, ts.URL)
+	c, err := NewDeepLCollector([]*Client{client}, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.EnablePolling()
+	if _, _, ok := c.CachedUsage("default"); ok {
+		t.Error("expected no usage before the first refresh")
+	}
+
+	c.Refresh(t.Context(), client)
+	usage, refreshed, ok := c.CachedUsage("default")
+	if !ok || usage.CharacterCount != 1000 || usage.CharacterLimit != 500000 || refreshed.IsZero() {
+		t.Errorf("unexpected cached usage %+v at %s", usage, refreshed)
+	}
+
+	fail.Store(true)
+	c.Refresh(t.Context(), client)
+	if _, _, ok := c.CachedUsage("default"); ok {
+		t.Error("expected no usage after the usage module failed")
+	}
+}
+
+func TestDeepLCollector_Describe(t *testing.T) {
+	c, err := NewDeepLCollector([]*Client{newTestClient(t, "")}, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Errorf("failed to register collector: %v", err)
+	}
+}
+
+func TestDeepLCollector_CollectConcurrently(t *testing.T) {
+	usageRequested := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case UsagePath:
+			close(usageRequested)
+			_, _ = fmt.Fprintln(w, `{"character_count": 1000, "character_limit": 500000}`)
+		case GlossariesPath:
+			// Only answer once the usage request is in flight, which can
+			// only happen if both collectors run at the same time.
+			select {
+			case <-usageRequested:
+				_, _ = fmt.Fprintln(w, `{"glossaries": []}`)
+			case <-time.After(5 * time.Second):
+				w.WriteHeader(http.StatusGatewayTimeout)
+			}
+		case GlossaryLanguagePairsPath:
+			_, _ = fmt.Fprintln(w, `{"supported_languages": []}`)
+		}
+	}))
+	defer ts.Close()
+
+	client := newTestClient(t, ts.URL)
+
+	c, err := NewDeepLCollector([]*Client{client}, []string{"glossaries", "usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `
+# HELP deepl_scrape_collector_success Whether a collector succeeded
+# TYPE deepl_scrape_collector_success gauge
+deepl_scrape_collector_success{account="default",collector="glossaries"} 1
+deepl_scrape_collector_success{account="default",collector="usage"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "deepl_scrape_collector_success"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseBuckets(t *testing.T) {
+	buckets, err := ParseBuckets("0.5, 1,2.5,10")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(buckets) != 4 || buckets[0] != 0.5 || buckets[3] != 10 {
+		t.Errorf("unexpected buckets %v", buckets)
+	}
+
+	for _, list := range []string{"1,abc", "1,1", "2,1", "0,1", ""} {
+		if _, err := ParseBuckets(list); err == nil {
+			t.Errorf("%q: expected error, got nil", list)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package deepl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dnsCache resolves host names for the DeepL transport. Results are cached
+// for ttl and kept after a failed refresh, so a flaky DNS server does not
+// fail scrapes. Static entries take precedence and never expire.
+type dnsCache struct {
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	ttl        time.Duration
+	static     map[string][]string
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+func newDNSCache(ttl time.Duration, static map[string][]string) *dnsCache {
+	return &dnsCache{
+		lookupHost: net.DefaultResolver.LookupHost,
+		ttl:        ttl,
+		static:     static,
+		now:        time.Now,
+		entries:    make(map[string]dnsEntry),
+	}
+}
+
+// lookup returns the addresses of host, using the cache when possible.
+func (d *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := d.static[host]; ok {
+		return addrs, nil
+	}
+	if net.ParseIP(host) != nil || d.ttl <= 0 {
+		return []string{host}, nil
+	}
+
+	d.mu.Lock()
+	entry, cached := d.entries[host]
+	d.mu.Unlock()
+	if cached && d.now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := d.lookupHost(ctx, host)
+	if err != nil {
+		if cached {
+			return entry.addrs, nil
+		}
+		return nil, err
+	}
+
+	d.mu.Lock()
+	d.entries[host] = dnsEntry{addrs: addrs, expires: d.now().Add(d.ttl)}
+	d.mu.Unlock()
+
+	return addrs, nil
+}
+
+// dialContext wraps dialer so that host names are resolved through the
+// cache. The addresses are tried in order until one connects.
+func (d *dnsCache) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		ips, err := d.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		var errs []error
+		for _, ip := range ips {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+		}
+		return nil, errors.Join(errs...)
+	}
+}
+
+// ParseStaticHosts parses entries of the form host:ip[,ip...] into a map of
+// host names to addresses.
+func ParseStaticHosts(entries []string) (map[string][]string, error) {
+	hosts := make(map[string][]string, len(entries))
+	for _, entry := range entries {
+		host, ips, ok := strings.Cut(entry, ":")
+		if !ok || host == "" || ips == "" {
+			return nil, fmt.Errorf("invalid static host entry %q: must be host:ip[,ip...]", entry)
+		}
+		for _, ip := range strings.Split(ips, ",") {
+			if net.ParseIP(ip) == nil {
+				return nil, fmt.Errorf("invalid static host entry %q: %q is not an IP address", entry, ip)
+			}
+			hosts[host] = append(hosts[host], ip)
+		}
+	}
+	return hosts, nil
+}
@@ -0,0 +1,98 @@
+package deepl
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDNSCache_lookup(t *testing.T) {
+	lookups := 0
+	fail := false
+	now := time.Now()
+
+	d := newDNSCache(time.Minute, map[string][]string{"static.example": {"192.0.2.1"}})
+	d.now = func() time.Time { return now }
+	d.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		lookups++
+		if fail {
+			return nil, errors.New("server misbehaving")
+		}
+		return []string{"198.51.100.1"}, nil
+	}
+
+	lookup := func(host string) []string {
+		t.Helper()
+		addrs, err := d.lookup(context.Background(), host)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return addrs
+	}
+
+	if addrs := lookup("static.example"); !reflect.DeepEqual(addrs, []string{"192.0.2.1"}) {
+		t.Errorf("expected static address, got %v", addrs)
+	}
+	if addrs := lookup("api.deepl.com"); !reflect.DeepEqual(addrs, []string{"198.51.100.1"}) {
+		t.Errorf("expected resolved address, got %v", addrs)
+	}
+	lookup("api.deepl.com")
+	if lookups != 1 {
+		t.Errorf("expected 1 lookup within the TTL, got %d", lookups)
+	}
+
+	now = now.Add(2 * time.Minute)
+	fail = true
+	if addrs := lookup("api.deepl.com"); !reflect.DeepEqual(addrs, []string{"198.51.100.1"}) {
+		t.Errorf("expected stale address after failed lookup, got %v", addrs)
+	}
+	if lookups != 2 {
+		t.Errorf("expected expired entry to be refreshed, got %d lookups", lookups)
+	}
+
+	if _, err := d.lookup(context.Background(), "unknown.example"); err == nil {
+		t.Error("expected error for uncached host")
+	}
+}
+
+func TestDNSCache_dialContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	_, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	d := newDNSCache(0, map[string][]string{"api.deepl.invalid": {"127.0.0.1"}})
+	conn, err := d.dialContext(&net.Dialer{})(context.Background(), "tcp", net.JoinHostPort("api.deepl.invalid", port))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = conn.Close()
+}
+
+func TestParseStaticHosts(t *testing.T) {
+	hosts, err := ParseStaticHosts([]string{"api.deepl.com:192.0.2.1,2001:db8::1", "api-free.deepl.com:192.0.2.2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string][]string{
+		"api.deepl.com":      {"192.0.2.1", "2001:db8::1"},
+		"api-free.deepl.com": {"192.0.2.2"},
+	}
+	if !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("expected %v, got %v", expected, hosts)
+	}
+
+	for _, entry := range []string{"api.deepl.com", "api.deepl.com:", ":192.0.2.1", "api.deepl.com:not-an-ip"} {
+		if _, err := ParseStaticHosts([]string{entry}); err == nil {
+			t.Errorf("expected error for %q", entry)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package deepl
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// DumpState writes the state of every account for diagnosing stuck
+// instances: its endpoint, the freshness of its cached metrics and the last
+// outcome of every module.
+func (c *DeepLCollector) DumpState(w io.Writer, now time.Time) {
+	c.mu.RLock()
+	cache := make(map[string]cachedMetrics, len(c.cache))
+	for name, cached := range c.cache {
+		cache[name] = cached
+	}
+	c.mu.RUnlock()
+
+	clients := c.Clients()
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	for _, client := range clients {
+		baseURL, _ := client.Endpoints()
+		_, _ = fmt.Fprintf(w, "State dump: account %s: endpoint %s, endpoint mismatch %t, key failover %t, rate limit tokens %s",
+			client.Name(), baseURL, client.EndpointMismatch(), client.Failover(), client.rateLimitTokens())
+		if c.polling {
+			if cached, ok := cache[client.Name()]; ok {
+				_, _ = fmt.Fprintf(w, ", refreshed %s ago", now.Sub(cached.refreshed).Round(time.Second))
+			} else {
+				_, _ = fmt.Fprint(w, ", never refreshed")
+			}
+		}
+		_, _ = fmt.Fprintln(w)
+
+		for _, name := range c.names {
+			status, ok := c.status[moduleKey{client.Name(), name}]
+			if !ok {
+				_, _ = fmt.Fprintf(w, "State dump: account %s: collector %s never ran\n", client.Name(), name)
+				continue
+			}
+			_, _ = fmt.Fprintf(w, "State dump: account %s: collector %s last succeeded %s", client.Name(), name, since(now, status.lastSuccess))
+			if status.lastError != nil {
+				_, _ = fmt.Fprintf(w, ", last failed %s: %v", since(now, status.lastFailure), status.lastError)
+			}
+			_, _ = fmt.Fprintln(w)
+		}
+	}
+}
+
+// since formats how long ago t was, or "never" for the zero time.
+func since(now, t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return now.Sub(t).Round(time.Second).String() + " ago"
+}
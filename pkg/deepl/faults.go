@@ -0,0 +1,109 @@
+package deepl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FaultConfig injects faults into the requests sent to DeepL, to rehearse
+// how dashboards and alerts behave when the exporter degrades.
+type FaultConfig struct {
+	// FailRate is the probability of a request failing with 503.
+	FailRate float64
+	// Latency is added to every request.
+	Latency time.Duration
+	// FreezeUsage keeps serving the first successful usage response.
+	FreezeUsage bool
+}
+
+func (f FaultConfig) enabled() bool {
+	return f.FailRate > 0 || f.Latency > 0 || f.FreezeUsage
+}
+
+func (f FaultConfig) validate() error {
+	if f.FailRate < 0 || f.FailRate > 1 {
+		return fmt.Errorf("invalid fail rate %g: must be between 0 and 1", f.FailRate)
+	}
+	if f.Latency < 0 {
+		return fmt.Errorf("invalid latency %s: must not be negative", f.Latency)
+	}
+	return nil
+}
+
+// faultInjector is an http.RoundTripper injecting the faults of cfg into the
+// requests sent through next.
+type faultInjector struct {
+	next  http.RoundTripper
+	cfg   FaultConfig
+	float func() float64
+
+	mu     sync.Mutex
+	frozen map[string][]byte
+}
+
+func newFaultInjector(next http.RoundTripper, cfg FaultConfig) *faultInjector {
+	return &faultInjector{next: next, cfg: cfg, float: rand.Float64, frozen: make(map[string][]byte)}
+}
+
+func (f *faultInjector) RoundTrip(req *http.Request) (*http.Response, error) {
+	if f.cfg.Latency > 0 {
+		timer := time.NewTimer(f.cfg.Latency)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+
+	if f.cfg.FailRate > 0 && f.float() < f.cfg.FailRate {
+		return newFakeResponse(req, http.StatusServiceUnavailable, []byte(`{"message": "fault injected by --debug.fail-rate"}`)), nil
+	}
+
+	frozenKey := req.URL.Path + "\x00" + req.Header.Get(DefaultAuthHeader)
+	if f.cfg.FreezeUsage && req.URL.Path == UsagePath {
+		f.mu.Lock()
+		body, ok := f.frozen[frozenKey]
+		f.mu.Unlock()
+		if ok {
+			return newFakeResponse(req, http.StatusOK, body), nil
+		}
+	}
+
+	resp, err := f.next.RoundTrip(req)
+	if err != nil || !f.cfg.FreezeUsage || req.URL.Path != UsagePath || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	f.frozen[frozenKey] = body
+	f.mu.Unlock()
+	log.Printf("Fault injection: freezing the usage returned by %s", req.URL.Host)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+func newFakeResponse(req *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,97 @@
+package deepl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFaultInjectorFailRate(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte(`{"character_count": 1, "character_limit": 2}`))
+	}))
+	defer server.Close()
+
+	injector := newFaultInjector(http.DefaultTransport, FaultConfig{FailRate: 0.5})
+	values := []float64{0.2, 0.7}
+	injector.float = func() float64 {
+		v := values[0]
+		values = values[1:]
+		return v
+	}
+	client := &http.Client{Transport: injector}
+
+	resp, err := client.Get(server.URL + UsagePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || requests.Load() != 0 {
+		t.Errorf("expected an injected 503 without calling DeepL, got %d after %d requests", resp.StatusCode, requests.Load())
+	}
+
+	resp, err = client.Get(server.URL + UsagePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || requests.Load() != 1 {
+		t.Errorf("expected the request to pass, got %d after %d requests", resp.StatusCode, requests.Load())
+	}
+}
+
+func TestFaultInjectorLatency(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newFaultInjector(http.DefaultTransport, FaultConfig{Latency: time.Minute})}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+
+	begin := time.Now()
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("expected the request to time out")
+	}
+	if elapsed := time.Since(begin); elapsed > 5*time.Second {
+		t.Errorf("expected the latency to respect the context, took %s", elapsed)
+	}
+}
+
+func TestFaultInjectorFreezeUsage(t *testing.T) {
+	var count atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(DeepLUsage{CharacterCount: count.Add(1000), CharacterLimit: 500000})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(ClientConfig{APIKey: "test-key", ServerURL: server.URL, Faults: FaultConfig{FreezeUsage: true}})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	for range 3 {
+		usage, err := FetchUsage(context.Background(), client)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if usage.CharacterCount != 1000 {
+			t.Errorf("expected the usage to stay frozen at 1000, got %d", usage.CharacterCount)
+		}
+	}
+	if count.Load() != 1000 {
+		t.Errorf("expected DeepL to be called once, got %d calls", count.Load()/1000)
+	}
+}
+
+func TestFaultConfigValidate(t *testing.T) {
+	if _, err := NewClient(ClientConfig{APIKey: "test-key", Faults: FaultConfig{FailRate: 1.5}}); err == nil {
+		t.Error("expected a fail rate above 1 to be rejected")
+	}
+}
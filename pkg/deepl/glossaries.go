@@ -0,0 +1,143 @@
+package deepl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Paths of the glossary endpoints of the DeepL API.
+const (
+	GlossariesPath            = "/v2/glossaries"
+	GlossaryLanguagePairsPath = "/v2/glossary-language-pairs"
+)
+
+type DeepLGlossary struct {
+	GlossaryID string `json:"glossary_id"`
+	Name       string `json:"name"`
+	Ready      bool   `json:"ready"`
+	SourceLang string `json:"source_lang"`
+	TargetLang string `json:"target_lang"`
+	EntryCount int64  `json:"entry_count"`
+}
+
+type DeepLGlossaryLanguagePair struct {
+	SourceLang string `json:"source_lang"`
+	TargetLang string `json:"target_lang"`
+}
+
+type GlossariesCollector struct {
+	glossaryCount   *prometheus.Desc
+	glossaryEntries *prometheus.Desc
+	pairCount       *prometheus.Desc
+	pair            *prometheus.Desc
+}
+
+func init() {
+	RegisterCollector("glossaries", false, func(Options) Collector {
+		return NewGlossariesCollector()
+	})
+}
+
+func NewGlossariesCollector() *GlossariesCollector {
+	return &GlossariesCollector{
+		glossaryCount: prometheus.NewDesc(
+			"deepl_glossary_count",
+			"Number of glossaries stored in the account",
+			[]string{"account"},
+			nil,
+		),
+		glossaryEntries: prometheus.NewDesc(
+			"deepl_glossary_entries",
+			"Number of entries of a glossary",
+			[]string{"account", "glossary_id", "glossary_name"},
+			nil,
+		),
+		pairCount: prometheus.NewDesc(
+			"deepl_glossary_language_pair_count",
+			"Number of language pairs supported by glossaries",
+			[]string{"account"},
+			nil,
+		),
+		pair: prometheus.NewDesc(
+			"deepl_glossary_language_pair",
+			"Language pair supported by glossaries, always 1",
+			[]string{"account", "source_lang", "target_lang"},
+			nil,
+		),
+	}
+}
+
+func (c *GlossariesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.glossaryCount
+	ch <- c.glossaryEntries
+	ch <- c.pairCount
+	ch <- c.pair
+}
+
+func (c *GlossariesCollector) Update(ctx context.Context, client *Client, ch chan<- prometheus.Metric) error {
+	var errs []error
+	if glossaries, err := fetchGlossaries(ctx, client); err != nil {
+		errs = append(errs, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(
+			c.glossaryCount,
+			prometheus.GaugeValue,
+			float64(len(glossaries)),
+			client.Name(),
+		)
+
+		for _, glossary := range glossaries {
+			ch <- prometheus.MustNewConstMetric(
+				c.glossaryEntries,
+				prometheus.GaugeValue,
+				float64(glossary.EntryCount),
+				client.Name(), glossary.GlossaryID, glossary.Name,
+			)
+		}
+	}
+
+	if pairs, err := fetchGlossaryLanguagePairs(ctx, client); err != nil {
+		errs = append(errs, fmt.Errorf("language pairs: %w", err))
+	} else {
+		ch <- prometheus.MustNewConstMetric(
+			c.pairCount,
+			prometheus.GaugeValue,
+			float64(len(pairs)),
+			client.Name(),
+		)
+
+		for _, pair := range pairs {
+			ch <- prometheus.MustNewConstMetric(
+				c.pair,
+				prometheus.GaugeValue,
+				1,
+				client.Name(), pair.SourceLang, pair.TargetLang,
+			)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func fetchGlossaries(ctx context.Context, client *Client) ([]DeepLGlossary, error) {
+	var resp struct {
+		Glossaries []DeepLGlossary `json:"glossaries"`
+	}
+	if err := client.GetJSON(ctx, GlossariesPath, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Glossaries, nil
+}
+
+func fetchGlossaryLanguagePairs(ctx context.Context, client *Client) ([]DeepLGlossaryLanguagePair, error) {
+	var resp struct {
+		SupportedLanguages []DeepLGlossaryLanguagePair `json:"supported_languages"`
+	}
+	if err := client.GetJSON(ctx, GlossaryLanguagePairsPath, &resp); err != nil {
+		return nil, err
+	}
+	return resp.SupportedLanguages, nil
+}
@@ -0,0 +1,97 @@
+package deepl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFetchGlossaries(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "DeepL-Auth-Key test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"glossaries": [{"glossary_id": "g1", "name": "Product", "entry_count": 42}, {"glossary_id": "g2", "name": "Legal", "entry_count": 7}]}`)
+	}))
+	defer ts.Close()
+
+	client := newTestClient(t, ts.URL)
+
+	glossaries, err := fetchGlossaries(context.Background(), client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(glossaries) != 2 {
+		t.Fatalf("expected 2 glossaries, got %d", len(glossaries))
+	}
+	if glossaries[0].EntryCount != 42 {
+		t.Errorf("expected 42 entries, got %d", glossaries[0].EntryCount)
+	}
+}
+
+func TestGlossariesCollector(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == GlossaryLanguagePairsPath {
+			_, _ = fmt.Fprintln(w, `{"supported_languages": [{"source_lang": "de", "target_lang": "en"}, {"source_lang": "en", "target_lang": "de"}]}`)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"glossaries": [{"glossary_id": "g1", "name": "Product", "entry_count": 42}, {"glossary_id": "g2", "name": "Legal", "entry_count": 7}]}`)
+	}))
+	defer ts.Close()
+
+	collector, err := NewDeepLCollector([]*Client{newTestClient(t, ts.URL)}, []string{"glossaries"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `
+# HELP deepl_glossary_count Number of glossaries stored in the account
+# TYPE deepl_glossary_count gauge
+deepl_glossary_count{account="default"} 2
+# HELP deepl_glossary_entries Number of entries of a glossary
+# TYPE deepl_glossary_entries gauge
+deepl_glossary_entries{account="default",glossary_id="g1",glossary_name="Product"} 42
+deepl_glossary_entries{account="default",glossary_id="g2",glossary_name="Legal"} 7
+# HELP deepl_glossary_language_pair Language pair supported by glossaries, always 1
+# TYPE deepl_glossary_language_pair gauge
+deepl_glossary_language_pair{account="default",source_lang="de",target_lang="en"} 1
+deepl_glossary_language_pair{account="default",source_lang="en",target_lang="de"} 1
+# HELP deepl_glossary_language_pair_count Number of language pairs supported by glossaries
+# TYPE deepl_glossary_language_pair_count gauge
+deepl_glossary_language_pair_count{account="default"} 2
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"deepl_glossary_count", "deepl_glossary_entries", "deepl_glossary_language_pair", "deepl_glossary_language_pair_count"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGlossariesCollectorPartialFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == GlossaryLanguagePairsPath {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"glossaries": []}`)
+	}))
+	defer ts.Close()
+
+	client := newTestClient(t, ts.URL)
+	ch := make(chan prometheus.Metric, 10)
+	err := NewGlossariesCollector().Update(context.Background(), client, ch)
+	close(ch)
+	if err == nil || !strings.Contains(err.Error(), "language pairs") {
+		t.Errorf("expected a language pairs error, got %v", err)
+	}
+	if len(ch) != 1 {
+		t.Errorf("expected only the glossary count, got %d metrics", len(ch))
+	}
+}
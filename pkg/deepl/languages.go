@@ -0,0 +1,198 @@
+package deepl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LanguagesPath is the path of the languages endpoint of the DeepL API.
+const LanguagesPath = "/v2/languages"
+
+type DeepLLanguage struct {
+	Language          string `json:"language"`
+	Name              string `json:"name"`
+	SupportsFormality bool   `json:"supports_formality"`
+}
+
+type LanguagesCollector struct {
+	languageCount     *prometheus.Desc
+	languageSupported *prometheus.Desc
+	changed           *prometheus.Desc
+	formality         *prometheus.Desc
+
+	now func() time.Time
+
+	mu sync.Mutex
+	// seen are the languages observed per account and type, and whether
+	// they are still supported.
+	seen map[languageSetKey]*languageSet
+}
+
+type languageSetKey struct {
+	account, langType string
+}
+
+// languageSet follows the languages of one type supported by an account.
+type languageSet struct {
+	supported map[string]bool
+	// changed is when the set last changed, zero if it did not change since
+	// it was first observed.
+	changed time.Time
+}
+
+// observe records the languages fetched now and reports the languages
+// added and removed since the previous observation.
+func (s *languageSet) observe(now time.Time, languages []DeepLLanguage) (added, removed []string) {
+	first := s.supported == nil
+	current := make(map[string]bool, len(languages))
+	for _, language := range languages {
+		current[language.Language] = true
+	}
+	if first {
+		s.supported = current
+		return nil, nil
+	}
+	for language := range current {
+		if !s.supported[language] {
+			added = append(added, language)
+		}
+	}
+	for language, supported := range s.supported {
+		if supported && !current[language] {
+			removed = append(removed, language)
+		}
+		// Removed languages stay known, so they are exported as 0.
+		s.supported[language] = current[language]
+	}
+	for _, language := range added {
+		s.supported[language] = true
+	}
+	if len(added) > 0 || len(removed) > 0 {
+		s.changed = now
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+func init() {
+	RegisterCollector("languages", false, func(Options) Collector {
+		return NewLanguagesCollector()
+	})
+}
+
+func NewLanguagesCollector() *LanguagesCollector {
+	return &LanguagesCollector{
+		languageCount: prometheus.NewDesc(
+			"deepl_language_count",
+			"Number of languages supported by the DeepL API",
+			[]string{"account", "type"},
+			nil,
+		),
+		languageSupported: prometheus.NewDesc(
+			"deepl_language_supported",
+			"Whether the DeepL API supports the language, 0 once a language observed since the exporter started is removed",
+			[]string{"account", "type", "language"},
+			nil,
+		),
+		changed: prometheus.NewDesc(
+			"deepl_languages_changed_timestamp_seconds",
+			"Unix time the supported languages last changed, 0 if they did not change since the exporter started",
+			[]string{"account", "type"},
+			nil,
+		),
+		formality: prometheus.NewDesc(
+			"deepl_language_supports_formality",
+			"Whether the DeepL API supports the formality parameter for the target language",
+			[]string{"account", "language"},
+			nil,
+		),
+		now:  time.Now,
+		seen: make(map[languageSetKey]*languageSet),
+	}
+}
+
+func (c *LanguagesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.languageCount
+	ch <- c.languageSupported
+	ch <- c.changed
+	ch <- c.formality
+}
+
+func (c *LanguagesCollector) Update(ctx context.Context, client *Client, ch chan<- prometheus.Metric) error {
+	var errs []error
+	for _, langType := range []string{"source", "target"} {
+		languages, err := fetchLanguages(ctx, client, langType)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s languages: %w", langType, err))
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.languageCount,
+			prometheus.GaugeValue,
+			float64(len(languages)),
+			client.Name(),
+			langType,
+		)
+		c.observe(ctx, client.Name(), langType, languages, ch)
+
+		// Only target languages report whether they support formality.
+		if langType != "target" {
+			continue
+		}
+		for _, language := range languages {
+			supports := 0.0
+			if language.SupportsFormality {
+				supports = 1
+			}
+			ch <- prometheus.MustNewConstMetric(c.formality, prometheus.GaugeValue, supports, client.Name(), language.Language)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// observe compares languages to the ones fetched before and exports the
+// presence of every language and when they last changed.
+func (c *LanguagesCollector) observe(ctx context.Context, account, langType string, languages []DeepLLanguage, ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := languageSetKey{account: account, langType: langType}
+	set, ok := c.seen[key]
+	if !ok {
+		set = &languageSet{}
+		c.seen[key] = set
+	}
+	added, removed := set.observe(c.now(), languages)
+	if len(added) > 0 || len(removed) > 0 {
+		logf(ctx, "Account %s: DeepL %s languages changed, added %v, removed %v", account, langType, added, removed)
+	}
+
+	for language, supported := range set.supported {
+		value := 0.0
+		if supported {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.languageSupported, prometheus.GaugeValue, value, account, langType, language)
+	}
+	changed := 0.0
+	if !set.changed.IsZero() {
+		changed = float64(set.changed.Unix())
+	}
+	ch <- prometheus.MustNewConstMetric(c.changed, prometheus.GaugeValue, changed, account, langType)
+}
+
+func fetchLanguages(ctx context.Context, client *Client, langType string) ([]DeepLLanguage, error) {
+	var languages []DeepLLanguage
+	if err := client.GetJSON(ctx, LanguagesPath+"?type="+langType, &languages); err != nil {
+		return nil, err
+	}
+	return languages, nil
+}
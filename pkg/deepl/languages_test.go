@@ -0,0 +1,112 @@
+package deepl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestFetchLanguages(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("type") {
+		case "source":
+			_, _ = fmt.Fprintln(w, `[{"language": "DE", "name": "German"}, {"language": "EN", "name": "English"}]`)
+		case "target":
+			_, _ = fmt.Fprintln(w, `[{"language": "DE", "name": "German", "supports_formality": true}]`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	client := newTestClient(t, ts.URL)
+
+	source, err := fetchLanguages(context.Background(), client, "source")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(source) != 2 {
+		t.Errorf("expected 2 source languages, got %d", len(source))
+	}
+
+	target, err := fetchLanguages(context.Background(), client, "target")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(target) != 1 || !target[0].SupportsFormality {
+		t.Errorf("expected 1 target language supporting formality, got %+v", target)
+	}
+}
+
+func TestLanguagesCollectorChanges(t *testing.T) {
+	targets := `[{"language": "DE", "name": "German"}, {"language": "FR", "name": "French"}]`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("type") == "source" {
+			_, _ = fmt.Fprintln(w, `[{"language": "DE", "name": "German"}]`)
+			return
+		}
+		_, _ = fmt.Fprintln(w, targets)
+	}))
+	defer ts.Close()
+	client := newTestClient(t, ts.URL)
+
+	collector := NewLanguagesCollector()
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	collector.now = func() time.Time { return now }
+
+	update := func() map[string]float64 {
+		ch := make(chan prometheus.Metric, 20)
+		if err := collector.Update(context.Background(), client, ch); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		close(ch)
+		got := make(map[string]float64)
+		for metric := range ch {
+			var m dto.Metric
+			if err := metric.Write(&m); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			labels := make(map[string]string)
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["type"] == "source" {
+				continue
+			}
+			got[metricName(metric)+labels["language"]] = m.GetGauge().GetValue()
+		}
+		return got
+	}
+
+	got := update()
+	if got["deepl_language_supportedDE"] != 1 || got["deepl_language_supportedFR"] != 1 || got["deepl_languages_changed_timestamp_seconds"] != 0 {
+		t.Errorf("unexpected metrics after the first update: %v", got)
+	}
+
+	now = now.Add(time.Hour)
+	targets = `[{"language": "DE", "name": "German", "supports_formality": true}, {"language": "UK", "name": "Ukrainian"}]`
+	got = update()
+	want := map[string]float64{
+		"deepl_language_count":                      2,
+		"deepl_language_supportedDE":                1,
+		"deepl_language_supportedFR":                0,
+		"deepl_language_supportedUK":                1,
+		"deepl_languages_changed_timestamp_seconds": float64(now.Unix()),
+		"deepl_language_supports_formalityDE":       1,
+		"deepl_language_supports_formalityUK":       0,
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("%s: expected %v, got %v", name, value, got[name])
+		}
+	}
+}
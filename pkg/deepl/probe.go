@@ -0,0 +1,120 @@
+package deepl
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+// probeCollector runs the requested modules for a single account on every
+// collection, like the probes of the blackbox exporter.
+type probeCollector struct {
+	ctx     context.Context
+	parent  *DeepLCollector
+	client  *Client
+	names   []string
+	modules map[string]Collector
+	timeout time.Duration
+
+	probeSuccess  *prometheus.Desc
+	probeDuration *prometheus.Desc
+}
+
+// Probe returns a collector running the named modules, which may include
+// ones c does not run, for client on every collection within ctx, e.g. of
+// the probe request, and timeout. It exports probe_success and
+// probe_duration_seconds along with the metrics of the modules.
+func (c *DeepLCollector) Probe(ctx context.Context, client *Client, names []string, timeout time.Duration) (prometheus.Collector, error) {
+	modules := make(map[string]Collector, len(names))
+	for _, name := range names {
+		module, err := c.probeModule(name)
+		if err != nil {
+			return nil, err
+		}
+		modules[name] = module
+	}
+
+	return &probeCollector{
+		ctx:     ctx,
+		parent:  c,
+		client:  client,
+		names:   names,
+		modules: modules,
+		timeout: timeout,
+		probeSuccess: prometheus.NewDesc(
+			"probe_success",
+			"Whether all modules of the probe succeeded",
+			nil,
+			nil,
+		),
+		probeDuration: prometheus.NewDesc(
+			"probe_duration_seconds",
+			"Duration of the probe in seconds",
+			nil,
+			nil,
+		),
+	}, nil
+}
+
+// probeModule returns the module with the given name for probes: the one c
+// runs, or one created on first use and kept. Modules keep state per account,
+// such as the usage trackers deriving the counter, rate and anomaly series,
+// which must carry over from one probe to the next.
+func (c *DeepLCollector) probeModule(name string) (Collector, error) {
+	if module, ok := c.collectors[name]; ok {
+		return module, nil
+	}
+
+	c.probeMu.Lock()
+	defer c.probeMu.Unlock()
+	if module, ok := c.probeModules[name]; ok {
+		return module, nil
+	}
+	module, err := NewModule(name, c.opts)
+	if err != nil {
+		return nil, err
+	}
+	if c.probeModules == nil {
+		c.probeModules = make(map[string]Collector)
+	}
+	c.probeModules[name] = module
+	return module, nil
+}
+
+func (p *probeCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, name := range p.names {
+		p.modules[name].Describe(ch)
+	}
+	ch <- p.parent.scrapeDuration
+	ch <- p.parent.scrapeSuccess
+	ch <- p.probeSuccess
+	ch <- p.probeDuration
+}
+
+func (p *probeCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(p.parent.scrapeContext(p.ctx), p.timeout)
+	defer cancel()
+
+	begin := time.Now()
+	var failed atomic.Bool
+	var g errgroup.Group
+	for _, name := range p.names {
+		g.Go(func() error {
+			if err := p.parent.execute(ctx, p.client, name, p.modules[name], ch); err != nil {
+				failed.Store(true)
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	success := 1.0
+	if failed.Load() {
+		success = 0
+	}
+	ch <- prometheus.MustNewConstMetric(p.probeSuccess, prometheus.GaugeValue, success)
+	ch <- prometheus.MustNewConstMetric(p.probeDuration, prometheus.GaugeValue, time.Since(begin).Seconds())
+}
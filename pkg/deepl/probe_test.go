@@ -0,0 +1,56 @@
+package deepl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestProbeKeepsUsageTrackers(t *testing.T) {
+	var count atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"character_count": %d, "character_limit": 500000}`, count.Add(1000))
+	}))
+	defer ts.Close()
+
+	client := newTestClient(t, ts.URL)
+	for name, running := range map[string][]string{
+		"module run by the collector": {"usage"},
+		"module run by probes only":   {"glossaries"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			count.Store(0)
+			c, err := NewDeepLCollector([]*Client{client}, running)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var totals []float64
+			for range 2 {
+				probe, err := c.Probe(t.Context(), client, []string{"usage"}, time.Second)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				registry := prometheus.NewPedanticRegistry()
+				registry.MustRegister(probe)
+				families, err := registry.Gather()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				for _, family := range families {
+					if family.GetName() == "deepl_characters_translated_since_start_total" {
+						totals = append(totals, family.GetMetric()[0].GetCounter().GetValue())
+					}
+				}
+			}
+			if len(totals) != 2 || totals[0] != 0 || totals[1] != 1000 {
+				t.Errorf("expected the counter to go from 0 to 1000 across probes, got %v", totals)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package deepl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jadolg/deepl-exporter/pkg/provider"
+)
+
+// usageProvider fetches the usage of DeepL accounts for the provider
+// package.
+type usageProvider struct {
+	clients []*Client
+}
+
+// NewProvider returns a provider.Provider fetching the usage of clients, so
+// DeepL can be monitored the same way as other translation services.
+func NewProvider(clients []*Client) provider.Provider {
+	return &usageProvider{clients: clients}
+}
+
+func (p *usageProvider) Name() string {
+	return "deepl"
+}
+
+func (p *usageProvider) FetchUsage(ctx context.Context) ([]provider.Usage, error) {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		usages []provider.Usage
+		errs   []error
+	)
+	for _, client := range p.clients {
+		wg.Go(func() {
+			usage, err := FetchUsage(ctx, client)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("account %s: %w", client.Name(), err))
+				return
+			}
+			usages = append(usages, provider.Usage{
+				Account:        client.Name(),
+				CharacterCount: usage.CharacterCount,
+				CharacterLimit: usage.CharacterLimit,
+			})
+		})
+	}
+	wg.Wait()
+	return usages, errors.Join(errs...)
+}
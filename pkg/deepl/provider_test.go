@@ -0,0 +1,41 @@
+package deepl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProvider_FetchUsage(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "DeepL-Auth-Key key-a" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"character_count": 1000, "character_limit": 500000}`)
+	}))
+	defer ts.Close()
+
+	var clients []*Client
+	for _, name := range []string{"a", "b"} {
+		client, err := NewClient(ClientConfig{Name: name, APIKey: "key-" + name, ServerURL: ts.URL})
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		clients = append(clients, client)
+	}
+
+	p := NewProvider(clients)
+	if p.Name() != "deepl" {
+		t.Errorf("Name() = %q, want deepl", p.Name())
+	}
+	usages, err := p.FetchUsage(context.Background())
+	if err == nil {
+		t.Error("expected the error of account b")
+	}
+	if len(usages) != 1 || usages[0].Account != "a" || usages[0].CharacterCount != 1000 || usages[0].CharacterLimit != 500000 {
+		t.Errorf("unexpected usages: %+v", usages)
+	}
+}
@@ -0,0 +1,141 @@
+package deepl
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	recordFileSuffix = ".json"
+	// Redacted replaces API keys and other secrets.
+	Redacted = "REDACTED"
+	// recordTimeFormat sorts lexically in chronological order.
+	recordTimeFormat = "20060102T150405.000000000Z"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// ResponseRecorder writes copies of raw DeepL responses to a directory for
+// debugging, keeping only the most recent ones. API keys are redacted.
+type ResponseRecorder struct {
+	dir      string
+	maxFiles int
+
+	mu    sync.Mutex
+	files []string
+}
+
+// recordedResponse is the content of a record file.
+type recordedResponse struct {
+	Time           time.Time   `json:"time"`
+	Account        string      `json:"account"`
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	RequestHeaders http.Header `json:"request_headers"`
+	Status         int         `json:"status"`
+	Headers        http.Header `json:"headers"`
+	Body           string      `json:"body"`
+}
+
+// NewResponseRecorder returns a recorder writing to dir, which is created if
+// needed, and keeping at most maxFiles records including those already in it.
+func NewResponseRecorder(dir string, maxFiles int) (*ResponseRecorder, error) {
+	if maxFiles <= 0 {
+		return nil, fmt.Errorf("invalid maximum number of records %d: must be positive", maxFiles)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create record directory: %w", err)
+	}
+
+	existing, err := filepath.Glob(filepath.Join(dir, "*"+recordFileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(existing)
+
+	r := &ResponseRecorder{dir: dir, maxFiles: maxFiles, files: existing}
+	r.prune()
+	return r, nil
+}
+
+// Record writes a response of DeepL to req. Every occurrence of apiKey is
+// redacted, as are the auth header and cookies. Failures are only logged.
+func (r *ResponseRecorder) Record(account string, req *http.Request, resp *http.Response, body []byte, apiKey string) {
+	now := time.Now().UTC()
+	redact := func(s string) string {
+		if apiKey == "" {
+			return s
+		}
+		return strings.ReplaceAll(s, apiKey, Redacted)
+	}
+
+	record := recordedResponse{
+		Time:           now,
+		Account:        account,
+		Method:         req.Method,
+		URL:            redact(req.URL.String()),
+		RequestHeaders: redactHeaders(req.Header, redact),
+		Status:         resp.StatusCode,
+		Headers:        redactHeaders(resp.Header, redact),
+		Body:           redact(string(body)),
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		log.Printf("Failed to encode DeepL response record: %v", err)
+		return
+	}
+
+	name := fmt.Sprintf("%s-%s-%s-%d%s",
+		now.Format(recordTimeFormat),
+		unsafeFileChars.ReplaceAllString(account, "_"),
+		unsafeFileChars.ReplaceAllString(strings.Trim(req.URL.Path, "/"), "_"),
+		resp.StatusCode,
+		recordFileSuffix,
+	)
+	path := filepath.Join(r.dir, name)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		log.Printf("Failed to record DeepL response: %v", err)
+		return
+	}
+	r.files = append(r.files, path)
+	r.prune()
+}
+
+// prune removes the oldest records beyond the maximum. r.mu must be held or
+// r not shared yet.
+func (r *ResponseRecorder) prune() {
+	for len(r.files) > r.maxFiles {
+		if err := os.Remove(r.files[0]); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove old DeepL response record: %v", err)
+		}
+		r.files = r.files[1:]
+	}
+}
+
+// redactHeaders returns a copy of header with credentials removed.
+func redactHeaders(header http.Header, redact func(string) string) http.Header {
+	result := make(http.Header, len(header))
+	for name, values := range header {
+		switch http.CanonicalHeaderKey(name) {
+		case "Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie":
+			result[name] = []string{Redacted}
+			continue
+		}
+		for _, value := range values {
+			result[name] = append(result[name], redact(value))
+		}
+	}
+	return result
+}
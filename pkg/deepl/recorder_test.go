@@ -0,0 +1,81 @@
+package deepl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResponseRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		_, _ = w.Write([]byte(`{"character_count": 1, "character_limit": 2, "echo": "` + r.Header.Get("Authorization") + `"}`))
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "records")
+	recorder, err := NewResponseRecorder(dir, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client, err := NewClient(ClientConfig{Name: "team/a", APIKey: "secret-key", ServerURL: server.URL, Recorder: recorder})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	for range 3 {
+		if _, err := FetchUsage(context.Background(), client); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected the 2 most recent records to be kept, got %d", len(files))
+	}
+	if name := filepath.Base(files[0]); !strings.Contains(name, "-team_a-v2_usage-200.json") {
+		t.Errorf("unexpected record file name %s", name)
+	}
+
+	data, err := os.ReadFile(files[1])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("expected credentials to be redacted, got %s", data)
+	}
+
+	var record recordedResponse
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("invalid record: %v", err)
+	}
+	if record.Account != "team/a" || record.Status != http.StatusOK || !strings.Contains(record.Body, `"character_count": 1`) {
+		t.Errorf("unexpected record: %+v", record)
+	}
+}
+
+func TestResponseRecorderPrunesExisting(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"20260101T000000.000000000Z-a-v2_usage-200.json", "20260102T000000.000000000Z-a-v2_usage-200.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if _, err := NewResponseRecorder(dir, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 || !strings.HasPrefix(filepath.Base(files[0]), "20260102") {
+		t.Errorf("expected only the newest record to be kept, got %v", files)
+	}
+}
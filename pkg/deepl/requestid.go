@@ -0,0 +1,44 @@
+package deepl
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+)
+
+// requestIDKey is the context key of the ID of the scrape or poll the
+// requests to DeepL belong to.
+type requestIDKey struct{}
+
+// newRequestID returns a random ID for a scrape or poll.
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// withRequestID returns ctx carrying a new request ID, unless it already
+// has one, e.g. because the accounts are fetched for the same scrape.
+func withRequestID(ctx context.Context) context.Context {
+	if requestID(ctx) != "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, newRequestID())
+}
+
+// requestID returns the request ID of ctx, or "" if it has none.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// logf logs like log.Printf, prefixed with the request ID of ctx if it has
+// one, so the lines of one scrape or poll can be correlated in busy logs.
+func logf(ctx context.Context, format string, args ...any) {
+	if id := requestID(ctx); id != "" {
+		log.Printf("request_id=%s "+format, append([]any{id}, args...)...)
+		return
+	}
+	log.Printf(format, args...)
+}
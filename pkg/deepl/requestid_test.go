@@ -0,0 +1,48 @@
+package deepl
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"regexp"
+	"testing"
+)
+
+func TestWithRequestID(t *testing.T) {
+	if id := requestID(context.Background()); id != "" {
+		t.Errorf("expected no request ID, got %q", id)
+	}
+
+	ctx := withRequestID(context.Background())
+	id := requestID(ctx)
+	if !regexp.MustCompile(`^[0-9a-f]{16}$`).MatchString(id) {
+		t.Errorf("unexpected request ID %q", id)
+	}
+	// The accounts of one scrape share its ID.
+	if got := requestID(withRequestID(ctx)); got != id {
+		t.Errorf("request ID changed from %q to %q", id, got)
+	}
+	if other := requestID(withRequestID(context.Background())); other == id {
+		t.Errorf("two scrapes got the same request ID %q", id)
+	}
+}
+
+func TestLogf(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(w io.Writer, flags int) {
+		log.SetOutput(w)
+		log.SetFlags(flags)
+	}(log.Writer(), log.Flags())
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+
+	ctx := withRequestID(context.Background())
+	logf(ctx, "Error fetching DeepL %s for account %s: %v", "usage", "default", "timeout")
+	logf(context.Background(), "no %s", "id")
+
+	want := "request_id=" + requestID(ctx) + " Error fetching DeepL usage for account default: timeout\nno id\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
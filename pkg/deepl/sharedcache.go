@@ -0,0 +1,157 @@
+package deepl
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// sharedCacheWait is how often a replica checks for the result of another
+// replica that is already fetching the same response.
+const sharedCacheWait = 100 * time.Millisecond
+
+// SharedCache is a key-value store shared by several exporter replicas, so
+// that only one of them calls DeepL per cache period while all of them
+// serve the same data.
+type SharedCache interface {
+	// Get returns the value of key and whether it exists.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetNX stores value only if key does not exist yet and reports whether
+	// it was stored.
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// CompareAndDelete deletes key only if it still holds value, atomically,
+	// and reports whether it was deleted.
+	CompareAndDelete(ctx context.Context, key string, value []byte) (bool, error)
+}
+
+// sharedFetcher deduplicates DeepL requests across replicas. The replica
+// that takes the lock of a key fetches the response and stores it for ttl,
+// the others wait for it to appear.
+//
+// With leader election, isLeader is set and only the leader fetches from
+// DeepL, always refreshing the cache, while followers only ever serve what
+// the leader replicated.
+type sharedFetcher struct {
+	cache    SharedCache
+	ttl      time.Duration
+	isLeader func() bool
+}
+
+type bypassSharedCacheKey struct{}
+
+// WithoutSharedCache returns a context whose requests always call DeepL and
+// replace the response in the shared cache, e.g. for manual refreshes.
+func WithoutSharedCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassSharedCacheKey{}, true)
+}
+
+func bypassesSharedCache(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassSharedCacheKey{}).(bool)
+	return bypass
+}
+
+// sharedCacheKey derives the cache key of a request from the API key and the
+// URL without revealing the API key.
+func sharedCacheKey(apiKey, url string) string {
+	sum := sha256.Sum256([]byte(apiKey + "\x00" + url))
+	return "deepl-exporter:" + hex.EncodeToString(sum[:16])
+}
+
+// fetch returns the cached response for key, calling fetch to obtain it if
+// no replica has done so within the cache period. If the cache is not
+// reachable, fetch is called directly so the exporter keeps working.
+func (f *sharedFetcher) fetch(ctx context.Context, key string, fetch func(context.Context) ([]byte, error)) ([]byte, error) {
+	if bypassesSharedCache(ctx) {
+		return f.fetchAsLeader(ctx, key, fetch)
+	}
+	if f.isLeader != nil {
+		if f.isLeader() {
+			return f.fetchAsLeader(ctx, key, fetch)
+		}
+		return f.fetchAsFollower(ctx, key)
+	}
+
+	lockKey := key + ":lock"
+	for {
+		body, ok, err := f.cache.Get(ctx, key)
+		if err != nil {
+			logf(ctx, "Shared cache unavailable, fetching directly: %v", err)
+			return fetch(ctx)
+		}
+		if ok {
+			return body, nil
+		}
+
+		// The lock expires after DefaultTimeout, so it holds a token of its
+		// own to not release the lock another replica took since.
+		token := []byte(rand.Text())
+		locked, err := f.cache.SetNX(ctx, lockKey, token, DefaultTimeout)
+		if err != nil {
+			logf(ctx, "Shared cache unavailable, fetching directly: %v", err)
+			return fetch(ctx)
+		}
+		if locked {
+			return f.fetchLocked(ctx, key, lockKey, token, fetch)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(sharedCacheWait):
+		}
+	}
+}
+
+func (f *sharedFetcher) fetchLocked(ctx context.Context, key, lockKey string, token []byte, fetch func(context.Context) ([]byte, error)) ([]byte, error) {
+	defer func() {
+		if _, err := f.cache.CompareAndDelete(context.WithoutCancel(ctx), lockKey, token); err != nil {
+			logf(ctx, "Failed to release shared cache lock: %v", err)
+		}
+	}()
+
+	body, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.cache.Set(ctx, key, body, f.ttl); err != nil {
+		logf(ctx, "Failed to store response in shared cache: %v", err)
+	}
+	return body, nil
+}
+
+func (f *sharedFetcher) fetchAsLeader(ctx context.Context, key string, fetch func(context.Context) ([]byte, error)) ([]byte, error) {
+	body, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.cache.Set(ctx, key, body, f.ttl); err != nil {
+		logf(ctx, "Failed to replicate response through shared cache: %v", err)
+	}
+	return body, nil
+}
+
+// fetchAsFollower waits for the response replicated by the leader. It never
+// calls DeepL, not even when the cache is unreachable.
+func (f *sharedFetcher) fetchAsFollower(ctx context.Context, key string) ([]byte, error) {
+	for {
+		body, ok, err := f.cache.Get(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("shared cache unavailable: %w", err)
+		}
+		if ok {
+			return body, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.New("not the leader and no data replicated by the leader yet")
+		case <-time.After(sharedCacheWait):
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package deepl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryCache is an in-process SharedCache used in tests.
+type memoryCache struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	err    error
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{values: make(map[string][]byte)}
+}
+
+func (m *memoryCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.values[key]
+	return value, ok, m.err
+}
+
+func (m *memoryCache) Set(_ context.Context, key string, value []byte, _ time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key] = value
+	return m.err
+}
+
+func (m *memoryCache) SetNX(_ context.Context, key string, value []byte, _ time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.values[key]; ok {
+		return false, m.err
+	}
+	m.values[key] = value
+	return true, m.err
+}
+
+func (m *memoryCache) CompareAndDelete(_ context.Context, key string, value []byte) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, ok := m.values[key]
+	if !ok || string(current) != string(value) {
+		return false, m.err
+	}
+	delete(m.values, key)
+	return true, m.err
+}
+
+func TestClient_SharedCache(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		_, _ = fmt.Fprintln(w, `{"character_count": 1000, "character_limit": 500000}`)
+	}))
+	defer ts.Close()
+
+	cache := newMemoryCache()
+	newReplica := func() *Client {
+		c, err := NewClient(ClientConfig{
+			APIKey:         "test-key",
+			ServerURL:      ts.URL,
+			SharedCache:    cache,
+			SharedCacheTTL: time.Minute,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return c
+	}
+
+	var wg sync.WaitGroup
+	for _, replica := range []*Client{newReplica(), newReplica(), newReplica()} {
+		wg.Go(func() {
+			usage, err := FetchUsage(context.Background(), replica)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if usage.CharacterCount != 1000 {
+				t.Errorf("expected count 1000, got %d", usage.CharacterCount)
+			}
+		})
+	}
+	wg.Wait()
+
+	if n := requests.Load(); n != 1 {
+		t.Errorf("expected 1 request to DeepL, got %d", n)
+	}
+}
+
+func TestSharedFetcher_CacheUnavailable(t *testing.T) {
+	cache := newMemoryCache()
+	cache.err = errors.New("connection refused")
+	f := &sharedFetcher{cache: cache, ttl: time.Minute}
+
+	body, err := f.fetch(context.Background(), "key", func(context.Context) ([]byte, error) {
+		return []byte("direct"), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(body) != "direct" {
+		t.Errorf("expected direct fetch, got %q", body)
+	}
+}
+
+func TestSharedFetcher_ErrorsAreNotCached(t *testing.T) {
+	cache := newMemoryCache()
+	f := &sharedFetcher{cache: cache, ttl: time.Minute}
+
+	_, err := f.fetch(context.Background(), "key", func(context.Context) ([]byte, error) {
+		return nil, &APIError{StatusCode: http.StatusServiceUnavailable}
+	})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if len(cache.values) != 0 {
+		t.Errorf("expected nothing to be cached and the lock to be released, got %v", cache.values)
+	}
+}
+
+func TestSharedFetcher_KeepsLockOfAnotherReplica(t *testing.T) {
+	cache := newMemoryCache()
+	f := &sharedFetcher{cache: cache, ttl: time.Minute}
+
+	_, err := f.fetch(context.Background(), "key", func(context.Context) ([]byte, error) {
+		// The fetch outlived the lock, which another replica took since.
+		cache.mu.Lock()
+		cache.values["key:lock"] = []byte("other")
+		cache.mu.Unlock()
+		return []byte("body"), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lock := string(cache.values["key:lock"]); lock != "other" {
+		t.Errorf("expected the lock of the other replica to be kept, got %q", lock)
+	}
+}
+
+func TestSharedFetcherLeaderAlwaysFetches(t *testing.T) {
+	cache := newMemoryCache()
+	cache.values["key"] = []byte("stale")
+	f := &sharedFetcher{cache: cache, ttl: time.Minute, isLeader: func() bool { return true }}
+
+	body, err := f.fetch(context.Background(), "key", func(context.Context) ([]byte, error) {
+		return []byte("fresh"), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(body) != "fresh" || string(cache.values["key"]) != "fresh" {
+		t.Errorf("expected the leader to fetch and replicate, got %q cached %q", body, cache.values["key"])
+	}
+}
+
+func TestSharedFetcherFollowerNeverFetches(t *testing.T) {
+	cache := newMemoryCache()
+	f := &sharedFetcher{cache: cache, ttl: time.Minute, isLeader: func() bool { return false }}
+	fetch := func(context.Context) ([]byte, error) {
+		t.Error("follower must not call the DeepL API")
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if _, err := f.fetch(ctx, "key", fetch); err == nil {
+		t.Error("expected an error without replicated data")
+	}
+
+	cache.values["key"] = []byte("replicated")
+	body, err := f.fetch(context.Background(), "key", fetch)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(body) != "replicated" {
+		t.Errorf("expected replicated data, got %q", body)
+	}
+
+	cache.err = errors.New("connection refused")
+	if _, err := f.fetch(context.Background(), "key", fetch); err == nil {
+		t.Error("expected an error when the shared cache is unavailable")
+	}
+}
+
+func TestSharedFetcherBypass(t *testing.T) {
+	cache := newMemoryCache()
+	cache.values["key"] = []byte("stale")
+	f := &sharedFetcher{cache: cache, ttl: time.Minute}
+
+	body, err := f.fetch(WithoutSharedCache(context.Background()), "key", func(context.Context) ([]byte, error) {
+		return []byte("fresh"), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(body) != "fresh" || string(cache.values["key"]) != "fresh" {
+		t.Errorf("expected the cache to be bypassed and updated, got %q cached %q", body, cache.values["key"])
+	}
+}
@@ -0,0 +1,85 @@
+package deepl
+
+import "time"
+
+// AccountStatus is the health of the API key of an account, e.g. for an
+// operator overview of the configured keys.
+type AccountStatus struct {
+	Account string `json:"account"`
+	// APIKey is the key in use, masked with MaskKey.
+	APIKey           string `json:"api_key"`
+	BackupAPIKey     bool   `json:"backup_api_key"`
+	Failover         bool   `json:"failover"`
+	Endpoint         string `json:"endpoint"`
+	EndpointMismatch bool   `json:"endpoint_mismatch"`
+	// Refreshed is when the metrics were last fetched in polling mode, the
+	// zero time if never.
+	Refreshed  time.Time         `json:"refreshed"`
+	Collectors []CollectorStatus `json:"collectors"`
+}
+
+// CollectorStatus is the outcome of the last runs of a module for an
+// account. The times are zero if it never succeeded or failed.
+type CollectorStatus struct {
+	Name        string    `json:"name"`
+	LastSuccess time.Time `json:"last_success"`
+	LastFailure time.Time `json:"last_failure"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// Healthy reports whether the last run of the module succeeded.
+func (s CollectorStatus) Healthy() bool {
+	return !s.LastSuccess.IsZero() && !s.LastFailure.After(s.LastSuccess)
+}
+
+// Healthy reports whether the key is in use without a failover and the last
+// run of every module succeeded.
+func (s AccountStatus) Healthy() bool {
+	if s.Failover || s.EndpointMismatch {
+		return false
+	}
+	for _, collector := range s.Collectors {
+		if !collector.Healthy() {
+			return false
+		}
+	}
+	return true
+}
+
+// Status returns the status of every account in the configured order.
+func (c *DeepLCollector) Status() []AccountStatus {
+	c.mu.RLock()
+	refreshed := make(map[string]time.Time, len(c.cache))
+	for name, cached := range c.cache {
+		refreshed[name] = cached.refreshed
+	}
+	c.mu.RUnlock()
+
+	clients := c.Clients()
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	statuses := make([]AccountStatus, 0, len(clients))
+	for _, client := range clients {
+		baseURL, _ := client.Endpoints()
+		status := AccountStatus{
+			Account:          client.Name(),
+			APIKey:           client.MaskedAPIKey(),
+			BackupAPIKey:     client.HasBackupAPIKey(),
+			Failover:         client.Failover(),
+			Endpoint:         baseURL,
+			EndpointMismatch: client.EndpointMismatch(),
+			Refreshed:        refreshed[client.Name()],
+		}
+		for _, name := range c.names {
+			module := c.status[moduleKey{client.Name(), name}]
+			collector := CollectorStatus{Name: name, LastSuccess: module.lastSuccess, LastFailure: module.lastFailure}
+			if module.lastError != nil {
+				collector.LastError = module.lastError.Error()
+			}
+			status.Collectors = append(status.Collectors, collector)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
@@ -0,0 +1,109 @@
+package deepl
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// traceContext identifies the W3C trace a scrape is part of, taken from the
+// traceparent header of the scrape request. The exporter does not record
+// spans itself, it propagates the trace to DeepL unchanged so a tracing
+// egress proxy or service mesh sidecar can continue it.
+type traceContext struct {
+	traceID  string
+	parentID string
+	flags    string
+}
+
+type traceKey struct{}
+
+// ContextWithTraceparent returns ctx carrying the trace of the traceparent
+// header of a scrape, see https://www.w3.org/TR/trace-context/, so the
+// DeepL requests of a collector with Options.Tracing continue it. ctx is
+// returned unchanged if header is no valid traceparent.
+func ContextWithTraceparent(ctx context.Context, header string) context.Context {
+	trace, ok := parseTraceparent(header)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// parseTraceparent parses a traceparent header of version 00, or of a
+// later version whose first four fields it understands.
+func parseTraceparent(header string) (traceContext, bool) {
+	fields := strings.Split(strings.TrimSpace(header), "-")
+	if len(fields) < 4 {
+		return traceContext{}, false
+	}
+	version, traceID, parentID, flags := fields[0], fields[1], fields[2], fields[3]
+	if !isHex(version, 2) || version == "ff" || (version == "00" && len(fields) != 4) {
+		return traceContext{}, false
+	}
+	if !isHex(traceID, 32) || traceID == strings.Repeat("0", 32) ||
+		!isHex(parentID, 16) || parentID == strings.Repeat("0", 16) || !isHex(flags, 2) {
+		return traceContext{}, false
+	}
+	return traceContext{traceID: traceID, parentID: parentID, flags: flags}, true
+}
+
+// isHex reports whether s consists of n lowercase hex digits.
+func isHex(s string, n int) bool {
+	if len(s) != n || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// withoutTrace returns ctx without the trace it may carry.
+func withoutTrace(ctx context.Context) context.Context {
+	if _, ok := traceFrom(ctx); !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, traceKey{}, nil)
+}
+
+// traceFrom returns the trace of ctx, if it has one.
+func traceFrom(ctx context.Context) (traceContext, bool) {
+	trace, ok := ctx.Value(traceKey{}).(traceContext)
+	return trace, ok
+}
+
+// traceparent returns the traceparent header propagating the trace as it
+// was received.
+func (t traceContext) traceparent() string {
+	return "00-" + t.traceID + "-" + t.parentID + "-" + t.flags
+}
+
+// sampled reports whether the caller records the trace. Only those are
+// worth an exemplar, the others cannot be looked up.
+func (t traceContext) sampled() bool {
+	flags, _ := hex.DecodeString(t.flags)
+	return len(flags) == 1 && flags[0]&0x01 != 0
+}
+
+// sampledTrace returns the trace of ctx if it has one that is sampled.
+func sampledTrace(ctx context.Context) (traceContext, bool) {
+	trace, ok := traceFrom(ctx)
+	return trace, ok && trace.sampled()
+}
+
+// traceExemplar returns labels extended with the trace ID of ctx if it is
+// sampled, so Grafana can link the exemplar to the trace. labels is not
+// modified.
+func traceExemplar(ctx context.Context, labels prometheus.Labels) prometheus.Labels {
+	trace, ok := sampledTrace(ctx)
+	if !ok {
+		return labels
+	}
+	exemplar := make(prometheus.Labels, len(labels)+1)
+	for name, value := range labels {
+		exemplar[name] = value
+	}
+	exemplar["trace_id"] = trace.traceID
+	return exemplar
+}
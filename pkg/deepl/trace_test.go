@@ -0,0 +1,168 @@
+package deepl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	sampledTraceparent   = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	unsampledTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"
+	traceID              = "4bf92f3577b34da6a3ce929d0e0e4736"
+)
+
+func TestContextWithTraceparent(t *testing.T) {
+	tests := []struct {
+		header string
+		valid  bool
+	}{
+		{sampledTraceparent, true},
+		{unsampledTraceparent, true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true},
+		{"", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", false},
+	}
+	for _, tt := range tests {
+		trace, ok := traceFrom(ContextWithTraceparent(context.Background(), tt.header))
+		if ok != tt.valid {
+			t.Errorf("%q: expected valid %v, got %v", tt.header, tt.valid, ok)
+		}
+		if ok && trace.traceID != traceID {
+			t.Errorf("%q: unexpected trace ID %s", tt.header, trace.traceID)
+		}
+	}
+
+	// The trace is propagated as received, without marking it sampled.
+	trace, _ := traceFrom(ContextWithTraceparent(context.Background(), unsampledTraceparent))
+	if trace.traceparent() != unsampledTraceparent || trace.sampled() {
+		t.Errorf("unexpected trace %+v", trace)
+	}
+	if _, ok := traceFrom(withoutTrace(ContextWithTraceparent(context.Background(), sampledTraceparent))); ok {
+		t.Error("expected the trace to be removed")
+	}
+}
+
+func TestTraceExemplar(t *testing.T) {
+	labels := prometheus.Labels{"reason": "timeout"}
+	if got := traceExemplar(context.Background(), labels); len(got) != 1 || got["reason"] != "timeout" {
+		t.Errorf("unexpected exemplar without trace: %v", got)
+	}
+	ctx := ContextWithTraceparent(context.Background(), unsampledTraceparent)
+	if got := traceExemplar(ctx, labels); len(got) != 1 {
+		t.Errorf("unexpected exemplar of an unsampled trace: %v", got)
+	}
+
+	ctx = ContextWithTraceparent(context.Background(), sampledTraceparent)
+	got := traceExemplar(ctx, labels)
+	if got["reason"] != "timeout" || got["trace_id"] != traceID {
+		t.Errorf("unexpected exemplar: %v", got)
+	}
+	if len(labels) != 1 {
+		t.Errorf("labels were modified: %v", labels)
+	}
+}
+
+func TestCollector_TraceExemplars(t *testing.T) {
+	var traceparents []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	scrape := func(c *DeepLCollector, traceparent string) string {
+		t.Helper()
+		collector, err := c.ForRequest(ContextWithTraceparent(context.Background(), traceparent), nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(collector)
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		r.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+		rec := httptest.NewRecorder()
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true}).ServeHTTP(rec, r)
+		// The order of the exemplar labels is not stable.
+		for l := range strings.Lines(rec.Body.String()) {
+			if strings.HasPrefix(l, `deepl_api_errors_total{account="default",collector="usage"} `) {
+				return l
+			}
+		}
+		return ""
+	}
+
+	c, err := NewDeepLCollector([]*Client{newTestClient(t, ts.URL)}, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if line := scrape(c, sampledTraceparent); strings.Contains(line, "trace_id") || traceparents[0] != "" {
+		t.Errorf("expected the trace to be ignored without tracing, sent %q, got %q", traceparents[0], line)
+	}
+
+	c.tracing = true
+	line := scrape(c, sampledTraceparent)
+	if traceparents[1] != sampledTraceparent {
+		t.Errorf("expected the traceparent of the scrape to be propagated, got %q", traceparents[1])
+	}
+	for _, s := range []string{`reason="status_500"`, `trace_id="` + traceID + `"`} {
+		if !strings.Contains(line, s) {
+			t.Errorf("expected %s in the exemplar of deepl_api_errors_total, got %q", s, line)
+		}
+	}
+
+	// Scrapes without a trace, or an unsampled one, get no exemplar
+	// pointing to a trace nobody recorded.
+	for _, traceparent := range []string{"", unsampledTraceparent} {
+		line := scrape(c, traceparent)
+		if strings.Contains(line, "trace_id") {
+			t.Errorf("unexpected trace ID in the exemplar for %q: %q", traceparent, line)
+		}
+		if got := traceparents[len(traceparents)-1]; got != traceparent {
+			t.Errorf("expected traceparent %q to be propagated, got %q", traceparent, got)
+		}
+	}
+}
+
+func TestClient_getJSON_TraceExemplar(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	duration := NewRequestDurationHistogram(prometheus.DefBuckets, false)
+	client, err := NewClient(ClientConfig{Name: "default", APIKey: "test-key", ServerURL: ts.URL, RequestDuration: duration})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := ContextWithTraceparent(context.Background(), sampledTraceparent)
+	var v map[string]any
+	if err := client.GetJSON(ctx, UsagePath, &v); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m := &dto.Metric{}
+	if err := duration.WithLabelValues("default", UsagePath).(prometheus.Metric).Write(m); err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, bucket := range m.GetHistogram().GetBucket() {
+		for _, label := range bucket.GetExemplar().GetLabel() {
+			found = found || label.GetName() == "trace_id" && label.GetValue() == traceID
+		}
+	}
+	if !found {
+		t.Errorf("expected an exemplar with trace ID %s, got %v", traceID, m)
+	}
+}
@@ -0,0 +1,512 @@
+package deepl
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/provider"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// UsagePath is the path of the usage endpoint of the DeepL API.
+const UsagePath = "/v2/usage"
+
+type DeepLUsage struct {
+	CharacterCount int64 `json:"character_count"`
+	CharacterLimit int64 `json:"character_limit"`
+	// APIKeyCharacterCount and APIKeyCharacterLimit are the usage and limit
+	// of the key itself when it has its own limit within the account, nil
+	// when the response doesn't include them.
+	APIKeyCharacterCount *int64 `json:"api_key_character_count,omitempty"`
+	APIKeyCharacterLimit *int64 `json:"api_key_character_limit,omitempty"`
+	// Products splits the character count by DeepL product, e.g. translate
+	// and write. It is only returned for Pro accounts.
+	Products []DeepLProductUsage `json:"products,omitempty"`
+	// ModelTypes splits the character count by translation model, e.g.
+	// latency_optimized (classic) and quality_optimized (next-gen), for the
+	// accounts where DeepL reports it.
+	ModelTypes []DeepLModelTypeUsage `json:"model_types,omitempty"`
+	// DocumentCount and DocumentLimit are the documents translated by the
+	// account and its limit, TeamDocumentCount and TeamDocumentLimit the
+	// ones of its team, nil when the response doesn't include them.
+	DocumentCount     *int64 `json:"document_count,omitempty"`
+	DocumentLimit     *int64 `json:"document_limit,omitempty"`
+	TeamDocumentCount *int64 `json:"team_document_count,omitempty"`
+	TeamDocumentLimit *int64 `json:"team_document_limit,omitempty"`
+	// StartTime and EndTime delimit the current billing period, nil when
+	// the response doesn't include them.
+	StartTime *time.Time `json:"start_time,omitempty"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+}
+
+type DeepLProductUsage struct {
+	ProductType    string `json:"product_type"`
+	CharacterCount int64  `json:"character_count"`
+}
+
+type DeepLModelTypeUsage struct {
+	ModelType      string `json:"model_type"`
+	CharacterCount int64  `json:"character_count"`
+}
+
+// Quota is the consumption of one of the limits of an account.
+type Quota struct {
+	// Name is characters, api_key_characters, documents, team_documents,
+	// or the type of a product, e.g. translate or write.
+	Name  string
+	Count int64
+	Limit int64
+}
+
+// Percent returns the percentage of the limit used.
+func (q Quota) Percent() float64 {
+	return float64(q.Count) / float64(q.Limit) * 100
+}
+
+// Quotas returns every quota of usage that has a limit. The products share
+// the character limit of the account.
+func (u *DeepLUsage) Quotas() []Quota {
+	if u == nil {
+		return nil
+	}
+	var quotas []Quota
+	add := func(name string, count, limit int64) {
+		if limit > 0 {
+			quotas = append(quotas, Quota{Name: name, Count: count, Limit: limit})
+		}
+	}
+	add("characters", u.CharacterCount, u.CharacterLimit)
+	if u.APIKeyCharacterCount != nil && u.APIKeyCharacterLimit != nil {
+		add("api_key_characters", *u.APIKeyCharacterCount, *u.APIKeyCharacterLimit)
+	}
+	if u.DocumentCount != nil && u.DocumentLimit != nil {
+		add("documents", *u.DocumentCount, *u.DocumentLimit)
+	}
+	if u.TeamDocumentCount != nil && u.TeamDocumentLimit != nil {
+		add("team_documents", *u.TeamDocumentCount, *u.TeamDocumentLimit)
+	}
+	for _, product := range u.Products {
+		add(product.ProductType, product.CharacterCount, u.CharacterLimit)
+	}
+	return quotas
+}
+
+// UsageOptions configures the usage collector.
+type UsageOptions struct {
+	// Thresholds are the usage percentages exported as
+	// deepl_usage_above_threshold, in ascending order.
+	Thresholds []float64
+	// PricePerMillion is the price of a million characters, exporting
+	// deepl_characters_cost when positive.
+	PricePerMillion float64
+	// AnomalyWindow is the period of hourly consumption the anomaly score
+	// is computed against.
+	AnomalyWindow time.Duration
+	// AnomalyThreshold is the anomaly score from which the consumption of
+	// the last hour is flagged as a spike.
+	AnomalyThreshold float64
+	// BillingAnchor is the start of the monthly billing cycle, see
+	// ParseBillingAnchor. If set, or if the usage response reports the end
+	// of the period, deepl_billing_period_seconds_remaining is exported.
+	BillingAnchor time.Time
+}
+
+// DefaultUsageOptions returns the options of the usage collector used when
+// none are given.
+func DefaultUsageOptions() UsageOptions {
+	return UsageOptions{
+		Thresholds:       []float64{80, 95},
+		AnomalyWindow:    7 * 24 * time.Hour,
+		AnomalyThreshold: 3,
+	}
+}
+
+type UsageCollector struct {
+	characterCount    *prometheus.Desc
+	characterLimit    *prometheus.Desc
+	characterUsagePct *prometheus.Desc
+	translated        *prometheus.Desc
+	ratePerHour       *prometheus.Desc
+	anomalyScore      *prometheus.Desc
+	spike             *prometheus.Desc
+	aboveThreshold    *prometheus.Desc
+	productCount      *prometheus.Desc
+	modelTypeCount    *prometheus.Desc
+	keyCount          *prometheus.Desc
+	keyLimit          *prometheus.Desc
+	documentCount     *prometheus.Desc
+	documentLimit     *prometheus.Desc
+	teamDocumentCount *prometheus.Desc
+	teamDocumentLimit *prometheus.Desc
+	quotaUsagePct     *prometheus.Desc
+	periodRemaining   *prometheus.Desc
+	lastPeriodChars   *prometheus.Desc
+	lastPeriodDocs    *prometheus.Desc
+	costCenterInfo    *prometheus.Desc
+	cost              *prometheus.Desc
+	quota             *provider.QuotaMetrics
+
+	thresholds       []float64
+	pricePerMillion  float64
+	anomalyWindow    int
+	anomalyThreshold float64
+	billingAnchor    time.Time
+	now              func() time.Time
+
+	mu       sync.Mutex
+	trackers map[string]*usageTracker
+}
+
+func init() {
+	RegisterCollector("usage", true, func(opts Options) Collector {
+		return NewUsageCollector(*opts.Usage)
+	})
+}
+
+func NewUsageCollector(opts UsageOptions) *UsageCollector {
+	return &UsageCollector{
+		characterCount: prometheus.NewDesc(
+			"deepl_character_count",
+			"Current number of characters translated in the current billing period",
+			[]string{"account"},
+			nil,
+		),
+		characterLimit: prometheus.NewDesc(
+			"deepl_character_limit",
+			"Maximum number of characters that can be translated in the current billing period",
+			[]string{"account"},
+			nil,
+		),
+		characterUsagePct: prometheus.NewDesc(
+			"deepl_character_usage_percent",
+			"Percentage of character limit used",
+			[]string{"account"},
+			nil,
+		),
+		translated: prometheus.NewDesc(
+			"deepl_characters_translated_since_start_total",
+			"Characters translated since the exporter started, derived from the observed increases of the character count",
+			[]string{"account"},
+			nil,
+		),
+		ratePerHour: prometheus.NewDesc(
+			"deepl_characters_per_hour",
+			"Characters translated per hour during the last hour, or between the last two observations if they are further apart",
+			[]string{"account"},
+			nil,
+		),
+		anomalyScore: prometheus.NewDesc(
+			"deepl_usage_anomaly_score",
+			"Z-score of the characters translated during the last hour against the hourly consumption of the anomaly window",
+			[]string{"account"},
+			nil,
+		),
+		spike: prometheus.NewDesc(
+			"deepl_usage_spike",
+			"Whether the anomaly score reaches the spike threshold",
+			[]string{"account"},
+			nil,
+		),
+		aboveThreshold: prometheus.NewDesc(
+			"deepl_usage_above_threshold",
+			"Whether the percentage of the character limit used reached the threshold",
+			[]string{"account", "threshold"},
+			nil,
+		),
+		productCount: prometheus.NewDesc(
+			"deepl_product_character_count",
+			"Current number of characters used by a DeepL product, e.g. translate or write, in the current billing period",
+			[]string{"account", "product"},
+			nil,
+		),
+		modelTypeCount: prometheus.NewDesc(
+			"deepl_model_type_character_count",
+			"Current number of characters translated with a model type in the current billing period",
+			[]string{"account", "model_type"},
+			nil,
+		),
+		keyCount: prometheus.NewDesc(
+			"deepl_api_key_character_count",
+			"Current number of characters translated with the API key in the current billing period",
+			[]string{"account"},
+			nil,
+		),
+		keyLimit: prometheus.NewDesc(
+			"deepl_api_key_character_limit",
+			"Maximum number of characters that can be translated with the API key in the current billing period",
+			[]string{"account"},
+			nil,
+		),
+		documentCount: prometheus.NewDesc(
+			"deepl_document_count",
+			"Current number of documents translated in the current billing period",
+			[]string{"account"},
+			nil,
+		),
+		documentLimit: prometheus.NewDesc(
+			"deepl_document_limit",
+			"Maximum number of documents that can be translated in the current billing period",
+			[]string{"account"},
+			nil,
+		),
+		teamDocumentCount: prometheus.NewDesc(
+			"deepl_team_document_count",
+			"Current number of documents translated by the team in the current billing period",
+			[]string{"account"},
+			nil,
+		),
+		teamDocumentLimit: prometheus.NewDesc(
+			"deepl_team_document_limit",
+			"Maximum number of documents that can be translated by the team in the current billing period",
+			[]string{"account"},
+			nil,
+		),
+		quotaUsagePct: prometheus.NewDesc(
+			"deepl_quota_usage_percent",
+			"Percentage of a quota used, e.g. characters, documents or the characters of a product",
+			[]string{"account", "quota"},
+			nil,
+		),
+		periodRemaining: prometheus.NewDesc(
+			"deepl_billing_period_seconds_remaining",
+			"Seconds until the billing period resets, from the end of the period reported by DeepL or the configured billing anchor",
+			[]string{"account"},
+			nil,
+		),
+		lastPeriodChars: prometheus.NewDesc(
+			"deepl_characters_last_period_total",
+			"Characters translated in the previous billing period, as last observed before the reset",
+			[]string{"account"},
+			nil,
+		),
+		lastPeriodDocs: prometheus.NewDesc(
+			"deepl_documents_last_period_total",
+			"Documents translated in the previous billing period, as last observed before the reset",
+			[]string{"account"},
+			nil,
+		),
+		costCenterInfo: prometheus.NewDesc(
+			"deepl_account_cost_center_info",
+			"Cost center and project the spend of the account is attributed to, always 1",
+			[]string{"account", "cost_center", "project"},
+			nil,
+		),
+		cost: prometheus.NewDesc(
+			"deepl_characters_cost",
+			"Cost of the characters translated in the current billing period, by cost center and project",
+			[]string{"account", "cost_center", "project"},
+			nil,
+		),
+		quota:            provider.NewQuotaMetrics("deepl"),
+		thresholds:       opts.Thresholds,
+		pricePerMillion:  opts.PricePerMillion,
+		anomalyWindow:    max(int(opts.AnomalyWindow/time.Hour), minAnomalyBaseline),
+		anomalyThreshold: opts.AnomalyThreshold,
+		billingAnchor:    opts.BillingAnchor,
+		now:              time.Now,
+		trackers:         make(map[string]*usageTracker),
+	}
+}
+
+func (c *UsageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.characterCount
+	ch <- c.characterLimit
+	ch <- c.characterUsagePct
+	ch <- c.translated
+	ch <- c.ratePerHour
+	ch <- c.anomalyScore
+	ch <- c.spike
+	ch <- c.aboveThreshold
+	ch <- c.productCount
+	ch <- c.modelTypeCount
+	ch <- c.keyCount
+	ch <- c.keyLimit
+	ch <- c.documentCount
+	ch <- c.documentLimit
+	ch <- c.teamDocumentCount
+	ch <- c.teamDocumentLimit
+	ch <- c.quotaUsagePct
+	ch <- c.periodRemaining
+	ch <- c.lastPeriodChars
+	ch <- c.lastPeriodDocs
+	ch <- c.costCenterInfo
+	ch <- c.cost
+	c.quota.Describe(ch)
+}
+
+func (c *UsageCollector) Update(ctx context.Context, client *Client, ch chan<- prometheus.Metric) error {
+	usage, err := FetchUsage(ctx, client)
+	if err != nil {
+		return err
+	}
+	reportUsage(ctx, usage)
+
+	ch <- prometheus.MustNewConstMetric(
+		c.characterCount,
+		prometheus.GaugeValue,
+		float64(usage.CharacterCount),
+		client.Name(),
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		c.characterLimit,
+		prometheus.GaugeValue,
+		float64(usage.CharacterLimit),
+		client.Name(),
+	)
+
+	c.quota.Collect(ch, provider.Usage{
+		Account:        client.Name(),
+		CharacterCount: usage.CharacterCount,
+		CharacterLimit: usage.CharacterLimit,
+	})
+
+	costCenter, project := client.CostAllocation()
+	if costCenter != "" || project != "" {
+		ch <- prometheus.MustNewConstMetric(c.costCenterInfo, prometheus.GaugeValue, 1, client.Name(), costCenter, project)
+	}
+	if c.pricePerMillion > 0 {
+		ch <- prometheus.MustNewConstMetric(
+			c.cost,
+			prometheus.GaugeValue,
+			float64(usage.CharacterCount)/1e6*c.pricePerMillion,
+			client.Name(), costCenter, project,
+		)
+	}
+
+	if usage.APIKeyCharacterCount != nil {
+		ch <- prometheus.MustNewConstMetric(c.keyCount, prometheus.GaugeValue, float64(*usage.APIKeyCharacterCount), client.Name())
+	}
+	if usage.APIKeyCharacterLimit != nil {
+		ch <- prometheus.MustNewConstMetric(c.keyLimit, prometheus.GaugeValue, float64(*usage.APIKeyCharacterLimit), client.Name())
+	}
+
+	for _, product := range usage.Products {
+		ch <- prometheus.MustNewConstMetric(
+			c.productCount,
+			prometheus.GaugeValue,
+			float64(product.CharacterCount),
+			client.Name(), product.ProductType,
+		)
+	}
+	for _, model := range usage.ModelTypes {
+		ch <- prometheus.MustNewConstMetric(
+			c.modelTypeCount,
+			prometheus.GaugeValue,
+			float64(model.CharacterCount),
+			client.Name(), model.ModelType,
+		)
+	}
+
+	for _, document := range []struct {
+		count, limit *int64
+		countDesc    *prometheus.Desc
+		limitDesc    *prometheus.Desc
+	}{
+		{usage.DocumentCount, usage.DocumentLimit, c.documentCount, c.documentLimit},
+		{usage.TeamDocumentCount, usage.TeamDocumentLimit, c.teamDocumentCount, c.teamDocumentLimit},
+	} {
+		if document.count != nil {
+			ch <- prometheus.MustNewConstMetric(document.countDesc, prometheus.GaugeValue, float64(*document.count), client.Name())
+		}
+		if document.limit != nil {
+			ch <- prometheus.MustNewConstMetric(document.limitDesc, prometheus.GaugeValue, float64(*document.limit), client.Name())
+		}
+	}
+	for _, quota := range usage.Quotas() {
+		ch <- prometheus.MustNewConstMetric(c.quotaUsagePct, prometheus.GaugeValue, quota.Percent(), client.Name(), quota.Name)
+	}
+
+	now := c.now()
+	if end, ok := c.periodEnd(usage, now); ok {
+		ch <- prometheus.MustNewConstMetric(c.periodRemaining, prometheus.GaugeValue, max(end.Sub(now).Seconds(), 0), client.Name())
+	}
+
+	usagePercent := 0.0
+	if usage.CharacterLimit > 0 {
+		usagePercent = (float64(usage.CharacterCount) / float64(usage.CharacterLimit)) * 100
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.characterUsagePct,
+		prometheus.GaugeValue,
+		usagePercent,
+		client.Name(),
+	)
+
+	for _, threshold := range c.thresholds {
+		above := 0.0
+		if usagePercent >= threshold {
+			above = 1
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.aboveThreshold,
+			prometheus.GaugeValue,
+			above,
+			client.Name(), strconv.FormatFloat(threshold, 'f', -1, 64),
+		)
+	}
+
+	c.mu.Lock()
+	tracker, ok := c.trackers[client.Name()]
+	if !ok {
+		tracker = &usageTracker{}
+		c.trackers[client.Name()] = tracker
+	}
+	tracker.observe(now, usage.CharacterCount, c.anomalyWindow)
+	if usage.DocumentCount != nil {
+		tracker.documents.observe(*usage.DocumentCount)
+	}
+	characters, documents := tracker.characters, tracker.documents
+	total, started := tracker.total, tracker.started
+	rate, rateOK := tracker.ratePerHour()
+	score, ok := tracker.anomalyScore()
+	c.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(c.translated, prometheus.CounterValue, float64(total), started, client.Name())
+	if characters.reset {
+		ch <- prometheus.MustNewConstMetric(c.lastPeriodChars, prometheus.GaugeValue, float64(characters.previous), client.Name())
+	}
+	if documents.reset {
+		ch <- prometheus.MustNewConstMetric(c.lastPeriodDocs, prometheus.GaugeValue, float64(documents.previous), client.Name())
+	}
+	if rateOK {
+		ch <- prometheus.MustNewConstMetric(c.ratePerHour, prometheus.GaugeValue, rate, client.Name())
+	}
+
+	if ok {
+		spike := 0.0
+		if score >= c.anomalyThreshold {
+			spike = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.anomalyScore, prometheus.GaugeValue, score, client.Name())
+		ch <- prometheus.MustNewConstMetric(c.spike, prometheus.GaugeValue, spike, client.Name())
+	}
+
+	return nil
+}
+
+// periodEnd returns the end of the current billing period, following the
+// configured anchor, which the operator sets to the actual reset date, over
+// the one reported by DeepL.
+func (c *UsageCollector) periodEnd(usage *DeepLUsage, now time.Time) (time.Time, bool) {
+	if !c.billingAnchor.IsZero() {
+		return NextBillingReset(c.billingAnchor, now), true
+	}
+	if usage.EndTime != nil {
+		return *usage.EndTime, true
+	}
+	return time.Time{}, false
+}
+
+// FetchUsage returns the usage of the account of client in the current
+// billing period.
+func FetchUsage(ctx context.Context, client *Client) (*DeepLUsage, error) {
+	var usage DeepLUsage
+	if err := client.GetJSON(ctx, UsagePath, &usage); err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
@@ -0,0 +1,353 @@
+package deepl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestFetchUsage(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "DeepL-Auth-Key test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"character_count": 12345, "character_limit": 500000}`)
+	}))
+	defer ts.Close()
+
+	client := newTestClient(t, ts.URL)
+
+	usage, err := FetchUsage(context.Background(), client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if usage.CharacterCount != 12345 {
+		t.Errorf("expected count 12345, got %d", usage.CharacterCount)
+	}
+	if usage.CharacterLimit != 500000 {
+		t.Errorf("expected limit 500000, got %d", usage.CharacterLimit)
+	}
+}
+
+func TestUsageCollectorQuota(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `{"character_count": 850, "character_limit": 1000}`)
+	}))
+	defer ts.Close()
+
+	ch := make(chan prometheus.Metric, 20)
+	if err := NewUsageCollector(DefaultUsageOptions()).Update(context.Background(), newTestClient(t, ts.URL), ch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(ch)
+
+	got := make(map[string]float64)
+	for metric := range ch {
+		name := metricName(metric)
+		if name != "translation_quota_used" && name != "translation_quota_used_ratio" {
+			continue
+		}
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		labels := make(map[string]string)
+		for _, label := range m.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		if labels["provider"] != "deepl" || labels["account"] != "default" {
+			t.Errorf("unexpected labels of %s: %v", name, labels)
+		}
+		got[name] = m.GetGauge().GetValue()
+	}
+	if got["translation_quota_used"] != 850 || got["translation_quota_used_ratio"] != 0.85 {
+		t.Errorf("unexpected quota metrics: %v", got)
+	}
+}
+
+func TestDeepLUsageQuotas(t *testing.T) {
+	var usage DeepLUsage
+	if err := json.Unmarshal([]byte(`{
+		"character_count": 500, "character_limit": 1000,
+		"document_count": 3, "document_limit": 4,
+		"team_document_count": 10, "team_document_limit": 0,
+		"products": [{"product_type": "translate", "character_count": 400}, {"product_type": "write", "character_count": 100}]
+	}`), &usage); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := make(map[string]float64)
+	for _, quota := range usage.Quotas() {
+		got[quota.Name] = quota.Percent()
+	}
+	want := map[string]float64{"characters": 50, "documents": 75, "translate": 40, "write": 10}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for name, percent := range want {
+		if got[name] != percent {
+			t.Errorf("%s: expected %v, got %v", name, percent, got[name])
+		}
+	}
+}
+
+func TestUsageCollectorThresholds(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `{"character_count": 850, "character_limit": 1000}`)
+	}))
+	defer ts.Close()
+
+	collector := NewUsageCollector(DefaultUsageOptions())
+	collector.thresholds = []float64{80, 85, 92.5}
+
+	ch := make(chan prometheus.Metric, 20)
+	if err := collector.Update(context.Background(), newTestClient(t, ts.URL), ch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(ch)
+
+	got := make(map[string]float64)
+	for metric := range ch {
+		if metricName(metric) != "deepl_usage_above_threshold" {
+			continue
+		}
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, label := range m.GetLabel() {
+			if label.GetName() == "threshold" {
+				got[label.GetValue()] = m.GetGauge().GetValue()
+			}
+		}
+	}
+	want := map[string]float64{"80": 1, "85": 1, "92.5": 0}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for threshold, value := range want {
+		if got[threshold] != value {
+			t.Errorf("threshold %s: expected %v, got %v", threshold, value, got[threshold])
+		}
+	}
+}
+
+func TestUsageCollectorProducts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `{"character_count": 900, "character_limit": 1000, "products": [{"product_type": "write", "character_count": 200}, {"product_type": "translate", "character_count": 700}]}`)
+	}))
+	defer ts.Close()
+
+	ch := make(chan prometheus.Metric, 20)
+	if err := NewUsageCollector(DefaultUsageOptions()).Update(context.Background(), newTestClient(t, ts.URL), ch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(ch)
+
+	got := make(map[string]float64)
+	for metric := range ch {
+		if metricName(metric) != "deepl_product_character_count" {
+			continue
+		}
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, label := range m.GetLabel() {
+			if label.GetName() == "product" {
+				got[label.GetValue()] = m.GetGauge().GetValue()
+			}
+		}
+	}
+	if len(got) != 2 || got["write"] != 200 || got["translate"] != 700 {
+		t.Errorf("unexpected product usage: %v", got)
+	}
+}
+
+func TestUsageCollectorModelTypes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `{"character_count": 900, "character_limit": 1000, "model_types": [{"model_type": "latency_optimized", "character_count": 600}, {"model_type": "quality_optimized", "character_count": 300}]}`)
+	}))
+	defer ts.Close()
+
+	ch := make(chan prometheus.Metric, 20)
+	if err := NewUsageCollector(DefaultUsageOptions()).Update(context.Background(), newTestClient(t, ts.URL), ch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(ch)
+
+	got := make(map[string]float64)
+	for metric := range ch {
+		if metricName(metric) != "deepl_model_type_character_count" {
+			continue
+		}
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, label := range m.GetLabel() {
+			if label.GetName() == "model_type" {
+				got[label.GetValue()] = m.GetGauge().GetValue()
+			}
+		}
+	}
+	if len(got) != 2 || got["latency_optimized"] != 600 || got["quality_optimized"] != 300 {
+		t.Errorf("unexpected model type usage: %v", got)
+	}
+}
+
+func TestUsageCollectorKeyLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want map[string]float64
+	}{
+		{
+			name: "key limit",
+			body: `{"character_count": 900, "character_limit": 1000, "api_key_character_count": 300, "api_key_character_limit": 400}`,
+			want: map[string]float64{"deepl_api_key_character_count": 300, "deepl_api_key_character_limit": 400},
+		},
+		{
+			name: "account only",
+			body: `{"character_count": 900, "character_limit": 1000}`,
+			want: map[string]float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = fmt.Fprintln(w, tt.body)
+			}))
+			defer ts.Close()
+
+			ch := make(chan prometheus.Metric, 20)
+			if err := NewUsageCollector(DefaultUsageOptions()).Update(context.Background(), newTestClient(t, ts.URL), ch); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			close(ch)
+
+			got := make(map[string]float64)
+			for metric := range ch {
+				name := metricName(metric)
+				if name != "deepl_api_key_character_count" && name != "deepl_api_key_character_limit" {
+					continue
+				}
+				var m dto.Metric
+				if err := metric.Write(&m); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				got[name] = m.GetGauge().GetValue()
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			for name, value := range tt.want {
+				if got[name] != value {
+					t.Errorf("%s: expected %v, got %v", name, value, got[name])
+				}
+			}
+		})
+	}
+}
+
+func TestUsageCollectorCost(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `{"character_count": 2000000, "character_limit": 5000000}`)
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ClientConfig{Name: "website", APIKey: "test-key", ServerURL: ts.URL, CostCenter: "CC-42", Project: "web"})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	collector := NewUsageCollector(DefaultUsageOptions())
+	collector.pricePerMillion = 25
+
+	ch := make(chan prometheus.Metric, 20)
+	if err := collector.Update(context.Background(), client, ch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(ch)
+
+	got := make(map[string]float64)
+	for metric := range ch {
+		name := metricName(metric)
+		if name != "deepl_characters_cost" && name != "deepl_account_cost_center_info" {
+			continue
+		}
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		labels := make(map[string]string)
+		for _, label := range m.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		if labels["cost_center"] != "CC-42" || labels["project"] != "web" {
+			t.Errorf("%s: unexpected labels %v", name, labels)
+		}
+		got[name] = m.GetGauge().GetValue()
+	}
+	if got["deepl_characters_cost"] != 50 || got["deepl_account_cost_center_info"] != 1 {
+		t.Errorf("unexpected cost metrics: %v", got)
+	}
+}
+
+var descNamePattern = regexp.MustCompile(`fqName: "([^"]+)"`)
+
+// metricName returns the fully-qualified name of metric.
+func metricName(metric prometheus.Metric) string {
+	if m := descNamePattern.FindStringSubmatch(metric.Desc().String()); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+func TestUsageCollectorLastPeriod(t *testing.T) {
+	response := `{"character_count": 900, "character_limit": 1000, "document_count": 5, "document_limit": 10}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer ts.Close()
+	client := newTestClient(t, ts.URL)
+	collector := NewUsageCollector(DefaultUsageOptions())
+
+	update := func() map[string]float64 {
+		ch := make(chan prometheus.Metric, 30)
+		if err := collector.Update(context.Background(), client, ch); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		close(ch)
+		got := make(map[string]float64)
+		for metric := range ch {
+			name := metricName(metric)
+			if name != "deepl_characters_last_period_total" && name != "deepl_documents_last_period_total" {
+				continue
+			}
+			var m dto.Metric
+			if err := metric.Write(&m); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got[name] = m.GetGauge().GetValue()
+		}
+		return got
+	}
+
+	if got := update(); len(got) != 0 {
+		t.Errorf("expected no last period before a reset, got %v", got)
+	}
+	response = `{"character_count": 10, "character_limit": 1000, "document_count": 1, "document_limit": 10}`
+	got := update()
+	if got["deepl_characters_last_period_total"] != 900 || got["deepl_documents_last_period_total"] != 5 {
+		t.Errorf("unexpected last period totals: %v", got)
+	}
+}
@@ -0,0 +1,157 @@
+package deepl
+
+import (
+	"math"
+	"time"
+)
+
+// minAnomalyBaseline is the number of complete hours observed before an
+// anomaly score is computed.
+const minAnomalyBaseline = 24
+
+// usageObservation is a character count observed at some time, and the
+// characters consumed since the previous observation.
+type usageObservation struct {
+	time     time.Time
+	count    int64
+	consumed int64
+}
+
+// usageTracker follows the character count of one account to derive its
+// consumption.
+type usageTracker struct {
+	// started is when the account was first observed and total the
+	// characters consumed since.
+	started time.Time
+	total   int64
+
+	last *usageObservation
+	// recent are the observations of the last hour, preceded by the last
+	// one before it.
+	recent []usageObservation
+
+	// hourStart is the start of the hour being accumulated in hourConsumed.
+	// hourPartial is set if it was not observed completely, e.g. because the
+	// exporter started within it or scrapes stopped for a while.
+	hourStart    time.Time
+	hourConsumed int64
+	hourPartial  bool
+	// hourly is the consumption of the last complete hours, oldest first.
+	hourly []float64
+
+	// characters and documents keep the totals of the previous billing
+	// period.
+	characters periodTotal
+	documents  periodTotal
+}
+
+// periodTotal follows a count that grows within a billing period to keep
+// its last value before the period was reset.
+type periodTotal struct {
+	last int64
+	seen bool
+	// previous is the last count observed in the previous period, valid if
+	// reset is set.
+	previous int64
+	reset    bool
+}
+
+func (p *periodTotal) observe(count int64) {
+	if p.seen && count < p.last {
+		p.previous, p.reset = p.last, true
+	}
+	p.last, p.seen = count, true
+}
+
+// observe records the character count at now and keeps up to window hours
+// of hourly consumption.
+func (t *usageTracker) observe(now time.Time, count int64, window int) {
+	obs := usageObservation{time: now, count: count}
+	gap := t.last == nil || now.Sub(t.last.time) > time.Hour
+	if t.last == nil {
+		t.started = now
+	} else {
+		obs.consumed = count - t.last.count
+		if obs.consumed < 0 {
+			// The billing period was reset in between.
+			obs.consumed = count
+		}
+	}
+
+	hour := now.Truncate(time.Hour)
+	if hour.After(t.hourStart) {
+		if !t.hourStart.IsZero() && !t.hourPartial && hour.Equal(t.hourStart.Add(time.Hour)) {
+			t.hourly = append(t.hourly, float64(t.hourConsumed))
+			if len(t.hourly) > window {
+				t.hourly = t.hourly[len(t.hourly)-window:]
+			}
+		}
+		t.hourStart, t.hourConsumed, t.hourPartial = hour, 0, gap
+	}
+	t.hourConsumed += obs.consumed
+	t.total += obs.consumed
+	t.hourPartial = t.hourPartial || gap
+
+	t.characters.observe(count)
+	t.recent = append(t.recent, obs)
+	for len(t.recent) > 1 && !t.recent[1].time.After(now.Add(-time.Hour)) {
+		t.recent = t.recent[1:]
+	}
+	t.last = &obs
+}
+
+// lastHour returns the characters consumed during the last hour.
+func (t *usageTracker) lastHour() int64 {
+	var consumed int64
+	for _, obs := range t.recent {
+		if obs.time.After(t.last.time.Add(-time.Hour)) {
+			consumed += obs.consumed
+		}
+	}
+	return consumed
+}
+
+// ratePerHour returns the characters consumed per hour between the first and
+// the last of the recent observations, which span at least an hour once
+// observed for that long, or the time between the last two observations if
+// they are further apart. It returns false before two observations were
+// made.
+func (t *usageTracker) ratePerHour() (float64, bool) {
+	if len(t.recent) < 2 {
+		return 0, false
+	}
+	var consumed int64
+	for _, obs := range t.recent[1:] {
+		consumed += obs.consumed
+	}
+	elapsed := t.recent[len(t.recent)-1].time.Sub(t.recent[0].time)
+	if elapsed <= 0 {
+		return 0, false
+	}
+	return float64(consumed) / elapsed.Hours(), true
+}
+
+// anomalyScore returns the z-score of the consumption during the last hour
+// against the hourly consumption of the baseline, and false until the
+// baseline is long enough. The standard deviation is at least one character,
+// so a perfectly steady consumption does not turn every deviation into a
+// spike.
+func (t *usageTracker) anomalyScore() (float64, bool) {
+	if len(t.hourly) < minAnomalyBaseline {
+		return 0, false
+	}
+
+	var sum float64
+	for _, consumed := range t.hourly {
+		sum += consumed
+	}
+	mean := sum / float64(len(t.hourly))
+
+	var variance float64
+	for _, consumed := range t.hourly {
+		variance += (consumed - mean) * (consumed - mean)
+	}
+	stddev := math.Max(math.Sqrt(variance/float64(len(t.hourly))), 1)
+
+	return (float64(t.lastHour()) - mean) / stddev, true
+}
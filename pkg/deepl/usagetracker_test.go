@@ -0,0 +1,156 @@
+package deepl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestUsageTrackerHourly(t *testing.T) {
+	var tracker usageTracker
+	start := time.Date(2026, 10, 16, 10, 30, 0, 0, time.UTC)
+
+	// 10:30 to 13:45 every 15 minutes, consuming 100 characters each time
+	// with a billing period reset at 12:00.
+	count := int64(0)
+	for now := start; !now.After(start.Add(3*time.Hour + 15*time.Minute)); now = now.Add(15 * time.Minute) {
+		count += 100
+		if now.Equal(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)) {
+			count = 100
+		}
+		tracker.observe(now, count, 2)
+	}
+
+	// 10:00 was partial, 11:00 and 12:00 complete.
+	if len(tracker.hourly) != 2 || tracker.hourly[0] != 400 || tracker.hourly[1] != 400 {
+		t.Errorf("unexpected hourly consumption %v", tracker.hourly)
+	}
+	if got := tracker.lastHour(); got != 400 {
+		t.Errorf("expected 400 characters during the last hour, got %d", got)
+	}
+	if _, ok := tracker.anomalyScore(); ok {
+		t.Error("expected no score before the baseline is complete")
+	}
+	if !tracker.started.Equal(start) || tracker.total != 1300 {
+		t.Errorf("expected 1300 characters since %s, got %d since %s", start, tracker.total, tracker.started)
+	}
+	if !tracker.characters.reset || tracker.characters.previous != 600 {
+		t.Errorf("expected 600 characters in the previous period, got %+v", tracker.characters)
+	}
+
+	// A gap of two hours makes the hour after it partial.
+	tracker.observe(start.Add(6*time.Hour), count+5000, 2)
+	tracker.observe(start.Add(7*time.Hour), count+5100, 2)
+	if len(tracker.hourly) != 2 || tracker.hourly[1] != 400 {
+		t.Errorf("unexpected hourly consumption after a gap %v", tracker.hourly)
+	}
+}
+
+func TestUsageTrackerRatePerHour(t *testing.T) {
+	var tracker usageTracker
+	start := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	if _, ok := tracker.ratePerHour(); ok {
+		t.Error("expected no rate before two observations")
+	}
+
+	// Every 10 minutes 100 characters for 2 hours: 600 per hour.
+	for i := range 13 {
+		tracker.observe(start.Add(time.Duration(i)*10*time.Minute), int64(i)*100, 1)
+	}
+	if rate, ok := tracker.ratePerHour(); !ok || rate != 600 {
+		t.Errorf("expected 600 characters per hour, got %v (%t)", rate, ok)
+	}
+
+	// After a 3 hour gap, the rate covers the gap.
+	tracker.observe(start.Add(5*time.Hour), 1200+1500, 1)
+	if rate, ok := tracker.ratePerHour(); !ok || rate != 500 {
+		t.Errorf("expected 500 characters per hour, got %v (%t)", rate, ok)
+	}
+}
+
+func TestUsageTrackerAnomalyScore(t *testing.T) {
+	var tracker usageTracker
+	start := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	count := int64(0)
+	for i := range 2 * minAnomalyBaseline {
+		// Alternate between 900 and 1100 characters per hour.
+		count += 900 + int64(i%2)*200
+		tracker.observe(start.Add(time.Duration(i)*time.Hour), count, minAnomalyBaseline)
+	}
+
+	score, ok := tracker.anomalyScore()
+	if !ok || score < -1.1 || score > 1.1 {
+		t.Errorf("expected a normal score, got %v (%t)", score, ok)
+	}
+
+	count += 2000
+	tracker.observe(start.Add(time.Duration(2*minAnomalyBaseline)*time.Hour), count, minAnomalyBaseline)
+	if score, _ := tracker.anomalyScore(); score < 9 {
+		t.Errorf("expected a high score for a spike, got %v", score)
+	}
+}
+
+func TestUsageCollectorAnomaly(t *testing.T) {
+	var count int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(DeepLUsage{CharacterCount: count, CharacterLimit: 1000000})
+	}))
+	defer server.Close()
+	client := newTestClient(t, server.URL)
+
+	collector := NewUsageCollector(DefaultUsageOptions())
+	now := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	collector.now = func() time.Time { return now }
+
+	update := func() []prometheus.Metric {
+		ch := make(chan prometheus.Metric, 20)
+		if err := collector.Update(context.Background(), client, ch); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		close(ch)
+		var metrics []prometheus.Metric
+		for m := range ch {
+			metrics = append(metrics, m)
+		}
+		return metrics
+	}
+
+	hasScore := func(metrics []prometheus.Metric) bool {
+		for _, m := range metrics {
+			if metricName(m) == "deepl_usage_anomaly_score" {
+				return true
+			}
+		}
+		return false
+	}
+	for range minAnomalyBaseline + 1 {
+		if hasScore(update()) {
+			t.Fatal("expected no anomaly score before the baseline is complete")
+		}
+		count += 1000
+		now = now.Add(time.Hour)
+	}
+	count += 50000
+	metrics := update()
+	if !hasScore(metrics) {
+		t.Fatal("expected an anomaly score")
+	}
+	for _, m := range metrics {
+		if metricName(m) != "deepl_usage_spike" {
+			continue
+		}
+		var out dto.Metric
+		if err := m.Write(&out); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.GetGauge().GetValue() != 1 {
+			t.Errorf("expected a spike, got %v", out.GetGauge().GetValue())
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+const (
+	awsCloudWatchService = "monitoring"
+	awsGetMetricData     = "GraniteServiceVersion20100801.GetMetricData"
+
+	// awsCharactersExpression sums the characters of every language pair and
+	// operation Amazon Translate reports to CloudWatch, per day.
+	awsCharactersExpression = `SUM(SEARCH('{AWS/Translate,LanguagePair,Operation} MetricName="CharacterCount"', 'Sum', 86400))`
+)
+
+// AWSConfig configures the Amazon Translate provider.
+type AWSConfig struct {
+	// Regions are the AWS regions whose usage is exported, one account each.
+	Regions []string
+	// Credentials are those of an identity allowed to call
+	// cloudwatch:GetMetricData, e.g. of the default AWS credential chain.
+	Credentials aws.CredentialsProvider
+	// CharacterLimit is the monthly character budget of every region, 0 for
+	// none, e.g. 2000000 for the free tier.
+	CharacterLimit int64
+	// Endpoint overrides the CloudWatch endpoint of every region, e.g. for
+	// a VPC endpoint or LocalStack.
+	Endpoint string
+	// HTTPClient sends the requests, a client with a 10s timeout if nil.
+	HTTPClient *http.Client
+}
+
+// AWS fetches the characters translated by Amazon Translate in the current
+// month from the CharacterCount metric it reports to CloudWatch.
+type AWS struct {
+	cfg    AWSConfig
+	http   *http.Client
+	signer *v4.Signer
+	now    func() time.Time
+}
+
+// NewAWS returns the Amazon Translate provider configured by cfg.
+func NewAWS(cfg AWSConfig) (*AWS, error) {
+	if len(cfg.Regions) == 0 {
+		return nil, errors.New("aws: at least one region is required")
+	}
+	if cfg.Credentials == nil {
+		return nil, errors.New("aws: credentials are required")
+	}
+	a := &AWS{cfg: cfg, http: cfg.HTTPClient, signer: v4.NewSigner(), now: time.Now}
+	if a.http == nil {
+		a.http = &http.Client{Timeout: defaultTimeout}
+	}
+	return a, nil
+}
+
+func (a *AWS) Name() string {
+	return "amazon_translate"
+}
+
+func (a *AWS) FetchUsage(ctx context.Context) ([]Usage, error) {
+	var usages []Usage
+	var errs []error
+	for _, region := range a.cfg.Regions {
+		count, err := a.monthToDate(ctx, region)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("region %s: %w", region, err))
+			continue
+		}
+		usages = append(usages, Usage{Account: region, CharacterCount: count, CharacterLimit: a.cfg.CharacterLimit})
+	}
+	return usages, errors.Join(errs...)
+}
+
+// monthToDate sums the characters translated in region since the start of
+// the current month in UTC, following the pages of the response.
+func (a *AWS) monthToDate(ctx context.Context, region string) (int64, error) {
+	now := a.now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	endpoint := a.cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://monitoring." + region + ".amazonaws.com"
+	}
+
+	var total float64
+	var nextToken string
+	for {
+		request := map[string]any{
+			"MetricDataQueries": []map[string]any{{"Id": "characters", "Expression": awsCharactersExpression, "Period": 86400}},
+			"StartTime":         start.Unix(),
+			"EndTime":           now.Unix(),
+		}
+		if nextToken != "" {
+			request["NextToken"] = nextToken
+		}
+		var resp struct {
+			MetricDataResults []struct {
+				Values []float64 `json:"Values"`
+			} `json:"MetricDataResults"`
+			NextToken string `json:"NextToken"`
+		}
+		if err := a.getMetricData(ctx, endpoint, region, request, &resp); err != nil {
+			return 0, err
+		}
+		for _, result := range resp.MetricDataResults {
+			for _, value := range result.Values {
+				total += value
+			}
+		}
+		if resp.NextToken == "" {
+			return int64(total), nil
+		}
+		nextToken = resp.NextToken
+	}
+}
+
+// getMetricData calls the GetMetricData action of the CloudWatch JSON API.
+func (a *AWS) getMetricData(ctx context.Context, endpoint, region string, request, v any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", awsGetMetricData)
+
+	credentials, err := a.cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve credentials: %w", err)
+	}
+	bodyHash := sha256.Sum256(body)
+	if err := a.signer.SignHTTP(ctx, credentials, req, hex.EncodeToString(bodyHash[:]), awsCloudWatchService, region, a.now().UTC()); err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("GetMetricData returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
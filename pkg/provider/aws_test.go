@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestAWS_FetchUsage(t *testing.T) {
+	var requests []map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != awsGetMetricData ||
+			!strings.Contains(r.Header.Get("Authorization"), "Credential=AKID/20261016/eu-west-1/monitoring/aws4_request") ||
+			r.Header.Get("X-Amz-Security-Token") != "session" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var request map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, request)
+		if request["NextToken"] == nil {
+			_, _ = fmt.Fprintln(w, `{"MetricDataResults": [{"Id": "characters", "Values": [1000, 200]}], "NextToken": "page-2"}`)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"MetricDataResults": [{"Id": "characters", "Values": [34]}]}`)
+	}))
+	defer ts.Close()
+
+	a, err := NewAWS(AWSConfig{
+		Regions:        []string{"eu-west-1"},
+		Credentials:    credentials.NewStaticCredentialsProvider("AKID", "secret", "session"),
+		CharacterLimit: 2000000,
+		Endpoint:       ts.URL,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	a.now = func() time.Time { return time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) }
+
+	usages, err := a.FetchUsage(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(usages) != 1 || usages[0] != (Usage{Account: "eu-west-1", CharacterCount: 1234, CharacterLimit: 2000000}) {
+		t.Errorf("unexpected usages: %+v", usages)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	start := float64(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC).Unix())
+	if requests[0]["StartTime"] != start || requests[1]["NextToken"] != "page-2" {
+		t.Errorf("unexpected requests: %v", requests)
+	}
+}
+
+func TestNewAWS_Invalid(t *testing.T) {
+	for _, cfg := range []AWSConfig{
+		{Credentials: credentials.NewStaticCredentialsProvider("AKID", "secret", "")},
+		{Regions: []string{"eu-west-1"}},
+	} {
+		if _, err := NewAWS(cfg); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	azureManagementURL = "https://management.azure.com"
+	azureLoginURL      = "https://login.microsoftonline.com"
+	azureIMDSURL       = "http://169.254.169.254"
+	azureMetricsAPI    = "2018-01-01"
+	// azureCharactersMetric is the Azure Monitor metric of the characters
+	// translated by a Translator resource.
+	azureCharactersMetric = "TextCharactersTranslated"
+)
+
+// AzureConfig configures the Azure Translator provider.
+type AzureConfig struct {
+	// Resources are the IDs of the Translator resources whose usage is
+	// exported, e.g. /subscriptions/<id>/resourceGroups/<group>/providers/
+	// Microsoft.CognitiveServices/accounts/<name>. Each is an account
+	// labeled with its name.
+	Resources []string
+	// TenantID, ClientID and ClientSecret are the credentials of a service
+	// principal allowed to read the metrics of the resources. Without them,
+	// the managed identity of the instance the exporter runs on is used.
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+	// CharacterLimit is the monthly character limit of every resource, 0
+	// for none, e.g. 2000000 for the free tier.
+	CharacterLimit int64
+	// ManagementURL overrides the Azure Resource Manager endpoint.
+	ManagementURL string
+	// HTTPClient sends the requests, a client with a 10s timeout if nil.
+	HTTPClient *http.Client
+}
+
+// Azure fetches the characters translated by Azure Translator resources in
+// the current month from Azure Monitor.
+type Azure struct {
+	cfg      AzureConfig
+	http     *http.Client
+	tokens   *tokenSource
+	loginURL string
+	imdsURL  string
+	now      func() time.Time
+}
+
+// NewAzure returns the Azure Translator provider configured by cfg.
+func NewAzure(cfg AzureConfig) (*Azure, error) {
+	if len(cfg.Resources) == 0 {
+		return nil, errors.New("azure: at least one resource is required")
+	}
+	for _, resource := range cfg.Resources {
+		if !strings.HasPrefix(resource, "/subscriptions/") {
+			return nil, fmt.Errorf("azure: invalid resource ID %q: must start with /subscriptions/", resource)
+		}
+	}
+	if (cfg.ClientID != "" || cfg.ClientSecret != "") && (cfg.TenantID == "" || cfg.ClientID == "" || cfg.ClientSecret == "") {
+		return nil, errors.New("azure: tenant ID, client ID and client secret are required together")
+	}
+	if cfg.ManagementURL == "" {
+		cfg.ManagementURL = azureManagementURL
+	}
+
+	a := &Azure{cfg: cfg, http: cfg.HTTPClient, loginURL: azureLoginURL, imdsURL: azureIMDSURL, now: time.Now}
+	if a.http == nil {
+		a.http = &http.Client{Timeout: defaultTimeout}
+	}
+	if cfg.ClientID != "" {
+		a.tokens = newTokenSource(a.clientCredentialsToken)
+	} else {
+		a.tokens = newTokenSource(a.managedIdentityToken)
+	}
+	return a, nil
+}
+
+func (a *Azure) Name() string {
+	return "azure_translator"
+}
+
+func (a *Azure) FetchUsage(ctx context.Context) ([]Usage, error) {
+	token, err := a.tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var usages []Usage
+	var errs []error
+	for _, resource := range a.cfg.Resources {
+		count, err := a.monthToDate(ctx, token, resource)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("resource %s: %w", path.Base(resource), err))
+			continue
+		}
+		usages = append(usages, Usage{Account: path.Base(resource), CharacterCount: count, CharacterLimit: a.cfg.CharacterLimit})
+	}
+	return usages, errors.Join(errs...)
+}
+
+// monthToDate sums the characters translated by resource since the start of
+// the current month in UTC.
+func (a *Azure) monthToDate(ctx context.Context, token, resource string) (int64, error) {
+	now := a.now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	query := url.Values{
+		"api-version": {azureMetricsAPI},
+		"metricnames": {azureCharactersMetric},
+		"timespan":    {start.Format(time.RFC3339) + "/" + now.Format(time.RFC3339)},
+		"interval":    {"P1D"},
+		"aggregation": {"Total"},
+	}
+	var resp struct {
+		Value []struct {
+			Timeseries []struct {
+				Data []struct {
+					Total float64 `json:"total"`
+				} `json:"data"`
+			} `json:"timeseries"`
+		} `json:"value"`
+	}
+	rawURL := a.cfg.ManagementURL + strings.TrimRight(resource, "/") + "/providers/Microsoft.Insights/metrics?" + query.Encode()
+	if err := getJSON(ctx, a.http, rawURL, http.Header{"Authorization": {"Bearer " + token}}, &resp); err != nil {
+		return 0, err
+	}
+
+	var total float64
+	for _, metric := range resp.Value {
+		for _, series := range metric.Timeseries {
+			for _, point := range series.Data {
+				total += point.Total
+			}
+		}
+	}
+	return int64(total), nil
+}
+
+// clientCredentialsToken requests a token for the service principal from
+// Microsoft Entra ID.
+func (a *Azure) clientCredentialsToken(ctx context.Context) (*tokenResponse, error) {
+	return postTokenForm(ctx, a.http, a.loginURL+"/"+url.PathEscape(a.cfg.TenantID)+"/oauth2/v2.0/token", url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {a.cfg.ClientID},
+		"client_secret": {a.cfg.ClientSecret},
+		"scope":         {azureManagementURL + "/.default"},
+	})
+}
+
+// managedIdentityToken requests a token for the managed identity of the
+// instance from the instance metadata service.
+func (a *Azure) managedIdentityToken(ctx context.Context) (*tokenResponse, error) {
+	query := url.Values{"api-version": {"2018-02-01"}, "resource": {azureManagementURL + "/"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.imdsURL+"/metadata/identity/oauth2/token?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+	return doTokenRequest(a.http, req)
+}
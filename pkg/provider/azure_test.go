@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAzureResource = "/subscriptions/sub-1/resourceGroups/translation/providers/Microsoft.CognitiveServices/accounts/translator-eu"
+
+func TestAzure_FetchUsage(t *testing.T) {
+	var query string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tenant-1/oauth2/v2.0/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_secret") != "secret" {
+			http.Error(w, "invalid client", http.StatusUnauthorized)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"access_token": "token-1", "expires_in": 3599}`)
+	})
+	mux.HandleFunc("GET "+testAzureResource+"/providers/Microsoft.Insights/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		query = r.URL.RawQuery
+		_, _ = fmt.Fprintln(w, `{"value": [{"name": {"value": "TextCharactersTranslated"}, "timeseries": [{"data": [{"total": 1000}, {"total": 234}, {}]}]}]}`)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	a, err := NewAzure(AzureConfig{
+		Resources:      []string{testAzureResource},
+		TenantID:       "tenant-1",
+		ClientID:       "client-1",
+		ClientSecret:   "secret",
+		CharacterLimit: 2000000,
+		ManagementURL:  ts.URL,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	a.loginURL = ts.URL
+	a.now = func() time.Time { return time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) }
+
+	usages, err := a.FetchUsage(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(usages) != 1 || usages[0] != (Usage{Account: "translator-eu", CharacterCount: 1234, CharacterLimit: 2000000}) {
+		t.Errorf("unexpected usages: %+v", usages)
+	}
+	for _, want := range []string{"metricnames=TextCharactersTranslated", "timespan=2026-10-01T00%3A00%3A00Z%2F2026-10-16T12%3A00%3A00Z", "aggregation=Total"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("expected %s in the query, got %s", want, query)
+		}
+	}
+}
+
+func TestAzure_ManagedIdentityToken(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metadata/identity/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("resource") != "https://management.azure.com/" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// The instance metadata service returns expires_in as a string.
+		_, _ = fmt.Fprintln(w, `{"access_token": "identity-token", "expires_in": "86399"}`)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	a, err := NewAzure(AzureConfig{Resources: []string{testAzureResource}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	a.imdsURL = ts.URL
+	if token, err := a.tokens.Token(context.Background()); err != nil || token != "identity-token" {
+		t.Errorf("Token() = %q, %v", token, err)
+	}
+	if remaining := time.Until(a.tokens.expiry); remaining < 23*time.Hour {
+		t.Errorf("expected the token to be cached for about a day, got %s", remaining)
+	}
+}
+
+func TestNewAzure_Invalid(t *testing.T) {
+	for _, cfg := range []AzureConfig{
+		{},
+		{Resources: []string{"translator-eu"}},
+		{Resources: []string{testAzureResource}, ClientID: "client-1"},
+	} {
+		if _, err := NewAzure(cfg); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+}
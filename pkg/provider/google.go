@@ -0,0 +1,234 @@
+package provider
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	googleMonitoringURL = "https://monitoring.googleapis.com"
+	googleMetadataURL   = "http://metadata.google.internal"
+	googleTokenURL      = "https://oauth2.googleapis.com/token"
+	googleScope         = "https://www.googleapis.com/auth/monitoring.read"
+
+	// googleUsageFilter selects the characters counted against the quota
+	// of the general translation model, reported per minute.
+	googleUsageFilter = `metric.type="serviceruntime.googleapis.com/quota/rate/net_usage"` +
+		` AND resource.type="consumer_quota"` +
+		` AND resource.labels.service="translate.googleapis.com"` +
+		` AND metric.labels.quota_metric="translate.googleapis.com/default"`
+)
+
+// GoogleConfig configures the Google Cloud Translation provider.
+type GoogleConfig struct {
+	// Projects are the IDs of the Google Cloud projects whose usage is
+	// exported, one account each.
+	Projects []string
+	// CredentialsFile is the JSON key of a service account allowed to read
+	// the monitoring data of the projects. Without it, tokens are requested
+	// from the metadata server of the instance the exporter runs on.
+	CredentialsFile string
+	// CharacterLimit is the monthly character budget of every project, 0
+	// for none. Google only enforces per minute and per day quotas.
+	CharacterLimit int64
+	// MonitoringURL overrides the Cloud Monitoring API endpoint.
+	MonitoringURL string
+	// HTTPClient sends the requests, a client with a 10s timeout if nil.
+	HTTPClient *http.Client
+}
+
+// Google fetches the characters translated with Cloud Translation in the
+// current month from the quota usage Cloud Monitoring records per project.
+type Google struct {
+	cfg         GoogleConfig
+	http        *http.Client
+	tokens      *tokenSource
+	metadataURL string
+	now         func() time.Time
+}
+
+// NewGoogle returns the Google Cloud Translation provider configured by cfg.
+func NewGoogle(cfg GoogleConfig) (*Google, error) {
+	if len(cfg.Projects) == 0 {
+		return nil, errors.New("google: at least one project is required")
+	}
+	if cfg.MonitoringURL == "" {
+		cfg.MonitoringURL = googleMonitoringURL
+	}
+	g := &Google{cfg: cfg, http: cfg.HTTPClient, metadataURL: googleMetadataURL, now: time.Now}
+	if g.http == nil {
+		g.http = &http.Client{Timeout: defaultTimeout}
+	}
+
+	if cfg.CredentialsFile == "" {
+		g.tokens = newTokenSource(g.metadataToken)
+		return g, nil
+	}
+	key, err := loadGoogleServiceAccount(cfg.CredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	g.tokens = newTokenSource(func(ctx context.Context) (*tokenResponse, error) {
+		return g.serviceAccountToken(ctx, key)
+	})
+	return g, nil
+}
+
+func (g *Google) Name() string {
+	return "google_translate"
+}
+
+func (g *Google) FetchUsage(ctx context.Context) ([]Usage, error) {
+	token, err := g.tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var usages []Usage
+	var errs []error
+	for _, project := range g.cfg.Projects {
+		count, err := g.monthToDate(ctx, token, project)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("project %s: %w", project, err))
+			continue
+		}
+		usages = append(usages, Usage{Account: project, CharacterCount: count, CharacterLimit: g.cfg.CharacterLimit})
+	}
+	return usages, errors.Join(errs...)
+}
+
+// monthToDate sums the characters translated in project since the start
+// of the current month in UTC.
+func (g *Google) monthToDate(ctx context.Context, token, project string) (int64, error) {
+	now := g.now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	// The alignment period must be at least a minute.
+	period := max(now.Sub(start), time.Minute)
+
+	query := url.Values{
+		"filter":                         {googleUsageFilter},
+		"interval.startTime":             {start.Format(time.RFC3339)},
+		"interval.endTime":               {now.Format(time.RFC3339)},
+		"aggregation.alignmentPeriod":    {strconv.Itoa(int(period.Seconds())) + "s"},
+		"aggregation.perSeriesAligner":   {"ALIGN_SUM"},
+		"aggregation.crossSeriesReducer": {"REDUCE_SUM"},
+	}
+	var resp struct {
+		TimeSeries []struct {
+			Points []struct {
+				Value struct {
+					Int64Value string `json:"int64Value"`
+				} `json:"value"`
+			} `json:"points"`
+		} `json:"timeSeries"`
+	}
+	rawURL := g.cfg.MonitoringURL + "/v3/projects/" + url.PathEscape(project) + "/timeSeries?" + query.Encode()
+	if err := getJSON(ctx, g.http, rawURL, http.Header{"Authorization": {"Bearer " + token}}, &resp); err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for _, series := range resp.TimeSeries {
+		for _, point := range series.Points {
+			value, err := strconv.ParseInt(point.Value.Int64Value, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid usage value %q: %w", point.Value.Int64Value, err)
+			}
+			count += value
+		}
+	}
+	return count, nil
+}
+
+// metadataToken requests a token for the service account of the instance
+// from the metadata server.
+func (g *Google) metadataToken(ctx context.Context) (*tokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		g.metadataURL+"/computeMetadata/v1/instance/service-accounts/default/token?scopes="+url.QueryEscape(googleScope), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	return doTokenRequest(g.http, req)
+}
+
+// googleServiceAccount is the part of a service account key needed to
+// request tokens.
+type googleServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	key *rsa.PrivateKey
+}
+
+func loadGoogleServiceAccount(path string) (*googleServiceAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("google: failed to read credentials: %w", err)
+	}
+	var account googleServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("google: invalid credentials file %s: %w", path, err)
+	}
+	if account.ClientEmail == "" {
+		return nil, fmt.Errorf("google: credentials file %s is not a service account key", path)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = googleTokenURL
+	}
+
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("google: credentials file %s has no PEM private key", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("google: invalid private key in %s: %w", path, err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("google: private key in %s is not an RSA key", path)
+	}
+	account.key = rsaKey
+	return &account, nil
+}
+
+// serviceAccountToken exchanges a JWT signed with the key of account for
+// an access token, as described in RFC 7523.
+func (g *Google) serviceAccountToken(ctx context.Context, account *googleServiceAccount) (*tokenResponse, error) {
+	now := g.now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   account.ClientEmail,
+		"scope": googleScope,
+		"aud":   account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, account.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return postTokenForm(ctx, g.http, account.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	})
+}
@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeGoogleCredentials(t *testing.T, tokenURL string) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "exporter@project-a.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    tokenURL,
+	})
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGoogle_FetchUsage(t *testing.T) {
+	var tokenRequests int
+	var query string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || strings.Count(r.FormValue("assertion"), ".") != 2 {
+			http.Error(w, "invalid grant", http.StatusBadRequest)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"access_token": "token-1", "expires_in": 3600}`)
+	})
+	mux.HandleFunc("GET /v3/projects/{project}/timeSeries", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.PathValue("project") == "project-b" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		query = r.URL.RawQuery
+		_, _ = fmt.Fprintln(w, `{"timeSeries": [{"points": [{"value": {"int64Value": "1200"}}, {"value": {"int64Value": "300"}}]}]}`)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	g, err := NewGoogle(GoogleConfig{
+		Projects:        []string{"project-a", "project-b"},
+		CredentialsFile: writeGoogleCredentials(t, ts.URL+"/token"),
+		CharacterLimit:  500000,
+		MonitoringURL:   ts.URL,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g.now = func() time.Time { return time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) }
+
+	for range 2 {
+		usages, err := g.FetchUsage(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "project project-b") {
+			t.Errorf("expected the error of project-b, got %v", err)
+		}
+		if len(usages) != 1 || usages[0] != (Usage{Account: "project-a", CharacterCount: 1500, CharacterLimit: 500000}) {
+			t.Errorf("unexpected usages: %+v", usages)
+		}
+	}
+	if tokenRequests != 1 {
+		t.Errorf("expected the token to be reused, got %d token requests", tokenRequests)
+	}
+	for _, want := range []string{"interval.startTime=2026-10-01T00%3A00%3A00Z", "aggregation.alignmentPeriod=1339200s", "translate.googleapis.com%2Fdefault"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("expected %s in the query, got %s", want, query)
+		}
+	}
+}
+
+func TestGoogle_MetadataToken(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /computeMetadata/v1/instance/service-accounts/default/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"access_token": "metadata-token", "expires_in": 3599}`)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	g, err := NewGoogle(GoogleConfig{Projects: []string{"project-a"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g.metadataURL = ts.URL
+	if token, err := g.tokens.Token(context.Background()); err != nil || token != "metadata-token" {
+		t.Errorf("Token() = %q, %v", token, err)
+	}
+}
+
+func TestNewGoogle_InvalidCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, []byte(`{"type": "authorized_user"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewGoogle(GoogleConfig{Projects: []string{"project-a"}, CredentialsFile: path}); err == nil {
+		t.Error("expected error for a key that is not a service account's")
+	}
+	if _, err := NewGoogle(GoogleConfig{}); err == nil {
+		t.Error("expected error without projects")
+	}
+}
@@ -0,0 +1,163 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LibreTranslateConfig configures the LibreTranslate provider.
+type LibreTranslateConfig struct {
+	// URLs are the base URLs of the LibreTranslate instances, e.g.
+	// http://libretranslate:5000. Each is an account labeled with its host.
+	URLs []string
+	// HTTPClient sends the requests, a client with a 10s timeout if nil.
+	HTTPClient *http.Client
+}
+
+// libreTranslateSettings is the part of the settings of an instance that is
+// exported.
+type libreTranslateSettings struct {
+	CharLimit   int  `json:"charLimit"`
+	KeyRequired bool `json:"keyRequired"`
+	languages   int
+}
+
+// LibreTranslate monitors self-hosted LibreTranslate instances. They do not
+// meter the characters they translate, so it reports no usage but exports
+// the limits the instances enforce, and whether they are reachable as
+// libretranslate_scrape_success.
+type LibreTranslate struct {
+	cfg  LibreTranslateConfig
+	http *http.Client
+
+	mu       sync.Mutex
+	settings map[string]libreTranslateSettings
+
+	requestCharacterLimit *prometheus.Desc
+	apiKeyRequired        *prometheus.Desc
+	languages             *prometheus.Desc
+}
+
+// NewLibreTranslate returns the LibreTranslate provider configured by cfg.
+func NewLibreTranslate(cfg LibreTranslateConfig) (*LibreTranslate, error) {
+	if len(cfg.URLs) == 0 {
+		return nil, errors.New("libretranslate: at least one URL is required")
+	}
+	urls := make([]string, 0, len(cfg.URLs))
+	for _, rawURL := range cfg.URLs {
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("libretranslate: invalid URL %q", rawURL)
+		}
+		urls = append(urls, strings.TrimRight(rawURL, "/"))
+	}
+	cfg.URLs = urls
+	l := &LibreTranslate{
+		cfg:      cfg,
+		http:     cfg.HTTPClient,
+		settings: map[string]libreTranslateSettings{},
+		requestCharacterLimit: prometheus.NewDesc(
+			"libretranslate_request_character_limit",
+			"Maximum number of characters of a single translation request",
+			[]string{"account"},
+			nil,
+		),
+		apiKeyRequired: prometheus.NewDesc(
+			"libretranslate_api_key_required",
+			"Whether the instance requires an API key",
+			[]string{"account"},
+			nil,
+		),
+		languages: prometheus.NewDesc(
+			"libretranslate_languages",
+			"Number of languages the instance can translate",
+			[]string{"account"},
+			nil,
+		),
+	}
+	if l.http == nil {
+		l.http = &http.Client{Timeout: defaultTimeout}
+	}
+	return l, nil
+}
+
+func (l *LibreTranslate) Name() string {
+	return "libretranslate"
+}
+
+// FetchUsage refreshes the settings of every instance. It never returns
+// usages.
+func (l *LibreTranslate) FetchUsage(ctx context.Context) ([]Usage, error) {
+	settings := map[string]libreTranslateSettings{}
+	var errs []error
+	for _, instance := range l.cfg.URLs {
+		account := libreTranslateAccount(instance)
+		s, err := l.fetchSettings(ctx, instance)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("instance %s: %w", account, err))
+			continue
+		}
+		settings[account] = s
+	}
+
+	l.mu.Lock()
+	l.settings = settings
+	l.mu.Unlock()
+	return nil, errors.Join(errs...)
+}
+
+func (l *LibreTranslate) fetchSettings(ctx context.Context, instance string) (libreTranslateSettings, error) {
+	var settings libreTranslateSettings
+	if err := getJSON(ctx, l.http, instance+"/frontend/settings", nil, &settings); err != nil {
+		return settings, err
+	}
+	var languages []struct {
+		Code string `json:"code"`
+	}
+	if err := getJSON(ctx, l.http, instance+"/languages", nil, &languages); err != nil {
+		return settings, err
+	}
+	settings.languages = len(languages)
+	return settings, nil
+}
+
+func (l *LibreTranslate) Describe(ch chan<- *prometheus.Desc) {
+	ch <- l.requestCharacterLimit
+	ch <- l.apiKeyRequired
+	ch <- l.languages
+}
+
+// Collect exports the settings of the last FetchUsage.
+func (l *LibreTranslate) Collect(ch chan<- prometheus.Metric) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for account, s := range l.settings {
+		// A negative limit means unlimited.
+		if s.CharLimit > 0 {
+			ch <- prometheus.MustNewConstMetric(l.requestCharacterLimit, prometheus.GaugeValue, float64(s.CharLimit), account)
+		}
+		keyRequired := 0.0
+		if s.KeyRequired {
+			keyRequired = 1
+		}
+		ch <- prometheus.MustNewConstMetric(l.apiKeyRequired, prometheus.GaugeValue, keyRequired, account)
+		ch <- prometheus.MustNewConstMetric(l.languages, prometheus.GaugeValue, float64(s.languages), account)
+	}
+}
+
+// libreTranslateAccount returns the account label of an instance, its host.
+func libreTranslateAccount(instance string) string {
+	u, err := url.Parse(instance)
+	if err != nil {
+		return instance
+	}
+	return u.Host
+}
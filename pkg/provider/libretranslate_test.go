@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestLibreTranslate(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /frontend/settings", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `{"charLimit": 5000, "keyRequired": true, "apiKeys": true, "suggestions": false}`)
+	})
+	mux.HandleFunc("GET /languages", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `[{"code": "en", "name": "English"}, {"code": "de", "name": "German"}]`)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	defer down.Close()
+
+	l, err := NewLibreTranslate(LibreTranslateConfig{URLs: []string{ts.URL + "/", down.URL}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	account := strings.TrimPrefix(ts.URL, "http://")
+	expected := fmt.Sprintf(`
+# HELP libretranslate_api_key_required Whether the instance requires an API key
+# TYPE libretranslate_api_key_required gauge
+libretranslate_api_key_required{account=%[1]q} 1
+# HELP libretranslate_languages Number of languages the instance can translate
+# TYPE libretranslate_languages gauge
+libretranslate_languages{account=%[1]q} 2
+# HELP libretranslate_request_character_limit Maximum number of characters of a single translation request
+# TYPE libretranslate_request_character_limit gauge
+libretranslate_request_character_limit{account=%[1]q} 5000
+# HELP libretranslate_scrape_success Whether the usage was fetched from the provider
+# TYPE libretranslate_scrape_success gauge
+libretranslate_scrape_success 0
+`, account)
+	if err := testutil.CollectAndCompare(NewCollector(l), strings.NewReader(expected),
+		"libretranslate_api_key_required", "libretranslate_languages", "libretranslate_request_character_limit",
+		"libretranslate_scrape_success", "libretranslate_character_count"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewLibreTranslate_Invalid(t *testing.T) {
+	for _, cfg := range []LibreTranslateConfig{
+		{},
+		{URLs: []string{"libretranslate:5000"}},
+	} {
+		if _, err := NewLibreTranslate(cfg); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpiryLeeway renews access tokens this long before they expire, so
+// a token does not expire while a request is in flight.
+const tokenExpiryLeeway = time.Minute
+
+// tokenSource caches the OAuth 2.0 access token returned by fetch until it
+// is about to expire.
+type tokenSource struct {
+	fetch func(ctx context.Context) (*tokenResponse, error)
+	now   func() time.Time
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// tokenResponse is the response of an OAuth 2.0 token endpoint.
+type tokenResponse struct {
+	AccessToken string          `json:"access_token"`
+	ExpiresIn   json.RawMessage `json:"expires_in"`
+}
+
+func newTokenSource(fetch func(ctx context.Context) (*tokenResponse, error)) *tokenSource {
+	return &tokenSource{fetch: fetch, now: time.Now}
+}
+
+// Token returns a valid access token, fetching a new one if necessary.
+func (s *tokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && s.now().Before(s.expiry) {
+		return s.token, nil
+	}
+	resp, err := s.fetch(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get an access token: %w", err)
+	}
+	if resp.AccessToken == "" {
+		return "", errors.New("failed to get an access token: no access_token in the response")
+	}
+	// Azure returns expires_in as a string, Google as a number.
+	expiresIn, err := strconv.Atoi(strings.Trim(string(resp.ExpiresIn), `"`))
+	if err != nil {
+		expiresIn = 0
+	}
+	s.token = resp.AccessToken
+	s.expiry = s.now().Add(time.Duration(expiresIn)*time.Second - tokenExpiryLeeway)
+	return s.token, nil
+}
+
+// postTokenForm posts form to the token endpoint at tokenURL.
+func postTokenForm(ctx context.Context, client *http.Client, tokenURL string, form url.Values) (*tokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doTokenRequest(client, req)
+}
+
+func doTokenRequest(client *http.Client, req *http.Request) (*tokenResponse, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s returned status %d: %s", req.URL.Redacted(), resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// getJSON sends an authenticated GET request to rawURL and decodes the JSON
+// response into v.
+func getJSON(ctx context.Context, client *http.Client, rawURL string, header http.Header, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned status %d: %s", req.URL.Path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
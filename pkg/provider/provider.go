@@ -0,0 +1,144 @@
+// Package provider defines the interface implemented by the machine
+// translation services the exporter monitors besides DeepL, and the
+// collector exporting their usage with the same metrics as DeepL's.
+package provider
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultTimeout bounds a single fetch of the usage of a provider.
+const defaultTimeout = 10 * time.Second
+
+// Usage is the character consumption of one account of a provider in its
+// current billing period.
+type Usage struct {
+	// Account identifies the account, project or resource within the
+	// provider, e.g. a Google Cloud project ID.
+	Account        string
+	CharacterCount int64
+	// CharacterLimit is 0 if the account has no limit or the provider
+	// does not report it.
+	CharacterLimit int64
+}
+
+// Provider fetches the usage of the accounts of a machine translation
+// service.
+type Provider interface {
+	// Name is the metric prefix of the provider, e.g. "deepl" or
+	// "google_translate". It must be a valid Prometheus metric name.
+	Name() string
+	// FetchUsage returns the usage of every account of the provider. If
+	// some accounts failed, it returns the usage of the others along with
+	// the error.
+	FetchUsage(ctx context.Context) ([]Usage, error)
+}
+
+// Collector exports the usage of a Provider on every scrape as
+// <name>_character_count, <name>_character_limit and
+// <name>_character_usage_percent, labeled by account, plus whether the
+// fetch of all accounts succeeded as <name>_scrape_success, and the usage
+// in the shared schema of QuotaMetrics. If the provider
+// is also a prometheus.Collector, its metrics are collected after every
+// fetch of the usage and exported alongside.
+type Collector struct {
+	provider Provider
+	timeout  time.Duration
+
+	characterCount    *prometheus.Desc
+	characterLimit    *prometheus.Desc
+	characterUsagePct *prometheus.Desc
+	scrapeSuccess     *prometheus.Desc
+	scrapeDuration    *prometheus.Desc
+	quota             *QuotaMetrics
+}
+
+// NewCollector returns a collector for p, to be registered with a
+// prometheus.Registerer.
+func NewCollector(p Provider) *Collector {
+	name := p.Name()
+	return &Collector{
+		provider: p,
+		timeout:  defaultTimeout,
+		characterCount: prometheus.NewDesc(
+			name+"_character_count",
+			"Current number of characters translated in the current billing period",
+			[]string{"account"},
+			nil,
+		),
+		characterLimit: prometheus.NewDesc(
+			name+"_character_limit",
+			"Maximum number of characters that can be translated in the current billing period",
+			[]string{"account"},
+			nil,
+		),
+		characterUsagePct: prometheus.NewDesc(
+			name+"_character_usage_percent",
+			"Percentage of character limit used",
+			[]string{"account"},
+			nil,
+		),
+		scrapeSuccess: prometheus.NewDesc(
+			name+"_scrape_success",
+			"Whether the usage was fetched from the provider",
+			nil,
+			nil,
+		),
+		scrapeDuration: prometheus.NewDesc(
+			name+"_scrape_duration_seconds",
+			"Duration of fetching the usage from the provider",
+			nil,
+			nil,
+		),
+		quota: NewQuotaMetrics(name),
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.characterCount
+	ch <- c.characterLimit
+	ch <- c.characterUsagePct
+	ch <- c.scrapeSuccess
+	ch <- c.scrapeDuration
+	c.quota.Describe(ch)
+	if extra, ok := c.provider.(prometheus.Collector); ok {
+		extra.Describe(ch)
+	}
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	begin := time.Now()
+	usages, err := c.provider.FetchUsage(ctx)
+	ch <- prometheus.MustNewConstMetric(c.scrapeDuration, prometheus.GaugeValue, time.Since(begin).Seconds())
+	success := 1.0
+	if err != nil {
+		log.Printf("Error fetching the usage of %s: %v", c.provider.Name(), err)
+		success = 0
+	}
+	ch <- prometheus.MustNewConstMetric(c.scrapeSuccess, prometheus.GaugeValue, success)
+	if extra, ok := c.provider.(prometheus.Collector); ok {
+		extra.Collect(ch)
+	}
+
+	for _, usage := range usages {
+		c.quota.Collect(ch, usage)
+		ch <- prometheus.MustNewConstMetric(c.characterCount, prometheus.GaugeValue, float64(usage.CharacterCount), usage.Account)
+		if usage.CharacterLimit <= 0 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.characterLimit, prometheus.GaugeValue, float64(usage.CharacterLimit), usage.Account)
+		ch <- prometheus.MustNewConstMetric(
+			c.characterUsagePct,
+			prometheus.GaugeValue,
+			float64(usage.CharacterCount)/float64(usage.CharacterLimit)*100,
+			usage.Account,
+		)
+	}
+}
@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeProvider struct {
+	name   string
+	usages []Usage
+	err    error
+}
+
+func (f *fakeProvider) Name() string {
+	if f.name == "" {
+		return "fake"
+	}
+	return f.name
+}
+
+func (f *fakeProvider) FetchUsage(context.Context) ([]Usage, error) {
+	return f.usages, f.err
+}
+
+func TestCollector(t *testing.T) {
+	p := &fakeProvider{usages: []Usage{
+		{Account: "project-a", CharacterCount: 250, CharacterLimit: 1000},
+		{Account: "project-b", CharacterCount: 42},
+	}}
+
+	expected := `
+# HELP fake_character_count Current number of characters translated in the current billing period
+# TYPE fake_character_count gauge
+fake_character_count{account="project-a"} 250
+fake_character_count{account="project-b"} 42
+# HELP fake_character_limit Maximum number of characters that can be translated in the current billing period
+# TYPE fake_character_limit gauge
+fake_character_limit{account="project-a"} 1000
+# HELP fake_character_usage_percent Percentage of character limit used
+# TYPE fake_character_usage_percent gauge
+fake_character_usage_percent{account="project-a"} 25
+# HELP fake_scrape_success Whether the usage was fetched from the provider
+# TYPE fake_scrape_success gauge
+fake_scrape_success 1
+`
+	if err := testutil.CollectAndCompare(NewCollector(p), strings.NewReader(expected),
+		"fake_character_count", "fake_character_limit", "fake_character_usage_percent", "fake_scrape_success"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollector_Error(t *testing.T) {
+	p := &fakeProvider{
+		usages: []Usage{{Account: "project-a", CharacterCount: 250}},
+		err:    errors.New("project-b: quota API unavailable"),
+	}
+
+	expected := `
+# HELP fake_character_count Current number of characters translated in the current billing period
+# TYPE fake_character_count gauge
+fake_character_count{account="project-a"} 250
+# HELP fake_scrape_success Whether the usage was fetched from the provider
+# TYPE fake_scrape_success gauge
+fake_scrape_success 0
+`
+	if err := testutil.CollectAndCompare(NewCollector(p), strings.NewReader(expected), "fake_scrape_success", "fake_character_count"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollector_Quota(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		NewCollector(&fakeProvider{name: "google_translate", usages: []Usage{{Account: "project-a", CharacterCount: 250, CharacterLimit: 1000}}}),
+		NewCollector(&fakeProvider{name: "amazon_translate", usages: []Usage{{Account: "eu-west-1", CharacterCount: 42}}}),
+	)
+
+	expected := `
+# HELP translation_quota_limit Characters that can be translated in the current billing period of the provider
+# TYPE translation_quota_limit gauge
+translation_quota_limit{account="project-a",provider="google_translate"} 1000
+# HELP translation_quota_used Characters translated in the current billing period of the provider
+# TYPE translation_quota_used gauge
+translation_quota_used{account="eu-west-1",provider="amazon_translate"} 42
+translation_quota_used{account="project-a",provider="google_translate"} 250
+# HELP translation_quota_used_ratio Ratio of the character limit of the provider used, between 0 and 1
+# TYPE translation_quota_used_ratio gauge
+translation_quota_used_ratio{account="project-a",provider="google_translate"} 0.25
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"translation_quota_used", "translation_quota_limit", "translation_quota_used_ratio"); err != nil {
+		t.Error(err)
+	}
+}
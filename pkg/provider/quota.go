@@ -0,0 +1,57 @@
+package provider
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// QuotaMetrics exports usages in the schema shared by all providers, so a
+// single dashboard can compare the capacity left at each of them:
+// translation_quota_used, translation_quota_limit and
+// translation_quota_used_ratio, labeled by provider and account.
+type QuotaMetrics struct {
+	used  *prometheus.Desc
+	limit *prometheus.Desc
+	ratio *prometheus.Desc
+}
+
+// NewQuotaMetrics returns the quota metrics of the provider with the given
+// name. The name is a constant label, so the metrics of several providers
+// can be registered with the same registry.
+func NewQuotaMetrics(name string) *QuotaMetrics {
+	labels := prometheus.Labels{"provider": name}
+	return &QuotaMetrics{
+		used: prometheus.NewDesc(
+			"translation_quota_used",
+			"Characters translated in the current billing period of the provider",
+			[]string{"account"},
+			labels,
+		),
+		limit: prometheus.NewDesc(
+			"translation_quota_limit",
+			"Characters that can be translated in the current billing period of the provider",
+			[]string{"account"},
+			labels,
+		),
+		ratio: prometheus.NewDesc(
+			"translation_quota_used_ratio",
+			"Ratio of the character limit of the provider used, between 0 and 1",
+			[]string{"account"},
+			labels,
+		),
+	}
+}
+
+func (q *QuotaMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- q.used
+	ch <- q.limit
+	ch <- q.ratio
+}
+
+// Collect sends the metrics of usage to ch. The limit and ratio are left
+// out if the account has no limit.
+func (q *QuotaMetrics) Collect(ch chan<- prometheus.Metric, usage Usage) {
+	ch <- prometheus.MustNewConstMetric(q.used, prometheus.GaugeValue, float64(usage.CharacterCount), usage.Account)
+	if usage.CharacterLimit <= 0 {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(q.limit, prometheus.GaugeValue, float64(usage.CharacterLimit), usage.Account)
+	ch <- prometheus.MustNewConstMetric(q.ratio, prometheus.GaugeValue, float64(usage.CharacterCount)/float64(usage.CharacterLimit), usage.Account)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+// PollerConfig controls when a Poller refreshes the accounts.
+type PollerConfig struct {
+	Interval time.Duration
+	// Jitter is the maximum random delay added to every interval, so a
+	// fleet of exporters restarted together does not poll in lockstep.
+	Jitter time.Duration
+	// Stagger spreads the first poll of the accounts evenly across the
+	// interval instead of polling all of them at once.
+	Stagger bool
+	// OnRefresh, if set, is called after every poll of an account with the
+	// errors of its modules, see DeepLCollector.Refresh.
+	OnRefresh func(client *deepl.Client, errs map[string]error)
+}
+
+// Poller refreshes the metrics of a DeepLCollector in the background, so
+// scrapes are served from memory and never trigger DeepL requests.
+type Poller struct {
+	collector *deepl.DeepLCollector
+	cfg       PollerConfig
+	randN     func(n int64) int64
+
+	// ctx is the context of Run, nil before, and cancels stops polling an
+	// account, see Add and Remove.
+	mu      sync.Mutex
+	ctx     context.Context
+	wg      sync.WaitGroup
+	cancels map[string]context.CancelFunc
+}
+
+// NewPoller switches collector to polling mode and returns the Poller
+// refreshing it.
+func NewPoller(collector *deepl.DeepLCollector, cfg PollerConfig) *Poller {
+	collector.EnablePolling()
+	return &Poller{
+		collector: collector,
+		cfg:       cfg,
+		randN:     rand.Int64N,
+		cancels:   make(map[string]context.CancelFunc),
+	}
+}
+
+// Run polls every account on its own schedule until ctx is canceled.
+func (p *Poller) Run(ctx context.Context) {
+	p.mu.Lock()
+	p.ctx = ctx
+	for i, client := range p.collector.Clients() {
+		p.start(client, p.offset(i))
+	}
+	p.mu.Unlock()
+
+	<-ctx.Done()
+	p.wg.Wait()
+}
+
+// Add starts polling an account added to the collector at runtime right
+// away. Accounts added before Run are polled by Run.
+func (p *Poller) Add(client *deepl.Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ctx == nil || p.ctx.Err() != nil {
+		return
+	}
+	p.start(client, 0)
+}
+
+// Remove stops polling an account removed from the collector at runtime.
+func (p *Poller) Remove(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cancel, ok := p.cancels[name]; ok {
+		cancel()
+		delete(p.cancels, name)
+	}
+}
+
+// start polls client in the background unless it already is. p.mu must be
+// held.
+func (p *Poller) start(client *deepl.Client, offset time.Duration) {
+	if _, ok := p.cancels[client.Name()]; ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	p.cancels[client.Name()] = cancel
+	p.wg.Go(func() {
+		p.runAccount(ctx, client, offset)
+	})
+}
+
+// runAccount polls one account, starting after offset plus a random delay
+// within the jitter.
+func (p *Poller) runAccount(ctx context.Context, client *deepl.Client, offset time.Duration) {
+	timer := time.NewTimer(offset + p.jitterDelay())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		p.poll(ctx, client)
+		timer.Reset(p.cfg.Interval + p.jitterDelay())
+	}
+}
+
+func (p *Poller) poll(ctx context.Context, client *deepl.Client) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+	errs := p.collector.Refresh(ctx, client)
+	if p.cfg.OnRefresh != nil {
+		p.cfg.OnRefresh(client, errs)
+	}
+}
+
+// offset returns the delay of the first poll of the i-th account, spreading
+// the accounts evenly across the interval when staggering is enabled.
+func (p *Poller) offset(i int) time.Duration {
+	if !p.cfg.Stagger {
+		return 0
+	}
+	return p.cfg.Interval * time.Duration(i) / time.Duration(len(p.collector.Clients()))
+}
+
+// jitterDelay returns a random delay in [0, jitter).
+func (p *Poller) jitterDelay() time.Duration {
+	if p.cfg.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(p.randN(int64(p.cfg.Jitter)))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPoller_jitterDelay(t *testing.T) {
+	p := NewPoller(&deepl.DeepLCollector{}, PollerConfig{Interval: time.Minute})
+	if d := p.jitterDelay(); d != 0 {
+		t.Errorf("expected no delay without jitter, got %s", d)
+	}
+
+	p = NewPoller(&deepl.DeepLCollector{}, PollerConfig{Interval: time.Minute, Jitter: 30 * time.Second})
+	p.randN = func(n int64) int64 {
+		if n != int64(30*time.Second) {
+			t.Errorf("expected jitter bound of 30s, got %s", time.Duration(n))
+		}
+		return int64(12 * time.Second)
+	}
+	if d := p.jitterDelay(); d != 12*time.Second {
+		t.Errorf("expected 12s delay, got %s", d)
+	}
+}
+
+func TestPoller_offset(t *testing.T) {
+	clients := make([]*deepl.Client, 4)
+	for i := range clients {
+		clients[i] = newTestClient(t, "")
+	}
+	collector, err := deepl.NewDeepLCollector(clients, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p := NewPoller(collector, PollerConfig{Interval: time.Minute})
+	for i := range clients {
+		if d := p.offset(i); d != 0 {
+			t.Errorf("expected no offset without staggering, got %s for account %d", d, i)
+		}
+	}
+
+	p = NewPoller(collector, PollerConfig{Interval: time.Minute, Stagger: true})
+	for i, expected := range []time.Duration{0, 15 * time.Second, 30 * time.Second, 45 * time.Second} {
+		if d := p.offset(i); d != expected {
+			t.Errorf("expected offset %s for account %d, got %s", expected, i, d)
+		}
+	}
+}
+
+func TestPoller_Run(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = fmt.Fprintln(w, `{"character_count": 1000, "character_limit": 500000}`)
+	}))
+	defer ts.Close()
+
+	c, err := deepl.NewDeepLCollector([]*deepl.Client{newTestClient(t, ts.URL)}, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewPoller(c, PollerConfig{Interval: time.Hour}).Run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for requests.Load() == 0 || testutil.CollectAndCount(c, "deepl_character_count") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the first poll")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	expected := `
+# HELP deepl_character_count Current number of characters translated in the current billing period
+# TYPE deepl_character_count gauge
+deepl_character_count{account="default"} 1000
+`
+	for range 3 {
+		if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "deepl_character_count"); err != nil {
+			t.Error(err)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("expected scrapes to be served from the cache, got %d requests", n)
+	}
+	if n := testutil.CollectAndCount(c, "deepl_last_refresh_timestamp_seconds"); n != 1 {
+		t.Errorf("expected 1 refresh timestamp, got %d", n)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var postgresHistorySchema = []string{
+	`CREATE TABLE IF NOT EXISTS deepl_usage_history (
+		time timestamptz NOT NULL,
+		account text NOT NULL,
+		character_count bigint NOT NULL,
+		character_limit bigint NOT NULL,
+		hourly boolean NOT NULL DEFAULT false
+	)`,
+	`CREATE INDEX IF NOT EXISTS deepl_usage_history_time ON deepl_usage_history (time, account)`,
+}
+
+// postgresHistory is a HistoryStore in the deepl_usage_history table of a
+// PostgreSQL database, which several replicas can share and other tools can
+// query directly.
+type postgresHistory struct {
+	pool *pgxpool.Pool
+}
+
+// openPostgresHistory connects to the database at rawURL, a connection string
+// as understood by libpq, and creates the table if needed.
+func openPostgresHistory(ctx context.Context, rawURL string) (*postgresHistory, error) {
+	config, err := pgxpool.ParseConfig(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PostgreSQL URL: %w", err)
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+	for _, statement := range postgresHistorySchema {
+		if _, err := pool.Exec(ctx, statement); err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("failed to create the history table: %w", err)
+		}
+	}
+	return &postgresHistory{pool: pool}, nil
+}
+
+func (h *postgresHistory) Append(ctx context.Context, samples []HistorySample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	var (
+		values []string
+		args   []any
+	)
+	for _, sample := range samples {
+		n := len(args)
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5))
+		args = append(args, sample.Time, sample.Account, sample.CharacterCount, sample.CharacterLimit, sample.Hourly)
+	}
+
+	_, err := h.pool.Exec(ctx, "INSERT INTO deepl_usage_history (time, account, character_count, character_limit, hourly) VALUES "+strings.Join(values, ", "), args...)
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+func (h *postgresHistory) Query(ctx context.Context, account string, from, to time.Time) ([]HistorySample, error) {
+	rows, err := h.pool.Query(ctx,
+		`SELECT time, account, character_count, character_limit, hourly FROM deepl_usage_history
+		WHERE ($1 = '' OR account = $1) AND time >= $2 AND time < $3 ORDER BY time`,
+		account, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer rows.Close()
+
+	var samples []HistorySample
+	for rows.Next() {
+		var sample HistorySample
+		if err := rows.Scan(&sample.Time, &sample.Account, &sample.CharacterCount, &sample.CharacterLimit, &sample.Hourly); err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		sample.Time = sample.Time.UTC()
+		samples = append(samples, sample)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return samples, nil
+}
+
+// Compact applies the retention like HistoryRetention.apply, every statement
+// atomically. Only the rollups of hours that still have raw samples are
+// rewritten.
+func (h *postgresHistory) Compact(ctx context.Context, retention HistoryRetention, now time.Time) error {
+	if retention.Rollup > 0 {
+		_, err := h.pool.Exec(ctx, "DELETE FROM deepl_usage_history WHERE time < $1", now.Add(-retention.Rollup))
+		if err != nil {
+			return fmt.Errorf("failed to compact history: %w", err)
+		}
+	}
+
+	_, err := h.pool.Exec(ctx,
+		`WITH old AS (
+			DELETE FROM deepl_usage_history WHERE time < $1 AND (NOT hourly OR time >= (
+				SELECT date_trunc('hour', min(time)) FROM deepl_usage_history WHERE NOT hourly
+			)) RETURNING *
+		)
+		INSERT INTO deepl_usage_history (time, account, character_count, character_limit, hourly)
+		SELECT DISTINCT ON (account, date_trunc('hour', time)) time, account, character_count, character_limit, true
+		FROM old ORDER BY account, date_trunc('hour', time), time DESC`,
+		now.Add(-retention.Raw),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+	return nil
+}
+
+func (h *postgresHistory) Close() error {
+	h.pool.Close()
+	return nil
+}
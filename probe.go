@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// probeTimeoutOffset is subtracted from the scrape timeout sent by
+// Prometheus, leaving time to send the response before it gives up.
+const probeTimeoutOffset = 500 * time.Millisecond
+
+// probeHandler serves /probe?target=<account>&module=<collector>[,...]. The
+// modules default to the enabled collectors and may include disabled ones,
+// so Prometheus can scrape each account and module with its own job,
+// interval and timeout. opts configures the exposition of the results.
+func probeHandler(collector *deepl.DeepLCollector, opts promhttp.HandlerOpts) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+			return
+		}
+		client := collector.Client(target)
+		if client == nil {
+			http.Error(w, fmt.Sprintf("unknown target %q", target), http.StatusBadRequest)
+			return
+		}
+
+		names, err := probeModules(r.URL.Query().Get("module"), collector.CollectorNames())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx := deepl.ContextWithTraceparent(r.Context(), r.Header.Get("traceparent"))
+		probe, err := collector.Probe(ctx, client, names, probeTimeout(r))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(probe)
+		promhttp.HandlerFor(registry, opts).ServeHTTP(w, r)
+	})
+}
+
+// probeModules parses the comma-separated module parameter, falling back to
+// the enabled collectors if it is empty.
+func probeModules(param string, enabled []string) ([]string, error) {
+	if param == "" {
+		return enabled, nil
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, name := range strings.Split(param, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := deepl.Collectors()[name]; !ok {
+			return nil, fmt.Errorf("unknown module %q", name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// probeTimeout returns the time a probe may take, derived from the scrape
+// timeout Prometheus sends along with the request.
+func probeTimeout(r *http.Request) time.Duration {
+	seconds, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64)
+	if err != nil || seconds <= 0 {
+		return defaultTimeout
+	}
+	timeout := time.Duration(seconds*float64(time.Second)) - probeTimeoutOffset
+	if timeout <= 0 {
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	return timeout
+}
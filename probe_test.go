@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func TestProbeHandler(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case deepl.UsagePath:
+			_, _ = fmt.Fprintln(w, `{"character_count": 1000, "character_limit": 500000}`)
+		case deepl.GlossariesPath:
+			_, _ = fmt.Fprintln(w, `{"glossaries": [{"glossary_id": "a"}]}`)
+		case deepl.GlossaryLanguagePairsPath:
+			_, _ = fmt.Fprintln(w, `{"supported_languages": [{"source_lang": "en", "target_lang": "de"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := deepl.NewDeepLCollector([]*deepl.Client{newTestClient(t, ts.URL)}, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := probeHandler(c, promhttp.HandlerOpts{})
+
+	tests := []struct {
+		name     string
+		query    string
+		status   int
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "Enabled modules by default",
+			query:    "target=default",
+			status:   http.StatusOK,
+			contains: []string{`deepl_character_count{account="default"} 1000`, "probe_success 1"},
+			excludes: []string{"deepl_glossary_count"},
+		},
+		{
+			name:     "Disabled module",
+			query:    "target=default&module=glossaries",
+			status:   http.StatusOK,
+			contains: []string{`deepl_glossary_count{account="default"} 1`, "probe_success 1"},
+			excludes: []string{"deepl_character_count"},
+		},
+		{
+			name:     "Failing module",
+			query:    "target=default&module=usage,languages",
+			status:   http.StatusOK,
+			contains: []string{`deepl_scrape_collector_success{account="default",collector="languages"} 0`, "probe_success 0"},
+		},
+		{name: "Missing target", query: "", status: http.StatusBadRequest},
+		{name: "Unknown target", query: "target=other", status: http.StatusBadRequest},
+		{name: "Unknown module", query: "target=default&module=unknown", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/probe?"+tt.query, nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+			body := rec.Body.String()
+			for _, s := range tt.contains {
+				if !strings.Contains(body, s) {
+					t.Errorf("expected %q in response:\n%s", s, body)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(body, s) {
+					t.Errorf("unexpected %q in response:\n%s", s, body)
+				}
+			}
+		})
+	}
+}
+
+func TestProbeTimeout(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected time.Duration
+	}{
+		{header: "", expected: defaultTimeout},
+		{header: "invalid", expected: defaultTimeout},
+		{header: "5", expected: 4500 * time.Millisecond},
+		{header: "0.2", expected: 200 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/probe", nil)
+		r.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", tt.header)
+		if got := probeTimeout(r); got != tt.expected {
+			t.Errorf("header %q: expected %s, got %s", tt.header, tt.expected, got)
+		}
+	}
+}
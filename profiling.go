@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"regexp"
+	"runtime/pprof"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pyroscopeLabelName matches the label names Pyroscope accepts in the name
+// of an application.
+var pyroscopeLabelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.]*$`)
+
+// ProfilePusher continuously collects CPU and heap profiles of the exporter
+// and pushes them to the ingest API of a Pyroscope server, to watch its
+// behavior over months without manual pprof sessions.
+type ProfilePusher struct {
+	ingestURL string
+	username  string
+	password  string
+	name      string
+	interval  time.Duration
+	http      *http.Client
+	// cpuProfile collects a CPU profile for the given duration.
+	cpuProfile func(ctx context.Context, d time.Duration) ([]byte, error)
+}
+
+// NewProfilePusher returns a pusher for the Pyroscope server at serverURL,
+// which may carry basic auth credentials, e.g. for Grafana Cloud. The
+// profiles are stored for appName with the given labels and cover interval
+// each.
+func NewProfilePusher(serverURL, appName, labels string, interval time.Duration) (*ProfilePusher, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid Pyroscope URL %q: must be an http or https URL", serverURL)
+	}
+	if interval < time.Second {
+		return nil, fmt.Errorf("profiling interval must be at least 1s, got %s", interval)
+	}
+	name, err := pyroscopeName(appName, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ProfilePusher{
+		name:       name,
+		interval:   interval,
+		http:       &http.Client{Timeout: defaultTimeout},
+		cpuProfile: collectCPUProfile,
+	}
+	if u.User != nil {
+		p.username = u.User.Username()
+		p.password, _ = u.User.Password()
+		u.User = nil
+	}
+	p.ingestURL = strings.TrimSuffix(u.String(), "/") + "/ingest"
+	return p, nil
+}
+
+// pyroscopeName returns the application name with its labels in the
+// app{key=value,...} syntax of Pyroscope. labels is a comma-separated list
+// of key=value pairs.
+func pyroscopeName(appName, labels string) (string, error) {
+	if appName == "" || strings.ContainsAny(appName, "{},=") {
+		return "", fmt.Errorf("invalid profiling application name %q", appName)
+	}
+	if labels == "" {
+		return appName, nil
+	}
+
+	var pairs []string
+	for _, pair := range strings.Split(labels, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || !pyroscopeLabelName.MatchString(key) || value == "" || strings.ContainsAny(value, "{},=") {
+			return "", fmt.Errorf("invalid profiling label %q: must be key=value", pair)
+		}
+		pairs = append(pairs, key+"="+value)
+	}
+	slices.Sort(pairs)
+	return appName + "{" + strings.Join(pairs, ",") + "}", nil
+}
+
+// Run pushes a CPU profile covering every interval, followed by a heap
+// profile, until ctx is canceled. Failed pushes are logged and skipped.
+func (p *ProfilePusher) Run(ctx context.Context) {
+	for {
+		from := time.Now()
+		cpu, err := p.cpuProfile(ctx, p.interval)
+		if ctx.Err() != nil {
+			return
+		}
+		until := time.Now()
+		if err != nil {
+			log.Printf("Skipping CPU profile: %v", err)
+		} else if err := p.push(ctx, from, until, cpu, true); err != nil {
+			log.Printf("Failed to push CPU profile: %v", err)
+		}
+
+		var heap bytes.Buffer
+		if err := pprof.Lookup("heap").WriteTo(&heap, 0); err != nil {
+			log.Printf("Skipping heap profile: %v", err)
+		} else if err := p.push(ctx, from, until, heap.Bytes(), false); err != nil {
+			log.Printf("Failed to push heap profile: %v", err)
+		}
+	}
+}
+
+// collectCPUProfile records a CPU profile for d. If CPU profiling is busy,
+// e.g. because of a request to /debug/pprof/profile, it still waits d so
+// the caller doesn't spin.
+func collectCPUProfile(ctx context.Context, d time.Duration) ([]byte, error) {
+	var buf bytes.Buffer
+	err := pprof.StartCPUProfile(&buf)
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	pprof.StopCPUProfile()
+	return buf.Bytes(), nil
+}
+
+// push uploads a pprof profile covering from to until. Pyroscope derives
+// the profile types from its sample types, cpu tells it the sample rate.
+func (p *ProfilePusher) push(ctx context.Context, from, until time.Time, profile []byte, cpu bool) error {
+	query := url.Values{
+		"name":    {p.name},
+		"from":    {strconv.FormatInt(from.Unix(), 10)},
+		"until":   {strconv.FormatInt(until.Unix(), 10)},
+		"format":  {"pprof"},
+		"spyName": {"gospy"},
+	}
+	if cpu {
+		query.Set("sampleRate", "100")
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("profile", "profile.pprof")
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(profile); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.ingestURL+"?"+query.Encode(), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("User-Agent", buildUserAgent(""))
+	if p.username != "" {
+		req.SetBasicAuth(p.username, p.password)
+	}
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s returned status %d", p.ingestURL, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jadolg/deepl-exporter/pkg/provider"
+)
+
+// providerConstructors create the providers of the translation services
+// monitored besides DeepL from their flags. Each returns nil if its
+// provider is not configured.
+var providerConstructors []func() (provider.Provider, error)
+
+// registerProvider adds the constructor of a provider, meant to be called
+// from init.
+func registerProvider(constructor func() (provider.Provider, error)) {
+	providerConstructors = append(providerConstructors, constructor)
+}
+
+func init() {
+	registerProvider(newGoogleProvider)
+	registerProvider(newAzureProvider)
+	registerProvider(newAWSProvider)
+	registerProvider(newLibreTranslateProvider)
+}
+
+// newGoogleProvider returns the Google Cloud Translation provider if
+// --google.project is set.
+func newGoogleProvider() (provider.Provider, error) {
+	if len(settings.GoogleProjects.values) == 0 {
+		return nil, nil
+	}
+	if settings.GoogleCharacterLimit < 0 {
+		return nil, fmt.Errorf("--google.character-limit must not be negative, got %d", settings.GoogleCharacterLimit)
+	}
+	return provider.NewGoogle(provider.GoogleConfig{
+		Projects:        settings.GoogleProjects.values,
+		CredentialsFile: settings.GoogleCredentialsFile,
+		CharacterLimit:  int64(settings.GoogleCharacterLimit),
+	})
+}
+
+// newAzureProvider returns the Azure Translator provider if
+// --azure.resource is set.
+func newAzureProvider() (provider.Provider, error) {
+	if len(settings.AzureResources.values) == 0 {
+		return nil, nil
+	}
+	if settings.AzureCharacterLimit < 0 {
+		return nil, fmt.Errorf("--azure.character-limit must not be negative, got %d", settings.AzureCharacterLimit)
+	}
+	secret := os.Getenv("AZURE_CLIENT_SECRET")
+	if settings.AzureClientSecretFile != "" {
+		var err error
+		if secret, err = readTokenFile(settings.AzureClientSecretFile); err != nil {
+			return nil, fmt.Errorf("azure client secret: %w", err)
+		}
+	}
+	return provider.NewAzure(provider.AzureConfig{
+		Resources:      settings.AzureResources.values,
+		TenantID:       settings.AzureTenantID,
+		ClientID:       settings.AzureClientID,
+		ClientSecret:   secret,
+		CharacterLimit: int64(settings.AzureCharacterLimit),
+	})
+}
+
+// newAWSProvider returns the Amazon Translate provider if --aws.region is
+// set.
+func newAWSProvider() (provider.Provider, error) {
+	if len(settings.AwsRegions.values) == 0 {
+		return nil, nil
+	}
+	if settings.AwsCharacterLimit < 0 {
+		return nil, fmt.Errorf("--aws.character-limit must not be negative, got %d", settings.AwsCharacterLimit)
+	}
+	credentials, err := awsCredentials()
+	if err != nil {
+		return nil, err
+	}
+	return provider.NewAWS(provider.AWSConfig{
+		Regions:        settings.AwsRegions.values,
+		Credentials:    credentials,
+		CharacterLimit: int64(settings.AwsCharacterLimit),
+		Endpoint:       settings.AwsEndpoint,
+	})
+}
+
+// newLibreTranslateProvider returns the LibreTranslate provider if
+// --libretranslate.url is set.
+func newLibreTranslateProvider() (provider.Provider, error) {
+	if len(settings.LibreTranslateURLs.values) == 0 {
+		return nil, nil
+	}
+	return provider.NewLibreTranslate(provider.LibreTranslateConfig{URLs: settings.LibreTranslateURLs.values})
+}
+
+// newProviders returns the configured providers.
+func newProviders() ([]provider.Provider, error) {
+	var providers []provider.Provider
+	for _, constructor := range providerConstructors {
+		p, err := constructor()
+		if err != nil {
+			return nil, fmt.Errorf("invalid provider configuration: %w", err)
+		}
+		if p != nil {
+			providers = append(providers, p)
+		}
+	}
+	return providers, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jadolg/deepl-exporter/pkg/provider"
+)
+
+type staticProvider struct{}
+
+func (staticProvider) Name() string { return "static" }
+
+func (staticProvider) FetchUsage(context.Context) ([]provider.Usage, error) {
+	return nil, nil
+}
+
+func TestNewProviders(t *testing.T) {
+	defer func(constructors []func() (provider.Provider, error)) {
+		providerConstructors = constructors
+	}(providerConstructors)
+
+	providerConstructors = nil
+	registerProvider(func() (provider.Provider, error) { return nil, nil })
+	registerProvider(func() (provider.Provider, error) { return staticProvider{}, nil })
+	providers, err := newProviders()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(providers) != 1 || providers[0].Name() != "static" {
+		t.Errorf("expected only the configured provider, got %v", providers)
+	}
+
+	registerProvider(func() (provider.Provider, error) { return nil, errors.New("missing credentials") })
+	if _, err := newProviders(); err == nil {
+		t.Error("expected the error of the misconfigured provider")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisCache is a SharedCache backed by Redis.
+type redisCache struct {
+	addr   string
+	client *redis.Client
+}
+
+// newRedisCache creates a redisCache from a redis:// or rediss:// URL of the
+// form redis://[user:password@]host[:port][/db].
+func newRedisCache(rawURL string) (*redisCache, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	opts.DialTimeout = defaultTimeout
+	return &redisCache{addr: opts.Addr, client: redis.NewClient(opts)}, nil
+}
+
+func (r *redisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (r *redisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *redisCache) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, value, ttl).Result()
+}
+
+// redisCompareAndDelete deletes KEYS[1] if it holds ARGV[1], atomically as
+// Redis runs scripts without interleaving other commands.
+var redisCompareAndDelete = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`)
+
+func (r *redisCache) CompareAndDelete(ctx context.Context, key string, value []byte) (bool, error) {
+	deleted, err := redisCompareAndDelete.Run(ctx, r.client, []string{key}, value).Int()
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"golang.org/x/sync/errgroup"
+)
+
+// RefreshResult is the outcome of a manual refresh of one account.
+type RefreshResult struct {
+	Account   string    `json:"account"`
+	Refreshed time.Time `json:"refreshed"`
+	// Collectors maps every enabled collector to "ok" or its error.
+	Collectors map[string]string `json:"collectors"`
+	// Usage is set if the usage collector is enabled and succeeded.
+	Usage *AccountUsage `json:"usage,omitempty"`
+}
+
+// RefreshResponse is the body of /-/refresh.
+type RefreshResponse struct {
+	Accounts []RefreshResult `json:"accounts"`
+}
+
+// refreshHandler fetches the metrics of all accounts, or of those given with
+// ?account=<name>, from DeepL right away, bypassing the poll interval and
+// the shared cache, e.g. after rotating a key. The new metrics are served
+// from then on and returned with the status of every collector. Like the
+// key forms, it may only be posted by pages of the exporter itself.
+func refreshHandler(collector *deepl.DeepLCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if crossOrigin(r) {
+			http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+			return
+		}
+
+		clients := collector.Clients()
+		if names := r.URL.Query()["account"]; len(names) > 0 {
+			clients = nil
+			for _, name := range names {
+				client := collector.Client(name)
+				if client == nil {
+					http.Error(w, "unknown account "+name, http.StatusBadRequest)
+					return
+				}
+				clients = append(clients, client)
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(deepl.WithoutSharedCache(r.Context()), defaultTimeout)
+		defer cancel()
+
+		resp := RefreshResponse{Accounts: make([]RefreshResult, len(clients))}
+		var g errgroup.Group
+		for i, client := range clients {
+			g.Go(func() error {
+				resp.Accounts[i] = refreshNow(ctx, collector, client)
+				return nil
+			})
+		}
+		_ = g.Wait()
+
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
+
+// refreshNow refreshes the metrics of one account and summarizes the result.
+func refreshNow(ctx context.Context, c *deepl.DeepLCollector, client *deepl.Client) RefreshResult {
+	errs := c.Refresh(ctx, client)
+	_, refreshed, _ := c.CachedMetrics(client.Name())
+
+	result := RefreshResult{
+		Account:    client.Name(),
+		Refreshed:  refreshed,
+		Collectors: make(map[string]string, len(errs)),
+	}
+	for name, err := range errs {
+		result.Collectors[name] = "ok"
+		if err != nil {
+			result.Collectors[name] = err.Error()
+		}
+	}
+	if errs["usage"] == nil {
+		if usage, ok := cachedAccountUsage(c, client.Name()); ok {
+			result.Usage = &usage
+		}
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+func TestRefreshHandler(t *testing.T) {
+	var count atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == deepl.GlossariesPath {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, http.StatusOK, deepl.DeepLUsage{CharacterCount: count.Add(1000), CharacterLimit: 10000})
+	}))
+	defer server.Close()
+
+	var clients []*deepl.Client
+	for _, name := range []string{"team-a", "team-b"} {
+		client, err := deepl.NewClient(deepl.ClientConfig{Name: name, APIKey: "key-" + name, ServerURL: server.URL})
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		clients = append(clients, client)
+	}
+	collector, err := deepl.NewDeepLCollector(clients, []string{"glossaries", "usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	collector.EnablePolling()
+	handler := refreshHandler(collector)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/refresh", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/-/refresh?account=team-b", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp RefreshResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(resp.Accounts) != 1 {
+		t.Fatalf("expected 1 account, got %d", len(resp.Accounts))
+	}
+	result := resp.Accounts[0]
+	if result.Account != "team-b" || result.Collectors["usage"] != "ok" || result.Collectors["glossaries"] == "ok" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if result.Usage == nil || result.Usage.CharacterCount != 1000 || result.Usage.CharacterLimit != 10000 || result.Usage.UsagePercent != 10 {
+		t.Errorf("unexpected usage: %+v", result.Usage)
+	}
+
+	_, _, refreshedA := collector.CachedMetrics("team-a")
+	_, _, refreshedB := collector.CachedMetrics("team-b")
+	if refreshedA || !refreshedB {
+		t.Errorf("expected only team-b to be refreshed")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/-/refresh?account=unknown", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown account, got %d", rec.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/-/refresh", nil)
+	r.Header.Set("Sec-Fetch-Site", "cross-site")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a cross-site request, got %d", rec.Code)
+	}
+}
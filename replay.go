@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// usageRecord is the usage of one account at one point in time.
+type usageRecord struct {
+	Time    time.Time
+	Account string
+	Usage   deepl.DeepLUsage
+}
+
+// usageHistory is a recorded usage history, sorted by time per account.
+type usageHistory struct {
+	accounts map[string][]usageRecord
+	start    time.Time
+	end      time.Time
+}
+
+// readUsageHistory parses a usage history in CSV with the header
+// timestamp,account,character_count,character_limit. Timestamps are RFC 3339
+// or Unix seconds.
+func readUsageHistory(r io.Reader) (*usageHistory, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	if strings.Join(header, ",") != "timestamp,account,character_count,character_limit" {
+		return nil, fmt.Errorf("unexpected header %q, want timestamp,account,character_count,character_limit", strings.Join(header, ","))
+	}
+
+	history := &usageHistory{accounts: make(map[string][]usageRecord)}
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		record, err := parseUsageRecord(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		history.accounts[record.Account] = append(history.accounts[record.Account], record)
+		if history.start.IsZero() || record.Time.Before(history.start) {
+			history.start = record.Time
+		}
+		if record.Time.After(history.end) {
+			history.end = record.Time
+		}
+	}
+	if len(history.accounts) == 0 {
+		return nil, errors.New("no usage records")
+	}
+
+	for _, records := range history.accounts {
+		sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	}
+	return history, nil
+}
+
+func parseUsageRecord(fields []string) (usageRecord, error) {
+	timestamp, err := parseTimestamp(fields[0])
+	if err != nil {
+		return usageRecord{}, err
+	}
+	if fields[1] == "" {
+		return usageRecord{}, errors.New("account is required")
+	}
+	count, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return usageRecord{}, fmt.Errorf("invalid character_count %q", fields[2])
+	}
+	limit, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return usageRecord{}, fmt.Errorf("invalid character_limit %q", fields[3])
+	}
+	return usageRecord{
+		Time:    timestamp,
+		Account: fields[1],
+		Usage:   deepl.DeepLUsage{CharacterCount: count, CharacterLimit: limit},
+	}, nil
+}
+
+// parseTimestamp parses an RFC 3339 timestamp or Unix seconds.
+func parseTimestamp(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Unix(0, int64(seconds*1e9)).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q: must be RFC 3339 or Unix seconds", value)
+	}
+	return t, nil
+}
+
+// names returns the sorted names of the recorded accounts.
+func (h *usageHistory) names() []string {
+	names := make([]string, 0, len(h.accounts))
+	for name := range h.accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// at returns the last usage of account recorded at or before t.
+func (h *usageHistory) at(account string, t time.Time) (*deepl.DeepLUsage, error) {
+	records := h.accounts[account]
+	i := sort.Search(len(records), func(i int) bool { return records[i].Time.After(t) })
+	if i == 0 {
+		return nil, fmt.Errorf("no usage recorded for %s before %s", account, t.Format(time.RFC3339))
+	}
+	usage := records[i-1].Usage
+	return &usage, nil
+}
+
+// replayClock maps the wall clock to the recorded time, starting at the
+// beginning of the history and running speed times faster. It stops at the
+// end of the history.
+type replayClock struct {
+	history *usageHistory
+	started time.Time
+	speed   float64
+	now     func() time.Time
+}
+
+func (c *replayClock) Now() time.Time {
+	elapsed := time.Duration(float64(c.now().Sub(c.started)) * c.speed)
+	if t := c.history.start.Add(elapsed); t.Before(c.history.end) {
+		return t
+	}
+	return c.history.end
+}
+
+// newReplayTimestamp returns the gauge exporting the replayed point in time.
+func newReplayTimestamp(clock *replayClock) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "deepl_exporter_replay_timestamp_seconds",
+			Help: "Unix timestamp of the point in the recorded usage history currently replayed",
+		},
+		func() float64 {
+			return float64(clock.Now().UnixNano()) / 1e9
+		},
+	)
+}
+
+// startReplay serves the usage history in path through a fake DeepL API on a
+// loopback port, speed times faster than it was recorded. It returns the
+// configuration of the recorded accounts, the URL to reach them and the
+// clock of the replay.
+func startReplay(path string, speed float64) (*Config, string, *replayClock, error) {
+	if speed <= 0 {
+		return nil, "", nil, fmt.Errorf("invalid replay speed %g: must be positive", speed)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to open usage history: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	history, err := readUsageHistory(file)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to read usage history %s: %w", path, err)
+	}
+
+	clock := &replayClock{history: history, started: time.Now(), speed: speed, now: time.Now}
+	cfg := &Config{}
+	keys := make(map[string]string, len(history.accounts))
+	for _, name := range history.names() {
+		key := "replay-" + name
+		keys[key] = name
+		cfg.Accounts = append(cfg.Accounts, AccountConfig{Name: name, APIKey: key})
+	}
+
+	url, err := serveFakeAPI(fakeAPIHandler(keys, func(account string) (*deepl.DeepLUsage, error) {
+		return history.at(account, clock.Now())
+	}))
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return cfg, url, clock, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testHistory = `timestamp,account,character_count,character_limit
+2026-03-01T00:00:00Z,team-a,0,500000
+2026-03-01T01:00:00Z,team-a,1000,500000
+1772330400,team-b,5000,1000000
+2026-03-01T02:00:00Z,team-a,480000,500000
+`
+
+func TestReadUsageHistory(t *testing.T) {
+	history, err := readUsageHistory(strings.NewReader(testHistory))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := strings.Join(history.names(), ","); got != "team-a,team-b" {
+		t.Errorf("expected accounts team-a,team-b, got %s", got)
+	}
+	if !history.start.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) || !history.end.Equal(time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time range %s - %s", history.start, history.end)
+	}
+
+	usage, err := history.at("team-a", time.Date(2026, 3, 1, 1, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usage.CharacterCount != 1000 {
+		t.Errorf("expected the last usage before the given time, got %d", usage.CharacterCount)
+	}
+
+	if _, err := history.at("team-b", time.Date(2026, 3, 1, 0, 30, 0, 0, time.UTC)); err == nil {
+		t.Error("expected an error before the first record of an account")
+	}
+}
+
+func TestReadUsageHistoryInvalid(t *testing.T) {
+	tests := map[string]string{
+		"header":    "time,account,count,limit\n",
+		"empty":     "timestamp,account,character_count,character_limit\n",
+		"timestamp": "timestamp,account,character_count,character_limit\nyesterday,team-a,1,2\n",
+		"count":     "timestamp,account,character_count,character_limit\n1772330400,team-a,many,2\n",
+		"fields":    "timestamp,account,character_count,character_limit\n1772330400,team-a,1\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := readUsageHistory(strings.NewReader(content)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestReplayClock(t *testing.T) {
+	history, err := readUsageHistory(strings.NewReader(testHistory))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	started := time.Now()
+	now := started
+	clock := &replayClock{history: history, started: started, speed: 60, now: func() time.Time { return now }}
+
+	if !clock.Now().Equal(history.start) {
+		t.Errorf("expected the replay to start at %s, got %s", history.start, clock.Now())
+	}
+	now = started.Add(time.Minute)
+	if want := history.start.Add(time.Hour); !clock.Now().Equal(want) {
+		t.Errorf("expected %s after a minute at 60x, got %s", want, clock.Now())
+	}
+	now = started.Add(time.Hour)
+	if !clock.Now().Equal(history.end) {
+		t.Errorf("expected the replay to stop at %s, got %s", history.end, clock.Now())
+	}
+}
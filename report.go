@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportPeriods are the periods usage reports can cover.
+var reportPeriods = []string{"daily", "weekly", "monthly"}
+
+// UsageReport is the consumption of every account during a period, compared
+// to the period before.
+type UsageReport struct {
+	Period    string
+	From      time.Time
+	To        time.Time
+	Generated time.Time
+	Accounts  []ReportAccount
+}
+
+// ReportAccount is the consumption of one account in a UsageReport.
+type ReportAccount struct {
+	Account string
+	// Characters were translated during the period, PreviousCharacters
+	// during the period before.
+	Characters         int64
+	PreviousCharacters int64
+	// CharacterCount and CharacterLimit are the usage at the end of the
+	// period.
+	CharacterCount int64
+	CharacterLimit int64
+}
+
+// UsagePercent is the usage of the character limit at the end of the period.
+func (a ReportAccount) UsagePercent() float64 {
+	if a.CharacterLimit <= 0 {
+		return 0
+	}
+	return float64(a.CharacterCount) / float64(a.CharacterLimit) * 100
+}
+
+// Change is the relative change of the consumption compared to the period
+// before in percent, or nil if nothing was consumed then.
+func (a ReportAccount) Change() *float64 {
+	if a.PreviousCharacters == 0 {
+		return nil
+	}
+	change := float64(a.Characters-a.PreviousCharacters) / float64(a.PreviousCharacters) * 100
+	return &change
+}
+
+// lastReportPeriod returns the start of the last complete period before now and
+// of the period after it, in UTC. Weeks start on Monday.
+func lastReportPeriod(period string, now time.Time) (time.Time, time.Time, error) {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case "daily":
+		return today.AddDate(0, 0, -1), today, nil
+	case "weekly":
+		monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+		return monday.AddDate(0, 0, -7), monday, nil
+	case "monthly":
+		month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return month.AddDate(0, -1, 0), month, nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid report period %q: must be daily, weekly or monthly", period)
+	}
+}
+
+// previousPeriod returns the start of the period before the one starting at
+// from.
+func previousPeriod(period string, from time.Time) time.Time {
+	switch period {
+	case "daily":
+		return from.AddDate(0, 0, -1)
+	case "weekly":
+		return from.AddDate(0, 0, -7)
+	default:
+		return from.AddDate(0, -1, 0)
+	}
+}
+
+// consumption returns the characters translated between the samples, which
+// are sorted by time and follow baseline if not nil. A decreasing count
+// means the billing period was reset in between.
+func consumption(baseline *HistorySample, samples []HistorySample) int64 {
+	var total int64
+	for _, sample := range samples {
+		switch {
+		case baseline == nil:
+		case sample.CharacterCount >= baseline.CharacterCount:
+			total += sample.CharacterCount - baseline.CharacterCount
+		default:
+			total += sample.CharacterCount
+		}
+		baseline = &sample
+	}
+	return total
+}
+
+// newUsageReport builds the report of the last complete period before now
+// from the history.
+func newUsageReport(ctx context.Context, store HistoryStore, period string, now time.Time) (*UsageReport, error) {
+	from, to, err := lastReportPeriod(period, now)
+	if err != nil {
+		return nil, err
+	}
+	previous := previousPeriod(period, from)
+
+	// The period before the previous one provides the baseline of the
+	// previous one.
+	samples, err := store.Query(ctx, "", previousPeriod(period, previous), to)
+	if err != nil {
+		return nil, err
+	}
+
+	byAccount := make(map[string][]HistorySample)
+	for _, sample := range samples {
+		byAccount[sample.Account] = append(byAccount[sample.Account], sample)
+	}
+
+	report := &UsageReport{Period: period, From: from, To: to, Generated: now.UTC()}
+	for account, samples := range byAccount {
+		var (
+			last, current    []HistorySample
+			previousBaseline *HistorySample
+		)
+		for _, sample := range samples {
+			switch {
+			case sample.Time.Before(previous):
+				previousBaseline = &sample
+			case sample.Time.Before(from):
+				last = append(last, sample)
+			default:
+				current = append(current, sample)
+			}
+		}
+		if len(current) == 0 {
+			continue
+		}
+
+		baseline := previousBaseline
+		if len(last) > 0 {
+			baseline = &last[len(last)-1]
+		}
+		end := current[len(current)-1]
+		report.Accounts = append(report.Accounts, ReportAccount{
+			Account:            account,
+			Characters:         consumption(baseline, current),
+			PreviousCharacters: consumption(previousBaseline, last),
+			CharacterCount:     end.CharacterCount,
+			CharacterLimit:     end.CharacterLimit,
+		})
+	}
+	slices.SortFunc(report.Accounts, func(a, b ReportAccount) int {
+		return strings.Compare(a.Account, b.Account)
+	})
+	return report, nil
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>DeepL usage report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.4em 0.8em; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+</style>
+</head>
+<body>
+<h1>DeepL usage report</h1>
+<p>{{.Period}} report from {{.From.Format "2006-01-02"}} to {{.To.Format "2006-01-02"}} (exclusive), generated {{.Generated.Format "2006-01-02 15:04 MST"}}.</p>
+{{- if .Accounts}}
+<table>
+<tr><th>Account</th><th>Characters</th><th>Previous period</th><th>Change</th><th>Used at the end</th><th>Limit</th><th>Usage</th></tr>
+{{- range .Accounts}}
+<tr><td>{{.Account}}</td><td>{{.Characters}}</td><td>{{.PreviousCharacters}}</td><td>{{with .Change}}{{printf "%+.1f%%" .}}{{else}}-{{end}}</td><td>{{.CharacterCount}}</td><td>{{.CharacterLimit}}</td><td>{{printf "%.1f%%" .UsagePercent}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No usage was recorded during the period.</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// writeCSV writes the report as CSV.
+func (r *UsageReport) writeCSV(w *csv.Writer) error {
+	_ = w.Write([]string{"from", "to", "account", "characters", "previous_characters", "character_count", "character_limit", "usage_percent"})
+	for _, account := range r.Accounts {
+		_ = w.Write([]string{
+			r.From.Format(time.RFC3339),
+			r.To.Format(time.RFC3339),
+			account.Account,
+			strconv.FormatInt(account.Characters, 10),
+			strconv.FormatInt(account.PreviousCharacters, 10),
+			strconv.FormatInt(account.CharacterCount, 10),
+			strconv.FormatInt(account.CharacterLimit, 10),
+			strconv.FormatFloat(account.UsagePercent(), 'f', 2, 64),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// reportHandler serves the report of the last complete period from the
+// history as HTML, or as CSV with ?format=csv. ?period= overrides the
+// default period.
+func reportHandler(store HistoryStore, defaultPeriod string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		period := defaultPeriod
+		if p := query.Get("period"); p != "" {
+			period = p
+		}
+		if !slices.Contains(reportPeriods, period) {
+			http.Error(w, "period must be daily, weekly or monthly", http.StatusBadRequest)
+			return
+		}
+		format := query.Get("format")
+		if format != "" && format != "html" && format != "csv" {
+			http.Error(w, "format must be html or csv", http.StatusBadRequest)
+			return
+		}
+
+		report, err := newUsageReport(r.Context(), store, period, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if format == "csv" {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="deepl-usage-%s-%s.csv"`, period, report.From.Format("2006-01-02")))
+			_ = report.writeCSV(csv.NewWriter(w))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = reportTemplate.Execute(w, report)
+	})
+}
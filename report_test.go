@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLastReportPeriod(t *testing.T) {
+	// A Friday.
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	for period, want := range map[string][2]string{
+		"daily":   {"2026-10-15", "2026-10-16"},
+		"weekly":  {"2026-10-05", "2026-10-12"},
+		"monthly": {"2026-09-01", "2026-10-01"},
+	} {
+		from, to, err := lastReportPeriod(period, now)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", period, err)
+		}
+		if got := [2]string{from.Format(time.DateOnly), to.Format(time.DateOnly)}; got != want {
+			t.Errorf("%s: expected %v, got %v", period, want, got)
+		}
+	}
+	if _, _, err := lastReportPeriod("yearly", now); err == nil {
+		t.Error("expected error for an unknown period")
+	}
+}
+
+func TestConsumption(t *testing.T) {
+	samples := []HistorySample{{CharacterCount: 150}, {CharacterCount: 300}, {CharacterCount: 20}, {CharacterCount: 50}}
+	if got := consumption(&HistorySample{CharacterCount: 100}, samples); got != 250 {
+		t.Errorf("expected 250 characters across the reset, got %d", got)
+	}
+	if got := consumption(nil, samples); got != 200 {
+		t.Errorf("expected 200 characters without baseline, got %d", got)
+	}
+}
+
+func newTestReportHistory(t *testing.T) HistoryStore {
+	t.Helper()
+	store, err := openFileHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	day := func(d int, count int64) HistorySample {
+		return HistorySample{Time: time.Date(2026, 10, d, 12, 0, 0, 0, time.UTC), Account: "team-a", CharacterCount: count, CharacterLimit: 1000}
+	}
+	err = store.Append(context.Background(), []HistorySample{
+		day(13, 100),
+		day(14, 200),
+		day(15, 500),
+		day(16, 550),
+		{Time: time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC), Account: "team-b", CharacterCount: 10, CharacterLimit: 100},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return store
+}
+
+func TestNewUsageReport(t *testing.T) {
+	store := newTestReportHistory(t)
+	now := time.Date(2026, 10, 16, 13, 0, 0, 0, time.UTC)
+
+	report, err := newUsageReport(context.Background(), store, "daily", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Accounts) != 2 {
+		t.Fatalf("expected 2 accounts, got %+v", report.Accounts)
+	}
+	a := report.Accounts[0]
+	if a.Account != "team-a" || a.Characters != 300 || a.PreviousCharacters != 100 || a.CharacterCount != 500 || a.UsagePercent() != 50 {
+		t.Errorf("unexpected report of team-a: %+v", a)
+	}
+	if change := a.Change(); change == nil || *change != 200 {
+		t.Errorf("expected +200%% change, got %v", change)
+	}
+	if b := report.Accounts[1]; b.Account != "team-b" || b.Characters != 0 || b.Change() != nil {
+		t.Errorf("unexpected report of team-b: %+v", b)
+	}
+}
+
+func TestReportHandler(t *testing.T) {
+	handler := reportHandler(newTestReportHistory(t), "weekly")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/reports/latest", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("expected HTML report, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), "weekly report") {
+		t.Errorf("unexpected report:\n%s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/reports/latest?period=monthly&format=csv", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("expected CSV report, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.HasPrefix(rec.Body.String(), "from,to,account,characters,previous_characters,character_count,character_limit,usage_percent\n") {
+		t.Errorf("unexpected report:\n%s", rec.Body.String())
+	}
+
+	for _, query := range []string{"?period=yearly", "?format=pdf"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/reports/latest"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
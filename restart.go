@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	// handoverEnv tells a process started by handOver how many listening
+	// sockets it inherited.
+	handoverEnv = "DEEPL_EXPORTER_LISTEN_FDS"
+	// handoverPprofEnv tells a process started by handOver which inherited
+	// file descriptor is the listener of --web.pprof-address.
+	handoverPprofEnv = "DEEPL_EXPORTER_PPROF_FD"
+	// listenFDsStart is the first inherited file descriptor, following
+	// stdin, stdout and stderr as with systemd socket activation.
+	listenFDsStart = 3
+)
+
+// inheritedListeners returns the listening sockets passed by a previous
+// exporter process on restart, or by systemd socket activation, or nil if
+// there are none.
+func inheritedListeners() ([]net.Listener, error) {
+	n, err := inheritedFDCount()
+	if err != nil || n == 0 {
+		return nil, err
+	}
+
+	listeners := make([]net.Listener, 0, n)
+	for i := range n {
+		f := os.NewFile(uintptr(listenFDsStart+i), "listener-"+strconv.Itoa(i))
+		ln, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+			return nil, fmt.Errorf("inherited file descriptor %d: %w", listenFDsStart+i, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// inheritedFDCount returns the number of inherited listening sockets and
+// removes the variables announcing them, so they don't leak into child
+// processes.
+func inheritedFDCount() (int, error) {
+	if v, ok := os.LookupEnv(handoverEnv); ok {
+		_ = os.Unsetenv(handoverEnv)
+		return parseFDCount(handoverEnv, v)
+	}
+
+	// systemd sets LISTEN_PID so the variables only apply to the process
+	// it started, not to its children.
+	v, ok := os.LookupEnv("LISTEN_FDS")
+	if !ok || os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+	for _, key := range []string{"LISTEN_FDS", "LISTEN_PID", "LISTEN_FDNAMES"} {
+		_ = os.Unsetenv(key)
+	}
+	return parseFDCount("LISTEN_FDS", v)
+}
+
+// inheritedPprofListener returns the listener of the separate pprof address
+// passed by a previous exporter process on restart, or nil if there is none.
+func inheritedPprofListener() (net.Listener, error) {
+	v, ok := os.LookupEnv(handoverPprofEnv)
+	if !ok {
+		return nil, nil
+	}
+	_ = os.Unsetenv(handoverPprofEnv)
+	fd, err := strconv.Atoi(v)
+	if err != nil || fd < listenFDsStart {
+		return nil, fmt.Errorf("invalid %s %q", handoverPprofEnv, v)
+	}
+	f := os.NewFile(uintptr(fd), "pprof-listener")
+	defer func() { _ = f.Close() }()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("inherited file descriptor %d: %w", fd, err)
+	}
+	return ln, nil
+}
+
+func parseFDCount(key, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return n, nil
+}
+
+// handOver starts a new exporter process with the arguments of this one
+// and passes it the listening sockets, so it accepts the connections this
+// process stops accepting on shutdown without ever closing them. pprof is
+// the listener of the separate pprof address, if any.
+func handOver(listeners []net.Listener, pprof net.Listener) (*os.Process, error) {
+	files := make([]*os.File, 0, len(listeners)+1)
+	defer func() {
+		// The child has its own copies of the descriptors.
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	handed := listeners
+	if pprof != nil {
+		handed = append(handed[:len(handed):len(handed)], pprof)
+	}
+	for _, ln := range handed {
+		fileListener, ok := ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			return nil, fmt.Errorf("cannot hand over listener on %s", ln.Addr())
+		}
+		f, err := fileListener.File()
+		if err != nil {
+			return nil, fmt.Errorf("cannot hand over listener on %s: %w", ln.Addr(), err)
+		}
+		files = append(files, f)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(handoverEnviron(os.Environ()), handoverEnv+"="+strconv.Itoa(len(listeners)))
+	if pprof != nil {
+		cmd.Env = append(cmd.Env, handoverPprofEnv+"="+strconv.Itoa(listenFDsStart+len(listeners)))
+	}
+	cmd.ExtraFiles = files
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start the new process: %w", err)
+	}
+	return cmd.Process, nil
+}
+
+// handoverEnviron returns environ without the variables announcing
+// inherited sockets.
+func handoverEnviron(environ []string) []string {
+	filtered := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		key, _, _ := strings.Cut(kv, "=")
+		switch key {
+		case handoverEnv, handoverPprofEnv, "LISTEN_FDS", "LISTEN_PID", "LISTEN_FDNAMES":
+			continue
+		}
+		filtered = append(filtered, kv)
+	}
+	return filtered
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestInheritedFDCount(t *testing.T) {
+	t.Run("handover", func(t *testing.T) {
+		t.Setenv(handoverEnv, "2")
+		n, err := inheritedFDCount()
+		if err != nil || n != 2 {
+			t.Fatalf("inheritedFDCount() = %d, %v, want 2", n, err)
+		}
+		if _, ok := os.LookupEnv(handoverEnv); ok {
+			t.Errorf("%s was not unset", handoverEnv)
+		}
+	})
+
+	t.Run("systemd", func(t *testing.T) {
+		t.Setenv("LISTEN_FDS", "1")
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		t.Setenv("LISTEN_FDNAMES", "http")
+		n, err := inheritedFDCount()
+		if err != nil || n != 1 {
+			t.Fatalf("inheritedFDCount() = %d, %v, want 1", n, err)
+		}
+		for _, key := range []string{"LISTEN_FDS", "LISTEN_PID", "LISTEN_FDNAMES"} {
+			if _, ok := os.LookupEnv(key); ok {
+				t.Errorf("%s was not unset", key)
+			}
+		}
+	})
+
+	t.Run("systemd other process", func(t *testing.T) {
+		t.Setenv("LISTEN_FDS", "1")
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+		if n, err := inheritedFDCount(); err != nil || n != 0 {
+			t.Fatalf("inheritedFDCount() = %d, %v, want 0", n, err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv(handoverEnv, "two")
+		if _, err := inheritedFDCount(); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestHandoverEnviron(t *testing.T) {
+	got := handoverEnviron([]string{
+		"PATH=/usr/bin",
+		handoverEnv + "=1",
+		handoverPprofEnv + "=4",
+		"LISTEN_FDS=1",
+		"LISTEN_PID=42",
+		"LISTEN_FDNAMES=http",
+		"DEEPL_API_KEY=key",
+	})
+	want := []string{"PATH=/usr/bin", "DEEPL_API_KEY=key"}
+	if !slices.Equal(got, want) {
+		t.Errorf("handoverEnviron() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,51 @@
+//go:build !windows
+
+package main
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestInheritedPprofListener(t *testing.T) {
+	if ln, err := inheritedPprofListener(); ln != nil || err != nil {
+		t.Fatalf("inheritedPprofListener() = %v, %v, want none", ln, err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer func() { _ = ln.Close() }()
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The inherited descriptor is closed by inheritedPprofListener.
+	fd, err := syscall.Dup(int(f.Fd()))
+	_ = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(handoverPprofEnv, strconv.Itoa(fd))
+	inherited, err := inheritedPprofListener()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = inherited.Close() }()
+	if inherited.Addr().String() != ln.Addr().String() {
+		t.Errorf("expected the listener on %s, got %s", ln.Addr(), inherited.Addr())
+	}
+	if _, ok := os.LookupEnv(handoverPprofEnv); ok {
+		t.Errorf("%s was not unset", handoverPprofEnv)
+	}
+
+	t.Setenv(handoverPprofEnv, "stdin")
+	if _, err := inheritedPprofListener(); err == nil {
+		t.Error("expected an error")
+	}
+}
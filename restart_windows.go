@@ -0,0 +1,10 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// restartSignals returns nil, Windows has no SIGUSR2.
+func restartSignals() chan os.Signal {
+	return nil
+}
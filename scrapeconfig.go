@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"go.yaml.in/yaml/v2"
+)
+
+// scrapeConfigs is the scrape_configs section of prometheus.yml.
+type scrapeConfigs struct {
+	ScrapeConfigs []scrapeConfig `yaml:"scrape_configs"`
+}
+
+type scrapeConfig struct {
+	JobName        string              `yaml:"job_name"`
+	Scheme         string              `yaml:"scheme,omitempty"`
+	MetricsPath    string              `yaml:"metrics_path,omitempty"`
+	Params         map[string][]string `yaml:"params,omitempty"`
+	ScrapeInterval string              `yaml:"scrape_interval"`
+	ScrapeTimeout  string              `yaml:"scrape_timeout"`
+	StaticConfigs  []staticConfig      `yaml:"static_configs"`
+	RelabelConfigs []relabelConfig     `yaml:"relabel_configs,omitempty"`
+}
+
+type staticConfig struct {
+	Targets []string `yaml:"targets,flow"`
+}
+
+type relabelConfig struct {
+	SourceLabels []string `yaml:"source_labels,omitempty,flow"`
+	TargetLabel  string   `yaml:"target_label"`
+	Replacement  string   `yaml:"replacement,omitempty"`
+}
+
+// scrapeOptions control the generated scrape config.
+type scrapeOptions struct {
+	job      string
+	address  string
+	scheme   string
+	interval time.Duration
+	timeout  time.Duration
+	// accounts are the probe targets. The exporter is scraped through
+	// /metrics if empty.
+	accounts []string
+	modules  []string
+}
+
+// newScrapeConfig returns the scrape config for the exporter. In probe mode
+// every account is a target of /probe, rewritten to the exporter address
+// like for the blackbox exporter.
+func newScrapeConfig(opts scrapeOptions) scrapeConfigs {
+	job := scrapeConfig{
+		JobName:        opts.job,
+		ScrapeInterval: promDuration(opts.interval),
+		ScrapeTimeout:  promDuration(opts.timeout),
+	}
+	if opts.scheme == "https" {
+		job.Scheme = opts.scheme
+	}
+
+	if len(opts.accounts) == 0 {
+		job.StaticConfigs = []staticConfig{{Targets: []string{opts.address}}}
+		return scrapeConfigs{ScrapeConfigs: []scrapeConfig{job}}
+	}
+
+	job.MetricsPath = "/probe"
+	if len(opts.modules) > 0 {
+		job.Params = map[string][]string{"module": {strings.Join(opts.modules, ",")}}
+	}
+	job.StaticConfigs = []staticConfig{{Targets: opts.accounts}}
+	job.RelabelConfigs = []relabelConfig{
+		{SourceLabels: []string{"__address__"}, TargetLabel: "__param_target"},
+		{SourceLabels: []string{"__param_target"}, TargetLabel: "instance"},
+		{TargetLabel: "__address__", Replacement: opts.address},
+	}
+	return scrapeConfigs{ScrapeConfigs: []scrapeConfig{job}}
+}
+
+// defaultExporterAddress returns the address Prometheus reaches the exporter
+// at when running on the same host.
+func defaultExporterAddress() string {
+	return net.JoinHostPort("localhost", envOrDefault("PORT", "1818"))
+}
+
+// generateScrapeConfig prints a Prometheus scrape_configs stanza for the
+// exporter, probing every account of the config file with --probe.
+func generateScrapeConfig(args []string, out io.Writer) error {
+	fs := newGenerateFlagSet("scrape-config")
+	fs.SetOutput(out)
+	job := fs.String("job", "deepl", "Name of the scrape job.")
+	address := fs.String("address", defaultExporterAddress(), "Address Prometheus reaches the exporter at.")
+	interval := fs.Duration("scrape-interval", 5*time.Minute, "Scrape interval of the job.")
+	timeout := fs.Duration("scrape-timeout", 30*time.Second, "Scrape timeout of the job.")
+	probe := fs.Bool("probe", false, "Probe every account of the config file through /probe instead of scraping /metrics.")
+	modules := fs.String("module", "", "Comma-separated collectors to probe, the enabled collectors if empty. Only used with --probe.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := settings.resolve(fs, os.Getenv); err != nil {
+		return err
+	}
+
+	if *timeout > *interval {
+		return fmt.Errorf("--scrape-timeout %s must not be greater than --scrape-interval %s", *timeout, *interval)
+	}
+
+	opts := scrapeOptions{
+		job:      *job,
+		address:  *address,
+		interval: *interval,
+		timeout:  *timeout,
+	}
+	if settings.WebTLSCertFile != "" {
+		opts.scheme = "https"
+	}
+
+	if *probe {
+		if settings.ConfigFile == "" {
+			return errors.New("--probe requires --config.file to list the accounts")
+		}
+		cfg, err := LoadConfig(settings.ConfigFile)
+		if err != nil {
+			return err
+		}
+		for _, account := range cfg.Accounts {
+			opts.accounts = append(opts.accounts, account.Name)
+		}
+		if *modules != "" {
+			for _, module := range strings.Split(*modules, ",") {
+				module = strings.TrimSpace(module)
+				if _, ok := deepl.Collectors()[module]; !ok {
+					return fmt.Errorf("unknown collector %q", module)
+				}
+				opts.modules = append(opts.modules, module)
+			}
+		}
+	} else if *modules != "" {
+		return errors.New("--module requires --probe")
+	}
+
+	data, err := yaml.Marshal(newScrapeConfig(opts))
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+// Sources of a setting, from the highest to the lowest precedence.
+const (
+	sourceFlag    = "flag"
+	sourceEnv     = "env"
+	sourceConfig  = "config"
+	sourceDefault = "default"
+)
+
+// secretFlagPattern matches the names of flags holding credentials.
+var secretFlagPattern = regexp.MustCompile(`(?i)(key|token|secret|password)`)
+
+// Settings are the settings of the exporter. Each is given by the flag of
+// its flag tag, else by the first set environment variable of its env tag,
+// else by the flags section of the config file, else it keeps its default.
+type Settings struct {
+	ConfigFile                string        `flag:"config.file" env:"CONFIG_FILE"`
+	WebPort                   string        `flag:"web.port" env:"PORT"`
+	WebListenAddresses        stringSlice   `flag:"web.listen-address" env:"WEB_LISTEN_ADDRESS"`
+	WebReusePort              bool          `flag:"web.reuse-port" env:"WEB_REUSE_PORT"`
+	DeepLURL                  string        `flag:"deepl.url" env:"DEEPL_SERVER_URL"`
+	DeepLAPIType              string        `flag:"deepl.api-type" env:"DEEPL_API_TYPE"`
+	DeepLProxyURL             string        `flag:"deepl.proxy-url" env:"DEEPL_PROXY_URL"`
+	DeepLCAFile               string        `flag:"deepl.ca-file" env:"DEEPL_CA_FILE"`
+	DeepLInsecureSkipVerify   bool          `flag:"deepl.insecure-skip-verify" env:"DEEPL_INSECURE_SKIP_VERIFY"`
+	DeepLIdleConnTimeout      time.Duration `flag:"deepl.idle-conn-timeout" env:"DEEPL_IDLE_CONN_TIMEOUT"`
+	DeepLMaxIdleConns         int           `flag:"deepl.max-idle-conns" env:"DEEPL_MAX_IDLE_CONNS"`
+	DeepLTLSHandshakeTimeout  time.Duration `flag:"deepl.tls-handshake-timeout" env:"DEEPL_TLS_HANDSHAKE_TIMEOUT"`
+	DeepLDNSCacheTTL          time.Duration `flag:"deepl.dns-cache-ttl" env:"DEEPL_DNS_CACHE_TTL"`
+	DeepLResolve              stringSlice   `flag:"deepl.resolve" env:"DEEPL_RESOLVE"`
+	DeepLUserAgentSuffix      string        `flag:"deepl.user-agent-suffix" env:"DEEPL_USER_AGENT_SUFFIX"`
+	DeepLRequestIDHeader      string        `flag:"deepl.request-id-header" env:"DEEPL_REQUEST_ID_HEADER"`
+	DeepLTracePropagation     bool          `flag:"deepl.trace-propagation" env:"DEEPL_TRACE_PROPAGATION"`
+	DeepLRateLimit            int           `flag:"deepl.rate-limit" env:"DEEPL_RATE_LIMIT"`
+	DeepLRateLimitPerAccount  int           `flag:"deepl.rate-limit-per-account" env:"DEEPL_RATE_LIMIT_PER_ACCOUNT"`
+	DeepLPollInterval         time.Duration `flag:"deepl.poll-interval" env:"DEEPL_POLL_INTERVAL"`
+	DeepLPollJitter           time.Duration `flag:"deepl.poll-jitter" env:"DEEPL_POLL_JITTER"`
+	DeepLPollStagger          bool          `flag:"deepl.poll-stagger" env:"DEEPL_POLL_STAGGER"`
+	DeepLNativeHistograms     bool          `flag:"deepl.native-histograms" env:"DEEPL_NATIVE_HISTOGRAMS"`
+	DeepLLatencyBuckets       string        `flag:"deepl.latency-buckets" env:"DEEPL_LATENCY_BUCKETS"`
+	CacheRedisURL             string        `flag:"cache.redis-url" env:"CACHE_REDIS_URL"`
+	CacheTTL                  time.Duration `flag:"cache.ttl" env:"CACHE_TTL"`
+	LeaderElection            bool          `flag:"leader-election" env:"LEADER_ELECTION"`
+	LeaderElectionNamespace   string        `flag:"leader-election.namespace" env:"LEADER_ELECTION_NAMESPACE"`
+	LeaderElectionLeaseName   string        `flag:"leader-election.lease-name" env:"LEADER_ELECTION_LEASE_NAME"`
+	WebTLSCertFile            string        `flag:"web.tls-cert-file" env:"WEB_TLS_CERT_FILE"`
+	WebTLSKeyFile             string        `flag:"web.tls-key-file" env:"WEB_TLS_KEY_FILE"`
+	WebTLSMinVersion          string        `flag:"web.tls-min-version" env:"WEB_TLS_MIN_VERSION"`
+	WebTLSCipherSuites        string        `flag:"web.tls-cipher-suites" env:"WEB_TLS_CIPHER_SUITES"`
+	WebTLSReloadInterval      time.Duration `flag:"web.tls-reload-interval" env:"WEB_TLS_RELOAD_INTERVAL"`
+	WebDisableHTTP2           bool          `flag:"web.disable-http2" env:"WEB_DISABLE_HTTP2"`
+	WebH2C                    bool          `flag:"web.h2c" env:"WEB_H2C"`
+	CollectorGo               bool          `flag:"collector.go"`
+	CollectorGoRuntimeMetrics bool          `flag:"collector.go.runtime-metrics"`
+	CollectorProcess          bool          `flag:"collector.process"`
+	WebMaxRequests            int           `flag:"web.max-requests" env:"WEB_MAX_REQUESTS"`
+	WebReadHeaderTimeout      time.Duration `flag:"web.read-header-timeout" env:"WEB_READ_HEADER_TIMEOUT"`
+	WebReadTimeout            time.Duration `flag:"web.read-timeout" env:"WEB_READ_TIMEOUT"`
+	WebWriteTimeout           time.Duration `flag:"web.write-timeout" env:"WEB_WRITE_TIMEOUT"`
+	WebIdleTimeout            time.Duration `flag:"web.idle-timeout" env:"WEB_IDLE_TIMEOUT"`
+	WebCompression            string        `flag:"web.compression" env:"WEB_COMPRESSION"`
+	WebDisableOpenMetrics     bool          `flag:"web.disable-openmetrics" env:"WEB_DISABLE_OPENMETRICS"`
+	WebAPIRateLimit           int           `flag:"web.api-rate-limit" env:"WEB_API_RATE_LIMIT"`
+	DemoMode                  bool          `flag:"demo" env:"DEMO"`
+	ReplayFile                string        `flag:"replay.file" env:"REPLAY_FILE"`
+	ReplaySpeed               float64       `flag:"replay.speed" env:"REPLAY_SPEED"`
+	DebugRecordDir            string        `flag:"debug.record-dir" env:"DEBUG_RECORD_DIR"`
+	DebugRecordMaxFiles       int           `flag:"debug.record-max-files" env:"DEBUG_RECORD_MAX_FILES"`
+	DebugFailRate             float64       `flag:"debug.fail-rate" env:"DEBUG_FAIL_RATE"`
+	DebugLatency              time.Duration `flag:"debug.latency" env:"DEBUG_LATENCY"`
+	DebugFreezeUsage          bool          `flag:"debug.freeze-usage" env:"DEBUG_FREEZE_USAGE"`
+	SnapshotEndpoint          string        `flag:"snapshot.endpoint" env:"SNAPSHOT_ENDPOINT"`
+	SnapshotBucket            string        `flag:"snapshot.bucket" env:"SNAPSHOT_BUCKET"`
+	SnapshotPrefix            string        `flag:"snapshot.prefix" env:"SNAPSHOT_PREFIX"`
+	SnapshotRegion            string        `flag:"snapshot.region" env:"SNAPSHOT_REGION,AWS_REGION"`
+	SnapshotFormat            string        `flag:"snapshot.format" env:"SNAPSHOT_FORMAT"`
+	SnapshotInterval          time.Duration `flag:"snapshot.interval" env:"SNAPSHOT_INTERVAL"`
+	HistoryPath               string        `flag:"history.path" env:"HISTORY_PATH"`
+	HistoryPostgresURL        string        `flag:"history.postgres-url" env:"HISTORY_POSTGRES_URL"`
+	HistoryInterval           time.Duration `flag:"history.interval" env:"HISTORY_INTERVAL"`
+	HistoryRawRetention       time.Duration `flag:"history.raw-retention" env:"HISTORY_RAW_RETENTION"`
+	HistoryRollupRetention    time.Duration `flag:"history.rollup-retention" env:"HISTORY_ROLLUP_RETENTION"`
+	HistoryCompactInterval    time.Duration `flag:"history.compact-interval" env:"HISTORY_COMPACT_INTERVAL"`
+	ReportPeriod              string        `flag:"report.period" env:"REPORT_PERIOD"`
+	CanaryInterval            time.Duration `flag:"canary.interval" env:"CANARY_INTERVAL"`
+	CanaryText                string        `flag:"canary.text" env:"CANARY_TEXT"`
+	CanaryTargetLang          string        `flag:"canary.target-lang" env:"CANARY_TARGET_LANG"`
+	CanaryBudget              int64         `flag:"canary.budget" env:"CANARY_BUDGET"`
+	DocumentsPollInterval     time.Duration `flag:"documents.poll-interval" env:"DOCUMENTS_POLL_INTERVAL"`
+	DocumentsRetention        time.Duration `flag:"documents.retention" env:"DOCUMENTS_RETENTION"`
+	PrintVersion              bool          `flag:"version"`
+	WebAdminTokenFile         string        `flag:"web.admin-token-file" env:"WEB_ADMIN_TOKEN_FILE"`
+	WebOIDCIssuer             string        `flag:"web.oidc-issuer" env:"WEB_OIDC_ISSUER"`
+	WebOIDCAudience           string        `flag:"web.oidc-audience" env:"WEB_OIDC_AUDIENCE"`
+	WebOIDCJWKSURL            string        `flag:"web.oidc-jwks-url" env:"WEB_OIDC_JWKS_URL"`
+	WebOIDCScopeClaim         string        `flag:"web.oidc-scope-claim" env:"WEB_OIDC_SCOPE_CLAIM"`
+	WebOIDCAlgorithms         string        `flag:"web.oidc-algorithms" env:"WEB_OIDC_ALGORITHMS"`
+	WebAuditLog               string        `flag:"web.audit-log" env:"WEB_AUDIT_LOG"`
+	WebAuditLogKeyFile        string        `flag:"web.audit-log-key-file" env:"WEB_AUDIT_LOG_KEY_FILE"`
+	WebEnablePprof            bool          `flag:"web.enable-pprof" env:"WEB_ENABLE_PPROF"`
+	WebPprofAddress           string        `flag:"web.pprof-address" env:"WEB_PPROF_ADDRESS"`
+	ProfilingPyroscopeURL     string        `flag:"profiling.pyroscope-url" env:"PROFILING_PYROSCOPE_URL"`
+	ProfilingAppName          string        `flag:"profiling.app-name" env:"PROFILING_APP_NAME"`
+	ProfilingLabels           string        `flag:"profiling.labels" env:"PROFILING_LABELS"`
+	ProfilingInterval         time.Duration `flag:"profiling.interval" env:"PROFILING_INTERVAL"`
+	UpdateCheckInterval       time.Duration `flag:"update-check.interval" env:"UPDATE_CHECK_INTERVAL"`
+	UpdateCheckURL            string        `flag:"update-check.url" env:"UPDATE_CHECK_URL"`
+	GoogleProjects            stringSlice   `flag:"google.project" env:"GOOGLE_PROJECTS"`
+	GoogleCredentialsFile     string        `flag:"google.credentials-file" env:"GOOGLE_APPLICATION_CREDENTIALS"`
+	GoogleCharacterLimit      int           `flag:"google.character-limit" env:"GOOGLE_CHARACTER_LIMIT"`
+	AzureResources            stringSlice   `flag:"azure.resource" env:"AZURE_RESOURCES"`
+	AzureTenantID             string        `flag:"azure.tenant-id" env:"AZURE_TENANT_ID"`
+	AzureClientID             string        `flag:"azure.client-id" env:"AZURE_CLIENT_ID"`
+	AzureClientSecretFile     string        `flag:"azure.client-secret-file" env:"AZURE_CLIENT_SECRET_FILE"`
+	AzureCharacterLimit       int           `flag:"azure.character-limit" env:"AZURE_CHARACTER_LIMIT"`
+	AwsRegions                stringSlice   `flag:"aws.region" env:"AWS_TRANSLATE_REGIONS"`
+	AwsEndpoint               string        `flag:"aws.endpoint" env:"AWS_CLOUDWATCH_ENDPOINT"`
+	AwsCharacterLimit         int           `flag:"aws.character-limit" env:"AWS_CHARACTER_LIMIT"`
+	LibreTranslateURLs        stringSlice   `flag:"libretranslate.url" env:"LIBRETRANSLATE_URLS"`
+	UsageAnomalyWindow        time.Duration `flag:"collector.usage.anomaly-window" env:"USAGE_ANOMALY_WINDOW"`
+	UsageThresholds           string        `flag:"collector.usage.thresholds" env:"USAGE_THRESHOLDS"`
+	UsagePricePerMillion      float64       `flag:"collector.usage.price-per-million-characters" env:"USAGE_PRICE_PER_MILLION_CHARACTERS"`
+	UsageAnomalyThreshold     float64       `flag:"collector.usage.anomaly-threshold" env:"USAGE_ANOMALY_THRESHOLD"`
+	UsageBillingAnchor        string        `flag:"collector.usage.billing-anchor" env:"USAGE_BILLING_ANCHOR"`
+
+	// sources are where the flags were given, by name, see resolve.
+	sources map[string]string
+}
+
+// Setting is the effective value of a flag and where it comes from.
+type Setting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// settingEnvs returns the environment variables of the settings, by flag
+// name.
+func settingEnvs() map[string][]string {
+	envs := make(map[string][]string)
+	for field := range reflect.TypeFor[Settings]().Fields() {
+		if env := field.Tag.Get("env"); env != "" {
+			envs[field.Tag.Get("flag")] = strings.Split(env, ",")
+		}
+	}
+	return envs
+}
+
+// documentEnv adds the environment variables of the settings to the usage
+// of their flags in fs, e.g. "Port to listen on (env: PORT).".
+func documentEnv(fs *flag.FlagSet) {
+	for name, envs := range settingEnvs() {
+		f := fs.Lookup(name)
+		note := "env: " + strings.Join(envs, " or ")
+		if _, ok := f.Value.(*stringSlice); ok {
+			note += ", space-separated"
+		}
+		f.Usage = strings.TrimSuffix(f.Usage, ".") + " (" + note + ")."
+	}
+}
+
+// setFlags returns the names of the flags of fs given on the command line.
+func setFlags(fs *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
+
+// resolve sets the settings not given on the command line parsed into fs
+// from their environment variables, looked up with getenv. The values of
+// repeatable flags are separated by spaces.
+func (s *Settings) resolve(fs *flag.FlagSet, getenv func(string) string) error {
+	s.sources = make(map[string]string)
+	for name := range setFlags(fs) {
+		s.sources[name] = sourceFlag
+	}
+
+	envs := settingEnvs()
+	for _, name := range slices.Sorted(maps.Keys(envs)) {
+		if s.sources[name] != "" {
+			continue
+		}
+		for _, env := range envs[name] {
+			value := getenv(env)
+			if value == "" {
+				continue
+			}
+			f := fs.Lookup(name)
+			values := []string{value}
+			if _, ok := f.Value.(*stringSlice); ok {
+				values = strings.Fields(value)
+			}
+			for _, value := range values {
+				if err := f.Value.Set(value); err != nil {
+					return fmt.Errorf("invalid value %q for %s: %w", value, env, err)
+				}
+			}
+			s.sources[name] = sourceEnv
+			break
+		}
+	}
+	return nil
+}
+
+// applyConfig sets the flags of fs given in the flags section of the config
+// file, unless resolve found them on the command line or in the
+// environment.
+func (s *Settings) applyConfig(fs *flag.FlagSet, values map[string]any) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := fs.Lookup(name)
+		if f == nil || name == "config.file" {
+			return fmt.Errorf("flags: unknown flag %q", name)
+		}
+		if s.sources[name] != "" {
+			continue
+		}
+
+		list, ok := values[name].([]any)
+		if !ok {
+			list = []any{values[name]}
+		}
+		for _, value := range list {
+			if err := f.Value.Set(fmt.Sprint(value)); err != nil {
+				return fmt.Errorf("flags: invalid value for %s: %w", name, err)
+			}
+		}
+		s.sources[name] = sourceConfig
+	}
+	return nil
+}
+
+// effective returns the value and source of every setting with credentials
+// masked, followed by the --collector.<name> flags of fs.
+func (s *Settings) effective(fs *flag.FlagSet) []Setting {
+	source := func(name string) string {
+		if source := s.sources[name]; source != "" {
+			return source
+		}
+		return sourceDefault
+	}
+
+	var settings []Setting
+	value := reflect.ValueOf(s).Elem()
+	for field := range value.Type().Fields() {
+		name := field.Tag.Get("flag")
+		if name == "" {
+			continue
+		}
+		settings = append(settings, Setting{Name: name, Value: sanitizeSetting(name, settingValue(value.FieldByIndex(field.Index))), Source: source(name)})
+	}
+	for _, name := range slices.Sorted(maps.Keys(deepl.Collectors())) {
+		if f := fs.Lookup("collector." + name); f != nil {
+			settings = append(settings, Setting{Name: f.Name, Value: f.Value.String(), Source: source(f.Name)})
+		}
+	}
+	return settings
+}
+
+// settingValue formats a field of Settings like its flag.
+func settingValue(v reflect.Value) string {
+	if stringer, ok := v.Addr().Interface().(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// sanitizeSetting masks credentials in the value of a flag: secret flags
+// entirely and passwords in URLs.
+func sanitizeSetting(name, value string) string {
+	if value == "" {
+		return value
+	}
+	if secretFlagPattern.MatchString(name) && !strings.HasSuffix(name, "-file") {
+		return deepl.Redacted
+	}
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return value
+}
+
+// logSettings logs the settings that differ from the defaults.
+func logSettings(settings []Setting) {
+	for _, setting := range settings {
+		if setting.Source != sourceDefault {
+			log.Printf("Setting %s=%q (from %s)", setting.Name, setting.Value, setting.Source)
+		}
+	}
+}
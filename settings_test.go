@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+// newTestSettings returns settings whose flags are defined in a new flag
+// set, parsed from args.
+func newTestSettings(t *testing.T, args ...string) (*Settings, *flag.FlagSet) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	s := &Settings{}
+	registerSettings(fs, s)
+	fs.Bool("collector.glossaries", false, "Enable the glossaries collector (default: disabled).")
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s, fs
+}
+
+func TestSettingsPrecedence(t *testing.T) {
+	s, fs := newTestSettings(t, "--deepl.rate-limit=5")
+	env := map[string]string{
+		"DEEPL_RATE_LIMIT": "7",
+		"CACHE_REDIS_URL":  "redis://:secret@redis:6379",
+		"AWS_REGION":       "eu-central-1",
+		"DEEPL_RESOLVE":    "api.deepl.com:10.0.0.1  api-free.deepl.com:10.0.0.2",
+	}
+	if err := s.resolve(fs, func(key string) string { return env[key] }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := s.applyConfig(fs, map[string]any{
+		"deepl.poll-interval":  "5m",
+		"deepl.rate-limit":     10,
+		"cache.redis-url":      "redis://other:6379",
+		"collector.glossaries": true,
+		"libretranslate.url":   []any{"http://lt-a:5000", "http://lt-b:5000"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if s.DeepLPollInterval != 5*time.Minute {
+		t.Errorf("expected the config to set the poll interval, got %v", s.DeepLPollInterval)
+	}
+	if s.DeepLRateLimit != 5 {
+		t.Errorf("expected the command line to win over the environment and config, got %d", s.DeepLRateLimit)
+	}
+	if s.CacheRedisURL != "redis://:secret@redis:6379" {
+		t.Errorf("expected the environment to win over the config, got %s", s.CacheRedisURL)
+	}
+	if s.SnapshotRegion != "eu-central-1" {
+		t.Errorf("expected the fallback variable to be used, got %s", s.SnapshotRegion)
+	}
+	if !slices.Equal(s.DeepLResolve.values, []string{"api.deepl.com:10.0.0.1", "api-free.deepl.com:10.0.0.2"}) {
+		t.Errorf("expected space-separated values to set repeatable flags, got %v", s.DeepLResolve.values)
+	}
+	if !slices.Equal(s.LibreTranslateURLs.values, []string{"http://lt-a:5000", "http://lt-b:5000"}) {
+		t.Errorf("expected lists to set repeatable flags, got %v", s.LibreTranslateURLs.values)
+	}
+	if s.WebPort != "1818" {
+		t.Errorf("expected the default port, got %s", s.WebPort)
+	}
+
+	effective := make(map[string]Setting)
+	for _, setting := range s.effective(fs) {
+		effective[setting.Name] = setting
+	}
+	want := map[string]Setting{
+		"deepl.poll-interval":  {Name: "deepl.poll-interval", Value: "5m0s", Source: sourceConfig},
+		"deepl.rate-limit":     {Name: "deepl.rate-limit", Value: "5", Source: sourceFlag},
+		"cache.redis-url":      {Name: "cache.redis-url", Value: "redis://:xxxxx@redis:6379", Source: sourceEnv},
+		"deepl.resolve":        {Name: "deepl.resolve", Value: "api.deepl.com:10.0.0.1 api-free.deepl.com:10.0.0.2", Source: sourceEnv},
+		"collector.glossaries": {Name: "collector.glossaries", Value: "true", Source: sourceConfig},
+		"web.port":             {Name: "web.port", Value: "1818", Source: sourceDefault},
+	}
+	for name, setting := range want {
+		if effective[name] != setting {
+			t.Errorf("expected %+v, got %+v", setting, effective[name])
+		}
+	}
+}
+
+func TestSettingsInvalidEnv(t *testing.T) {
+	s, fs := newTestSettings(t)
+	err := s.resolve(fs, func(key string) string {
+		if key == "DEEPL_POLL_INTERVAL" {
+			return "often"
+		}
+		return ""
+	})
+	if err == nil || !strings.Contains(err.Error(), "DEEPL_POLL_INTERVAL") {
+		t.Errorf("expected an error naming the variable, got %v", err)
+	}
+}
+
+func TestSettingsApplyConfigUnknown(t *testing.T) {
+	for _, name := range []string{"deepl.pol-interval", "config.file"} {
+		s, fs := newTestSettings(t)
+		if err := s.resolve(fs, func(string) string { return "" }); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := s.applyConfig(fs, map[string]any{name: "x"}); err == nil {
+			t.Errorf("expected %s to be rejected", name)
+		}
+	}
+}
+
+func TestSettingsDocumentEnv(t *testing.T) {
+	_, fs := newTestSettings(t)
+	for name, want := range map[string]string{
+		"web.port":        "Port to listen on (env: PORT).",
+		"deepl.resolve":   "(env: DEEPL_RESOLVE, space-separated).",
+		"snapshot.region": "(env: SNAPSHOT_REGION or AWS_REGION).",
+	} {
+		if usage := fs.Lookup(name).Usage; !strings.HasSuffix(usage, want) {
+			t.Errorf("expected the usage of %s to end with %q, got %q", name, want, usage)
+		}
+	}
+	// Every flag with an environment variable is a field of Settings.
+	for field := range reflect.TypeFor[Settings]().Fields() {
+		if name := field.Tag.Get("flag"); name != "" && fs.Lookup(name) == nil {
+			t.Errorf("field %s has no flag %s", field.Name, name)
+		}
+	}
+}
+
+func TestSanitizeSetting(t *testing.T) {
+	tests := []struct {
+		name, value, want string
+	}{
+		{"cache.redis-url", "redis://:secret@redis:6379/0", "redis://:xxxxx@redis:6379/0"},
+		{"deepl.url", "https://api.deepl.com", "https://api.deepl.com"},
+		{"deepl.api-key", "abc", deepl.Redacted},
+		{"web.tls-key-file", "/etc/tls.key", "/etc/tls.key"},
+		{"deepl.api-key", "", ""},
+	}
+	for _, tt := range tests {
+		if got := sanitizeSetting(tt.name, tt.value); got != tt.want {
+			t.Errorf("sanitizeSetting(%q, %q) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
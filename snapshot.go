@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+const (
+	amzDateFormat   = "20060102T150405Z"
+	snapshotService = "s3"
+)
+
+// snapshotSigner signs the uploads with AWS Signature Version 4. S3 expects
+// the path to be escaped only once, unlike the other AWS services.
+var snapshotSigner = v4.NewSigner(func(o *v4.SignerOptions) {
+	o.DisableURIPathEscaping = true
+})
+
+// SnapshotConfig configures the periodic upload of usage snapshots to an S3
+// compatible object storage, e.g. AWS S3, GCS in interoperability mode or
+// MinIO.
+type SnapshotConfig struct {
+	// Endpoint is the base URL of the storage, e.g.
+	// https://s3.eu-central-1.amazonaws.com or https://storage.googleapis.com.
+	// Objects are addressed path-style as <Endpoint>/<Bucket>/<key>.
+	Endpoint string
+	Bucket   string
+	// Prefix is prepended to the object keys, e.g. deepl/.
+	Prefix string
+	// Region is used for signing, "auto" works for GCS and MinIO.
+	Region   string
+	Format   string
+	Interval time.Duration
+
+	// Credentials sign the uploads, e.g. those of awsCredentials.
+	Credentials aws.CredentialsProvider
+}
+
+// Snapshot is the usage of every account at one point in time.
+type Snapshot struct {
+	Time     time.Time      `json:"time"`
+	Accounts []AccountUsage `json:"accounts"`
+}
+
+// SnapshotUploader periodically uploads the usage of all accounts.
+type SnapshotUploader struct {
+	cfg       SnapshotConfig
+	collector *deepl.DeepLCollector
+	http      *http.Client
+	now       func() time.Time
+}
+
+func NewSnapshotUploader(collector *deepl.DeepLCollector, cfg SnapshotConfig) (*SnapshotUploader, error) {
+	if _, err := deepl.NormalizeServerURL(cfg.Endpoint); err != nil {
+		return nil, fmt.Errorf("invalid snapshot endpoint: %w", err)
+	}
+	if cfg.Bucket == "" {
+		return nil, errors.New("snapshot bucket is required")
+	}
+	if cfg.Format != "json" && cfg.Format != "csv" {
+		return nil, fmt.Errorf("invalid snapshot format %q: must be json or csv", cfg.Format)
+	}
+	if cfg.Interval <= 0 {
+		return nil, fmt.Errorf("invalid snapshot interval %s: must be positive", cfg.Interval)
+	}
+	if cfg.Credentials == nil {
+		return nil, errors.New("snapshot upload requires AWS credentials")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+
+	return &SnapshotUploader{
+		cfg:       cfg,
+		collector: collector,
+		http:      &http.Client{Timeout: defaultTimeout},
+		now:       time.Now,
+	}, nil
+}
+
+// Run uploads a snapshot every interval until ctx is canceled. With leader
+// election only the leader uploads, isLeader is nil otherwise.
+func (u *SnapshotUploader) Run(ctx context.Context, isLeader func() bool) {
+	ticker := time.NewTicker(u.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if isLeader != nil && !isLeader() {
+			continue
+		}
+		if key, err := u.Upload(ctx); err != nil {
+			log.Printf("Failed to upload usage snapshot: %v", err)
+		} else {
+			log.Printf("Uploaded usage snapshot to %s/%s", u.cfg.Bucket, key)
+		}
+	}
+}
+
+// Upload uploads a snapshot of the current usage and returns its key.
+func (u *SnapshotUploader) Upload(ctx context.Context) (string, error) {
+	snapshot := Snapshot{Time: u.now().UTC(), Accounts: usageSnapshot(ctx, u.collector)}
+	body, contentType, err := encodeSnapshot(snapshot, u.cfg.Format)
+	if err != nil {
+		return "", err
+	}
+
+	key := fmt.Sprintf("%s%s/usage-%s.%s", u.cfg.Prefix, snapshot.Time.Format("2006/01/02"), snapshot.Time.Format(amzDateFormat), u.cfg.Format)
+	if err := u.put(ctx, key, body, contentType); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// usageSnapshot returns the usage of every account, see accountUsages.
+func usageSnapshot(ctx context.Context, c *deepl.DeepLCollector) []AccountUsage {
+	return accountUsages(ctx, c, c.Clients())
+}
+
+// encodeSnapshot encodes snapshot as json or csv and returns the content
+// type.
+func encodeSnapshot(snapshot Snapshot, format string) ([]byte, string, error) {
+	if format == "json" {
+		data, err := json.Marshal(snapshot)
+		return data, "application/json", err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"timestamp", "account", "character_count", "character_limit", "usage_percent", "error"})
+	for _, usage := range snapshot.Accounts {
+		_ = w.Write([]string{
+			snapshot.Time.Format(time.RFC3339),
+			usage.Account,
+			strconv.FormatInt(usage.CharacterCount, 10),
+			strconv.FormatInt(usage.CharacterLimit, 10),
+			strconv.FormatFloat(usage.UsagePercent, 'f', 2, 64),
+			usage.Error,
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), "text/csv", w.Error()
+}
+
+// put uploads an object with a request signed with AWS Signature Version 4.
+func (u *SnapshotUploader) put(ctx context.Context, key string, body []byte, contentType string) error {
+	target := u.cfg.Endpoint + "/" + awsURIEncode(u.cfg.Bucket) + "/" + strings.ReplaceAll(awsURIEncode(key), "%2F", "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	payloadHash := sha256Hex(body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	credentials, err := u.cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	if err := snapshotSigner.SignHTTP(ctx, credentials, req, payloadHash, snapshotService, u.cfg.Region, u.now().UTC()); err != nil {
+		return fmt.Errorf("failed to sign snapshot upload: %w", err)
+	}
+
+	resp, err := u.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload snapshot: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &deepl.APIError{StatusCode: resp.StatusCode, Body: string(message)}
+	}
+	return nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// awsURIEncode percent-encodes everything but unreserved characters, as S3
+// expects the object keys in the path of a request.
+func awsURIEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+func TestAWSURIEncode(t *testing.T) {
+	if got := awsURIEncode("a b/c+d~e"); got != "a%20b%2Fc%2Bd~e" {
+		t.Errorf("unexpected encoding %q", got)
+	}
+}
+
+func TestEncodeSnapshotCSV(t *testing.T) {
+	snapshot := Snapshot{
+		Time: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+		Accounts: []AccountUsage{
+			{Account: "team-a", CharacterCount: 250, CharacterLimit: 1000, UsagePercent: 25},
+			{Account: "team-b", Error: "unauthorized"},
+		},
+	}
+	body, contentType, err := encodeSnapshot(snapshot, "csv")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if contentType != "text/csv" {
+		t.Errorf("unexpected content type %q", contentType)
+	}
+	want := "timestamp,account,character_count,character_limit,usage_percent,error\n" +
+		"2026-10-16T12:00:00Z,team-a,250,1000,25.00,\n" +
+		"2026-10-16T12:00:00Z,team-b,0,0,0.00,unauthorized\n"
+	if string(body) != want {
+		t.Errorf("unexpected CSV:\n%s", body)
+	}
+}
+
+func TestSnapshotUploaderUpload(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, deepl.DeepLUsage{CharacterCount: 300, CharacterLimit: 1000})
+	}))
+	defer api.Close()
+
+	var uploaded struct {
+		path, auth, contentType, hash string
+		body                          []byte
+	}
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("expected PUT, got %s", r.Method)
+		}
+		uploaded.path = r.URL.Path
+		uploaded.auth = r.Header.Get("Authorization")
+		uploaded.contentType = r.Header.Get("Content-Type")
+		uploaded.hash = r.Header.Get("X-Amz-Content-Sha256")
+		uploaded.body, _ = io.ReadAll(r.Body)
+	}))
+	defer storage.Close()
+
+	client, err := deepl.NewClient(deepl.ClientConfig{Name: "team-a", APIKey: "key", ServerURL: api.URL})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	collector, err := deepl.NewDeepLCollector([]*deepl.Client{client}, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	uploader, err := NewSnapshotUploader(collector, SnapshotConfig{
+		Endpoint:    storage.URL + "/",
+		Bucket:      "billing",
+		Prefix:      "deepl/",
+		Format:      "json",
+		Interval:    time.Hour,
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "secret", ""),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	uploader.now = func() time.Time { return time.Date(2026, 10, 16, 12, 30, 0, 0, time.UTC) }
+
+	key, err := uploader.Upload(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if key != "deepl/2026/10/16/usage-20261016T123000Z.json" {
+		t.Errorf("unexpected key %q", key)
+	}
+	if uploaded.path != "/billing/"+key {
+		t.Errorf("unexpected path %q", uploaded.path)
+	}
+	if !strings.HasPrefix(uploaded.auth, "AWS4-HMAC-SHA256 Credential=AKID/20261016/us-east-1/s3/aws4_request, SignedHeaders=content-length;content-type;host;x-amz-content-sha256;x-amz-date, Signature=") {
+		t.Errorf("unexpected authorization %q", uploaded.auth)
+	}
+	if uploaded.contentType != "application/json" || uploaded.hash != sha256Hex(uploaded.body) {
+		t.Errorf("unexpected headers: content type %q, hash %q", uploaded.contentType, uploaded.hash)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(uploaded.body, &snapshot); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(snapshot.Accounts) != 1 || snapshot.Accounts[0].CharacterCount != 300 || snapshot.Accounts[0].UsagePercent != 30 {
+		t.Errorf("unexpected snapshot: %+v", snapshot)
+	}
+}
+
+func TestNewSnapshotUploaderValidation(t *testing.T) {
+	valid := SnapshotConfig{Endpoint: "https://storage.example", Bucket: "b", Format: "json", Interval: time.Hour, Credentials: credentials.NewStaticCredentialsProvider("a", "s", "")}
+	if _, err := NewSnapshotUploader(nil, valid); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for name, modify := range map[string]func(*SnapshotConfig){
+		"no bucket":      func(c *SnapshotConfig) { c.Bucket = "" },
+		"bad format":     func(c *SnapshotConfig) { c.Format = "xml" },
+		"no interval":    func(c *SnapshotConfig) { c.Interval = 0 },
+		"no credentials": func(c *SnapshotConfig) { c.Credentials = nil },
+	} {
+		cfg := valid
+		modify(&cfg)
+		if _, err := NewSnapshotUploader(nil, cfg); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+const (
+	// streamKeepAlive is how often an idle stream sends a comment, so
+	// proxies do not close it.
+	streamKeepAlive = 30 * time.Second
+	// streamBuffer is the number of updates buffered for a slow client
+	// before further updates are dropped for it.
+	streamBuffer = 16
+)
+
+// UsageStream fans the usage of every account refreshed by the Poller out
+// to the connected clients.
+type UsageStream struct {
+	mu          sync.Mutex
+	subscribers map[chan AccountUsage]struct{}
+}
+
+func NewUsageStream() *UsageStream {
+	return &UsageStream{subscribers: make(map[chan AccountUsage]struct{})}
+}
+
+// Subscribe returns a channel receiving every published usage and a
+// function to unsubscribe, which closes the channel.
+func (s *UsageStream) Subscribe() (<-chan AccountUsage, func()) {
+	ch := make(chan AccountUsage, streamBuffer)
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subscribers, ch)
+			s.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Wait blocks until every subscriber unsubscribed or ctx is done. On
+// shutdown, it waits for the WebSocket connections to be closed, which
+// http.Server.Shutdown does not track.
+func (s *UsageStream) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		s.mu.Lock()
+		n := len(s.subscribers)
+		s.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Publish sends usage to every subscriber. Subscribers whose buffer is full
+// miss it rather than holding up the Poller.
+func (s *UsageStream) Publish(usage AccountUsage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- usage:
+		default:
+		}
+	}
+}
+
+// PublishRefresh publishes the usage of an account just refreshed with the
+// errors errs, or the error of the usage collector. Nothing is published if
+// the usage collector is disabled.
+func (s *UsageStream) PublishRefresh(c *deepl.DeepLCollector, client *deepl.Client, errs map[string]error) {
+	err, ok := errs["usage"]
+	if !ok {
+		return
+	}
+	if err != nil {
+		s.Publish(AccountUsage{Account: client.Name(), Error: err.Error()})
+		return
+	}
+	if usage, ok := cachedAccountUsage(c, client.Name()); ok {
+		s.Publish(usage)
+	}
+}
+
+// cachedUsages returns the usage of every account fetched so far, sent to
+// clients when they connect so they need not wait for the next refresh.
+func cachedUsages(c *deepl.DeepLCollector) []AccountUsage {
+	var usages []AccountUsage
+	for _, client := range c.Clients() {
+		if usage, ok := cachedAccountUsage(c, client.Name()); ok {
+			usages = append(usages, usage)
+		}
+	}
+	return usages
+}
+
+// streamHandler serves the usage refreshed by the Poller as Server-Sent
+// Events, a "usage" event with the JSON of an AccountUsage each, starting
+// with the cached usage of every account. Streams end once shutdown is
+// done, as http.Server.Shutdown waits for them otherwise; clients reconnect.
+func streamHandler(shutdown context.Context, stream *UsageStream, collector *deepl.DeepLCollector, keepAlive time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// The stream outlives --web.write-timeout.
+		rc := http.NewResponseController(w)
+		_ = rc.SetWriteDeadline(time.Time{})
+
+		updates, unsubscribe := stream.Subscribe()
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// Disables response buffering in nginx.
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		send := func(usage AccountUsage) error {
+			data, err := json.Marshal(usage)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "event: usage\ndata: %s\n\n", data); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		for _, usage := range cachedUsages(collector) {
+			if err := send(usage); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-shutdown.Done():
+				return
+			case usage := <-updates:
+				if err := send(usage); err != nil {
+					return
+				}
+			case <-ticker.C:
+				if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+					return
+				}
+				if err := rc.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
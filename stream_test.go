@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+// readEvent returns the event type and data of the next event of an SSE
+// stream, skipping comments.
+func readEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+	var event, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read the stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && event != "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	var count atomic.Int64
+	deeplServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, deepl.DeepLUsage{CharacterCount: count.Add(1000), CharacterLimit: 10000})
+	}))
+	defer deeplServer.Close()
+
+	client, err := deepl.NewClient(deepl.ClientConfig{Name: "team-a", APIKey: "key", ServerURL: deeplServer.URL})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	collector, err := deepl.NewDeepLCollector([]*deepl.Client{client}, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	collector.EnablePolling()
+	collector.Refresh(t.Context(), client)
+
+	stream := NewUsageStream()
+	shutdown, stopStreams := context.WithCancel(t.Context())
+	server := httptest.NewUnstartedServer(streamHandler(shutdown, stream, collector, 10*time.Millisecond))
+	server.Config.RegisterOnShutdown(stopStreams)
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", ct)
+	}
+	body := bufio.NewReader(resp.Body)
+
+	// The cached usage is sent right away.
+	event, data := readEvent(t, body)
+	var usage AccountUsage
+	if err := json.Unmarshal([]byte(data), &usage); err != nil {
+		t.Fatalf("invalid JSON %q: %v", data, err)
+	}
+	if event != "usage" || usage.Account != "team-a" || usage.CharacterCount != 1000 || usage.UsagePercent != 10 {
+		t.Errorf("unexpected first event %s: %+v", event, usage)
+	}
+
+	// Wait for the handler to subscribe, signalled by the keepalives.
+	for {
+		line, err := body.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read the stream: %v", err)
+		}
+		if line == ": keepalive\n" {
+			break
+		}
+	}
+	stream.PublishRefresh(collector, client, collector.Refresh(t.Context(), client))
+	_, data = readEvent(t, body)
+	if err := json.Unmarshal([]byte(data), &usage); err != nil {
+		t.Fatalf("invalid JSON %q: %v", data, err)
+	}
+	if usage.CharacterCount != 2000 {
+		t.Errorf("expected the refreshed usage, got %+v", usage)
+	}
+
+	// Shutting down the server ends the stream instead of waiting for it.
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	if err := server.Config.Shutdown(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := io.ReadAll(body); err != nil {
+		t.Errorf("expected the stream to end, got %v", err)
+	}
+}
+
+func TestUsageStream(t *testing.T) {
+	stream := NewUsageStream()
+	updates, unsubscribe := stream.Subscribe()
+
+	// A slow subscriber misses updates instead of blocking the publisher.
+	for range streamBuffer + 1 {
+		stream.Publish(AccountUsage{Account: "team-a"})
+	}
+	if len(updates) != streamBuffer {
+		t.Errorf("expected %d buffered updates, got %d", streamBuffer, len(updates))
+	}
+
+	stream.PublishRefresh(nil, nil, map[string]error{"glossaries": nil})
+	if len(updates) != streamBuffer {
+		t.Error("expected nothing to be published without the usage collector")
+	}
+
+	unsubscribe()
+	unsubscribe()
+	stream.Publish(AccountUsage{Account: "team-a"})
+	for range updates {
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// TenantConfig gives a team a scrape target of its own, exporting the DeepL
+// metrics of its accounts only. Exactly one of Token and TokenFile must be
+// set.
+type TenantConfig struct {
+	Name      string     `yaml:"name"`
+	Accounts  stringList `yaml:"accounts"`
+	Token     string     `yaml:"token"`
+	TokenFile string     `yaml:"token_file"`
+}
+
+// resolveToken returns the bearer token of the tenant.
+func (t *TenantConfig) resolveToken() (string, error) {
+	if t.TokenFile == "" {
+		return t.Token, nil
+	}
+	token, err := readTokenFile(t.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("tenant %q: %w", t.Name, err)
+	}
+	return token, nil
+}
+
+// tenantMetricsHandler serves /metrics/{tenant} with the DeepL metrics of
+// the accounts of the tenant, to requests carrying the tenant's bearer
+// token only. Only those accounts are fetched from DeepL.
+func tenantMetricsHandler(tenants []TenantConfig, collector *deepl.DeepLCollector, opts promhttp.HandlerOpts) (http.Handler, error) {
+	endpoints := make(map[string]http.Handler, len(tenants))
+	for _, tenant := range tenants {
+		token, err := tenant.resolveToken()
+		if err != nil {
+			return nil, err
+		}
+		filtered, err := collector.ForAccounts(tenant.Accounts)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %q: %w", tenant.Name, err)
+		}
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(filtered)
+		endpoints[tenant.Name] = requireToken(token, promhttp.HandlerFor(registry, opts))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint, ok := endpoints[r.PathValue("tenant")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		endpoint.ServeHTTP(w, r)
+	}), nil
+}
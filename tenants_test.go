@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func TestTenantMetricsHandler(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `{"character_count": 1000, "character_limit": 500000}`)
+	}))
+	defer ts.Close()
+
+	var clients []*deepl.Client
+	for _, name := range []string{"a", "b", "c"} {
+		client, err := deepl.NewClient(deepl.ClientConfig{Name: name, APIKey: "key-" + name, ServerURL: ts.URL})
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		clients = append(clients, client)
+	}
+	c, err := deepl.NewDeepLCollector(clients, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("secret-b\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	handler, err := tenantMetricsHandler([]TenantConfig{
+		{Name: "team-a", Accounts: []string{"a", "c"}, Token: "secret-a"},
+		{Name: "team-b", Accounts: []string{"b"}, TokenFile: tokenFile},
+	}, c, promhttp.HandlerOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics/{tenant}", handler)
+
+	tests := []struct {
+		path, token string
+		code        int
+		want        []string
+		unwanted    []string
+	}{
+		{path: "/metrics/team-a", token: "secret-a", code: http.StatusOK, want: []string{`account="a"`, `account="c"`}, unwanted: []string{`account="b"`}},
+		{path: "/metrics/team-b", token: "secret-b", code: http.StatusOK, want: []string{`account="b"`}, unwanted: []string{`account="a"`}},
+		{path: "/metrics/team-b", token: "secret-a", code: http.StatusUnauthorized},
+		{path: "/metrics/team-a", code: http.StatusUnauthorized},
+		{path: "/metrics/unknown", token: "secret-a", code: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, r)
+		if rec.Code != tt.code {
+			t.Errorf("%s with token %q: expected status %d, got %d", tt.path, tt.token, tt.code, rec.Code)
+			continue
+		}
+		body := rec.Body.String()
+		for _, want := range tt.want {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: expected %s in:\n%s", tt.path, want, body)
+			}
+		}
+		for _, unwanted := range tt.unwanted {
+			if strings.Contains(body, unwanted) {
+				t.Errorf("%s: unexpected %s in:\n%s", tt.path, unwanted, body)
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader serves the server certificate from files that may be
+// replaced at runtime, e.g. by cert-manager, without restarting the
+// listener. Connections established before a reload keep their certificate.
+type certReloader struct {
+	certFile, keyFile string
+	// audit records every reload, if set.
+	audit *AuditLog
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime map[string]time.Time
+}
+
+// newCertReloader loads the certificate and key pair, which must be valid.
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// reload loads the certificate and key pair again. On failure, e.g. while
+// only one of the files was replaced, the previous certificate is kept.
+func (r *certReloader) reload() error {
+	modTime, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cert, r.modTime = &cert, modTime
+	r.mu.Unlock()
+	return nil
+}
+
+// changed reports whether the files were modified since the last reload.
+func (r *certReloader) changed() bool {
+	modTime, err := r.modTimes()
+	if err != nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for file, t := range modTime {
+		if !t.Equal(r.modTime[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// modTimes returns the modification times of the files, following symlinks
+// as swapped by Kubernetes secret volumes.
+func (r *certReloader) modTimes() (map[string]time.Time, error) {
+	modTime := make(map[string]time.Time, 2)
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat TLS certificate: %w", err)
+		}
+		modTime[file] = info.ModTime()
+	}
+	return modTime, nil
+}
+
+// Run reloads the certificate whenever the files change, checking every
+// interval, and on every reload signal (SIGHUP) until ctx is canceled. A
+// non-positive interval disables watching the files.
+func (r *certReloader) Run(ctx context.Context, interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	signals := reloadSignals()
+	defer stopReloadSignals(signals)
+
+	// A file that stays broken is only recorded once in the audit log.
+	var lastErr string
+	for {
+		var trigger string
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+			if !r.changed() {
+				continue
+			}
+			trigger = "file-change"
+		case <-signals:
+			trigger = "signal:SIGHUP"
+		}
+		err := r.reload()
+		if err == nil || err.Error() != lastErr || trigger != "file-change" {
+			r.audit.recordReload("reload-tls", trigger, r.certFile, err)
+		}
+		if err != nil {
+			lastErr = err.Error()
+			log.Printf("Keeping the previous TLS certificate: %v", err)
+			continue
+		}
+		lastErr = ""
+		log.Printf("Reloaded TLS certificate from %s", r.certFile)
+	}
+}
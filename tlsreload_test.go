@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestCertificate writes a self-signed certificate for commonName and
+// its key to certFile and keyFile.
+func writeTestCertificate(t *testing.T, certFile, keyFile, commonName string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func servedCommonName(t *testing.T, r *certReloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeTestCertificate(t, certFile, keyFile, "first")
+
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if name := servedCommonName(t, reloader); name != "first" {
+		t.Fatalf("expected the first certificate, got %q", name)
+	}
+
+	auditFile := filepath.Join(dir, "audit.log")
+	if reloader.audit, err = OpenAuditLog(auditFile, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Run(ctx, 10*time.Millisecond)
+
+	// A broken key keeps the previous certificate.
+	if err := os.WriteFile(keyFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(keyFile, future, future); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if name := servedCommonName(t, reloader); name != "first" {
+		t.Fatalf("expected the first certificate to be kept, got %q", name)
+	}
+
+	writeTestCertificate(t, certFile, keyFile, "second")
+	future = future.Add(time.Minute)
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, future, future); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for servedCommonName(t, reloader) != "second" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the second certificate to be served")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Both the failed and the successful reload are audited.
+	for {
+		data, err := os.ReadFile(auditFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var failed, reloaded bool
+		for line := range strings.Lines(string(data)) {
+			if !strings.HasSuffix(line, "\n") {
+				break
+			}
+			if !strings.Contains(line, `"action":"reload-tls","actor":"file-change"`) {
+				t.Errorf("unexpected entry %s", line)
+			}
+			failed = failed || strings.Contains(line, `"error":`)
+			reloaded = reloaded || !strings.Contains(line, `"error":`)
+		}
+		if failed && reloaded {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a failed and a successful reload in the audit log, got:\n%s", data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNewCertReloaderInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := newCertReloader(filepath.Join(dir, "missing.crt"), filepath.Join(dir, "missing.key")); err == nil {
+		t.Error("expected error for missing files")
+	}
+}
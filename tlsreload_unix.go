@@ -0,0 +1,21 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// reloadSignals returns a channel receiving SIGHUP, which asks to reload
+// the TLS certificate.
+func reloadSignals() chan os.Signal {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	return signals
+}
+
+func stopReloadSignals(signals chan os.Signal) {
+	signal.Stop(signals)
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// reloadSignals returns nil, Windows has no SIGHUP.
+func reloadSignals() chan os.Signal {
+	return nil
+}
+
+func stopReloadSignals(chan os.Signal) {}
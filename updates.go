@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultReleasesURL = "https://api.github.com/repos/jadolg/deepl-exporter/releases/latest"
+
+// UpdateChecker periodically fetches the latest release of the exporter
+// and exports whether it is newer than the running version.
+type UpdateChecker struct {
+	url      string
+	interval time.Duration
+	current  string
+	http     *http.Client
+
+	mu      sync.Mutex
+	latest  string
+	checked bool
+
+	available *prometheus.Desc
+}
+
+func NewUpdateChecker(url string, interval time.Duration) (*UpdateChecker, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid update check interval %s: must be positive", interval)
+	}
+	return &UpdateChecker{
+		url:      url,
+		interval: interval,
+		current:  buildInfo().Version,
+		http:     &http.Client{Timeout: defaultTimeout},
+		available: prometheus.NewDesc(
+			"deepl_exporter_update_available",
+			"Whether a release newer than the running exporter is available, labeled by the latest version",
+			[]string{"version", "latest_version"},
+			nil,
+		),
+	}, nil
+}
+
+// Run checks for a new release every interval until ctx is canceled.
+func (u *UpdateChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(u.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := u.Check(ctx); err != nil {
+			log.Printf("Error checking for a new release: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check fetches the latest release.
+func (u *UpdateChecker) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", buildUserAgent(""))
+	resp, err := u.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned status %d: %s", u.url, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return err
+	}
+	if release.TagName == "" {
+		return errors.New("the latest release has no tag")
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if release.TagName != u.latest && newerVersion(release.TagName, u.current) {
+		log.Printf("deepl-exporter %s is available, running %s", release.TagName, u.current)
+	}
+	u.latest, u.checked = release.TagName, true
+	return nil
+}
+
+func (u *UpdateChecker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- u.available
+}
+
+func (u *UpdateChecker) Collect(ch chan<- prometheus.Metric) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.checked {
+		return
+	}
+	available := 0.0
+	if newerVersion(u.latest, u.current) {
+		available = 1
+	}
+	ch <- prometheus.MustNewConstMetric(u.available, prometheus.GaugeValue, available, u.current, u.latest)
+}
+
+// newerVersion reports whether the semantic version latest is newer than
+// current. Development builds and versions that are not of the form
+// v1.2.3 are never outdated.
+func newerVersion(latest, current string) bool {
+	l, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	c, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	for i := range l {
+		if l[i] != c[i] {
+			return l[i] > c[i]
+		}
+	}
+	return false
+}
+
+// parseVersion returns the major, minor and patch version of v, ignoring a
+// pre-release or build suffix.
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	fields := strings.Split(v, ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNewerVersion(t *testing.T) {
+	for _, tc := range []struct {
+		latest, current string
+		want            bool
+	}{
+		{"v1.3.0", "v1.2.9", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v2.0.0", "1.9.9", true},
+		{"v1.2.3", "v1.2.3", false},
+		{"v1.2.3", "v1.3.0", false},
+		{"v1.2.3", "dev", false},
+		{"nightly", "v1.0.0", false},
+		{"v1.2.4", "v1.2.3-0.20261016120000-abcdef123456", true},
+	} {
+		if got := newerVersion(tc.latest, tc.current); got != tc.want {
+			t.Errorf("newerVersion(%q, %q) = %v, expected %v", tc.latest, tc.current, got, tc.want)
+		}
+	}
+}
+
+func TestUpdateChecker(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `{"tag_name": "v1.4.0", "name": "v1.4.0"}`)
+	}))
+	defer ts.Close()
+
+	checker, err := NewUpdateChecker(ts.URL, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checker.current = "v1.3.2"
+	if count := testutil.CollectAndCount(checker); count != 0 {
+		t.Errorf("expected no metric before the first check, got %d", count)
+	}
+	if err := checker.Check(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `
+# HELP deepl_exporter_update_available Whether a release newer than the running exporter is available, labeled by the latest version
+# TYPE deepl_exporter_update_available gauge
+deepl_exporter_update_available{latest_version="v1.4.0",version="v1.3.2"} 1
+`
+	if err := testutil.CollectAndCompare(checker, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Build metadata, set at build time with -ldflags "-X main.version=...
+// -X main.commit=... -X main.date=...", which GoReleaser does by default.
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+// BuildInfo describes the running binary.
+type BuildInfo struct {
+	Version  string
+	Revision string
+	Date     string
+}
+
+// buildInfo returns the metadata of the binary, falling back to what Go
+// embeds from the module and VCS when not set via ldflags.
+func buildInfo() BuildInfo {
+	info := BuildInfo{Version: version, Revision: commit, Date: date}
+	if embedded, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && embedded.Main.Version != "" && embedded.Main.Version != "(devel)" {
+			info.Version = embedded.Main.Version
+		}
+		for _, setting := range embedded.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Revision == "":
+				info.Revision = setting.Value
+			case setting.Key == "vcs.time" && info.Date == "":
+				info.Date = setting.Value
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if info.Revision == "" {
+		info.Revision = "unknown"
+	}
+	if info.Date == "" {
+		info.Date = "unknown"
+	}
+	return info
+}
+
+// versionString returns the output of --version.
+func versionString() string {
+	info := buildInfo()
+	return fmt.Sprintf("deepl-exporter version %s\n  commit:   %s\n  built:    %s\n  go:       %s\n  platform: %s/%s\n",
+		info.Version, info.Revision, info.Date, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+// buildUserAgent returns the User-Agent sent to DeepL, deepl-exporter/<version>
+// followed by suffix if it is set, e.g. to identify the site.
+func buildUserAgent(suffix string) string {
+	agent := "deepl-exporter/" + buildInfo().Version
+	if suffix != "" {
+		agent += " " + suffix
+	}
+	return agent
+}
+
+// newBuildInfoCollector returns the deepl_exporter_build_info metric, which
+// is always 1 and describes the running binary in its labels.
+func newBuildInfoCollector() prometheus.Collector {
+	info := buildInfo()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "deepl_exporter_build_info",
+		Help: "A metric with a constant '1' value labeled by the version, revision and Go version of the exporter",
+		ConstLabels: prometheus.Labels{
+			"version":   info.Version,
+			"revision":  info.Revision,
+			"goversion": runtime.Version(),
+		},
+	})
+	gauge.Set(1)
+	return gauge
+}
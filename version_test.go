@@ -0,0 +1,57 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNewBuildInfoCollector(t *testing.T) {
+	defer func(v, c string) { version, commit = v, c }(version, commit)
+	version, commit = "v1.2.3", "abc123"
+
+	expected := `
+# HELP deepl_exporter_build_info A metric with a constant '1' value labeled by the version, revision and Go version of the exporter
+# TYPE deepl_exporter_build_info gauge
+deepl_exporter_build_info{goversion="` + runtime.Version() + `",revision="abc123",version="v1.2.3"} 1
+`
+	if err := testutil.CollectAndCompare(newBuildInfoCollector(), strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBuildInfo_Defaults(t *testing.T) {
+	defer func(v, c, d string) { version, commit, date = v, c, d }(version, commit, date)
+	version, commit, date = "", "", ""
+
+	info := buildInfo()
+	if info.Version == "" || info.Revision == "" || info.Date == "" {
+		t.Errorf("expected fallback values, got %+v", info)
+	}
+}
+
+func TestBuildUserAgent(t *testing.T) {
+	defer func(v string) { version = v }(version)
+	version = "v1.2.3"
+
+	if got := buildUserAgent(""); got != "deepl-exporter/v1.2.3" {
+		t.Errorf("buildUserAgent(\"\") = %q", got)
+	}
+	if got := buildUserAgent("site/eu-1"); got != "deepl-exporter/v1.2.3 site/eu-1" {
+		t.Errorf("buildUserAgent(\"site/eu-1\") = %q", got)
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	defer func(v, c, d string) { version, commit, date = v, c, d }(version, commit, date)
+	version, commit, date = "v1.2.3", "abc123", "2026-01-02T03:04:05Z"
+
+	got := versionString()
+	for _, s := range []string{"version v1.2.3", "commit:   abc123", "built:    2026-01-02T03:04:05Z", runtime.Version()} {
+		if !strings.Contains(got, s) {
+			t.Errorf("expected %q in %q", s, got)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	watchBarWidth = 30
+	// clearScreen moves the cursor home and clears the terminal.
+	clearScreen = "\x1b[H\x1b[2J"
+)
+
+// watchError is a failed usage request shown by `deepl-exporter watch`.
+type watchError struct {
+	time    time.Time
+	account string
+	err     string
+}
+
+// runWatch implements `deepl-exporter watch`: a live terminal view of the
+// usage of every account, refreshed on the poll interval until interrupted.
+// It returns the exit code.
+func runWatch(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	fs.SetOutput(out)
+	config := fs.String("config", "", "Configuration file of the accounts to watch. DEEPL_API_KEY is watched if unset (env: CONFIG_FILE).")
+	interval := fs.Duration("interval", 0, "Refresh interval, the poll interval or 1m if unset.")
+	maxErrors := fs.Int("errors", 5, "Number of recent errors shown.")
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(out, "Usage: deepl-exporter watch [--config FILE] [--interval DURATION] [flags]")
+		_, _ = fmt.Fprintln(out, "Shows the usage of every account in the terminal, refreshed until interrupted.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := settings.resolve(fs, os.Getenv); err != nil {
+		_, _ = fmt.Fprintf(out, "Error: %v\n", err)
+		return 2
+	}
+	if *config == "" {
+		*config = settings.ConfigFile
+	}
+
+	var cfg *Config
+	if *config != "" {
+		var err error
+		if cfg, err = LoadConfig(*config); err != nil {
+			_, _ = fmt.Fprintf(out, "Error: %v\n", err)
+			return 1
+		}
+		if err = settings.applyConfig(fs, cfg.Flags); err != nil {
+			_, _ = fmt.Fprintf(out, "Error: invalid config file %s: %v\n", *config, err)
+			return 1
+		}
+	}
+	if *interval <= 0 {
+		*interval = settings.DeepLPollInterval
+	}
+	if *interval <= 0 {
+		*interval = time.Minute
+	}
+
+	defaults, err := flagClientConfig()
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "Error: %v\n", err)
+		return 1
+	}
+	if cfg, _, err = simulateAccounts(cfg, &defaults); err != nil {
+		_, _ = fmt.Fprintf(out, "Error: %v\n", err)
+		return 1
+	}
+	clients, err := newClients(cfg, defaults)
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "Error: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	var errors []watchError
+	for {
+		usages := watchUsage(ctx, clients)
+		now := time.Now()
+		for _, usage := range usages {
+			if usage.Error != "" {
+				errors = append(errors, watchError{time: now, account: usage.Account, err: usage.Error})
+			}
+		}
+		if len(errors) > *maxErrors {
+			errors = errors[len(errors)-*maxErrors:]
+		}
+
+		_, _ = io.WriteString(out, clearScreen+renderWatch(now, *interval, usages, errors))
+
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchUsage fetches the usage of all clients concurrently.
+func watchUsage(ctx context.Context, clients []*deepl.Client) []AccountUsage {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	usages := make([]AccountUsage, len(clients))
+	var g errgroup.Group
+	for i, client := range clients {
+		g.Go(func() error {
+			usages[i] = accountUsage(ctx, client)
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return usages
+}
+
+// renderWatch returns one frame of `deepl-exporter watch`.
+func renderWatch(now time.Time, interval time.Duration, usages []AccountUsage, errors []watchError) string {
+	width := len("ACCOUNT")
+	for _, usage := range usages {
+		width = max(width, len(usage.Account))
+	}
+
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "DeepL usage at %s, refreshing every %s (Ctrl+C to quit)\n\n", now.Format(time.DateTime), interval)
+	_, _ = fmt.Fprintf(&b, "%-*s  %-*s  %7s  %15s  %15s\n", width, "ACCOUNT", watchBarWidth, "USAGE", "PERCENT", "CHARACTERS", "LIMIT")
+	for _, usage := range usages {
+		if usage.Error != "" {
+			_, _ = fmt.Fprintf(&b, "%-*s  %-*s\n", width, usage.Account, watchBarWidth, "error, see below")
+			continue
+		}
+		_, _ = fmt.Fprintf(&b, "%-*s  %s  %6.1f%%  %15d  %15d\n",
+			width, usage.Account, usageBar(usage.UsagePercent), usage.UsagePercent, usage.CharacterCount, usage.CharacterLimit)
+	}
+
+	if len(errors) > 0 {
+		b.WriteString("\nRecent errors:\n")
+		for i := len(errors) - 1; i >= 0; i-- {
+			_, _ = fmt.Fprintf(&b, "%s  %s: %s\n", errors[i].time.Format(time.TimeOnly), errors[i].account, errors[i].err)
+		}
+	}
+	return b.String()
+}
+
+// usageBar returns a bar of watchBarWidth characters filled to percent.
+func usageBar(percent float64) string {
+	filled := int(percent / 100 * watchBarWidth)
+	filled = min(max(filled, 0), watchBarWidth)
+	return strings.Repeat("█", filled) + strings.Repeat("░", watchBarWidth-filled)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUsageBar(t *testing.T) {
+	tests := []struct {
+		percent float64
+		filled  int
+	}{
+		{0, 0},
+		{50, 15},
+		{100, 30},
+		{150, 30},
+	}
+	for _, tt := range tests {
+		bar := usageBar(tt.percent)
+		if got := strings.Count(bar, "█"); got != tt.filled {
+			t.Errorf("usageBar(%g): expected %d filled cells, got %d", tt.percent, tt.filled, got)
+		}
+		if got := strings.Count(bar, "█") + strings.Count(bar, "░"); got != watchBarWidth {
+			t.Errorf("usageBar(%g): expected %d cells, got %d", tt.percent, watchBarWidth, got)
+		}
+	}
+}
+
+func TestRenderWatch(t *testing.T) {
+	now := time.Date(2026, 3, 16, 12, 0, 0, 0, time.UTC)
+	usages := []AccountUsage{
+		{Account: "team-a", CharacterCount: 250000, CharacterLimit: 500000, UsagePercent: 50},
+		{Account: "team-b", Error: "API returned status 403"},
+	}
+	errors := []watchError{
+		{time: now.Add(-time.Minute), account: "team-b", err: "first"},
+		{time: now, account: "team-b", err: "second"},
+	}
+
+	frame := renderWatch(now, time.Minute, usages, errors)
+
+	for _, want := range []string{"2026-03-16 12:00:00", "team-a", "50.0%", "250000", "500000", "Recent errors:"} {
+		if !strings.Contains(frame, want) {
+			t.Errorf("expected frame to contain %q, got:\n%s", want, frame)
+		}
+	}
+	if strings.Index(frame, "second") > strings.Index(frame, "first") {
+		t.Errorf("expected the most recent error first, got:\n%s", frame)
+	}
+}
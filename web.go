@@ -0,0 +1,336 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/time/rate"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newServerTLSConfig builds the TLS configuration of the HTTPS listener.
+// minVersion is "1.2" or "1.3" and cipherSuites a comma-separated list of
+// IANA cipher suite names, which only apply to TLS 1.2 connections.
+func newServerTLSConfig(minVersion, cipherSuites string) (*tls.Config, error) {
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS version %q: must be 1.2 or 1.3", minVersion)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: version,
+	}
+
+	if cipherSuites == "" {
+		return tlsConfig, nil
+	}
+
+	if version == tls.VersionTLS13 {
+		return nil, fmt.Errorf("cipher suites cannot be configured for TLS 1.3")
+	}
+
+	available := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		available[suite.Name] = suite.ID
+	}
+
+	for _, name := range strings.Split(cipherSuites, ",") {
+		name = strings.TrimSpace(name)
+		id, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+	}
+
+	return tlsConfig, nil
+}
+
+// serverProtocols returns the protocols the server speaks: HTTP/1.1
+// always, HTTP/2 over TLS if http2 is set, and HTTP/2 with prior knowledge
+// on plaintext connections (h2c) if h2c is set, e.g. for service meshes
+// multiplexing scrapes over long-lived connections.
+func serverProtocols(http2, h2c bool) *http.Protocols {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(http2)
+	protocols.SetUnencryptedHTTP2(h2c)
+	return protocols
+}
+
+// metricsHandler serves all metrics of registry and collector, or only the
+// DeepL metrics of the accounts given with ?account=<name>, which may be
+// repeated. Only the selected accounts are fetched from DeepL, continuing
+// the trace of the traceparent header of the scrape. opts configures the
+// exposition, e.g. the offered compressions.
+func metricsHandler(registry *prometheus.Registry, collector *deepl.DeepLCollector, opts promhttp.HandlerOpts) http.Handler {
+	return promhttp.InstrumentMetricHandler(registry, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accounts := r.URL.Query()["account"]
+		ctx := deepl.ContextWithTraceparent(r.Context(), r.Header.Get("traceparent"))
+		scraped, err := collector.ForRequest(ctx, accounts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		scrapeRegistry := prometheus.NewRegistry()
+		scrapeRegistry.MustRegister(scraped)
+
+		var gatherer prometheus.Gatherer = scrapeRegistry
+		if len(accounts) == 0 {
+			gatherer = prometheus.Gatherers{registry, scrapeRegistry}
+		}
+		promhttp.HandlerFor(gatherer, opts).ServeHTTP(w, r)
+	}))
+}
+
+// crossOrigin reports whether r was sent by a page of another site, which
+// browsers attach credentials such as basic auth to as well.
+func crossOrigin(r *http.Request) bool {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		return err != nil || !strings.EqualFold(u.Host, r.Host)
+	}
+	return r.Header.Get("Sec-Fetch-Site") == "cross-site"
+}
+
+// acceptJSON answers requests whose JSON body may have been forged by a
+// browser and reports whether r may be served: those of another site, and
+// those not declaring the body as JSON, since a form posted by any site can
+// carry JSON as text/plain.
+func acceptJSON(w http.ResponseWriter, r *http.Request) bool {
+	if crossOrigin(r) {
+		http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+		return false
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
+}
+
+// registerPprof adds the Go profiling endpoints to mux under /debug/pprof.
+// On the main port, CPU profiles and traces are limited by its write
+// timeout, while the separate pprof listener has none.
+func registerPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
+
+// httpMetrics instruments the HTTP handlers of the exporter itself, to show
+// the scrape pressure it is under.
+type httpMetrics struct {
+	inFlight     *prometheus.GaugeVec
+	duration     *prometheus.HistogramVec
+	responseSize *prometheus.HistogramVec
+}
+
+func newHTTPMetrics(reg prometheus.Registerer) *httpMetrics {
+	m := &httpMetrics{
+		inFlight: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "deepl_exporter_http_requests_in_flight",
+				Help: "Number of HTTP requests currently served by the exporter",
+			},
+			[]string{"handler"},
+		),
+		duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "deepl_exporter_http_request_duration_seconds",
+				Help:    "Duration of the HTTP requests served by the exporter",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"handler", "code", "method"},
+		),
+		responseSize: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "deepl_exporter_http_response_size_bytes",
+				Help:    "Size of the HTTP responses sent by the exporter",
+				Buckets: prometheus.ExponentialBuckets(100, 4, 8),
+			},
+			[]string{"handler", "code", "method"},
+		),
+	}
+	reg.MustRegister(m.inFlight, m.duration, m.responseSize)
+	return m
+}
+
+// instrument wraps h to record its requests under the given handler label.
+func (m *httpMetrics) instrument(handler string, h http.Handler) http.Handler {
+	labels := prometheus.Labels{"handler": handler}
+	return promhttp.InstrumentHandlerInFlight(
+		m.inFlight.With(labels),
+		promhttp.InstrumentHandlerDuration(
+			m.duration.MustCurryWith(labels),
+			promhttp.InstrumentHandlerResponseSize(m.responseSize.MustCurryWith(labels), h),
+		),
+	)
+}
+
+// inFlightLimiter caps the number of concurrent requests across the handlers
+// it wraps, so a scrape storm cannot pile up DeepL fetches and goroutines.
+type inFlightLimiter struct {
+	slots chan struct{}
+}
+
+// newInFlightLimiter returns a limiter allowing max concurrent requests, or
+// an unlimited one if max is not positive.
+func newInFlightLimiter(max int) *inFlightLimiter {
+	if max <= 0 {
+		return &inFlightLimiter{}
+	}
+	return &inFlightLimiter{slots: make(chan struct{}, max)}
+}
+
+// limit wraps h to reject requests exceeding the limit with 503.
+func (l *inFlightLimiter) limit(h http.Handler) http.Handler {
+	if l.slots == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case l.slots <- struct{}{}:
+			defer func() { <-l.slots }()
+			h.ServeHTTP(w, r)
+		default:
+			http.Error(w, fmt.Sprintf("Limit of concurrent requests reached (%d), try again later.", cap(l.slots)), http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// clientIdleTimeout is how long the rate limiter of a client IP is kept after
+// its last request.
+const clientIdleTimeout = 10 * time.Minute
+
+// clientRateLimiter limits the requests per minute of every client IP, so a
+// single misconfigured dashboard cannot monopolize the exporter.
+type clientRateLimiter struct {
+	perMinute int
+	now       func() time.Time
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newClientRateLimiter returns a limiter allowing perMinute requests per
+// minute and client IP, or nil if perMinute is not positive.
+func newClientRateLimiter(perMinute int) *clientRateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &clientRateLimiter{
+		perMinute: perMinute,
+		now:       time.Now,
+		clients:   make(map[string]*clientLimiter),
+	}
+}
+
+// limit wraps h to reject requests of clients exceeding their limit with
+// 429. A nil limiter leaves h unchanged.
+func (l *clientRateLimiter) limit(h http.Handler) http.Handler {
+	if l == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if delay := l.wait(clientIP(r)); delay > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			http.Error(w, "rate limit exceeded, try again later", http.StatusTooManyRequests)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// wait takes a token for a request of ip and returns zero, or how long the
+// client has to wait for the next one if it exceeded its limit.
+func (l *clientRateLimiter) wait(ip string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) > clientIdleTimeout {
+		for key, client := range l.clients {
+			if now.Sub(client.lastSeen) > clientIdleTimeout {
+				delete(l.clients, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	client, ok := l.clients[ip]
+	if !ok {
+		client = &clientLimiter{limiter: deepl.NewRateLimiter(l.perMinute)}
+		l.clients[ip] = client
+	}
+	client.lastSeen = now
+
+	reservation := client.limiter.ReserveN(now, 1)
+	delay := reservation.DelayFrom(now)
+	if delay > 0 {
+		reservation.CancelAt(now)
+	}
+	return delay
+}
+
+// clientIP returns the IP address of the client that sent r.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// readTokenFile returns the bearer token stored in path.
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// requireToken wraps h to reject requests without the bearer token with 401.
+func requireToken(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="deepl-exporter"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
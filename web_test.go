@@ -0,0 +1,332 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNewServerTLSConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		minVersion   string
+		cipherSuites string
+		wantVersion  uint16
+		wantSuites   []uint16
+		wantErr      bool
+	}{
+		{name: "Defaults", minVersion: "1.2", wantVersion: tls.VersionTLS12},
+		{name: "TLS 1.3", minVersion: "1.3", wantVersion: tls.VersionTLS13},
+		{
+			name:         "Cipher suites",
+			minVersion:   "1.2",
+			cipherSuites: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+			wantVersion:  tls.VersionTLS12,
+			wantSuites:   []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+		},
+		{name: "Unsupported version", minVersion: "1.0", wantErr: true},
+		{name: "Unknown cipher suite", minVersion: "1.2", cipherSuites: "TLS_FOO", wantErr: true},
+		{name: "Insecure cipher suite", minVersion: "1.2", cipherSuites: "TLS_RSA_WITH_RC4_128_SHA", wantErr: true},
+		{name: "Cipher suites with TLS 1.3", minVersion: "1.3", cipherSuites: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := newServerTLSConfig(tt.minVersion, tt.cipherSuites)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.MinVersion != tt.wantVersion {
+				t.Errorf("expected min version %x, got %x", tt.wantVersion, cfg.MinVersion)
+			}
+			if len(cfg.CipherSuites) != len(tt.wantSuites) {
+				t.Fatalf("expected cipher suites %v, got %v", tt.wantSuites, cfg.CipherSuites)
+			}
+			for i := range tt.wantSuites {
+				if cfg.CipherSuites[i] != tt.wantSuites[i] {
+					t.Errorf("expected cipher suites %v, got %v", tt.wantSuites, cfg.CipherSuites)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricsHandler_AccountFilter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "DeepL-Auth-Key key-b" {
+			t.Error("account b must not be fetched")
+		}
+		_, _ = fmt.Fprintln(w, `{"character_count": 1000, "character_limit": 500000}`)
+	}))
+	defer ts.Close()
+
+	var clients []*deepl.Client
+	for _, name := range []string{"a", "b"} {
+		client, err := deepl.NewClient(deepl.ClientConfig{Name: name, APIKey: "key-" + name, ServerURL: ts.URL})
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		clients = append(clients, client)
+	}
+	c, err := deepl.NewDeepLCollector(clients, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := metricsHandler(prometheus.NewRegistry(), c, promhttp.HandlerOpts{})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics?account=a", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `deepl_character_count{account="a"} 1000`) {
+		t.Errorf("expected metrics of account a:\n%s", body)
+	}
+	if strings.Contains(body, `account="b"`) {
+		t.Errorf("unexpected metrics of account b:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics?account=a&account=a", nil))
+	if rec.Code != http.StatusOK || strings.Count(rec.Body.String(), `deepl_character_count{account="a"}`) != 1 {
+		t.Errorf("expected a repeated account to be exported once, got %d:\n%s", rec.Code, rec.Body)
+	}
+
+	for _, query := range []string{"account=unknown", "account="} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestServerProtocols_H2C(t *testing.T) {
+	for _, h2c := range []bool{true, false} {
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprint(w, r.Proto)
+		}))
+		srv.Config.Protocols = serverProtocols(true, h2c)
+		srv.Start()
+
+		// The client only speaks HTTP/2 with prior knowledge, like a mesh
+		// sidecar would.
+		clientProtocols := new(http.Protocols)
+		clientProtocols.SetUnencryptedHTTP2(true)
+		client := &http.Client{Transport: &http.Transport{Protocols: clientProtocols}}
+
+		resp, err := client.Get(srv.URL)
+		if h2c {
+			if err != nil {
+				t.Fatalf("h2c request failed: %v", err)
+			}
+			if resp.ProtoMajor != 2 {
+				t.Errorf("expected HTTP/2, got %s", resp.Proto)
+			}
+			_ = resp.Body.Close()
+		} else if err == nil {
+			_ = resp.Body.Close()
+			t.Errorf("expected h2c to be rejected, got %s", resp.Proto)
+		}
+		srv.Close()
+	}
+}
+
+func TestServerProtocols_HTTP2OverTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeTestCertificate(t, certFile, keyFile, "localhost")
+
+	for _, http2 := range []bool{true, false} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := &http.Server{
+			Handler:   http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+			Protocols: serverProtocols(http2, false),
+		}
+		go func() { _ = srv.ServeTLS(ln, certFile, keyFile) }()
+
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+		}}
+		resp, err := client.Get("https://" + ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if want := map[bool]int{true: 2, false: 1}[http2]; resp.ProtoMajor != want {
+			t.Errorf("http2=%t: expected HTTP/%d, got %s", http2, want, resp.Proto)
+		}
+		_ = srv.Close()
+	}
+}
+
+func TestRegisterPprof(t *testing.T) {
+	mux := http.NewServeMux()
+	registerPprof(mux)
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/goroutine?debug=1"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestHTTPMetrics_Instrument(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	m := newHTTPMetrics(reg)
+	handler := m.instrument("/test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+
+	for range 2 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+	}
+
+	expected := `
+# HELP deepl_exporter_http_requests_in_flight Number of HTTP requests currently served by the exporter
+# TYPE deepl_exporter_http_requests_in_flight gauge
+deepl_exporter_http_requests_in_flight{handler="/test"} 0
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "deepl_exporter_http_requests_in_flight"); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(m.duration); n != 1 {
+		t.Errorf("expected 1 duration series, got %d", n)
+	}
+}
+
+func TestInFlightLimiter(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	limiter := newInFlightLimiter(1)
+	handler := limiter.limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	done := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		done <- rec.Code
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 above the limit, got %d", rec.Code)
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("expected status 200 within the limit, got %d", code)
+	}
+
+	if newInFlightLimiter(0).slots != nil {
+		t.Error("expected no limit for 0")
+	}
+}
+
+func TestClientRateLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := newClientRateLimiter(2)
+	limiter.now = func() time.Time { return now }
+	handler := limiter.limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/usage", nil)
+		r.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	for i := range 2 {
+		if rec := request("192.0.2.1:1234"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status 200, got %d", i+1, rec.Code)
+		}
+	}
+
+	rec := request("192.0.2.1:5678")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("expected Retry-After 30, got %q", got)
+	}
+
+	if rec := request("192.0.2.2:1234"); rec.Code != http.StatusOK {
+		t.Errorf("expected other clients to be unaffected, got %d", rec.Code)
+	}
+
+	now = now.Add(30 * time.Second)
+	if rec := request("192.0.2.1:1234"); rec.Code != http.StatusOK {
+		t.Errorf("expected status 200 after waiting, got %d", rec.Code)
+	}
+
+	now = now.Add(clientIdleTimeout + time.Second)
+	request("192.0.2.3:1234")
+	if len(limiter.clients) != 1 {
+		t.Errorf("expected idle clients to be evicted, got %d", len(limiter.clients))
+	}
+
+	if newClientRateLimiter(0) != nil {
+		t.Error("expected no limiter for 0")
+	}
+}
+
+func TestMetricsHandler_OpenMetrics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	c, err := deepl.NewDeepLCollector([]*deepl.Client{newTestClient(t, ts.URL)}, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := metricsHandler(prometheus.NewRegistry(), c, promhttpOpts(nil, true))
+
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Fatalf("expected OpenMetrics content type, got %q", ct)
+	}
+	body := rec.Body.String()
+	for _, s := range []string{
+		`deepl_api_errors_created{account="default",collector="usage"}`,
+		`deepl_api_errors_total{account="default",collector="usage"} 1.0 # {reason="status_500"} 1.0`,
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("expected %q in response:\n%s", s, body)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+)
+
+const (
+	// wsMaxMessage bounds the messages accepted from clients, which have
+	// nothing to send but control frames.
+	wsMaxMessage = 4096
+	// wsWriteTimeout bounds every write to a client.
+	wsWriteTimeout = 10 * time.Second
+	// wsCloseTimeout is how long the server waits for the client to answer
+	// its close frame on shutdown.
+	wsCloseTimeout = time.Second
+)
+
+var wsUpgrader = websocket.Upgrader{
+	// Browsers send credentials such as basic auth along with WebSockets
+	// opened by any site, so only pages served by the exporter's own host
+	// may connect.
+	CheckOrigin: func(r *http.Request) bool { return !crossOrigin(r) },
+}
+
+// websocketHandler serves the same updates as streamHandler over a
+// WebSocket: a text message with the JSON of an AccountUsage each, starting
+// with the cached usage of every account. The server pings every keepAlive
+// and closes the connection if the client does not answer within two. Once
+// shutdown is done, it closes the connection with 1001 Going Away, as
+// http.Server.Shutdown does not track hijacked connections.
+func websocketHandler(shutdown context.Context, stream *UsageStream, collector *deepl.DeepLCollector, keepAlive time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !websocket.IsWebSocketUpgrade(r) {
+			w.Header().Set("Upgrade", "websocket")
+			http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		if crossOrigin(r) {
+			http.Error(w, "cross-origin websocket requests are not allowed", http.StatusForbidden)
+			return
+		}
+		updates, unsubscribe := stream.Subscribe()
+		defer unsubscribe()
+
+		// Upgrade responds with the error itself.
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		// The reader answers the pings and close frame of the client and
+		// discards its messages. The pongs to the pings of the server keep
+		// the connection alive.
+		conn.SetReadLimit(wsMaxMessage)
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * keepAlive))
+		})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				_ = conn.SetReadDeadline(time.Now().Add(2 * keepAlive))
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+
+		send := func(usage AccountUsage) error {
+			data, err := json.Marshal(usage)
+			if err != nil {
+				return err
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			return conn.WriteMessage(websocket.TextMessage, data)
+		}
+		for _, usage := range cachedUsages(collector) {
+			if err := send(usage); err != nil {
+				return
+			}
+		}
+
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-shutdown.Done():
+				closing := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+				if err := conn.WriteControl(websocket.CloseMessage, closing, time.Now().Add(wsWriteTimeout)); err != nil {
+					return
+				}
+				select {
+				case <-done:
+				case <-time.After(wsCloseTimeout):
+				}
+				return
+			case usage := <-updates:
+				if err := send(usage); err != nil {
+					return
+				}
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jadolg/deepl-exporter/pkg/deepl"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestWebsocketHandler(t *testing.T) {
+	var count atomic.Int64
+	deeplServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, deepl.DeepLUsage{CharacterCount: count.Add(1000), CharacterLimit: 10000})
+	}))
+	defer deeplServer.Close()
+
+	client, err := deepl.NewClient(deepl.ClientConfig{Name: "team-a", APIKey: "key", ServerURL: deeplServer.URL})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	collector, err := deepl.NewDeepLCollector([]*deepl.Client{client}, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	collector.EnablePolling()
+	collector.Refresh(t.Context(), client)
+
+	stream := NewUsageStream()
+	shutdown, stopStreams := context.WithCancel(t.Context())
+	// Instrumented like in main, whose wrappers must let the connection be
+	// hijacked.
+	server := httptest.NewUnstartedServer(newHTTPMetrics(prometheus.NewRegistry()).instrument("/api/v1/ws", websocketHandler(shutdown, stream, collector, 20*time.Millisecond)))
+	server.Config.RegisterOnShutdown(stopStreams)
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("expected 426 without an upgrade, got %d", resp.StatusCode)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/v1/ws", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(data string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	var pong atomic.Bool
+	conn.SetPongHandler(func(data string) error {
+		pong.Store(data == "hi")
+		return nil
+	})
+
+	// The cached usage is sent right away.
+	var usage AccountUsage
+	if err := conn.ReadJSON(&usage); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usage.Account != "team-a" || usage.CharacterCount != 1000 {
+		t.Errorf("unexpected first message: %+v", usage)
+	}
+
+	// The server pings, which also shows it subscribed. Pings are handled
+	// while reading, the next message is the refreshed usage.
+	if err := conn.WriteControl(websocket.PingMessage, []byte("hi"), time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	go func() {
+		<-pinged
+		stream.PublishRefresh(collector, client, collector.Refresh(context.Background(), client))
+	}()
+	if err := conn.ReadJSON(&usage); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usage.CharacterCount != 2000 {
+		t.Errorf("expected the refreshed usage, got %+v", usage)
+	}
+	if !pong.Load() {
+		t.Error("expected the ping of the client to be answered")
+	}
+
+	// Shutting down the server closes the connection with a close frame.
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	if err := server.Config.Shutdown(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for {
+		_, _, err := conn.ReadMessage()
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) {
+			if closeErr.Code != websocket.CloseGoingAway {
+				t.Errorf("expected 1001 Going Away, got %v", closeErr)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("expected a close frame, got %v", err)
+		}
+	}
+	if err := stream.Wait(ctx); err != nil {
+		t.Errorf("expected the handler to return, got %v", err)
+	}
+}
+
+func TestWebsocketHandlerRejectsOtherOrigins(t *testing.T) {
+	collector, err := deepl.NewDeepLCollector([]*deepl.Client{newTestClient(t, "")}, []string{"usage"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "http://exporter:1818/api/v1/ws", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	r.Header.Set("Origin", "https://evil.example.com")
+	rec := httptest.NewRecorder()
+	websocketHandler(t.Context(), NewUsageStream(), collector, time.Minute).ServeHTTP(rec, r)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "cross-origin") {
+		t.Errorf("expected a cross-origin request to be rejected, got %d: %s", rec.Code, rec.Body.String())
+	}
+}